@@ -2,206 +2,384 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
+	"errors"
+	"flag"
 	"html/template"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	commentRepository "redditclone/pkg/comment/repository/mongo"
-	postRepository "redditclone/pkg/post/repository/mongo"
-	sessionRepository "redditclone/pkg/session/repository/redis"
-	userRepository "redditclone/pkg/user/repository/mysql"
+	ratelimit "redditclone/pkg/ratelimit/repository"
+	throttle "redditclone/pkg/throttle/repository"
 
 	commentDelivery "redditclone/pkg/comment/delivery"
+	"redditclone/pkg/config"
+	healthDelivery "redditclone/pkg/health/delivery"
+	"redditclone/pkg/mail"
+	"redditclone/pkg/metrics"
 	"redditclone/pkg/middleware"
 	postDelivery "redditclone/pkg/post/delivery"
+	"redditclone/pkg/tracing"
 	userDelivery "redditclone/pkg/user/delivery"
+	"redditclone/pkg/user/policy"
 	"redditclone/tools"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/gomodule/redigo/redis"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"gopkg.in/yaml.v2"
 )
 
-const configPath = "config.yaml"
-
-type Config struct {
-	StaticRoot string `yaml:"STATIC_ROOT"`
-	Port       int    `yaml:"PORT"`
-}
+var (
+	// Пять ошибок входа подряд блокируют IP и аккаунт на секунду, дальше блокировка удваивается.
+	loginPolicy = throttle.Policy{
+		FreeAttempts: 5,
+		BaseDelay:    time.Second,
+		MaxDelay:     15 * time.Minute,
+		Window:       time.Hour,
+	}
 
-var AppConfig *Config
+	signupPolicy = throttle.Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     24 * time.Hour,
+		Window:       24 * time.Hour,
+	}
+)
 
 func main() {
 	tools.Init()
 
-	err := godotenv.Load()
-	if err != nil {
-		tools.Logger.Fatal("error loading .env file:", err)
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		// errors.Join разделяет ошибки переводом строки, а в логе нужна одна строка.
+		tools.Logger.Fatal("error loading config: ", strings.ReplaceAll(err.Error(), "\n", "; "))
 	}
-
-	configFile, err := os.Open(configPath)
-	if err != nil {
-		tools.Logger.Fatal("error opening config file:", err)
+	if err = tools.ConfigureLogger(cfg.LogLevel, cfg.LogFormat); err != nil {
+		tools.Logger.Fatal("error configuring logger: ", err)
 	}
-
-	decoder := yaml.NewDecoder(configFile)
-	err = decoder.Decode(&AppConfig)
-	if err != nil {
-		tools.Logger.Fatal("error reading config file:", err)
+	for _, line := range strings.Split(cfg.String(), "\n") {
+		tools.Logger.Info("config: ", line)
 	}
 
-	mysqlDSN := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?",
-		os.Getenv("MYSQL_USER"),
-		os.Getenv("MYSQL_PASSWORD"),
-		os.Getenv("MYSQL_HOST"),
-		os.Getenv("MYSQL_PORT"),
-		os.Getenv("MYSQL_DATABASE"),
-	)
-	mysqlDSN += "&charset=utf8"
-	mysqlDSN += "&interpolateParams=true"
+	// SIGTERM от docker stop и Ctrl+C отменяют ctx: старт прерывается, сервер начинает завершение.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	mysqlConnect, err := sql.Open("mysql", mysqlDSN)
-	if err != nil {
-		panic(err)
+	if len(args) > 0 && args[0] == "migrate" {
+		if err = runMigrate(ctx, cfg, args[1:]); err != nil {
+			tools.Logger.Fatal("error running migrations:", err)
+		}
+		return
 	}
 
-	mysqlConnect.SetConnMaxLifetime(time.Minute * 3)
-	mysqlConnect.SetMaxOpenConns(10)
-	mysqlConnect.SetMaxIdleConns(10)
-
-	ctx := context.Background()
-	mongoURI := fmt.Sprintf("mongodb://%s:%s@%s:%s/?maxPoolSize=10",
-		os.Getenv("MONGODB_USER"),
-		os.Getenv("MONGODB_PASSWORD"),
-		os.Getenv("MONGODB_HOST"),
-		os.Getenv("MONGODB_PORT"),
-	)
-	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-	opts := options.Client().ApplyURI(mongoURI).SetServerAPIOptions(serverAPI)
-
-	mongoConnect, err := mongo.Connect(ctx, opts)
-	if err != nil {
-		panic(err)
-	}
-	mongoDB := mongoConnect.Database(os.Getenv("MONGODB_DATABASE"))
-	postsCollection := mongoDB.Collection("posts")
-	commentsCollection := mongoDB.Collection("comments")
-
-	redisURL := fmt.Sprintf("redis://user:@%s:%s/%s",
-		os.Getenv("REDIS_HOST"),
-		os.Getenv("REDIS_PORT"),
-		os.Getenv("REDIS_DATABASE"),
-	)
-	redisConn, err := redis.DialURL(redisURL)
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Exporter, cfg.Tracing.OTLPEndpoint)
 	if err != nil {
-		panic(err)
+		tools.Logger.Fatal("error setting up tracing:", err)
 	}
 
-	defer func() {
-		configFile.Close()
+	var repos *repositories
+	switch cfg.Storage {
+	case config.StorageMemory:
+		tools.Logger.Warn("STORAGE is memory: data will be lost on restart")
+		repos = newMemoryRepositories()
+	case config.StorageMongo:
+		repos, err = newMongoRepositories(ctx, cfg)
+	default:
+		repos, err = newDatabaseRepositories(ctx, cfg)
+	}
+	if err != nil {
+		tools.Logger.Fatal("error connecting to storage:", err)
+	}
+	repos.instrument()
 
-		if err := mongoConnect.Disconnect(ctx); err != nil {
-			panic(err)
+	rateLimit := func(name string, next http.Handler) http.Handler {
+		limit, ok := cfg.RateLimits[name]
+		if !ok || limit.Rate <= 0 || limit.Period <= 0 || limit.Burst <= 0 {
+			tools.Logger.Fatalf("rate limit %q is not configured", name)
 		}
 
-		if err = mysqlConnect.Close(); err != nil {
-			panic(err)
-		}
+		return middleware.RateLimit(repos.RateLimiter, name, ratelimit.Limit{
+			Rate:   limit.Rate,
+			Period: limit.Period,
+			Burst:  limit.Burst,
+		}, next)
+	}
 
-		if err = redisConn.Close(); err != nil {
-			panic(err)
-		}
-	}()
+	// Тело запроса больше лимита обрывается с ответом 413, не дочитываясь до конца.
+	postBodyLimit := int64(cfg.BodyLimits.Post)
+	commentBodyLimit := int64(cfg.BodyLimits.Comment)
+	defaultBodyLimit := int64(cfg.BodyLimits.Default)
 
 	router := mux.NewRouter()
+	router.Use(middleware.Tracing, middleware.AccessLog, middleware.Metrics)
 
-	userRepo := userRepository.NewUserMySqlRepo(mysqlConnect)
-	sessionRepo := sessionRepository.NewSessionRedisManager(redisConn)
-	postRepo := postRepository.NewPostMongoDBMemoryRepo(postsCollection)
-	commentRepo := commentRepository.NewCommentMongoDBRepository(commentsCollection)
+	userRepo := repos.Users
+	sessionRepo := repos.Sessions
+	loginThrottler := repos.LoginThrottler
+	signupThrottler := repos.SignupThrottler
+	postRepo := repos.Posts
+	commentRepo := repos.Comments
+	tokenKey := []byte(cfg.TokenKey)
+
+	credentialsPolicy, err := policy.LoadPolicy(cfg.PasswordBlocklist)
+	if err != nil {
+		tools.Logger.Fatal("error loading password blocklist:", err)
+	}
+
+	var mailer mail.Sender
+	switch cfg.MailSender {
+	case "smtp":
+		mailer = mail.NewSMTPSender(
+			cfg.SMTP.Host,
+			cfg.SMTP.Port,
+			cfg.SMTP.User,
+			cfg.SMTP.Password,
+			cfg.MailFrom,
+		)
+	case "file":
+		mailer, err = mail.NewFileSender(cfg.MailDir)
+		if err != nil {
+			tools.Logger.Fatal("error creating mail directory:", err)
+		}
+	default:
+		mailer = mail.NewLogSender()
+	}
 
 	postHandler := postDelivery.PostHandler{
 		CommentRepo: commentRepo,
 		PostRepo:    postRepo,
 		UserRepo:    userRepo,
+
+		RequireVerifiedEmail: cfg.RequireVerifiedEmail,
 	}
 
 	commentHandler := commentDelivery.CommentHandler{
 		CommentRepo: commentRepo,
 		PostRepo:    postRepo,
 		UserRepo:    userRepo,
+
+		RequireVerifiedEmail: cfg.RequireVerifiedEmail,
 	}
 
 	authHandler := userDelivery.UserHandler{
+		UserRepo:          userRepo,
+		SessionRepo:       sessionRepo,
+		LoginThrottler:    loginThrottler,
+		SignupThrottler:   signupThrottler,
+		CredentialsPolicy: credentialsPolicy,
+		Mailer:            mailer,
+		PublicURL:         cfg.PublicURL,
+		TokenKey:          tokenKey,
+	}
+
+	adminHandler := userDelivery.AdminHandler{
+		UserRepo:       userRepo,
+		LoginThrottler: loginThrottler,
+	}
+
+	accountHandler := userDelivery.AccountHandler{
+		UserRepo:          userRepo,
+		SessionRepo:       sessionRepo,
+		PostRepo:          postRepo,
+		CommentRepo:       commentRepo,
+		CredentialsPolicy: credentialsPolicy,
+		Mailer:            mailer,
+		PublicURL:         cfg.PublicURL,
+		TokenKey:          tokenKey,
+	}
+
+	profileHandler := userDelivery.ProfileHandler{
 		UserRepo:    userRepo,
-		SessionRepo: sessionRepo,
+		PostRepo:    postRepo,
+		CommentRepo: commentRepo,
 	}
 
-	fileServer := http.FileServer(http.Dir(AppConfig.StaticRoot))
+	healthHandler := healthDelivery.HealthHandler{
+		Checks:  repos.Checks,
+		Timeout: cfg.ReadinessTimeout,
+	}
+
+	// Зонды оркестратора не проходят через rate limit: иначе под нагрузкой реплику сочтут мертвой.
+	router.HandleFunc("/healthz", healthHandler.Live).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Ready).Methods("GET")
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	fileServer := http.FileServer(http.Dir(cfg.StaticRoot))
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fileServer))
 
 	router.Handle("/api/post/{postID}/upvote",
 		middleware.ValidateJWTToken(
 			sessionRepo,
-			http.HandlerFunc(postHandler.Upvote))).Methods("GET")
+			tokenKey,
+			rateLimit("vote", http.HandlerFunc(postHandler.Upvote)))).Methods("GET")
 
 	router.Handle("/api/post/{postID}/downvote",
 		middleware.ValidateJWTToken(
 			sessionRepo,
-			http.HandlerFunc(postHandler.Downvote))).Methods("GET")
+			tokenKey,
+			rateLimit("vote", http.HandlerFunc(postHandler.Downvote)))).Methods("GET")
 
 	router.Handle("/api/post/{postID}/unvote",
 		middleware.ValidateJWTToken(
 			sessionRepo,
-			http.HandlerFunc(postHandler.Unvote))).Methods("GET")
+			tokenKey,
+			rateLimit("vote", http.HandlerFunc(postHandler.Unvote)))).Methods("GET")
 
-	router.HandleFunc("/api/posts/", postHandler.Index).Methods("GET")
+	router.Handle("/api/post/{postID}/{commentID}/upvote",
+		middleware.ValidateJWTToken(
+			sessionRepo,
+			tokenKey,
+			rateLimit("vote", http.HandlerFunc(commentHandler.Upvote)))).Methods("GET")
 
-	router.HandleFunc("/api/posts/{category}", postHandler.IndexByCategory).Methods("GET")
+	router.Handle("/api/post/{postID}/{commentID}/downvote",
+		middleware.ValidateJWTToken(
+			sessionRepo,
+			tokenKey,
+			rateLimit("vote", http.HandlerFunc(commentHandler.Downvote)))).Methods("GET")
+
+	router.Handle("/api/post/{postID}/{commentID}/unvote",
+		middleware.ValidateJWTToken(
+			sessionRepo,
+			tokenKey,
+			rateLimit("vote", http.HandlerFunc(commentHandler.Unvote)))).Methods("GET")
+
+	router.Handle("/api/posts/", middleware.Compress(rateLimit("read", http.HandlerFunc(postHandler.Index)))).Methods("GET")
 
-	router.HandleFunc("/api/post/{id}", postHandler.GetPost).Methods("GET")
+	router.Handle("/api/posts/{category}", middleware.Compress(rateLimit("read", http.HandlerFunc(postHandler.IndexByCategory)))).Methods("GET")
+
+	router.Handle("/api/post/{id}", middleware.Compress(rateLimit("read", http.HandlerFunc(postHandler.GetPost)))).Methods("GET")
 
 	router.Handle("/api/posts",
-		middleware.ValidateContentType(
-			middleware.ValidateJWTToken(
-				sessionRepo,
-				http.HandlerFunc(postHandler.Create)))).Methods("POST")
+		middleware.LimitBody(postBodyLimit,
+			middleware.ValidateContentType(
+				middleware.ValidateJWTToken(
+					sessionRepo,
+					tokenKey,
+					rateLimit("post_create", http.HandlerFunc(postHandler.Create)))))).Methods("POST")
 
 	router.Handle("/api/post/{postID}",
-		middleware.ValidateContentType(
-			middleware.ValidateJWTToken(
-				sessionRepo,
-				http.HandlerFunc(commentHandler.Create)))).Methods("POST")
+		middleware.LimitBody(commentBodyLimit,
+			middleware.ValidateContentType(
+				middleware.ValidateJWTToken(
+					sessionRepo,
+					tokenKey,
+					rateLimit("comment_create", http.HandlerFunc(commentHandler.Create)))))).Methods("POST")
 
 	router.Handle("/api/post/{postID}",
-		middleware.ValidateContentType(
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				middleware.ValidateJWTToken(
+					sessionRepo,
+					tokenKey,
+					rateLimit("write", http.HandlerFunc(postHandler.Delete)))))).Methods("DELETE")
+
+	router.Handle("/api/post/{postID}/{commentID}",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				middleware.ValidateJWTToken(
+					sessionRepo,
+					tokenKey,
+					rateLimit("write", http.HandlerFunc(commentHandler.Delete)))))).Methods("DELETE")
+
+	router.Handle("/api/user/{username}", middleware.Compress(rateLimit("read", http.HandlerFunc(postHandler.IndexByUser)))).Methods("GET")
+
+	router.Handle("/api/user/{username}/about", middleware.Compress(rateLimit("read", http.HandlerFunc(profileHandler.About)))).Methods("GET")
+
+	router.Handle("/api/user/{username}/posts", middleware.Compress(rateLimit("read", http.HandlerFunc(profileHandler.Posts)))).Methods("GET")
+
+	router.Handle("/api/user/{username}/comments", middleware.Compress(rateLimit("read", http.HandlerFunc(profileHandler.Comments)))).Methods("GET")
+
+	router.Handle("/api/user/{username}/overview", middleware.Compress(rateLimit("read", http.HandlerFunc(profileHandler.Overview)))).Methods("GET")
+
+	router.Handle("/api/login",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				rateLimit("auth", http.HandlerFunc(authHandler.Login))))).Methods("POST")
+
+	router.Handle("/api/login/2fa",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				rateLimit("auth", http.HandlerFunc(authHandler.LoginTwoFactor))))).Methods("POST")
+
+	router.Handle("/api/register",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				rateLimit("auth", http.HandlerFunc(authHandler.Signup))))).Methods("POST")
+
+	router.Handle("/api/account/password",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				middleware.ValidateJWTToken(
+					sessionRepo,
+					tokenKey,
+					rateLimit("account", http.HandlerFunc(accountHandler.ChangePassword)))))).Methods("POST")
+
+	router.Handle("/api/account",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				middleware.ValidateJWTToken(
+					sessionRepo,
+					tokenKey,
+					rateLimit("account", http.HandlerFunc(accountHandler.DeleteAccount)))))).Methods("DELETE")
+
+	router.Handle("/api/admin/lockouts/{username}",
+		middleware.LimitBody(defaultBodyLimit,
 			middleware.ValidateJWTToken(
 				sessionRepo,
-				http.HandlerFunc(postHandler.Delete)))).Methods("DELETE")
+				tokenKey,
+				rateLimit("account", http.HandlerFunc(adminHandler.ClearLockout))))).Methods("DELETE")
 
-	router.Handle("/api/post/{postID}/{commentID}",
-		middleware.ValidateContentType(
+	router.Handle("/api/account/2fa",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateJWTToken(
+				sessionRepo,
+				tokenKey,
+				rateLimit("account", http.HandlerFunc(authHandler.EnrollTwoFactor))))).Methods("POST")
+
+	router.Handle("/api/account/2fa/verify",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				middleware.ValidateJWTToken(
+					sessionRepo,
+					tokenKey,
+					rateLimit("account", http.HandlerFunc(authHandler.VerifyTwoFactor)))))).Methods("POST")
+
+	router.Handle("/api/account/2fa",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				middleware.ValidateJWTToken(
+					sessionRepo,
+					tokenKey,
+					rateLimit("account", http.HandlerFunc(authHandler.DisableTwoFactor)))))).Methods("DELETE")
+
+	router.Handle("/api/account/email/verify",
+		middleware.LimitBody(defaultBodyLimit,
 			middleware.ValidateJWTToken(
 				sessionRepo,
-				http.HandlerFunc(commentHandler.Delete)))).Methods("DELETE")
+				tokenKey,
+				rateLimit("account", http.HandlerFunc(accountHandler.ResendVerification))))).Methods("POST")
 
-	router.HandleFunc("/api/user/{username}", postHandler.IndexByUser).Methods("GET")
+	router.Handle("/api/account/email/confirm", rateLimit("account", http.HandlerFunc(accountHandler.ConfirmEmail))).Methods("GET")
 
-	router.Handle("/api/login", middleware.ValidateContentType(
-		http.HandlerFunc(authHandler.Login))).Methods("POST")
+	router.Handle("/api/password/reset",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				rateLimit("account", http.HandlerFunc(accountHandler.RequestPasswordReset))))).Methods("POST")
 
-	router.Handle("/api/register", middleware.ValidateContentType(
-		http.HandlerFunc(authHandler.Signup))).Methods("POST")
+	// Ссылка из письма о сбросе пароля ведет сюда, а не в SPA.
+	router.Handle(userDelivery.PasswordResetPagePath,
+		rateLimit("read", http.HandlerFunc(accountHandler.ResetPasswordPage))).Methods("GET")
+
+	router.Handle("/api/password/reset/confirm",
+		middleware.LimitBody(defaultBodyLimit,
+			middleware.ValidateContentType(
+				rateLimit("account", http.HandlerFunc(accountHandler.ResetPassword))))).Methods("POST")
 
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		tmpl, err := template.ParseFiles(AppConfig.StaticRoot + "/html/index.html")
+		tmpl, err := template.ParseFiles(cfg.StaticRoot + "/html/index.html")
 		if err != nil {
 			tools.Logger.Fatal("error due parsing index.html:", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -216,6 +394,26 @@ func main() {
 		}
 	})
 
-	tools.Logger.Printf("starting server at http://127.0.0.1:%d", AppConfig.Port)
-	tools.Logger.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", AppConfig.Port), router))
+	cors := middleware.CORSOptions{
+		AllowedOrigins:   config.SplitList(cfg.CORS.AllowedOrigins),
+		AllowedMethods:   config.SplitList(cfg.CORS.AllowedMethods),
+		AllowedHeaders:   config.SplitList(cfg.CORS.AllowedHeaders),
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}
+
+	err = serve(ctx, cfg, middleware.Recover(middleware.CORS(cors, router)))
+	// Хранилища закрываются только после того, как сервер дождался начатых запросов.
+	repos.Close()
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if flushErr := shutdownTracing(flushCtx); flushErr != nil {
+		tools.Logger.Error("error flushing spans:", flushErr)
+	}
+
+	if err != nil {
+		tools.Logger.Fatal("error serving http:", err)
+	}
+	tools.Logger.Print("server stopped")
 }
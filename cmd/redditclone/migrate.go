@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"redditclone/pkg/config"
+	"redditclone/pkg/migrate"
+	migrateMongo "redditclone/pkg/migrate/mongo"
+	migrateMysql "redditclone/pkg/migrate/mysql"
+	migratePostgres "redditclone/pkg/migrate/postgres"
+	migrateSqlite "redditclone/pkg/migrate/sqlite"
+	"redditclone/tools"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errMigrateUsage = errors.New("usage: redditclone migrate [-db all|mysql|postgres|sqlite|mongo] up | down [steps] | status")
+
+// userMigrations возвращает миграции базы пользователей, выбранной в USER_STORE.
+// Только у MySQL есть пользователи старше кармы, поэтому пересчет из Mongo есть только в ней.
+func userMigrations(cfg *config.Config, userDB *sql.DB, mongoDB *mongo.Database) (migrate.Store, []*migrate.Migration, error) {
+	switch cfg.UserStore {
+	case config.UserStoreMySQL:
+		migrations, err := migrateMysql.Migrations(userDB)
+		migrations = append(migrations, migrateMysql.KarmaBackfill(userDB, mongoDB))
+		return migrateMysql.NewMigrationMysqlStore(userDB), migrations, err
+	case config.UserStorePostgres:
+		migrations, err := migratePostgres.Migrations(userDB)
+		return migratePostgres.NewMigrationPostgresStore(userDB), migrations, err
+	case config.UserStoreSQLite:
+		migrations, err := migrateSqlite.Migrations(userDB)
+		return migrateSqlite.NewMigrationSqliteStore(userDB), migrations, err
+	default:
+		return nil, nil, fmt.Errorf("unknown USER_STORE %q", cfg.UserStore)
+	}
+}
+
+func newMigrators(cfg *config.Config, userDB *sql.DB, mongoDB *mongo.Database, target string) (map[string]*migrate.Migrator, error) {
+	migrators := map[string]*migrate.Migrator{}
+
+	// В режиме STORAGE: mongo SQL-базы нет, userDB == nil.
+	if userDB != nil && (target == "all" || target == cfg.UserStore) {
+		store, migrations, err := userMigrations(cfg, userDB, mongoDB)
+		if err != nil {
+			return nil, err
+		}
+
+		migrator, err := migrate.NewMigrator(store, migrations)
+		if err != nil {
+			return nil, err
+		}
+		migrators[cfg.UserStore] = migrator
+	}
+
+	if target == "all" || target == "mongo" {
+		migrator, err := migrate.NewMigrator(migrateMongo.NewMigrationMongoDBStore(mongoDB), migrateMongo.Migrations(mongoDB))
+		if err != nil {
+			return nil, err
+		}
+		migrators["mongo"] = migrator
+	}
+
+	if len(migrators) == 0 {
+		return nil, errMigrateUsage
+	}
+
+	return migrators, nil
+}
+
+// migrateUp накатывает все непримененные миграции; его же вызывает сервер при MIGRATE_ON_START.
+func migrateUp(ctx context.Context, cfg *config.Config, userDB *sql.DB, mongoDB *mongo.Database) error {
+	migrators, err := newMigrators(cfg, userDB, mongoDB, "all")
+	if err != nil {
+		return err
+	}
+
+	for _, db := range []string{cfg.UserStore, "mongo"} {
+		migrator, ok := migrators[db]
+		if !ok {
+			continue
+		}
+
+		applied, err := migrator.Up(ctx)
+		logMigrations(db, "up", applied)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runMigrate - подкоманда "redditclone migrate": подключается только к базе пользователей и Mongo.
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	var userDB *sql.DB
+	if cfg.Storage != config.StorageMongo {
+		var err error
+		userDB, err = connectUserDB(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer userDB.Close()
+	}
+
+	mongoConnect, mongoDB, err := connectMongo(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer mongoConnect.Disconnect(ctx)
+
+	return runMigrateCommand(ctx, cfg, args, userDB, mongoDB)
+}
+
+func runMigrateCommand(ctx context.Context, cfg *config.Config, args []string, userDB *sql.DB, mongoDB *mongo.Database) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	target := flags.String("db", "all", "database to migrate: all, the USER_STORE database or mongo")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errMigrateUsage
+	}
+
+	migrators, err := newMigrators(cfg, userDB, mongoDB, *target)
+	if err != nil {
+		return err
+	}
+
+	for _, db := range []string{cfg.UserStore, "mongo"} {
+		migrator, ok := migrators[db]
+		if !ok {
+			continue
+		}
+
+		switch flags.Arg(0) {
+		case "up":
+			applied, err := migrator.Up(ctx)
+			logMigrations(db, "up", applied)
+			if err != nil {
+				return err
+			}
+		case "down":
+			steps := 1
+			if flags.NArg() > 1 {
+				steps, err = strconv.Atoi(flags.Arg(1))
+				if err != nil || steps <= 0 {
+					return errMigrateUsage
+				}
+			}
+
+			reverted, err := migrator.Down(ctx, steps)
+			logMigrations(db, "down", reverted)
+			if err != nil {
+				return err
+			}
+		case "status":
+			statuses, err := migrator.Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			for _, status := range statuses {
+				state := "pending"
+				if status.Applied {
+					state = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Printf("%s\t%04d_%s\t%s\n", db, status.Version, status.Name, state)
+			}
+		default:
+			return errMigrateUsage
+		}
+	}
+
+	return nil
+}
+
+func logMigrations(db, direction string, migrations []*migrate.Migration) {
+	for _, m := range migrations {
+		tools.Logger.Printf("migrated %s %s: %04d_%s", db, direction, m.Version, m.Name)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"redditclone/pkg/config"
+	"redditclone/tools"
+)
+
+// serve отдает запросы, пока ctx не отменят сигналом. После сигнала новые соединения
+// не принимаются, а начатым запросам дается SERVER_SHUTDOWN_TIMEOUT на завершение.
+func serve(ctx context.Context, cfg *config.Config, handler http.Handler) error {
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.Port),
+		Handler:      handler,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	tools.Logger.Printf("starting server at http://127.0.0.1:%d", cfg.Port)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	tools.Logger.Printf("shutting down, waiting up to %s for requests to finish", cfg.Server.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// Не успевшие запросы обрываются, чтобы хранилища можно было закрыть.
+		server.Close()
+		return err
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	commentRepository "redditclone/pkg/comment/repository"
+	commentInstrumented "redditclone/pkg/comment/repository/instrumented"
+	commentMemory "redditclone/pkg/comment/repository/memory"
+	commentMongo "redditclone/pkg/comment/repository/mongo"
+	"redditclone/pkg/config"
+	"redditclone/pkg/health"
+	postRepository "redditclone/pkg/post/repository"
+	postCached "redditclone/pkg/post/repository/cached"
+	postInstrumented "redditclone/pkg/post/repository/instrumented"
+	postMemory "redditclone/pkg/post/repository/memory"
+	postMongo "redditclone/pkg/post/repository/mongo"
+	ratelimit "redditclone/pkg/ratelimit/repository"
+	ratelimitMemory "redditclone/pkg/ratelimit/repository/memory"
+	ratelimitRedis "redditclone/pkg/ratelimit/repository/redis"
+	sessionRepository "redditclone/pkg/session/repository"
+	sessionInstrumented "redditclone/pkg/session/repository/instrumented"
+	sessionMemory "redditclone/pkg/session/repository/memory"
+	sessionMongo "redditclone/pkg/session/repository/mongo"
+	sessionRedis "redditclone/pkg/session/repository/redis"
+	throttle "redditclone/pkg/throttle/repository"
+	throttleMemory "redditclone/pkg/throttle/repository/memory"
+	throttleRedis "redditclone/pkg/throttle/repository/redis"
+	"redditclone/pkg/tracing"
+	userRepository "redditclone/pkg/user/repository"
+	userInstrumented "redditclone/pkg/user/repository/instrumented"
+	userMemory "redditclone/pkg/user/repository/memory"
+	userMongo "redditclone/pkg/user/repository/mongo"
+	userMysql "redditclone/pkg/user/repository/mysql"
+	userPostgres "redditclone/pkg/user/repository/postgres"
+	userSqlite "redditclone/pkg/user/repository/sqlite"
+	"redditclone/tools"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gomodule/redigo/redis"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/uptrace/opentelemetry-go-extra/otelsql"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Пауза между попытками достучаться до базы при старте: от полсекунды до пяти секунд.
+const (
+	startupRetryDelay    = 500 * time.Millisecond
+	startupRetryMaxDelay = 5 * time.Second
+)
+
+const (
+	redisPoolMaxIdle     = 16
+	redisPoolIdleTimeout = 4 * time.Minute
+	redisPoolIdleCheck   = time.Minute
+)
+
+// repositories - все хранилища сервера, независимо от того, где лежат данные.
+type repositories struct {
+	Users           userRepository.UserRepo
+	Sessions        sessionRepository.SessionManager
+	Posts           postRepository.PostRepo
+	Comments        commentRepository.CommentRepo
+	LoginThrottler  throttle.Throttler
+	SignupThrottler throttle.Throttler
+	RateLimiter     ratelimit.Limiter
+
+	// Checks пингует внешние базы для /readyz; у хранилищ в памяти проверок нет.
+	Checks  []health.Check
+	closers []func() error
+}
+
+func (repos *repositories) Close() {
+	for i := len(repos.closers) - 1; i >= 0; i-- {
+		if err := repos.closers[i](); err != nil {
+			tools.Logger.Error("error closing storage:", err)
+		}
+	}
+}
+
+// instrument оборачивает хранилища декораторами с метриками и трассировкой; троттлеры и лимитер не оборачиваются.
+func (repos *repositories) instrument() {
+	repos.Users = userInstrumented.NewUserInstrumentedRepository(repos.Users)
+	repos.Sessions = sessionInstrumented.NewSessionInstrumentedManager(repos.Sessions)
+	repos.Posts = postInstrumented.NewPostInstrumentedRepository(repos.Posts)
+	repos.Comments = commentInstrumented.NewCommentInstrumentedRepository(repos.Comments)
+}
+
+// newMemoryRepositories держит все данные в памяти процесса: сервер запускается без MySQL,
+// Mongo и Redis, но все теряет при перезапуске.
+func newMemoryRepositories() *repositories {
+	return &repositories{
+		Users:           userMemory.NewUserMemoryRepository(),
+		Sessions:        sessionMemory.NewSessionMemoryManager(),
+		Posts:           postMemory.NewPostMemoryRepository(),
+		Comments:        commentMemory.NewCommentMemoryRepository(),
+		LoginThrottler:  throttleMemory.NewThrottleMemoryManager(loginPolicy),
+		SignupThrottler: throttleMemory.NewThrottleMemoryManager(signupPolicy),
+		RateLimiter:     ratelimitMemory.NewLimiterMemoryRepository(),
+	}
+}
+
+// newMongoRepositories держит пользователей, сессии, посты и комментарии в одной Mongo.
+// Счетчики троттлинга и rate limit живут в памяти процесса, поэтому режим рассчитан на один узел.
+func newMongoRepositories(ctx context.Context, cfg *config.Config) (*repositories, error) {
+	repos := &repositories{}
+
+	mongoConnect, mongoDB, err := connectMongo(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	repos.closers = append(repos.closers, func() error {
+		return mongoConnect.Disconnect(context.Background())
+	})
+	repos.Checks = append(repos.Checks, mongoCheck(mongoConnect))
+
+	if cfg.MigrateOnStart {
+		if err = migrateUp(ctx, cfg, nil, mongoDB); err != nil {
+			repos.Close()
+			return nil, err
+		}
+	}
+
+	repos.Users = userMongo.NewUserMongoDBRepository(mongoDB.Collection("users"), mongoDB.Collection("counters"), cfg.TOTPEncryptionKey)
+	repos.Sessions = sessionMongo.NewSessionMongoDBManager(mongoDB.Collection("sessions"))
+	repos.Posts = postMongo.NewPostMongoDBMemoryRepo(mongoDB.Collection("posts"))
+	repos.Comments = commentMongo.NewCommentMongoDBRepository(mongoDB.Collection("comments"))
+	repos.LoginThrottler = throttleMemory.NewThrottleMemoryManager(loginPolicy)
+	repos.SignupThrottler = throttleMemory.NewThrottleMemoryManager(signupPolicy)
+	repos.RateLimiter = ratelimitMemory.NewLimiterMemoryRepository()
+
+	return repos, nil
+}
+
+func newDatabaseRepositories(ctx context.Context, cfg *config.Config) (*repositories, error) {
+	repos := &repositories{}
+
+	userDB, err := connectUserDB(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	repos.closers = append(repos.closers, userDB.Close)
+	repos.Checks = append(repos.Checks, health.Check{Name: cfg.UserStore, Ping: userDB.PingContext})
+
+	mongoConnect, mongoDB, err := connectMongo(ctx, cfg)
+	if err != nil {
+		repos.Close()
+		return nil, err
+	}
+	repos.closers = append(repos.closers, func() error {
+		return mongoConnect.Disconnect(context.Background())
+	})
+	repos.Checks = append(repos.Checks, mongoCheck(mongoConnect))
+
+	if cfg.MigrateOnStart {
+		if err = migrateUp(ctx, cfg, userDB, mongoDB); err != nil {
+			repos.Close()
+			return nil, err
+		}
+	}
+
+	redisURL := fmt.Sprintf("redis://user:@%s:%s/%s",
+		cfg.Redis.Host,
+		cfg.Redis.Port,
+		cfg.Redis.Database,
+	)
+
+	// redis.Conn нельзя делить между горутинами, а менеджеры сериализуют запросы
+	// только своим мьютексом, поэтому каждому менеджеру открывается отдельное соединение.
+	dial := func() (redis.Conn, error) {
+		var conn redis.Conn
+		err := waitFor(ctx, cfg, "redis", func(ctx context.Context) (err error) {
+			conn, err = redis.DialURLContext(ctx, redisURL)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		repos.closers = append(repos.closers, conn.Close)
+		return tracing.WrapRedisConn(conn), nil
+	}
+
+	// Рабочие соединения заняты менеджерами, поэтому проверка открывает свое.
+	repos.Checks = append(repos.Checks, health.Check{Name: "redis", Ping: func(ctx context.Context) error {
+		conn, err := redis.DialURLContext(ctx, redisURL)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		_, err = redis.DoContext(conn, ctx, "PING")
+		return err
+	}})
+
+	redisConn, err := dial()
+	if err != nil {
+		repos.Close()
+		return nil, err
+	}
+
+	loginThrottleConn, err := dial()
+	if err != nil {
+		repos.Close()
+		return nil, err
+	}
+
+	signupThrottleConn, err := dial()
+	if err != nil {
+		repos.Close()
+		return nil, err
+	}
+
+	switch cfg.RateLimitStore {
+	case config.StorageMemory:
+		repos.RateLimiter = ratelimitMemory.NewLimiterMemoryRepository()
+	default:
+		rateLimitConn, err := dial()
+		if err != nil {
+			repos.Close()
+			return nil, err
+		}
+		repos.RateLimiter = ratelimitRedis.NewLimiterRedisRepository(rateLimitConn)
+	}
+
+	repos.Users = newUserRepo(cfg, userDB)
+	repos.Sessions = sessionRedis.NewSessionRedisManager(redisConn)
+	repos.Posts = postMongo.NewPostMongoDBMemoryRepo(mongoDB.Collection("posts"))
+	if cfg.PostCache.Enabled {
+		postCachePool := newRedisPool(redisURL)
+		repos.closers = append(repos.closers, postCachePool.Close)
+		repos.Posts = postCached.NewPostCachedRepository(repos.Posts, postCachePool, cfg.PostCache.PostTTL, cfg.PostCache.ListTTL)
+	}
+	repos.Comments = commentMongo.NewCommentMongoDBRepository(mongoDB.Collection("comments"))
+	repos.LoginThrottler = throttleRedis.NewThrottleRedisManager(loginThrottleConn, "login", loginPolicy)
+	repos.SignupThrottler = throttleRedis.NewThrottleRedisManager(signupThrottleConn, "signup", signupPolicy)
+
+	return repos, nil
+}
+
+// newRedisPool нужен кэшу постов: его читает каждый запрос, и одно соединение под мьютексом
+// стало бы узким местом. К этому моменту Redis уже ответил на dial, поэтому пул не ждет его.
+func newRedisPool(redisURL string) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     redisPoolMaxIdle,
+		IdleTimeout: redisPoolIdleTimeout,
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			conn, err := redis.DialURLContext(ctx, redisURL)
+			if err != nil {
+				return nil, err
+			}
+			return tracing.WrapRedisConn(conn), nil
+		},
+		// Простоявшее соединение могло умереть вместе с перезапущенным Redis.
+		TestOnBorrow: func(conn redis.Conn, lastUsed time.Time) error {
+			if time.Since(lastUsed) < redisPoolIdleCheck {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}
+}
+
+// waitFor повторяет ping, пока зависимость не ответит или не истечет STARTUP_TIMEOUT:
+// в docker-compose базы поднимаются дольше сервера.
+func waitFor(ctx context.Context, cfg *config.Config, name string, ping func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.StartupTimeout)
+	defer cancel()
+
+	if err := tools.Retry(ctx, name, startupRetryDelay, startupRetryMaxDelay, ping); err != nil {
+		return fmt.Errorf("%s is not available: %w", name, err)
+	}
+
+	return nil
+}
+
+// connectUserDB открывает базу пользователей, выбранную в USER_STORE, и дожидается ее ответа.
+func connectUserDB(ctx context.Context, cfg *config.Config) (*sql.DB, error) {
+	var db *sql.DB
+	var err error
+	switch cfg.UserStore {
+	case config.UserStoreMySQL:
+		db, err = connectMySQL(cfg.MySQL)
+	case config.UserStorePostgres:
+		db, err = connectPostgres(cfg.Postgres)
+	case config.UserStoreSQLite:
+		db, err = connectSQLite(cfg.SQLitePath)
+	default:
+		return nil, fmt.Errorf("unknown USER_STORE %q", cfg.UserStore)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = waitFor(ctx, cfg, cfg.UserStore, db.PingContext); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func newUserRepo(cfg *config.Config, db *sql.DB) userRepository.UserRepo {
+	switch cfg.UserStore {
+	case config.UserStorePostgres:
+		return userPostgres.NewUserPostgresRepo(db, cfg.TOTPEncryptionKey)
+	case config.UserStoreSQLite:
+		return userSqlite.NewUserSqliteRepo(db, cfg.TOTPEncryptionKey)
+	default:
+		return userMysql.NewUserMySqlRepo(db, cfg.TOTPEncryptionKey)
+	}
+}
+
+func connectMySQL(db config.DatabaseConfig) (*sql.DB, error) {
+	mysqlDSN := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?",
+		db.User,
+		db.Password,
+		db.Host,
+		db.Port,
+		db.Database,
+	)
+	mysqlDSN += "&charset=utf8"
+	mysqlDSN += "&interpolateParams=true"
+	mysqlDSN += "&parseTime=true"
+	// Без clientFoundRows UPDATE, не изменивший строку, выглядит как "пользователь не найден".
+	mysqlDSN += "&clientFoundRows=true"
+
+	mysqlConnect, err := otelsql.Open("mysql", mysqlDSN, otelsql.WithDBSystem("mysql"), otelsql.WithDBName(db.Database))
+	if err != nil {
+		return nil, err
+	}
+
+	mysqlConnect.SetConnMaxLifetime(time.Minute * 3)
+	mysqlConnect.SetMaxOpenConns(10)
+	mysqlConnect.SetMaxIdleConns(10)
+
+	return mysqlConnect, nil
+}
+
+func connectPostgres(db config.PostgresConfig) (*sql.DB, error) {
+	postgresDSN := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		db.Host,
+		db.Port,
+		db.User,
+		db.Password,
+		db.Database,
+		db.SSLMode,
+	)
+
+	postgresConnect, err := otelsql.Open("postgres", postgresDSN, otelsql.WithDBSystem("postgresql"), otelsql.WithDBName(db.Database))
+	if err != nil {
+		return nil, err
+	}
+
+	postgresConnect.SetConnMaxLifetime(time.Minute * 3)
+	postgresConnect.SetMaxOpenConns(10)
+	postgresConnect.SetMaxIdleConns(10)
+
+	return postgresConnect, nil
+}
+
+func connectSQLite(path string) (*sql.DB, error) {
+	sqliteDSN := "file:" + path + "?_foreign_keys=on"
+	// WAL позволяет читать во время записи, а busy_timeout и немедленная блокировка в транзакциях
+	// заставляют конкурентных писателей ждать, а не получать SQLITE_BUSY.
+	sqliteDSN += "&_journal_mode=WAL"
+	sqliteDSN += "&_busy_timeout=5000"
+	sqliteDSN += "&_txlock=immediate"
+
+	return otelsql.Open("sqlite3", sqliteDSN, otelsql.WithDBSystem("sqlite"))
+}
+
+func mongoCheck(client *mongo.Client) health.Check {
+	return health.Check{Name: "mongo", Ping: func(ctx context.Context) error {
+		return client.Ping(ctx, nil)
+	}}
+}
+
+func connectMongo(ctx context.Context, cfg *config.Config) (*mongo.Client, *mongo.Database, error) {
+	mongoURI := fmt.Sprintf("mongodb://%s:%s@%s:%s/?maxPoolSize=10",
+		cfg.MongoDB.User,
+		cfg.MongoDB.Password,
+		cfg.MongoDB.Host,
+		cfg.MongoDB.Port,
+	)
+	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
+	opts := options.Client().ApplyURI(mongoURI).SetServerAPIOptions(serverAPI).SetMonitor(tracing.MongoMonitor())
+
+	// Connect не ходит в сеть, доступность проверяет только Ping.
+	mongoConnect, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = waitFor(ctx, cfg, "mongo", func(ctx context.Context) error {
+		return mongoConnect.Ping(ctx, nil)
+	})
+	if err != nil {
+		mongoConnect.Disconnect(context.Background())
+		return nil, nil, err
+	}
+
+	return mongoConnect, mongoConnect.Database(cfg.MongoDB.Database), nil
+}
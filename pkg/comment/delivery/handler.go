@@ -5,18 +5,23 @@ import (
 	"io"
 	"net/http"
 	commentRepository "redditclone/pkg/comment/repository"
+	"redditclone/pkg/metrics"
 	"redditclone/pkg/middleware"
+	"redditclone/pkg/models"
 	postRepository "redditclone/pkg/post/repository"
 	userRepository "redditclone/pkg/user/repository"
 	"redditclone/tools"
 
 	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type CommentHandler struct {
 	PostRepo    postRepository.PostRepo
 	CommentRepo commentRepository.CommentRepo
 	UserRepo    userRepository.UserRepo
+
+	RequireVerifiedEmail bool
 }
 
 type CommentForm struct {
@@ -26,59 +31,69 @@ type CommentForm struct {
 func (h *CommentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
 	if !ok {
-		tools.JSONError(w, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
 		return
 	}
 
-	user, err := h.UserRepo.GetUserByID(userID)
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
 	if err != nil {
-		tools.JSONError(w, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	if h.RequireVerifiedEmail && !user.EmailVerified {
+		tools.JSONError(w, r, http.StatusForbidden, models.ErrEmailNotVerified.Error(), "CommentHandler.Create")
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "CommentHandler.Create")
+		return
+	}
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
 		return
 	}
 
 	commentForm := &CommentForm{}
-	err = json.Unmarshal(body, commentForm)
+	err = tools.UnmarshalStrict(body, commentForm)
 	if err != nil {
-		tools.JSONError(w, http.StatusUnauthorized, "couldnt umarshall comment", "CommentHandler.Create")
+		tools.JSONError(w, r, http.StatusUnauthorized, "couldnt umarshall comment", "CommentHandler.Create")
 		return
 	}
 
 	vars := mux.Vars(r)
 	postID := vars["postID"]
-	post, err := h.PostRepo.GetPostByID(postID)
+	post, err := h.PostRepo.GetPostByIDForUpdate(r.Context(), postID)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostByID")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostByIDForUpdate")
 		return
 	}
 
-	comment, err := h.CommentRepo.CreateComment(post, user, commentForm.Text)
+	comment, err := h.CommentRepo.CreateComment(r.Context(), post, user, commentForm.Text)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "CommentRepo.CreateComment")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentRepo.CreateComment")
 		return
 	}
+	metrics.CommentsTotal.Inc()
 
-	post, err = h.PostRepo.AddPostComment(post, comment)
+	post, err = h.PostRepo.AddPostComment(r.Context(), post, comment)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.AddPostComment")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.AddPostComment")
 		return
 	}
 
 	jsonPost, err := json.Marshal(post)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
 		return
 	}
 
 	_, err = w.Write(jsonPost)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
 		return
 	}
 }
@@ -86,51 +101,153 @@ func (h *CommentHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *CommentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
 	if !ok {
-		tools.JSONError(w, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
 		return
 	}
 
 	vars := mux.Vars(r)
 	commentID := vars["commentID"]
-	comment, err := h.CommentRepo.GetCommentByID(commentID)
+	comment, err := h.CommentRepo.GetCommentByID(r.Context(), commentID)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "CommentRepo.GetCommentByID")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentRepo.GetCommentByID")
 		return
 	}
 
 	if userID != comment.Author.ID {
-		tools.JSONError(w, http.StatusForbidden, "you are not allowed to delete this comment", "CommentHandler.Delete")
+		tools.JSONError(w, r, http.StatusForbidden, "you are not allowed to delete this comment", "CommentHandler.Delete")
 		return
 	}
 
 	postID := vars["postID"]
-	post, err := h.PostRepo.GetPostByID(postID)
+	post, err := h.PostRepo.GetPostByIDForUpdate(r.Context(), postID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostByIDForUpdate")
+		return
+	}
+
+	err = h.CommentRepo.DeleteComment(r.Context(), comment)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentRepo.DeleteComment")
+		return
+	}
+
+	err = h.PostRepo.DeletePostComment(r.Context(), post, comment)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.DeletePostComment")
+		return
+	}
+
+	jsonPost, err := json.Marshal(post)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
+		return
+	}
+
+	_, err = w.Write(jsonPost)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
+		return
+	}
+}
+
+func (h *CommentHandler) Vote(w http.ResponseWriter, r *http.Request, rate int) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
+	if !ok {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "CommentHandler.Vote")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostByID")
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	vars := mux.Vars(r)
+	post, err := h.PostRepo.GetPostByIDForUpdate(r.Context(), vars["postID"])
+	if err == models.ErrCorruptedPostID {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "PostRepo.GetPostByIDForUpdate")
+		return
+	} else if err == models.ErrNoPost {
+		tools.JSONError(w, r, http.StatusNotFound, err.Error(), "PostRepo.GetPostByIDForUpdate")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostByIDForUpdate")
+		return
+	}
+
+	comment, err := h.CommentRepo.GetCommentByID(r.Context(), vars["commentID"])
+	if err == models.ErrCorruptedCommentID {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "CommentRepo.GetCommentByID")
+		return
+	} else if err == models.ErrNoComment {
+		tools.JSONError(w, r, http.StatusNotFound, err.Error(), "CommentRepo.GetCommentByID")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentRepo.GetCommentByID")
 		return
 	}
 
-	err = h.CommentRepo.DeleteComment(comment)
+	// Комментарий из чужого поста не найден: иначе голос попал бы в пост из адреса.
+	if !hasComment(post, comment.ID) {
+		tools.JSONError(w, r, http.StatusNotFound, models.ErrNoComment.Error(), "CommentHandler.Vote")
+		return
+	}
+
+	scoreBefore := comment.Score
+	err = h.CommentRepo.VoteComment(r.Context(), user, comment, rate)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "CommentRepo.DeleteComment")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentRepo.VoteComment")
 		return
 	}
+	metrics.CountVote("comment", rate)
 
-	err = h.PostRepo.DeletePostComment(post, comment)
+	err = h.PostRepo.UpdatePostComment(r.Context(), post, comment)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.DeletePostComment")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.UpdatePostComment")
 		return
 	}
 
+	// У удаленного автора кармы нет; голос к этому моменту уже сохранен.
+	if karmaDelta := comment.Score - scoreBefore; karmaDelta != 0 && comment.Author.ID != models.DeletedUserID {
+		err = h.UserRepo.UpdateKarma(r.Context(), comment.Author.ID, 0, karmaDelta)
+		if err != nil && err != models.ErrNoUser {
+			tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.UpdateKarma")
+			return
+		}
+	}
+
 	jsonPost, err := json.Marshal(post)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentHandler.Vote")
 		return
 	}
 
 	_, err = w.Write(jsonPost)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "CommentHandler.Create")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentHandler.Vote")
 		return
 	}
 }
+
+func (h *CommentHandler) Upvote(w http.ResponseWriter, r *http.Request) {
+	h.Vote(w, r, 1)
+}
+
+func (h *CommentHandler) Unvote(w http.ResponseWriter, r *http.Request) {
+	h.Vote(w, r, 0)
+}
+
+func (h *CommentHandler) Downvote(w http.ResponseWriter, r *http.Request) {
+	h.Vote(w, r, -1)
+}
+
+func hasComment(post *models.Post, commentID primitive.ObjectID) bool {
+	for _, comment := range post.Comments {
+		if comment.ID == commentID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,177 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	commentInstrumented "redditclone/pkg/comment/repository/instrumented"
+	commentMemory "redditclone/pkg/comment/repository/memory"
+	"redditclone/pkg/middleware"
+	postInstrumented "redditclone/pkg/post/repository/instrumented"
+	postMemory "redditclone/pkg/post/repository/memory"
+	userInstrumented "redditclone/pkg/user/repository/instrumented"
+	userMemory "redditclone/pkg/user/repository/memory"
+	"redditclone/tools"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestCommentHandlerCreateTracing(t *testing.T) {
+	tools.Init()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	ctx := context.Background()
+	users := userInstrumented.NewUserInstrumentedRepository(userMemory.NewUserMemoryRepository())
+	posts := postInstrumented.NewPostInstrumentedRepository(postMemory.NewPostMemoryRepository())
+	commentHandler := &CommentHandler{
+		UserRepo:    users,
+		PostRepo:    posts,
+		CommentRepo: commentInstrumented.NewCommentInstrumentedRepository(commentMemory.NewCommentMemoryRepository()),
+	}
+
+	user, err := users.CreateUser(ctx, "alex12345", "password", "alex@example.com")
+	if !assert.NoError(t, err) {
+		return
+	}
+	post, err := posts.CreateNewPost(ctx, "music", "title", "text", "", "body", user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// Спаны подготовки данных не относятся к проверяемому запросу.
+	exporter.Reset()
+
+	// ValidateJWTToken подменен: проверяется только трасса самого обработчика.
+	authorized := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middleware.UserIDContextKey, user.ID)))
+		})
+	}
+	router := mux.NewRouter()
+	router.Use(middleware.Tracing)
+	router.Handle("/api/post/{postID}", authorized(http.HandlerFunc(commentHandler.Create))).Methods("POST")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentSpanID = "00f067aa0ba902b7"
+	r := httptest.NewRequest("POST", "/api/post/"+post.ID.Hex(), strings.NewReader(`{"comment":"first"}`))
+	r.Header.Set("traceparent", "00-"+traceID+"-"+parentSpanID+"-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+
+	spans := exporter.GetSpans()
+	var root *tracetest.SpanStub
+	children := []string{}
+	for i := range spans {
+		if spans[i].Name == "POST /api/post/{postID}" {
+			root = &spans[i]
+		}
+	}
+	if !assert.NotNil(t, root) {
+		return
+	}
+
+	// Серверный спан продолжает трассу клиента из traceparent.
+	assert.Equal(t, trace.SpanKindServer, root.SpanKind)
+	assert.Equal(t, traceID, root.SpanContext.TraceID().String())
+	assert.Equal(t, parentSpanID, root.Parent.SpanID().String())
+	assert.True(t, root.Parent.IsRemote())
+
+	// Спаны заканчиваются раньше родителя, поэтому экспортер получает их в порядке вызовов.
+	for _, span := range spans {
+		assert.Equal(t, traceID, span.SpanContext.TraceID().String())
+		if span.Parent.SpanID() == root.SpanContext.SpanID() {
+			children = append(children, span.Name)
+		}
+	}
+	assert.Equal(t, []string{
+		"UserRepo.GetUserByID",
+		"PostRepo.GetPostByIDForUpdate",
+		"CommentRepo.CreateComment",
+		"PostRepo.AddPostComment",
+	}, children)
+	assert.Len(t, spans, len(children)+1)
+}
+
+func TestCommentHandlerVote(t *testing.T) {
+	tools.Init()
+
+	ctx := context.Background()
+	users := userMemory.NewUserMemoryRepository()
+	posts := postMemory.NewPostMemoryRepository()
+	comments := commentMemory.NewCommentMemoryRepository()
+	commentHandler := &CommentHandler{
+		UserRepo:    users,
+		PostRepo:    posts,
+		CommentRepo: comments,
+	}
+
+	user, err := users.CreateUser(ctx, "alex12345", "password", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	post, err := posts.CreateNewPost(ctx, "music", "title", "text", "", "body", user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	other, err := posts.CreateNewPost(ctx, "news", "other", "text", "", "body", user)
+	if !assert.NoError(t, err) {
+		return
+	}
+	comment, err := comments.CreateComment(ctx, post, user, "first")
+	if !assert.NoError(t, err) {
+		return
+	}
+	if _, err = posts.AddPostComment(ctx, post, comment); !assert.NoError(t, err) {
+		return
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/post/{postID}/{commentID}/upvote", func(w http.ResponseWriter, r *http.Request) {
+		commentHandler.Upvote(w, r.WithContext(context.WithValue(r.Context(), middleware.UserIDContextKey, user.ID)))
+	})
+
+	for name, tc := range map[string]struct {
+		postID, commentID string
+		status            int
+	}{
+		"correct vote":       {post.ID.Hex(), comment.ID.Hex(), http.StatusOK},
+		"comment of another": {other.ID.Hex(), comment.ID.Hex(), http.StatusNotFound},
+		"bad post id":        {"bad", comment.ID.Hex(), http.StatusBadRequest},
+		"missing post":       {primitive.NewObjectID().Hex(), comment.ID.Hex(), http.StatusNotFound},
+		"bad comment id":     {post.ID.Hex(), "bad", http.StatusBadRequest},
+		"missing comment":    {post.ID.Hex(), primitive.NewObjectID().Hex(), http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/post/"+tc.postID+"/"+tc.commentID+"/upvote", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+		})
+	}
+
+	// Чужой пост не изменился.
+	found, err := posts.GetPostByID(ctx, other.ID.Hex())
+	assert.NoError(t, err)
+	assert.Empty(t, found.Comments)
+}
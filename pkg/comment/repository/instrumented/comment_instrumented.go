@@ -0,0 +1,70 @@
+package instrumented
+
+import (
+	"context"
+	commentRepository "redditclone/pkg/comment/repository"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	"redditclone/pkg/tracing"
+)
+
+// CommentInstrumentedRepository пишет в метрики длительность и ошибки каждого вызова CommentRepo
+// и открывает на него спан трассировки.
+type CommentInstrumentedRepository struct {
+	Repo commentRepository.CommentRepo
+}
+
+func NewCommentInstrumentedRepository(repo commentRepository.CommentRepo) *CommentInstrumentedRepository {
+	return &CommentInstrumentedRepository{
+		Repo: repo,
+	}
+}
+
+func (repo *CommentInstrumentedRepository) GetCommentByID(ctx context.Context, commentID string) (comment *models.Comment, err error) {
+	ctx, endSpan := tracing.Start(ctx, "CommentRepo.GetCommentByID")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("comment", "GetCommentByID")(&err)
+	return repo.Repo.GetCommentByID(ctx, commentID)
+}
+
+func (repo *CommentInstrumentedRepository) CreateComment(ctx context.Context, post *models.Post, user *models.User, commentText string) (comment *models.Comment, err error) {
+	ctx, endSpan := tracing.Start(ctx, "CommentRepo.CreateComment")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("comment", "CreateComment")(&err)
+	return repo.Repo.CreateComment(ctx, post, user, commentText)
+}
+
+func (repo *CommentInstrumentedRepository) DeleteComment(ctx context.Context, comment *models.Comment) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "CommentRepo.DeleteComment")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("comment", "DeleteComment")(&err)
+	return repo.Repo.DeleteComment(ctx, comment)
+}
+
+func (repo *CommentInstrumentedRepository) GetCommentsByAuthor(ctx context.Context, username string, opts *models.ListOptions) (comments []*models.Comment, err error) {
+	ctx, endSpan := tracing.Start(ctx, "CommentRepo.GetCommentsByAuthor")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("comment", "GetCommentsByAuthor")(&err)
+	return repo.Repo.GetCommentsByAuthor(ctx, username, opts)
+}
+
+func (repo *CommentInstrumentedRepository) CountCommentsByAuthor(ctx context.Context, username string) (count int, err error) {
+	ctx, endSpan := tracing.Start(ctx, "CommentRepo.CountCommentsByAuthor")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("comment", "CountCommentsByAuthor")(&err)
+	return repo.Repo.CountCommentsByAuthor(ctx, username)
+}
+
+func (repo *CommentInstrumentedRepository) VoteComment(ctx context.Context, user *models.User, comment *models.Comment, rate int) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "CommentRepo.VoteComment")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("comment", "VoteComment")(&err)
+	return repo.Repo.VoteComment(ctx, user, comment, rate)
+}
+
+func (repo *CommentInstrumentedRepository) AnonymizeAuthor(ctx context.Context, userID int) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "CommentRepo.AnonymizeAuthor")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("comment", "AnonymizeAuthor")(&err)
+	return repo.Repo.AnonymizeAuthor(ctx, userID)
+}
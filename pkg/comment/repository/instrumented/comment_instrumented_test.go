@@ -0,0 +1,32 @@
+package instrumented
+
+import (
+	"context"
+	"redditclone/pkg/comment/repository"
+	"redditclone/pkg/comment/repository/memory"
+	"redditclone/pkg/comment/repository/repotest"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.TestCommentRepo(t, func(t *testing.T) repository.CommentRepo {
+		return NewCommentInstrumentedRepository(memory.NewCommentMemoryRepository())
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewCommentInstrumentedRepository(memory.NewCommentMemoryRepository())
+	errors := metrics.RepositoryCallErrors.WithLabelValues("comment", "GetCommentByID")
+	before := testutil.ToFloat64(errors)
+
+	_, err := repo.GetCommentByID(ctx, "not an id")
+	assert.Equal(t, models.ErrCorruptedCommentID, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(errors))
+}
@@ -0,0 +1,210 @@
+package memory
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CommentMemoryRepository хранит комментарии в памяти процесса: для разработки без Mongo и для тестов.
+type CommentMemoryRepository struct {
+	mu       *sync.RWMutex
+	comments map[primitive.ObjectID]*models.Comment
+	order    []primitive.ObjectID
+}
+
+func NewCommentMemoryRepository() *CommentMemoryRepository {
+	return &CommentMemoryRepository{
+		mu:       &sync.RWMutex{},
+		comments: make(map[primitive.ObjectID]*models.Comment),
+		order:    []primitive.ObjectID{},
+	}
+}
+
+func (repo *CommentMemoryRepository) GetCommentByID(ctx context.Context, commentID string) (*models.Comment, error) {
+	primitiveID, err := primitive.ObjectIDFromHex(commentID)
+	if err != nil {
+		return nil, models.ErrCorruptedCommentID
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	comment, ok := repo.comments[primitiveID]
+	if !ok {
+		return nil, models.ErrNoComment
+	}
+
+	return cloneComment(comment), nil
+}
+
+func (repo *CommentMemoryRepository) CreateComment(ctx context.Context, post *models.Post, user *models.User, commentText string) (*models.Comment, error) {
+	author := *user
+	newComment := &models.Comment{
+		ID:      primitive.NewObjectID(),
+		Text:    commentText,
+		Author:  &author,
+		Created: time.Now(),
+		Score:   0,
+		Votes:   []*models.Vote{},
+		Post: &models.PostRef{
+			ID:    post.ID,
+			Title: post.Title,
+		},
+	}
+
+	repo.mu.Lock()
+	repo.comments[newComment.ID] = cloneComment(newComment)
+	repo.order = append(repo.order, newComment.ID)
+	repo.mu.Unlock()
+
+	return newComment, nil
+}
+
+func (repo *CommentMemoryRepository) DeleteComment(ctx context.Context, comment *models.Comment) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.comments[comment.ID]; !ok {
+		return models.ErrNoComment
+	}
+
+	delete(repo.comments, comment.ID)
+	repo.order = slices.DeleteFunc(repo.order, func(id primitive.ObjectID) bool {
+		return id == comment.ID
+	})
+
+	return nil
+}
+
+// GetCommentsByAuthor сортирует так же, как индексы в Mongo: по дате или по рейтингу, затем по дате.
+func (repo *CommentMemoryRepository) GetCommentsByAuthor(ctx context.Context, username string, listOpts *models.ListOptions) ([]*models.Comment, error) {
+	repo.mu.RLock()
+	comments := []*models.Comment{}
+	for _, id := range repo.order {
+		comment := repo.comments[id]
+		if comment.Author != nil && comment.Author.Login == username {
+			comments = append(comments, cloneComment(comment))
+		}
+	}
+	repo.mu.RUnlock()
+
+	byTop := listOpts != nil && listOpts.Sort == models.SortTop
+	slices.SortStableFunc(comments, func(a, b *models.Comment) int {
+		if byTop && a.Score != b.Score {
+			return b.Score - a.Score
+		}
+		return b.Created.Compare(a.Created)
+	})
+
+	if listOpts != nil {
+		if listOpts.Offset > 0 {
+			comments = comments[min(listOpts.Offset, len(comments)):]
+		}
+		if listOpts.Limit > 0 && listOpts.Limit < len(comments) {
+			comments = comments[:listOpts.Limit]
+		}
+	}
+
+	return comments, nil
+}
+
+func (repo *CommentMemoryRepository) CountCommentsByAuthor(ctx context.Context, username string) (int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	count := 0
+	for _, comment := range repo.comments {
+		if comment.Author != nil && comment.Author.Login == username {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (repo *CommentMemoryRepository) VoteComment(ctx context.Context, user *models.User, comment *models.Comment, rate int) error {
+	if rate > 1 || rate < -1 {
+		return models.ErrUnrecognizedRate
+	}
+
+	voteIndex := slices.IndexFunc(comment.Votes, func(vote *models.Vote) bool {
+		return vote.Author.ID == user.ID
+	})
+
+	if voteIndex != -1 {
+		comment.Score -= comment.Votes[voteIndex].Vote
+		comment.Votes = slices.Delete(comment.Votes, voteIndex, voteIndex+1)
+	}
+
+	if rate == 1 || rate == -1 {
+		comment.Votes = append(comment.Votes, &models.Vote{
+			Author:   *user,
+			AuthorID: user.ID,
+			Vote:     rate,
+		})
+		comment.Score += rate
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.comments[comment.ID]
+	if !ok {
+		return models.ErrNoComment
+	}
+	stored.Votes = cloneComment(comment).Votes
+	stored.Score = comment.Score
+
+	return nil
+}
+
+func (repo *CommentMemoryRepository) AnonymizeAuthor(ctx context.Context, userID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, comment := range repo.comments {
+		if comment.Author != nil && comment.Author.ID == userID {
+			comment.Author.ID = models.DeletedUserID
+			comment.Author.Login = models.DeletedUserLogin
+		}
+		anonymizeVotes(comment.Votes, userID)
+	}
+
+	return nil
+}
+
+// anonymizeVotes оставляет голоса удаленного пользователя в рейтинге, но стирает из них автора.
+func anonymizeVotes(votes []*models.Vote, userID int) {
+	for _, vote := range votes {
+		if vote.AuthorID == userID {
+			vote.AuthorID = models.DeletedUserID
+			vote.Author = models.User{ID: models.DeletedUserID, Login: models.DeletedUserLogin}
+		}
+	}
+}
+
+func cloneComment(comment *models.Comment) *models.Comment {
+	clone := *comment
+	if comment.Author != nil {
+		author := *comment.Author
+		clone.Author = &author
+	}
+	if comment.Post != nil {
+		postRef := *comment.Post
+		clone.Post = &postRef
+	}
+	if comment.Votes != nil {
+		clone.Votes = make([]*models.Vote, 0, len(comment.Votes))
+		for _, vote := range comment.Votes {
+			voteClone := *vote
+			clone.Votes = append(clone.Votes, &voteClone)
+		}
+	}
+
+	return &clone
+}
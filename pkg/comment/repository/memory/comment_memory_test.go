@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"redditclone/pkg/comment/repository"
+	"redditclone/pkg/comment/repository/repotest"
+	"redditclone/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestComments(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewCommentMemoryRepository()
+	author := &models.User{ID: 1, Login: "alex12345"}
+	voter := &models.User{ID: 2, Login: "bob"}
+	post := &models.Post{ID: primitive.NewObjectID(), Title: "title"}
+
+	first, err := repo.CreateComment(ctx, post, author, "first")
+	assert.NoError(t, err)
+	assert.Equal(t, post.ID, first.Post.ID)
+
+	second, _ := repo.CreateComment(ctx, post, author, "second")
+	repo.CreateComment(ctx, post, voter, "other")
+
+	// Время создания задаем явно, чтобы порядок не зависел от разрешения часов.
+	repo.comments[first.ID].Created = time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo.comments[second.ID].Created = time.Date(2022, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("get by id", func(t *testing.T) {
+		found, err := repo.GetCommentByID(ctx, first.ID.Hex())
+		assert.NoError(t, err)
+		assert.Equal(t, "first", found.Text)
+
+		_, err = repo.GetCommentByID(ctx, "bad id")
+		assert.Equal(t, models.ErrCorruptedCommentID, err)
+
+		_, err = repo.GetCommentByID(ctx, primitive.NewObjectID().Hex())
+		assert.Equal(t, models.ErrNoComment, err)
+	})
+
+	t.Run("vote", func(t *testing.T) {
+		found, _ := repo.GetCommentByID(ctx, first.ID.Hex())
+		assert.NoError(t, repo.VoteComment(ctx, voter, found, 1))
+		assert.Equal(t, models.ErrUnrecognizedRate, repo.VoteComment(ctx, voter, found, 5))
+
+		stored, _ := repo.GetCommentByID(ctx, first.ID.Hex())
+		assert.Equal(t, 1, stored.Score)
+		assert.Len(t, stored.Votes, 1)
+	})
+
+	t.Run("by author", func(t *testing.T) {
+		byNew, _ := repo.GetCommentsByAuthor(ctx, "alex12345", nil)
+		assert.Equal(t, []string{"second", "first"}, []string{byNew[0].Text, byNew[1].Text})
+
+		byTop, _ := repo.GetCommentsByAuthor(ctx, "alex12345", &models.ListOptions{Sort: models.SortTop})
+		assert.Equal(t, "first", byTop[0].Text)
+
+		page, _ := repo.GetCommentsByAuthor(ctx, "alex12345", &models.ListOptions{Limit: 1, Offset: 1})
+		assert.Len(t, page, 1)
+		assert.Equal(t, "first", page[0].Text)
+
+		empty, _ := repo.GetCommentsByAuthor(ctx, "alex12345", &models.ListOptions{Offset: 10})
+		assert.Empty(t, empty)
+
+		count, _ := repo.CountCommentsByAuthor(ctx, "alex12345")
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("anonymize and delete", func(t *testing.T) {
+		assert.NoError(t, repo.AnonymizeAuthor(ctx, author.ID))
+		count, _ := repo.CountCommentsByAuthor(ctx, models.DeletedUserLogin)
+		assert.Equal(t, 2, count)
+
+		assert.NoError(t, repo.DeleteComment(ctx, first))
+		assert.Equal(t, models.ErrNoComment, repo.DeleteComment(ctx, first))
+		assert.Equal(t, models.ErrNoComment, repo.VoteComment(ctx, voter, first, 1))
+	})
+}
+
+func TestConformance(t *testing.T) {
+	repotest.TestCommentRepo(t, func(t *testing.T) repository.CommentRepo {
+		return NewCommentMemoryRepository()
+	})
+}
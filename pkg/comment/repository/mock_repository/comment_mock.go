@@ -5,6 +5,7 @@
 package mock_repository
 
 import (
+	context "context"
 	models "redditclone/pkg/models"
 	reflect "reflect"
 
@@ -34,46 +35,104 @@ func (m *MockCommentRepo) EXPECT() *MockCommentRepoMockRecorder {
 	return m.recorder
 }
 
+// AnonymizeAuthor mocks base method.
+func (m *MockCommentRepo) AnonymizeAuthor(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeAuthor", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AnonymizeAuthor indicates an expected call of AnonymizeAuthor.
+func (mr *MockCommentRepoMockRecorder) AnonymizeAuthor(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeAuthor", reflect.TypeOf((*MockCommentRepo)(nil).AnonymizeAuthor), ctx, userID)
+}
+
+// CountCommentsByAuthor mocks base method.
+func (m *MockCommentRepo) CountCommentsByAuthor(ctx context.Context, username string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountCommentsByAuthor", ctx, username)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountCommentsByAuthor indicates an expected call of CountCommentsByAuthor.
+func (mr *MockCommentRepoMockRecorder) CountCommentsByAuthor(ctx, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountCommentsByAuthor", reflect.TypeOf((*MockCommentRepo)(nil).CountCommentsByAuthor), ctx, username)
+}
+
 // CreateComment mocks base method.
-func (m *MockCommentRepo) CreateComment(post *models.Post, user *models.User, commentText string) (*models.Comment, error) {
+func (m *MockCommentRepo) CreateComment(ctx context.Context, post *models.Post, user *models.User, commentText string) (*models.Comment, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateComment", post, user, commentText)
+	ret := m.ctrl.Call(m, "CreateComment", ctx, post, user, commentText)
 	ret0, _ := ret[0].(*models.Comment)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateComment indicates an expected call of CreateComment.
-func (mr *MockCommentRepoMockRecorder) CreateComment(post, user, commentText interface{}) *gomock.Call {
+func (mr *MockCommentRepoMockRecorder) CreateComment(ctx, post, user, commentText interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateComment", reflect.TypeOf((*MockCommentRepo)(nil).CreateComment), post, user, commentText)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateComment", reflect.TypeOf((*MockCommentRepo)(nil).CreateComment), ctx, post, user, commentText)
 }
 
 // DeleteComment mocks base method.
-func (m *MockCommentRepo) DeleteComment(comment *models.Comment) error {
+func (m *MockCommentRepo) DeleteComment(ctx context.Context, comment *models.Comment) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteComment", comment)
+	ret := m.ctrl.Call(m, "DeleteComment", ctx, comment)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteComment indicates an expected call of DeleteComment.
-func (mr *MockCommentRepoMockRecorder) DeleteComment(comment interface{}) *gomock.Call {
+func (mr *MockCommentRepoMockRecorder) DeleteComment(ctx, comment interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockCommentRepo)(nil).DeleteComment), comment)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockCommentRepo)(nil).DeleteComment), ctx, comment)
 }
 
 // GetCommentByID mocks base method.
-func (m *MockCommentRepo) GetCommentByID(commentID string) (*models.Comment, error) {
+func (m *MockCommentRepo) GetCommentByID(ctx context.Context, commentID string) (*models.Comment, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCommentByID", commentID)
+	ret := m.ctrl.Call(m, "GetCommentByID", ctx, commentID)
 	ret0, _ := ret[0].(*models.Comment)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetCommentByID indicates an expected call of GetCommentByID.
-func (mr *MockCommentRepoMockRecorder) GetCommentByID(commentID interface{}) *gomock.Call {
+func (mr *MockCommentRepoMockRecorder) GetCommentByID(ctx, commentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentByID", reflect.TypeOf((*MockCommentRepo)(nil).GetCommentByID), ctx, commentID)
+}
+
+// GetCommentsByAuthor mocks base method.
+func (m *MockCommentRepo) GetCommentsByAuthor(ctx context.Context, username string, opts *models.ListOptions) ([]*models.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentsByAuthor", ctx, username, opts)
+	ret0, _ := ret[0].([]*models.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommentsByAuthor indicates an expected call of GetCommentsByAuthor.
+func (mr *MockCommentRepoMockRecorder) GetCommentsByAuthor(ctx, username, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsByAuthor", reflect.TypeOf((*MockCommentRepo)(nil).GetCommentsByAuthor), ctx, username, opts)
+}
+
+// VoteComment mocks base method.
+func (m *MockCommentRepo) VoteComment(ctx context.Context, user *models.User, comment *models.Comment, rate int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoteComment", ctx, user, comment, rate)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VoteComment indicates an expected call of VoteComment.
+func (mr *MockCommentRepoMockRecorder) VoteComment(ctx, user, comment, rate interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentByID", reflect.TypeOf((*MockCommentRepo)(nil).GetCommentByID), commentID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoteComment", reflect.TypeOf((*MockCommentRepo)(nil).VoteComment), ctx, user, comment, rate)
 }
@@ -3,13 +3,18 @@ package mongo
 import (
 	"context"
 	"redditclone/pkg/models"
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// deletedVoteAuthor заменяет автора в голосах удаленного пользователя.
+var deletedVoteAuthor = bson.M{"id": models.DeletedUserID, "username": models.DeletedUserLogin}
+
 type CommentMongoDBRepository struct {
 	DB *mongo.Collection
 }
@@ -20,7 +25,7 @@ func NewCommentMongoDBRepository(commentCollection *mongo.Collection) *CommentMo
 	}
 }
 
-func (repo *CommentMongoDBRepository) GetCommentByID(commentID string) (*models.Comment, error) {
+func (repo *CommentMongoDBRepository) GetCommentByID(ctx context.Context, commentID string) (*models.Comment, error) {
 	primitiveID, err := primitive.ObjectIDFromHex(commentID)
 	if err != nil {
 		return nil, models.ErrCorruptedCommentID
@@ -28,7 +33,7 @@ func (repo *CommentMongoDBRepository) GetCommentByID(commentID string) (*models.
 	filter := bson.M{"_id": primitiveID}
 	comment := models.Comment{}
 
-	err = repo.DB.FindOne(context.Background(), filter).Decode(&comment)
+	err = repo.DB.FindOne(ctx, filter).Decode(&comment)
 	if err != nil {
 		return nil, models.ErrNoComment
 	}
@@ -36,19 +41,25 @@ func (repo *CommentMongoDBRepository) GetCommentByID(commentID string) (*models.
 	return &comment, nil
 }
 
-func (repo *CommentMongoDBRepository) CreateComment(post *models.Post, user *models.User, commentText string) (*models.Comment, error) {
+func (repo *CommentMongoDBRepository) CreateComment(ctx context.Context, post *models.Post, user *models.User, commentText string) (*models.Comment, error) {
 	newCommentBSON := bson.M{
 		"_id":     primitive.NewObjectID(),
 		"text":    commentText,
 		"author":  user,
 		"created": time.Now(),
+		"score":   0,
+		"votes":   []*models.Vote{},
+		"post": models.PostRef{
+			ID:    post.ID,
+			Title: post.Title,
+		},
 	}
 	newCommentDoc, err := bson.Marshal(newCommentBSON)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = repo.DB.InsertOne(context.Background(), newCommentBSON)
+	_, err = repo.DB.InsertOne(ctx, newCommentBSON)
 	if err != nil {
 		return nil, err
 	}
@@ -62,10 +73,10 @@ func (repo *CommentMongoDBRepository) CreateComment(post *models.Post, user *mod
 	return newComment, nil
 }
 
-func (repo *CommentMongoDBRepository) DeleteComment(comment *models.Comment) error {
+func (repo *CommentMongoDBRepository) DeleteComment(ctx context.Context, comment *models.Comment) error {
 	filter := bson.M{"_id": comment.ID}
 
-	res, err := repo.DB.DeleteOne(context.Background(), filter)
+	res, err := repo.DB.DeleteOne(ctx, filter)
 	if err != nil {
 		return models.ErrDeleteComment
 	} else if res.DeletedCount == 0 {
@@ -74,3 +85,115 @@ func (repo *CommentMongoDBRepository) DeleteComment(comment *models.Comment) err
 
 	return nil
 }
+
+func (repo *CommentMongoDBRepository) GetCommentsByAuthor(ctx context.Context, username string, listOpts *models.ListOptions) ([]*models.Comment, error) {
+	comments := []*models.Comment{}
+
+	filter := bson.M{"author.username": username}
+	findOpts := options.Find().SetSort(bson.D{{Key: "created", Value: -1}})
+	if listOpts != nil {
+		if listOpts.Sort == models.SortTop {
+			findOpts.SetSort(bson.D{{Key: "score", Value: -1}, {Key: "created", Value: -1}})
+		}
+		if listOpts.Offset > 0 {
+			findOpts.SetSkip(int64(listOpts.Offset))
+		}
+		if listOpts.Limit > 0 {
+			findOpts.SetLimit(int64(listOpts.Limit))
+		}
+	}
+
+	cursor, err := repo.DB.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &comments)
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func (repo *CommentMongoDBRepository) CountCommentsByAuthor(ctx context.Context, username string) (int, error) {
+	count, err := repo.DB.CountDocuments(ctx, bson.M{"author.username": username})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+func (repo *CommentMongoDBRepository) VoteComment(ctx context.Context, user *models.User, comment *models.Comment, rate int) error {
+	if rate > 1 || rate < -1 {
+		return models.ErrUnrecognizedRate
+	}
+
+	voteIndex := slices.IndexFunc(comment.Votes, func(vote *models.Vote) bool {
+		return vote.Author.ID == user.ID
+	})
+
+	if voteIndex != -1 {
+		comment.Score -= comment.Votes[voteIndex].Vote
+		comment.Votes = slices.Delete(comment.Votes, voteIndex, voteIndex+1)
+	}
+
+	if rate == 1 || rate == -1 {
+		comment.Votes = append(comment.Votes, &models.Vote{
+			Author:   *user,
+			AuthorID: user.ID,
+			Vote:     rate,
+		})
+		comment.Score += rate
+	}
+
+	filter := bson.M{"_id": comment.ID}
+	res, err := repo.DB.UpdateOne(
+		ctx,
+		filter,
+		bson.M{"$set": bson.M{
+			"votes": comment.Votes,
+			"score": comment.Score,
+		}},
+	)
+	if err != nil {
+		return models.ErrUpdateComment
+	} else if res.MatchedCount == 0 {
+		return models.ErrNoComment
+	}
+
+	return nil
+}
+
+func (repo *CommentMongoDBRepository) AnonymizeAuthor(ctx context.Context, userID int) error {
+	_, err := repo.DB.UpdateMany(
+		ctx,
+		bson.M{"author.id": userID},
+		bson.M{"$set": bson.M{
+			"author.id":       models.DeletedUserID,
+			"author.username": models.DeletedUserLogin,
+		}},
+	)
+	if err != nil {
+		return models.ErrUpdateComment
+	}
+
+	// Голоса остаются в рейтинге, но в них не должно остаться ни id, ни логина автора.
+	_, err = repo.DB.UpdateMany(
+		ctx,
+		bson.M{"votes.user": userID},
+		bson.M{"$set": bson.M{
+			"votes.$[vote].user":   models.DeletedUserID,
+			"votes.$[vote].author": deletedVoteAuthor,
+		}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"vote.user": userID}},
+		}),
+	)
+	if err != nil {
+		return models.ErrUpdateComment
+	}
+
+	return nil
+}
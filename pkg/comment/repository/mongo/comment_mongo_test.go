@@ -0,0 +1,37 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"redditclone/pkg/comment/repository"
+	"redditclone/pkg/comment/repository/repotest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TestConformance гоняет общий набор проверок на настоящей базе, например:
+// MONGODB_TEST_URI="mongodb://127.0.0.1:27017" go test ./pkg/comment/repository/mongo
+func TestConformance(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("cant connect to mongo: %s", err)
+	}
+	defer client.Disconnect(ctx)
+
+	collection := client.Database("redditclone_test").Collection("comments")
+	repotest.TestCommentRepo(t, func(t *testing.T) repository.CommentRepo {
+		if err := collection.Drop(ctx); err != nil {
+			t.Fatalf("cant drop comments: %s", err)
+		}
+
+		return NewCommentMongoDBRepository(collection)
+	})
+}
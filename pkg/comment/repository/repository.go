@@ -1,10 +1,17 @@
 package repository
 
-import "redditclone/pkg/models"
+import (
+	"context"
+	"redditclone/pkg/models"
+)
 
 //go:generate mockgen -source=repository.go -destination=mock_repository/comment_mock.go -package=mock_repository MockCommentRepository
 type CommentRepo interface {
-	GetCommentByID(commentID string) (*models.Comment, error)
-	CreateComment(post *models.Post, user *models.User, commentText string) (*models.Comment, error)
-	DeleteComment(comment *models.Comment) error
+	GetCommentByID(ctx context.Context, commentID string) (*models.Comment, error)
+	CreateComment(ctx context.Context, post *models.Post, user *models.User, commentText string) (*models.Comment, error)
+	DeleteComment(ctx context.Context, comment *models.Comment) error
+	GetCommentsByAuthor(ctx context.Context, username string, opts *models.ListOptions) ([]*models.Comment, error)
+	CountCommentsByAuthor(ctx context.Context, username string) (int, error)
+	VoteComment(ctx context.Context, user *models.User, comment *models.Comment, rate int) error
+	AnonymizeAuthor(ctx context.Context, userID int) error
 }
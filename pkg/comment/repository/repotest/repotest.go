@@ -0,0 +1,212 @@
+// Package repotest проверяет, что реализация CommentRepo соблюдает контракт интерфейса.
+// Его запускает каждое хранилище: in-memory всегда, Mongo - при наличии тестовой базы.
+package repotest
+
+import (
+	"context"
+	"redditclone/pkg/comment/repository"
+	"redditclone/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	author = &models.User{ID: 1, Login: "alex12345"}
+	voter  = &models.User{ID: 2, Login: "bob"}
+	post   = &models.Post{ID: primitive.NewObjectID(), Title: "title"}
+)
+
+// TestCommentRepo прогоняет набор проверок; newRepo должен каждый раз возвращать пустое хранилище.
+func TestCommentRepo(t *testing.T, newRepo func(t *testing.T) repository.CommentRepo) {
+	ctx := context.Background()
+
+	t.Run("not found", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetCommentByID(ctx, "not an id")
+		assert.Equal(t, models.ErrCorruptedCommentID, err)
+		_, err = repo.GetCommentByID(ctx, primitive.NewObjectID().Hex())
+		assert.Equal(t, models.ErrNoComment, err)
+
+		missing := &models.Comment{ID: primitive.NewObjectID()}
+		assert.Equal(t, models.ErrNoComment, repo.DeleteComment(ctx, missing))
+		assert.Equal(t, models.ErrNoComment, repo.VoteComment(ctx, voter, missing, 1))
+
+		comments, err := repo.GetCommentsByAuthor(ctx, author.Login, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, comments)
+
+		count, err := repo.CountCommentsByAuthor(ctx, author.Login)
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		repo := newRepo(t)
+
+		comment, err := repo.CreateComment(ctx, post, author, "text")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.False(t, comment.ID.IsZero())
+		assert.Zero(t, comment.Score)
+		assert.Empty(t, comment.Votes)
+
+		found, err := repo.GetCommentByID(ctx, comment.ID.Hex())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "text", found.Text)
+		assert.Equal(t, author.ID, found.Author.ID)
+		assert.Equal(t, author.Login, found.Author.Login)
+		assert.Equal(t, post.ID, found.Post.ID)
+		assert.Equal(t, post.Title, found.Post.Title)
+		assert.WithinDuration(t, comment.Created, found.Created, time.Millisecond)
+	})
+
+	t.Run("votes", func(t *testing.T) {
+		repo := newRepo(t)
+		comment, _ := repo.CreateComment(ctx, post, author, "text")
+		reload := func() *models.Comment {
+			found, err := repo.GetCommentByID(ctx, comment.ID.Hex())
+			assert.NoError(t, err)
+			return found
+		}
+
+		assert.Equal(t, models.ErrUnrecognizedRate, repo.VoteComment(ctx, voter, reload(), -2))
+
+		assert.NoError(t, repo.VoteComment(ctx, voter, reload(), 1))
+		assert.NoError(t, repo.VoteComment(ctx, author, reload(), 1))
+		found := reload()
+		assert.Equal(t, 2, found.Score)
+		assert.Len(t, found.Votes, 2)
+
+		// Повторный голос заменяет прежний, а не добавляется к нему.
+		assert.NoError(t, repo.VoteComment(ctx, voter, reload(), -1))
+		assert.NoError(t, repo.VoteComment(ctx, voter, reload(), -1))
+		found = reload()
+		assert.Equal(t, 0, found.Score)
+		assert.Len(t, found.Votes, 2)
+
+		assert.NoError(t, repo.VoteComment(ctx, voter, reload(), 0))
+		found = reload()
+		assert.Equal(t, 1, found.Score)
+		assert.Len(t, found.Votes, 1)
+	})
+
+	t.Run("ordering and pagination", func(t *testing.T) {
+		repo := newRepo(t)
+
+		// Паузы нужны хранилищам, которые округляют время создания до миллисекунд.
+		oldest, _ := repo.CreateComment(ctx, post, author, "oldest")
+		time.Sleep(5 * time.Millisecond)
+		middle, _ := repo.CreateComment(ctx, post, author, "middle")
+		time.Sleep(5 * time.Millisecond)
+		newest, _ := repo.CreateComment(ctx, post, author, "newest")
+		repo.CreateComment(ctx, post, voter, "foreign")
+
+		found, _ := repo.GetCommentByID(ctx, oldest.ID.Hex())
+		repo.VoteComment(ctx, voter, found, 1)
+		found, _ = repo.GetCommentByID(ctx, newest.ID.Hex())
+		repo.VoteComment(ctx, voter, found, 1)
+
+		texts := func(comments []*models.Comment) []string {
+			result := []string{}
+			for _, comment := range comments {
+				result = append(result, comment.Text)
+			}
+			return result
+		}
+
+		byNew, err := repo.GetCommentsByAuthor(ctx, author.Login, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"newest", "middle", "oldest"}, texts(byNew))
+
+		byNew, err = repo.GetCommentsByAuthor(ctx, author.Login, &models.ListOptions{Sort: models.SortNew})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"newest", "middle", "oldest"}, texts(byNew))
+
+		// Среди комментариев с одинаковым рейтингом новые идут первыми.
+		byTop, err := repo.GetCommentsByAuthor(ctx, author.Login, &models.ListOptions{Sort: models.SortTop})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"newest", "oldest", "middle"}, texts(byTop))
+
+		page, err := repo.GetCommentsByAuthor(ctx, author.Login, &models.ListOptions{Sort: models.SortNew, Limit: 2, Offset: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"middle", "oldest"}, texts(page))
+
+		page, err = repo.GetCommentsByAuthor(ctx, author.Login, &models.ListOptions{Limit: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"newest"}, texts(page))
+
+		page, err = repo.GetCommentsByAuthor(ctx, author.Login, &models.ListOptions{Offset: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, page)
+
+		count, err := repo.CountCommentsByAuthor(ctx, author.Login)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+		assert.Equal(t, middle.ID, byNew[1].ID)
+	})
+
+	t.Run("anonymize author", func(t *testing.T) {
+		repo := newRepo(t)
+		own, _ := repo.CreateComment(ctx, post, author, "mine")
+		repo.CreateComment(ctx, post, voter, "theirs")
+
+		assert.NoError(t, repo.AnonymizeAuthor(ctx, author.ID))
+
+		found, _ := repo.GetCommentByID(ctx, own.ID.Hex())
+		assert.Equal(t, models.DeletedUserLogin, found.Author.Login)
+		assert.Equal(t, 0, found.Author.ID)
+
+		count, _ := repo.CountCommentsByAuthor(ctx, author.Login)
+		assert.Zero(t, count)
+		count, _ = repo.CountCommentsByAuthor(ctx, voter.Login)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("anonymize votes", func(t *testing.T) {
+		repo := newRepo(t)
+		theirs, _ := repo.CreateComment(ctx, post, voter, "theirs")
+
+		found, _ := repo.GetCommentByID(ctx, theirs.ID.Hex())
+		assert.NoError(t, repo.VoteComment(ctx, author, found, 1))
+		found, _ = repo.GetCommentByID(ctx, theirs.ID.Hex())
+		assert.NoError(t, repo.VoteComment(ctx, voter, found, 1))
+
+		assert.NoError(t, repo.AnonymizeAuthor(ctx, author.ID))
+
+		// Рейтинг не меняется, но ни id, ни логина удаленного пользователя в голосах нет.
+		found, _ = repo.GetCommentByID(ctx, theirs.ID.Hex())
+		assert.Equal(t, 2, found.Score)
+		if assert.Len(t, found.Votes, 2) {
+			assert.Equal(t, models.DeletedUserID, found.Votes[0].AuthorID)
+			assert.Equal(t, models.DeletedUserID, found.Votes[0].Author.ID)
+			assert.Equal(t, models.DeletedUserLogin, found.Votes[0].Author.Login)
+			assert.Equal(t, voter.ID, found.Votes[1].AuthorID)
+			assert.Equal(t, voter.Login, found.Votes[1].Author.Login)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		comment, _ := repo.CreateComment(ctx, post, author, "text")
+		other, _ := repo.CreateComment(ctx, post, author, "other")
+
+		assert.NoError(t, repo.DeleteComment(ctx, comment))
+		assert.Equal(t, models.ErrNoComment, repo.DeleteComment(ctx, comment))
+
+		_, err := repo.GetCommentByID(ctx, comment.ID.Hex())
+		assert.Equal(t, models.ErrNoComment, err)
+		assert.Equal(t, models.ErrNoComment, repo.VoteComment(ctx, voter, comment, 1))
+
+		comments, _ := repo.GetCommentsByAuthor(ctx, author.Login, nil)
+		if assert.Len(t, comments, 1) {
+			assert.Equal(t, other.ID, comments[0].ID)
+		}
+	})
+}
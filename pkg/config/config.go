@@ -0,0 +1,545 @@
+// Package config собирает настройки сервера из слоев: значения по умолчанию, YAML-файл,
+// переменные окружения и флаги командной строки. Каждый следующий слой важнее предыдущего.
+//
+// Ключ настройки един для всех слоев: поле MySQL.Host задается в YAML как MYSQL: {HOST: ...},
+// в окружении как MYSQL_HOST и флагом -mysql-host.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DefaultPath    = "config.yaml"
+	DefaultEnvPath = ".env"
+)
+
+const (
+	StorageDatabase = "database"
+	StorageMongo    = "mongo"
+	StorageMemory   = "memory"
+)
+
+const (
+	UserStoreMySQL    = "mysql"
+	UserStorePostgres = "postgres"
+	UserStoreSQLite   = "sqlite"
+)
+
+type Config struct {
+	StaticRoot string `yaml:"STATIC_ROOT"`
+	Port       int    `yaml:"PORT"`
+	PublicURL  string `yaml:"PUBLIC_URL"`
+
+	Server         ServerConfig  `yaml:"SERVER"`
+	StartupTimeout time.Duration `yaml:"STARTUP_TIMEOUT"`
+	// ReadinessTimeout ограничивает пинг зависимостей в /readyz.
+	ReadinessTimeout time.Duration `yaml:"READINESS_TIMEOUT"`
+
+	LogLevel  string `yaml:"LOG_LEVEL"`
+	LogFormat string `yaml:"LOG_FORMAT"`
+
+	TokenKey          string `yaml:"TOKEN_KEY" secret:"true"`
+	TOTPEncryptionKey string `yaml:"TOTP_ENCRYPTION_KEY" secret:"true"`
+
+	Storage        string `yaml:"STORAGE"`
+	UserStore      string `yaml:"USER_STORE"`
+	SQLitePath     string `yaml:"SQLITE_PATH"`
+	MigrateOnStart bool   `yaml:"MIGRATE_ON_START"`
+
+	MySQL    DatabaseConfig `yaml:"MYSQL"`
+	Postgres PostgresConfig `yaml:"POSTGRES"`
+	MongoDB  DatabaseConfig `yaml:"MONGODB"`
+	Redis    RedisConfig    `yaml:"REDIS"`
+
+	MailSender string     `yaml:"MAIL_SENDER"`
+	MailDir    string     `yaml:"MAIL_DIR"`
+	MailFrom   string     `yaml:"MAIL_FROM"`
+	SMTP       SMTPConfig `yaml:"SMTP"`
+
+	RequireVerifiedEmail bool   `yaml:"REQUIRE_VERIFIED_EMAIL"`
+	PasswordBlocklist    string `yaml:"PASSWORD_BLOCKLIST"`
+
+	RateLimitStore string                     `yaml:"RATE_LIMIT_STORE"`
+	RateLimits     map[string]RateLimitConfig `yaml:"RATE_LIMITS"`
+
+	Tracing TracingConfig `yaml:"TRACING"`
+	CORS    CORSConfig    `yaml:"CORS"`
+
+	BodyLimits BodyLimitsConfig `yaml:"BODY_LIMITS"`
+	PostCache  PostCacheConfig  `yaml:"POST_CACHE"`
+}
+
+type ServerConfig struct {
+	ReadTimeout  time.Duration `yaml:"READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"WRITE_TIMEOUT"`
+	IdleTimeout  time.Duration `yaml:"IDLE_TIMEOUT"`
+	// ShutdownTimeout - сколько ждать завершения начатых запросов после SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"SHUTDOWN_TIMEOUT"`
+}
+
+type DatabaseConfig struct {
+	User     string `yaml:"USER"`
+	Password string `yaml:"PASSWORD" secret:"true"`
+	Host     string `yaml:"HOST"`
+	Port     string `yaml:"PORT"`
+	Database string `yaml:"DATABASE"`
+}
+
+type PostgresConfig struct {
+	User     string `yaml:"USER"`
+	Password string `yaml:"PASSWORD" secret:"true"`
+	Host     string `yaml:"HOST"`
+	Port     string `yaml:"PORT"`
+	Database string `yaml:"DATABASE"`
+	SSLMode  string `yaml:"SSLMODE"`
+}
+
+type RedisConfig struct {
+	Host     string `yaml:"HOST"`
+	Port     string `yaml:"PORT"`
+	Database string `yaml:"DATABASE"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"HOST"`
+	Port     string `yaml:"PORT"`
+	User     string `yaml:"USER"`
+	Password string `yaml:"PASSWORD" secret:"true"`
+}
+
+type TracingConfig struct {
+	// Exporter - куда отправлять спаны: none, stdout или otlp.
+	Exporter string `yaml:"EXPORTER"`
+	// OTLPEndpoint - адрес коллектора OTLP/HTTP, например http://otel-collector:4318.
+	OTLPEndpoint string `yaml:"OTLP_ENDPOINT"`
+}
+
+// CORSConfig разрешает вызывать /api со страниц других доменов. Списки - через запятую;
+// пустой ALLOWED_ORIGINS выключает CORS.
+type CORSConfig struct {
+	// AllowedOrigins - точные origin вида https://app.example.com или * для любого.
+	AllowedOrigins   string        `yaml:"ALLOWED_ORIGINS"`
+	AllowedMethods   string        `yaml:"ALLOWED_METHODS"`
+	AllowedHeaders   string        `yaml:"ALLOWED_HEADERS"`
+	AllowCredentials bool          `yaml:"ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"MAX_AGE"`
+}
+
+// BodyLimitsConfig - наибольший размер тела запроса в байтах; больше - ответ 413.
+type BodyLimitsConfig struct {
+	Post    int `yaml:"POST"`
+	Comment int `yaml:"COMMENT"`
+	// Default - вход, регистрация, аккаунт и удаления: там тело - пара коротких полей.
+	Default int `yaml:"DEFAULT"`
+}
+
+// PostCacheConfig - кэш постов и лент в Redis; есть только при STORAGE: database.
+type PostCacheConfig struct {
+	Enabled bool          `yaml:"ENABLED"`
+	PostTTL time.Duration `yaml:"POST_TTL"`
+	ListTTL time.Duration `yaml:"LIST_TTL"`
+}
+
+type RateLimitConfig struct {
+	Rate   int           `yaml:"RATE"`
+	Period time.Duration `yaml:"PERIOD"`
+	Burst  int           `yaml:"BURST"`
+}
+
+// Default возвращает настройки для локального запуска из cmd/redditclone.
+// Секретов и адресов баз по умолчанию нет: их обязан задать оператор.
+func Default() *Config {
+	return &Config{
+		StaticRoot: "../../static",
+		Port:       8080,
+		PublicURL:  "http://127.0.0.1:8080",
+
+		Server: ServerConfig{
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 15 * time.Second,
+		},
+		StartupTimeout:   30 * time.Second,
+		ReadinessTimeout: 2 * time.Second,
+
+		LogLevel:  "info",
+		LogFormat: "text",
+
+		Storage:        StorageDatabase,
+		UserStore:      UserStoreMySQL,
+		SQLitePath:     "./redditclone.db",
+		MigrateOnStart: true,
+
+		MySQL:    DatabaseConfig{Port: "3306"},
+		Postgres: PostgresConfig{Port: "5432", SSLMode: "disable"},
+		MongoDB:  DatabaseConfig{Port: "27017"},
+		Redis:    RedisConfig{Port: "6379", Database: "0"},
+
+		MailSender: "log",
+		MailDir:    "./mail",
+		SMTP:       SMTPConfig{Port: "587"},
+
+		PasswordBlocklist: "./breached_passwords.txt",
+
+		RateLimitStore: "redis",
+		RateLimits: map[string]RateLimitConfig{
+			"read":           {Rate: 120, Period: time.Minute, Burst: 60},
+			"vote":           {Rate: 30, Period: time.Minute, Burst: 15},
+			"post_create":    {Rate: 5, Period: 10 * time.Minute, Burst: 3},
+			"comment_create": {Rate: 20, Period: 10 * time.Minute, Burst: 5},
+			"write":          {Rate: 30, Period: time.Minute, Burst: 10},
+			"auth":           {Rate: 20, Period: time.Minute, Burst: 10},
+			"account":        {Rate: 10, Period: time.Minute, Burst: 5},
+		},
+
+		Tracing: TracingConfig{Exporter: "none", OTLPEndpoint: "http://localhost:4318"},
+		CORS: CORSConfig{
+			AllowedMethods: "GET, POST, DELETE",
+			AllowedHeaders: "Authorization, Content-Type, X-Request-ID",
+			MaxAge:         10 * time.Minute,
+		},
+		BodyLimits: BodyLimitsConfig{Post: 64 << 10, Comment: 16 << 10, Default: 4 << 10},
+		PostCache:  PostCacheConfig{Enabled: true, PostTTL: 5 * time.Minute, ListTTL: 30 * time.Second},
+	}
+}
+
+// Load применяет слои поверх Default и проверяет результат. Кроме флагов настроек
+// понимает -config и -env; аргументы после флагов (например, "migrate up") возвращает как есть.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("redditclone", flag.ContinueOnError)
+	configPath := flags.String("config", DefaultPath, "path to the YAML config file")
+	envPath := flags.String("env", DefaultEnvPath, "path to the .env file, skipped if missing")
+
+	overrides := map[string]string{}
+	for _, s := range cfg.settings() {
+		flags.Var(&flagValue{key: s.key, overrides: overrides}, flagName(s.key), "overrides "+s.key)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	// Переменные из .env не перекрывают уже заданные в окружении.
+	if err := godotenv.Load(*envPath); err != nil && !(errors.Is(err, fs.ErrNotExist) && !explicit["env"]) {
+		return nil, nil, fmt.Errorf("reading %s: %w", *envPath, err)
+	}
+
+	if err := cfg.loadFile(*configPath); err != nil && !(errors.Is(err, fs.ErrNotExist) && !explicit["config"]) {
+		return nil, nil, fmt.Errorf("reading %s: %w", *configPath, err)
+	}
+
+	for _, s := range cfg.settings() {
+		if value, ok := os.LookupEnv(s.key); ok {
+			if err := s.set(value); err != nil {
+				return nil, nil, fmt.Errorf("environment variable %s: %w", s.key, err)
+			}
+		}
+	}
+
+	for _, s := range cfg.settings() {
+		if value, ok := overrides[s.key]; ok {
+			if err := s.set(value); err != nil {
+				return nil, nil, fmt.Errorf("flag -%s: %w", flagName(s.key), err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+// loadFile читает YAML строго: опечатка в ключе - ошибка, а не молча забытая настройка.
+func (cfg *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Строгий декодер не пишет в непустой словарь, поэтому лимиты по умолчанию
+	// убираем на время чтения и возвращаем те, что файл не переопределил.
+	defaultLimits := cfg.RateLimits
+	cfg.RateLimits = nil
+
+	decoder := yaml.NewDecoder(file)
+	decoder.SetStrict(true)
+	err = decoder.Decode(cfg)
+
+	if cfg.RateLimits == nil {
+		cfg.RateLimits = map[string]RateLimitConfig{}
+	}
+	for name, limit := range defaultLimits {
+		if _, ok := cfg.RateLimits[name]; !ok {
+			cfg.RateLimits[name] = limit
+		}
+	}
+
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// Validate собирает все ошибки сразу, чтобы оператор исправил конфиг за один заход.
+func (cfg *Config) Validate() error {
+	errs := []error{}
+	require := func(key, value string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", key))
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) bool {
+		for _, option := range allowed {
+			if value == option {
+				return true
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value))
+		return false
+	}
+
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be between 1 and 65535, got %d", cfg.Port))
+	}
+	positive := func(key string, value time.Duration) {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", key, value))
+		}
+	}
+	positive("SERVER_READ_TIMEOUT", cfg.Server.ReadTimeout)
+	positive("SERVER_WRITE_TIMEOUT", cfg.Server.WriteTimeout)
+	positive("SERVER_IDLE_TIMEOUT", cfg.Server.IdleTimeout)
+	positive("SERVER_SHUTDOWN_TIMEOUT", cfg.Server.ShutdownTimeout)
+	positive("STARTUP_TIMEOUT", cfg.StartupTimeout)
+	positive("READINESS_TIMEOUT", cfg.ReadinessTimeout)
+	oneOf("LOG_LEVEL", cfg.LogLevel, "debug", "info", "warn", "error")
+	oneOf("LOG_FORMAT", cfg.LogFormat, "text", "json")
+	require("STATIC_ROOT", cfg.StaticRoot)
+	require("PASSWORD_BLOCKLIST", cfg.PasswordBlocklist)
+	require("TOKEN_KEY", cfg.TokenKey)
+
+	if oneOf("STORAGE", cfg.Storage, StorageDatabase, StorageMongo, StorageMemory) && cfg.Storage != StorageMemory {
+		require("TOTP_ENCRYPTION_KEY", cfg.TOTPEncryptionKey)
+		require("MONGODB_HOST", cfg.MongoDB.Host)
+		require("MONGODB_DATABASE", cfg.MongoDB.Database)
+	}
+
+	if cfg.Storage == StorageDatabase {
+		require("REDIS_HOST", cfg.Redis.Host)
+
+		if oneOf("USER_STORE", cfg.UserStore, UserStoreMySQL, UserStorePostgres, UserStoreSQLite) {
+			switch cfg.UserStore {
+			case UserStoreMySQL:
+				require("MYSQL_HOST", cfg.MySQL.Host)
+				require("MYSQL_USER", cfg.MySQL.User)
+				require("MYSQL_DATABASE", cfg.MySQL.Database)
+			case UserStorePostgres:
+				require("POSTGRES_HOST", cfg.Postgres.Host)
+				require("POSTGRES_USER", cfg.Postgres.User)
+				require("POSTGRES_DATABASE", cfg.Postgres.Database)
+			case UserStoreSQLite:
+				require("SQLITE_PATH", cfg.SQLitePath)
+			}
+		}
+	}
+
+	if oneOf("MAIL_SENDER", cfg.MailSender, "log", "file", "smtp") {
+		switch cfg.MailSender {
+		case "file":
+			require("MAIL_DIR", cfg.MailDir)
+		case "smtp":
+			require("SMTP_HOST", cfg.SMTP.Host)
+			require("MAIL_FROM", cfg.MailFrom)
+		}
+	}
+
+	oneOf("RATE_LIMIT_STORE", cfg.RateLimitStore, "redis", StorageMemory)
+	for _, name := range sortedKeys(cfg.RateLimits) {
+		limit := cfg.RateLimits[name]
+		if limit.Rate <= 0 || limit.Period <= 0 || limit.Burst <= 0 {
+			errs = append(errs, fmt.Errorf("RATE_LIMITS.%s: RATE, PERIOD and BURST must be positive", name))
+		}
+	}
+
+	if oneOf("TRACING_EXPORTER", cfg.Tracing.Exporter, "none", "stdout", "otlp") && cfg.Tracing.Exporter == "otlp" {
+		require("TRACING_OTLP_ENDPOINT", cfg.Tracing.OTLPEndpoint)
+	}
+
+	if cfg.CORS.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("CORS_MAX_AGE must not be negative, got %s", cfg.CORS.MaxAge))
+	}
+	// Браузер не примет ответ с учетными данными, если разрешены все origin.
+	if cfg.CORS.AllowCredentials && slices.Contains(SplitList(cfg.CORS.AllowedOrigins), "*") {
+		errs = append(errs, errors.New("CORS_ALLOW_CREDENTIALS cannot be used with CORS_ALLOWED_ORIGINS *"))
+	}
+
+	bodyLimit := func(key string, value int) {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %d", key, value))
+		}
+	}
+	bodyLimit("BODY_LIMITS_POST", cfg.BodyLimits.Post)
+	bodyLimit("BODY_LIMITS_COMMENT", cfg.BodyLimits.Comment)
+	bodyLimit("BODY_LIMITS_DEFAULT", cfg.BodyLimits.Default)
+
+	if cfg.PostCache.Enabled {
+		positive("POST_CACHE_POST_TTL", cfg.PostCache.PostTTL)
+		positive("POST_CACHE_LIST_TTL", cfg.PostCache.ListTTL)
+	}
+
+	return errors.Join(errs...)
+}
+
+// String печатает итоговые настройки по одной на строку; секреты заменены звездочками.
+func (cfg *Config) String() string {
+	lines := []string{}
+	for _, s := range cfg.settings() {
+		value := fmt.Sprint(s.value.Interface())
+		if s.secret && value != "" {
+			value = "***"
+		}
+		lines = append(lines, s.key+"="+value)
+	}
+
+	for _, name := range sortedKeys(cfg.RateLimits) {
+		limit := cfg.RateLimits[name]
+		lines = append(lines, fmt.Sprintf("RATE_LIMITS.%s=RATE:%d PERIOD:%s BURST:%d", name, limit.Rate, limit.Period, limit.Burst))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// SplitList разбирает список через запятую, пропуская пустые элементы и пробелы вокруг них.
+func SplitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// setting - одна скалярная настройка: ее ключ и поле, в которое она пишется.
+type setting struct {
+	key    string
+	value  reflect.Value
+	secret bool
+}
+
+func (s setting) set(raw string) error {
+	switch {
+	case s.value.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", s.value.Type())
+	}
+
+	return nil
+}
+
+// settings обходит структуру по yaml-тегам. Словари (RATE_LIMITS) задаются только в YAML.
+func (cfg *Config) settings() []setting {
+	return collectSettings("", reflect.ValueOf(cfg).Elem())
+}
+
+func collectSettings(prefix string, v reflect.Value) []setting {
+	result := []setting{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := prefix + field.Tag.Get("yaml")
+
+		switch v.Field(i).Kind() {
+		case reflect.Struct:
+			result = append(result, collectSettings(key+"_", v.Field(i))...)
+		case reflect.Map:
+			continue
+		default:
+			result = append(result, setting{
+				key:    key,
+				value:  v.Field(i),
+				secret: field.Tag.Get("secret") == "true",
+			})
+		}
+	}
+
+	return result
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// flagValue только запоминает значение: флаги применяются последними, уже после YAML и окружения.
+type flagValue struct {
+	key       string
+	overrides map[string]string
+}
+
+func (f *flagValue) String() string {
+	if f == nil || f.overrides == nil {
+		return ""
+	}
+	return f.overrides[f.key]
+}
+
+func (f *flagValue) Set(value string) error {
+	f.overrides[f.key] = value
+	return nil
+}
+
+func sortedKeys(limits map[string]RateLimitConfig) []string {
+	keys := make([]string, 0, len(limits))
+	for name := range limits {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// validEnv задает обязательные настройки режима по умолчанию (MySQL, Mongo и Redis).
+func validEnv(t *testing.T) {
+	for key, value := range map[string]string{
+		"TOKEN_KEY":           "token key",
+		"TOTP_ENCRYPTION_KEY": "totp key",
+		"MYSQL_HOST":          "mysql",
+		"MYSQL_USER":          "user",
+		"MYSQL_DATABASE":      "db",
+		"MONGODB_HOST":        "mongo",
+		"MONGODB_DATABASE":    "db",
+		"REDIS_HOST":          "redis",
+	} {
+		t.Setenv(key, value)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("cant write %s: %s", name, err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	validEnv(t)
+	configPath := writeFile(t, "config.yaml", `
+PORT: 9000
+PUBLIC_URL: http://example.com
+MYSQL:
+  PORT: "3307"
+SERVER:
+  READ_TIMEOUT: 5s
+RATE_LIMITS:
+  read:
+    RATE: 1
+    PERIOD: 1s
+    BURST: 1
+`)
+	t.Setenv("PUBLIC_URL", "http://env.example.com")
+	t.Setenv("MYSQL_PORT", "3308")
+	t.Setenv("SERVER_SHUTDOWN_TIMEOUT", "1m")
+
+	cfg, args, err := Load([]string{"-config", configPath, "-mysql-port", "3309", "-startup-timeout", "2s", "migrate", "up"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"migrate", "up"}, args)
+	// YAML важнее значений по умолчанию.
+	assert.Equal(t, 9000, cfg.Port)
+	// Окружение важнее YAML.
+	assert.Equal(t, "http://env.example.com", cfg.PublicURL)
+	// Флаги важнее всего.
+	assert.Equal(t, "3309", cfg.MySQL.Port)
+	assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, time.Minute, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, 2*time.Second, cfg.StartupTimeout)
+	// Нетронутые настройки остаются по умолчанию, словари сливаются по ключам.
+	assert.Equal(t, "27017", cfg.MongoDB.Port)
+	assert.Equal(t, RateLimitConfig{Rate: 1, Period: time.Second, Burst: 1}, cfg.RateLimits["read"])
+	assert.Equal(t, Default().RateLimits["vote"], cfg.RateLimits["vote"])
+}
+
+func TestLoadFiles(t *testing.T) {
+	t.Run("dot env", func(t *testing.T) {
+		validEnv(t)
+		t.Setenv("TOKEN_KEY", "")
+		os.Unsetenv("TOKEN_KEY")
+		envPath := writeFile(t, ".env", "TOKEN_KEY=from dot env\nREDIS_PORT=6380\n")
+		t.Setenv("REDIS_PORT", "6381")
+
+		cfg, _, err := Load([]string{"-env", envPath, "-config", writeFile(t, "config.yaml", "")})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "from dot env", cfg.TokenKey)
+		// .env не перекрывает настоящее окружение.
+		assert.Equal(t, "6381", cfg.Redis.Port)
+	})
+
+	t.Run("missing default files", func(t *testing.T) {
+		validEnv(t)
+		wd, _ := os.Getwd()
+		os.Chdir(t.TempDir())
+		t.Cleanup(func() { os.Chdir(wd) })
+
+		_, _, err := Load(nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("missing explicit file", func(t *testing.T) {
+		validEnv(t)
+
+		_, _, err := Load([]string{"-config", filepath.Join(t.TempDir(), "nope.yaml")})
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+	})
+
+	t.Run("unknown yaml key", func(t *testing.T) {
+		validEnv(t)
+
+		_, _, err := Load([]string{"-config", writeFile(t, "config.yaml", "PROT: 8080\n")})
+		assert.Error(t, err)
+	})
+
+	t.Run("bad value", func(t *testing.T) {
+		validEnv(t)
+		t.Setenv("PORT", "eighty")
+
+		_, _, err := Load([]string{"-config", writeFile(t, "config.yaml", "")})
+		assert.ErrorContains(t, err, "PORT")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Port = 0
+	cfg.UserStore = "oracle"
+	cfg.MailSender = "smtp"
+	cfg.Server.ShutdownTimeout = 0
+	cfg.ReadinessTimeout = -time.Second
+	cfg.Tracing.Exporter = "jaeger"
+	cfg.LogFormat = "xml"
+
+	err := cfg.Validate()
+	if !assert.Error(t, err) {
+		return
+	}
+	for _, expected := range []string{"PORT", "TOKEN_KEY", "TOTP_ENCRYPTION_KEY", "MONGODB_HOST", "REDIS_HOST", "USER_STORE", "SMTP_HOST", "MAIL_FROM", "SERVER_SHUTDOWN_TIMEOUT", "READINESS_TIMEOUT", "TRACING_EXPORTER", "LOG_FORMAT"} {
+		assert.Contains(t, err.Error(), expected)
+	}
+
+	memory := Default()
+	memory.Storage = StorageMemory
+	memory.TokenKey = "token key"
+	assert.NoError(t, memory.Validate())
+
+	sqlite := Default()
+	sqlite.UserStore = UserStoreSQLite
+	sqlite.TokenKey = "token key"
+	sqlite.TOTPEncryptionKey = "totp key"
+	sqlite.MongoDB.Host, sqlite.MongoDB.Database = "mongo", "db"
+	sqlite.Redis.Host = "redis"
+	assert.NoError(t, sqlite.Validate())
+
+	sqlite.Tracing = TracingConfig{Exporter: "otlp"}
+	assert.ErrorContains(t, sqlite.Validate(), "TRACING_OTLP_ENDPOINT")
+	sqlite.Tracing = Default().Tracing
+
+	sqlite.CORS.AllowedOrigins = "https://app.example.com, *"
+	sqlite.CORS.AllowCredentials = true
+	assert.ErrorContains(t, sqlite.Validate(), "CORS_ALLOW_CREDENTIALS")
+	sqlite.CORS = Default().CORS
+
+	sqlite.BodyLimits.Comment = 0
+	assert.ErrorContains(t, sqlite.Validate(), "BODY_LIMITS_COMMENT")
+	sqlite.BodyLimits = Default().BodyLimits
+
+	sqlite.PostCache.ListTTL = 0
+	assert.ErrorContains(t, sqlite.Validate(), "POST_CACHE_LIST_TTL")
+	sqlite.PostCache.Enabled = false
+	assert.NoError(t, sqlite.Validate())
+	sqlite.PostCache = Default().PostCache
+
+	sqlite.RateLimits["read"] = RateLimitConfig{Rate: 1}
+	assert.ErrorContains(t, sqlite.Validate(), "RATE_LIMITS.read")
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"GET", "POST"}, SplitList(" GET,POST, "))
+	assert.Empty(t, SplitList(""))
+}
+
+func TestString(t *testing.T) {
+	cfg := Default()
+	cfg.TokenKey = "super secret"
+	cfg.MySQL.Password = "hunter2"
+	cfg.MySQL.Host = "mysql"
+
+	printed := cfg.String()
+	assert.NotContains(t, printed, "super secret")
+	assert.NotContains(t, printed, "hunter2")
+	assert.Contains(t, printed, "TOKEN_KEY=***")
+	assert.Contains(t, printed, "MYSQL_PASSWORD=***")
+	assert.Contains(t, printed, "MYSQL_HOST=mysql")
+	// Пустой секрет печатается пустым: видно, что его забыли задать.
+	assert.Contains(t, strings.Split(printed, "\n"), "TOTP_ENCRYPTION_KEY=")
+	assert.Contains(t, printed, "RATE_LIMITS.read=RATE:120 PERIOD:1m0s BURST:60")
+}
@@ -0,0 +1,66 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"redditclone/pkg/health"
+	"redditclone/tools"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type HealthHandler struct {
+	Checks []health.Check
+	// Timeout ограничивает одну проверку готовности: зонд оркестратора не должен ждать дольше.
+	Timeout time.Duration
+}
+
+type readinessResponse struct {
+	Status string                    `json:"status"`
+	Checks map[string]*health.Result `json:"checks"`
+}
+
+// Live отвечает, пока процесс способен обслуживать запросы; зависимости не проверяются,
+// иначе падение базы перезапускало бы все реплики разом.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, map[string]string{"status": health.StatusUp}, "HealthHandler.Live")
+}
+
+// Ready возвращает 503, если хоть одна зависимость не ответила, и статус каждой из них.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	response := &readinessResponse{
+		Status: health.StatusUp,
+		Checks: health.Run(r.Context(), h.Checks, h.Timeout),
+	}
+
+	status := http.StatusOK
+	for name, result := range response.Checks {
+		if result.Status != health.StatusUp {
+			response.Status = health.StatusDown
+			status = http.StatusServiceUnavailable
+
+			tools.LoggerFrom(r.Context()).WithFields(logrus.Fields{
+				"method":     "HealthHandler.Ready",
+				"dependency": name,
+			}).Warn(result.Error)
+		}
+	}
+
+	writeJSON(w, r, status, response, "HealthHandler.Ready")
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, body interface{}, method string) {
+	response, err := json.Marshal(body)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), method)
+		return
+	}
+
+	w.WriteHeader(status)
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), method)
+		return
+	}
+}
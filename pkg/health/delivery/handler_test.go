@@ -0,0 +1,97 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/pkg/health"
+	"redditclone/tools"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func up(ctx context.Context) error {
+	return nil
+}
+
+func TestHealthHandlerLive(t *testing.T) {
+	tools.Init()
+
+	healthHandler := &HealthHandler{
+		Checks: []health.Check{{Name: "mysql", Ping: func(ctx context.Context) error {
+			t.Error("liveness must not ping dependencies")
+			return nil
+		}}},
+		Timeout: time.Second,
+	}
+
+	w := httptest.NewRecorder()
+	healthHandler.Live(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"up"}`, w.Body.String())
+}
+
+func TestHealthHandlerReady(t *testing.T) {
+	tools.Init()
+
+	ready := func(checks ...health.Check) (int, *readinessResponse) {
+		healthHandler := &HealthHandler{Checks: checks, Timeout: 50 * time.Millisecond}
+
+		w := httptest.NewRecorder()
+		healthHandler.Ready(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		response := &readinessResponse{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), response))
+		return w.Code, response
+	}
+
+	t.Run("all up", func(t *testing.T) {
+		status, response := ready(health.Check{Name: "mysql", Ping: up}, health.Check{Name: "redis", Ping: up})
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, health.StatusUp, response.Status)
+		assert.Len(t, response.Checks, 2)
+		assert.Equal(t, health.StatusUp, response.Checks["redis"].Status)
+		assert.Empty(t, response.Checks["redis"].Error)
+	})
+
+	t.Run("no dependencies", func(t *testing.T) {
+		status, response := ready()
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, response.Checks)
+	})
+
+	t.Run("one down", func(t *testing.T) {
+		status, response := ready(
+			health.Check{Name: "mysql", Ping: up},
+			health.Check{Name: "redis", Ping: func(ctx context.Context) error {
+				return errors.New("connection refused")
+			}},
+		)
+
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, health.StatusDown, response.Status)
+		assert.Equal(t, health.StatusUp, response.Checks["mysql"].Status)
+		assert.Equal(t, health.StatusDown, response.Checks["redis"].Status)
+		assert.Equal(t, "connection refused", response.Checks["redis"].Error)
+	})
+
+	t.Run("hanging dependency times out", func(t *testing.T) {
+		start := time.Now()
+		status, response := ready(health.Check{Name: "mongo", Ping: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, health.StatusDown, response.Checks["mongo"].Status)
+		assert.GreaterOrEqual(t, response.Checks["mongo"].LatencyMs, float64(50))
+	})
+}
@@ -0,0 +1,60 @@
+// Package health проверяет, отвечают ли внешние зависимости сервера.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check - одна зависимость: MySQL, Mongo, Redis.
+type Check struct {
+	Name string
+	Ping func(ctx context.Context) error
+}
+
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Run пингует все зависимости параллельно, так что общий ответ не дольше timeout.
+// Зависшая зависимость получает отмену контекста и считается недоступной.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) map[string]*Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	results := make(map[string]*Result, len(checks))
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check.Ping(ctx)
+			result := &Result{
+				Status:    StatusUp,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	return results
+}
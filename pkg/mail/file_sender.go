@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type FileSender struct {
+	Dir string
+	mu  *sync.Mutex
+}
+
+func NewFileSender(dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileSender{
+		Dir: dir,
+		mu:  &sync.Mutex{},
+	}, nil
+}
+
+func (s *FileSender) Send(msg *Message) error {
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s\r\n", msg.To, msg.Subject, msg.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fileName := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+
+	return os.WriteFile(filepath.Join(s.Dir, fileName), []byte(content), 0o644)
+}
@@ -0,0 +1,22 @@
+package mail
+
+import (
+	"redditclone/tools"
+
+	"github.com/sirupsen/logrus"
+)
+
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(msg *Message) error {
+	tools.Logger.WithFields(logrus.Fields{
+		"to":      msg.To,
+		"subject": msg.Subject,
+	}).Info(msg.Body)
+
+	return nil
+}
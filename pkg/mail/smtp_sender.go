@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPSender struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func NewSMTPSender(host, port, username, password, from string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPSender{
+		Addr: net.JoinHostPort(host, port),
+		From: from,
+		Auth: auth,
+	}
+}
+
+func (s *SMTPSender) Send(msg *Message) error {
+	headers := []string{
+		"From: " + s.From,
+		"To: " + msg.To,
+		"Subject: " + msg.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	content := fmt.Sprintf("%s\r\n\r\n%s\r\n", strings.Join(headers, "\r\n"), msg.Body)
+
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{msg.To}, []byte(content))
+}
@@ -0,0 +1,122 @@
+// Package metrics собирает метрики Prometheus: HTTP-запросы, вызовы хранилищ и бизнес-события.
+// Метрики регистрируются в реестре по умолчанию, их отдает Handler.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "redditclone"
+
+var (
+	// Метка route - шаблон маршрута mux (/api/post/{postID}), а не сам путь: иначе каждый пост стал бы новым рядом.
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	PanicsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_panics_recovered_total",
+		Help:      "Handler panics turned into 500 responses.",
+	})
+
+	RepositoryCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_call_duration_seconds",
+		Help:      "Repository call latency by repository and method.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"repository", "method"})
+
+	// Ошибками считаются и ожидаемые ответы вроде "пользователь не найден": их доля видна по методу.
+	RepositoryCallErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repository_call_errors_total",
+		Help:      "Repository calls that returned an error, by repository and method.",
+	}, []string{"repository", "method"})
+
+	// result: hit, miss или error - Redis недоступен, и запрос ушел в хранилище.
+	CacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache and result (hit, miss or error).",
+	}, []string{"cache", "result"})
+
+	SignupsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signups_total",
+		Help:      "Registered users.",
+	})
+
+	LoginsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Successful logins, including ones completed with a second factor.",
+	})
+
+	FailedLoginsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failed_logins_total",
+		Help:      "Logins rejected because of a wrong password or second factor.",
+	})
+
+	PostsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_created_total",
+		Help:      "Created posts.",
+	})
+
+	CommentsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_created_total",
+		Help:      "Created comments.",
+	})
+
+	VotesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "votes_total",
+		Help:      "Votes by target (post or comment) and vote (up, down or unvote).",
+	}, []string{"target", "vote"})
+)
+
+// TrackRepositoryCall засекает вызов хранилища; возвращенную функцию вызывают в defer
+// с адресом именованной ошибки: defer metrics.TrackRepositoryCall("post", "GetPostByID")(&err).
+func TrackRepositoryCall(repository, method string) func(err *error) {
+	start := time.Now()
+
+	return func(err *error) {
+		RepositoryCallDuration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+		if *err != nil {
+			RepositoryCallErrors.WithLabelValues(repository, method).Inc()
+		}
+	}
+}
+
+// CountVote учитывает голос с rate хранилищ: 1 - за, -1 - против, 0 - отмена голоса.
+func CountVote(target string, rate int) {
+	vote := "unvote"
+	if rate > 0 {
+		vote = "up"
+	} else if rate < 0 {
+		vote = "down"
+	}
+
+	VotesTotal.WithLabelValues(target, vote).Inc()
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
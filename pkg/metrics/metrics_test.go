@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackRepositoryCall(t *testing.T) {
+	call := func(err error) (result error) {
+		defer TrackRepositoryCall("test", "Call")(&result)
+		return err
+	}
+
+	errorsBefore := testutil.ToFloat64(RepositoryCallErrors.WithLabelValues("test", "Call"))
+
+	assert.NoError(t, call(nil))
+	assert.Error(t, call(errors.New("boom")))
+
+	assert.Equal(t, errorsBefore+1, testutil.ToFloat64(RepositoryCallErrors.WithLabelValues("test", "Call")))
+	assert.Equal(t, 1, testutil.CollectAndCount(RepositoryCallDuration, "redditclone_repository_call_duration_seconds"))
+}
+
+func TestCountVote(t *testing.T) {
+	CountVote("post", 1)
+	CountVote("post", -1)
+	CountVote("post", -1)
+	CountVote("comment", 0)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(VotesTotal.WithLabelValues("post", "up")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(VotesTotal.WithLabelValues("post", "down")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(VotesTotal.WithLabelValues("comment", "unvote")))
+}
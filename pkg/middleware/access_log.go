@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"redditclone/tools"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Чужой ID принимается, только если он короткий и без пробелов и управляющих символов:
+// иначе клиент мог бы подделывать строки лога.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// accessRecord собирает то, что узнают обработчики глубже по цепочке: ID пользователя
+// ставит ValidateJWTToken, а строку лога пишет AccessLog уже после ответа.
+type accessRecord struct {
+	userID int
+}
+
+type accessRecordKey struct{}
+
+// AccessLog присваивает запросу X-Request-ID (или берет присланный клиентом), кладет в контекст
+// логгер с этим ID и после ответа пишет одну строку лога на запрос. Подключается через router.Use
+// после Tracing, чтобы в строку попал и trace_id.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		fields := logrus.Fields{"request_id": requestID}
+		if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.IsValid() {
+			fields["trace_id"] = spanContext.TraceID().String()
+		}
+		logger := tools.Logger.WithFields(fields)
+
+		record := &accessRecord{}
+		ctx := tools.WithLogger(r.Context(), logger)
+		ctx = context.WithValue(ctx, accessRecordKey{}, record)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		// Строка пишется и при панике: запрос, уронивший обработчик, должен остаться в логе.
+		// Саму панику перехватывает и отвечает 500 Recover снаружи роутера.
+		defer func() {
+			if !completed {
+				rec.status = http.StatusInternalServerError
+			}
+
+			// Ключ method уже занят именем обработчика в JSONError, поэтому HTTP-метод - http_method.
+			entry := logger.WithFields(logrus.Fields{
+				"http_method": r.Method,
+				"route":       routeTemplate(r),
+				"path":        r.URL.Path,
+				"status":      rec.status,
+				"latency_ms":  float64(time.Since(start).Microseconds()) / 1000,
+				"bytes":       rec.bytes,
+				"remote_ip":   tools.ClientIP(r),
+			})
+			if record.userID != 0 {
+				entry = entry.WithField("user_id", record.userID)
+			}
+			entry.Info("request")
+		}()
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		completed = true
+	})
+}
+
+// setAccessUserID сообщает AccessLog, от чьего имени выполнен запрос, и добавляет
+// user_id в логгер запроса.
+func setAccessUserID(ctx context.Context, userID int) context.Context {
+	if record, ok := ctx.Value(accessRecordKey{}).(*accessRecord); ok {
+		record.userID = userID
+	}
+
+	return tools.WithLogger(ctx, tools.LoggerFrom(ctx).WithField("user_id", userID))
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand на поддерживаемых системах не отказывает; время хотя бы различает запросы.
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+
+	return hex.EncodeToString(buf)
+}
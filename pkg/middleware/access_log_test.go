@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/pkg/session/repository/memory"
+	"redditclone/tools"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	tools.Init()
+	hook := test.NewLocal(tools.Logger)
+
+	tokenKey := []byte("test key")
+	sessions := memory.NewSessionMemoryManager()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user": map[string]interface{}{"id": "7", "username": "alex12345"},
+	}).SignedString(tokenKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, sessions.Create(context.Background(), token, 7))
+
+	router := mux.NewRouter()
+	router.Use(AccessLog)
+	router.HandleFunc("/api/posts/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}).Methods("GET")
+	router.Handle("/api/post/{postID}", ValidateJWTToken(sessions, tokenKey, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tools.JSONError(w, r, http.StatusInternalServerError, "mongo is down", "PostRepo.GetPostByID")
+	}))).Methods("DELETE")
+
+	t.Run("generates request id", func(t *testing.T) {
+		hook.Reset()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/posts/", nil))
+
+		requestID := w.Header().Get(RequestIDHeader)
+		assert.Len(t, requestID, 32)
+
+		entry := hook.LastEntry()
+		if !assert.NotNil(t, entry) {
+			return
+		}
+		assert.Equal(t, logrus.InfoLevel, entry.Level)
+		assert.Equal(t, requestID, entry.Data["request_id"])
+		assert.Equal(t, "/api/posts/", entry.Data["route"])
+		assert.Equal(t, "GET", entry.Data["http_method"])
+		assert.Equal(t, http.StatusOK, entry.Data["status"])
+		assert.Equal(t, 2, entry.Data["bytes"])
+		assert.Contains(t, entry.Data, "latency_ms")
+		assert.NotContains(t, entry.Data, "user_id")
+	})
+
+	t.Run("propagates request id and user", func(t *testing.T) {
+		hook.Reset()
+		r := httptest.NewRequest("DELETE", "/api/post/1", nil)
+		r.Header.Set(RequestIDHeader, "req-42")
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, "req-42", w.Header().Get(RequestIDHeader))
+		entries := hook.AllEntries()
+		if !assert.Len(t, entries, 2) {
+			return
+		}
+
+		// Ошибка обработчика и строка доступа связаны одним request_id и user_id.
+		assert.Equal(t, "mongo is down", entries[0].Message)
+		assert.Equal(t, "PostRepo.GetPostByID", entries[0].Data["method"])
+		for _, entry := range entries {
+			assert.Equal(t, "req-42", entry.Data["request_id"])
+			assert.Equal(t, 7, entry.Data["user_id"])
+		}
+		assert.Equal(t, "/api/post/{postID}", entries[1].Data["route"])
+		assert.Equal(t, http.StatusInternalServerError, entries[1].Data["status"])
+	})
+
+	t.Run("replaces unsafe request id", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/api/posts/", nil)
+		r.Header.Set(RequestIDHeader, "fake\nlevel=error")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		assert.Len(t, w.Header().Get(RequestIDHeader), 32)
+	})
+}
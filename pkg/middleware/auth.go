@@ -4,8 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"os"
-	"redditclone/pkg/session/repository/redis"
+	"redditclone/pkg/session/repository"
 	"redditclone/tools"
 	"strconv"
 	"strings"
@@ -17,54 +16,67 @@ type contextKey string
 
 const UserIDContextKey contextKey = "user_id"
 
-func ValidateJWTToken(repo *redis.SessionRedisManager, next http.Handler) http.Handler {
+type TokenUser struct {
+	Username string `json:"username"`
+	ID       string `json:"id"`
+}
+
+// UserClaims - содержимое токена сессии. Фронтенд читает из него user, поэтому формат
+// ({"user": {"username", "id"}, "iat", "exp"}) менять нельзя.
+type UserClaims struct {
+	User TokenUser `json:"user"`
+	jwt.StandardClaims
+}
+
+func ValidateJWTToken(repo repository.SessionManager, tokenKey []byte, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenKey := []byte(os.Getenv("TOKEN_KEY"))
 		tokenString := r.Header.Get("Authorization")
 		if tokenString == "" {
-			tools.JSONError(w, http.StatusUnauthorized, "missing token", "middleware.ValidateJWTToken")
+			tools.JSONError(w, r, http.StatusUnauthorized, "missing token", "middleware.ValidateJWTToken")
 			return
 		}
 
 		fieldParts := strings.Split(tokenString, " ")
 		if len(fieldParts) != 2 || fieldParts[0] != "Bearer" {
-			tools.JSONError(w, http.StatusUnauthorized, "bad token format", "middleware.ValidateJWTToken")
+			tools.JSONError(w, r, http.StatusUnauthorized, "bad token format", "middleware.ValidateJWTToken")
 			return
 		}
 		pureToken := fieldParts[1]
 
-		token, err := jwt.Parse(pureToken, func(token *jwt.Token) (interface{}, error) {
+		claims := &UserClaims{}
+		token, err := jwt.ParseWithClaims(pureToken, claims, func(token *jwt.Token) (interface{}, error) {
 			method, ok := token.Method.(*jwt.SigningMethodHMAC)
 			if !ok || method.Alg() != "HS256" {
 				return nil, errors.New("bad sign method")
 			}
 			return tokenKey, nil
 		})
-		if err != nil || !token.Valid {
-			tools.JSONError(w, http.StatusUnauthorized, err.Error()+" | bad token", "middleware.ValidateJWTToken")
+		if err != nil {
+			tools.JSONError(w, r, http.StatusUnauthorized, err.Error()+" | bad token", "middleware.ValidateJWTToken")
 			return
-		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			tools.JSONError(w, http.StatusUnauthorized, "no payload", "middleware.ValidateJWTToken")
+		} else if !token.Valid {
+			tools.JSONError(w, r, http.StatusUnauthorized, "bad token", "middleware.ValidateJWTToken")
 			return
 		}
 
-		claimsUser := claims["user"].(map[string]interface{})
-		userIDString := claimsUser["id"].(string)
-		userID, err := strconv.Atoi(userIDString)
+		userID, err := strconv.Atoi(claims.User.ID)
 		if err != nil {
-			tools.JSONError(w, http.StatusInternalServerError, "type cast error", "middleware.ValidateJWTToken")
+			tools.JSONError(w, r, http.StatusUnauthorized, "no payload", "middleware.ValidateJWTToken")
 			return
 		}
-		_, err = repo.Check(userID)
+		session, err := repo.Check(r.Context(), userID)
 		if err != nil {
-			tools.JSONError(w, http.StatusUnauthorized, "no session", "SessionRedisManager.Check")
+			tools.JSONError(w, r, http.StatusUnauthorized, "no session", "SessionManager.Check")
+			return
+		}
+
+		if session.JWT != pureToken {
+			tools.JSONError(w, r, http.StatusUnauthorized, "session revoked", "middleware.ValidateJWTToken")
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), UserIDContextKey, userID)
+		ctx = setAccessUserID(ctx, userID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
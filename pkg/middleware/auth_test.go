@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/pkg/session/repository/memory"
+	"redditclone/tools"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJWTToken(t *testing.T) {
+	tools.Init()
+
+	tokenKey := []byte("test key")
+	sessions := memory.NewSessionMemoryManager()
+	handler := ValidateJWTToken(sessions, tokenKey, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, 7, r.Context().Value(UserIDContextKey))
+	}))
+
+	sign := func(claims jwt.Claims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(tokenKey)
+		assert.NoError(t, err)
+		return token
+	}
+	request := func(token string) int {
+		r := httptest.NewRequest("GET", "/api/posts/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	valid := sign(&UserClaims{User: TokenUser{Username: "alex12345", ID: "7"}})
+	assert.NoError(t, sessions.Create(context.Background(), valid, 7))
+	assert.Equal(t, http.StatusOK, request(valid))
+
+	// Подписанные, но неверно устроенные токены отклоняются, а не роняют обработчик.
+	for name, claims := range map[string]jwt.MapClaims{
+		"user is a string":  {"user": "alex12345"},
+		"no user":           {"sub": "7"},
+		"numeric id":        {"user": map[string]interface{}{"id": 7}},
+		"non numeric id":    {"user": map[string]interface{}{"id": "seven"}},
+		"expired":           {"user": map[string]interface{}{"id": "7"}, "exp": 1},
+		"exp is not a date": {"user": map[string]interface{}{"id": "7"}, "exp": "tomorrow"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusUnauthorized, request(sign(claims)))
+		})
+	}
+}
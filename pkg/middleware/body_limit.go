@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+	"redditclone/tools"
+)
+
+// LimitBody ограничивает тело запроса maxBytes байтами. Заявленный Content-Length сверх лимита
+// отклоняется сразу, а тело без длины (chunked) обрывается при чтении: обработчик получит
+// ошибку, по которой tools.BodyTooLarge вернет true, и ответит 413.
+func LimitBody(maxBytes int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			tools.JSONError(w, r, http.StatusRequestEntityTooLarge, "request body is too large", "middleware.LimitBody")
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
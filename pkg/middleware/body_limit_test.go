@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/tools"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitBody(t *testing.T) {
+	tools.Init()
+
+	handler := LimitBody(8, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		if tools.BodyTooLarge(err) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		assert.NoError(t, err)
+	}))
+
+	serve := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(httptest.NewRequest("POST", "/api/posts", strings.NewReader("12345678"))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(httptest.NewRequest("POST", "/api/posts", strings.NewReader("123456789"))))
+
+	// Длина не заявлена - лимит срабатывает при чтении.
+	r := httptest.NewRequest("POST", "/api/posts", io.MultiReader(strings.NewReader("12345"), strings.NewReader("67890")))
+	r.ContentLength = -1
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(r))
+}
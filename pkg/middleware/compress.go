@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Ответ короче этого порога не сжимаем: заголовки и служебные байты кодека съедят выигрыш.
+const compressMinSize = 1024
+
+const (
+	encodingBrotli   = "br"
+	encodingGzip     = "gzip"
+	encodingIdentity = "identity"
+)
+
+var (
+	gzipWriters   = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+	brotliWriters = sync.Pool{New: func() interface{} { return brotli.NewWriterLevel(io.Discard, 5) }}
+)
+
+// Compress сжимает ответ в brotli или gzip, смотря что клиент перечислил в Accept-Encoding.
+// Сжатое представление побайтно отличается от исходного, поэтому сильный ETag обработчика
+// становится слабым, как это делает nginx: If-None-Match сравнивает ETag слабо и по-прежнему
+// дает 304.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == encodingIdentity || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		// Не в defer: при панике накопленное начало ответа не должно уйти клиенту со статусом 200,
+		// ответ 500 напишет Recover.
+		cw.Close()
+	})
+}
+
+// negotiateEncoding выбирает кодек с наибольшим q; при равенстве brotli предпочтительнее.
+func negotiateEncoding(header string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		weight := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		weights[coding] = weight
+	}
+
+	best, bestWeight := encodingIdentity, 0.0
+	for _, coding := range []string{encodingBrotli, encodingGzip} {
+		weight, ok := weights[coding]
+		if !ok {
+			weight = weights["*"]
+		}
+		if weight > bestWeight {
+			best, bestWeight = coding, weight
+		}
+	}
+
+	return best
+}
+
+// compressWriter копит начало ответа, пока не станет ясно, стоит ли его сжимать:
+// заголовки уходят клиенту только после этого решения.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+
+	buf         []byte
+	wroteHeader bool
+	encoder     io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.status = status
+	// У ответов без тела решать нечего.
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < http.StatusOK {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.wroteHeader {
+		if cw.encoder != nil {
+			return cw.encoder.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) < compressMinSize {
+		return len(p), nil
+	}
+
+	cw.start(cw.compressible())
+	if err := cw.flushBuffer(); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close дописывает то, что осталось в буфере, и закрывает кодек.
+func (cw *compressWriter) Close() error {
+	if !cw.wroteHeader {
+		cw.start(len(cw.buf) >= compressMinSize && cw.compressible())
+	}
+	if err := cw.flushBuffer(); err != nil {
+		return err
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	switch encoder := cw.encoder.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(encoder)
+	case *brotli.Writer:
+		brotliWriters.Put(encoder)
+	}
+	cw.encoder = nil
+
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	if cw.status != http.StatusOK || header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	// Обработчики пишут JSON, не выставляя Content-Type. Определяем его по несжатым байтам:
+	// после сжатия net/http угадал бы архив.
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf)
+		header.Set("Content-Type", contentType)
+	}
+
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "javascript") ||
+		strings.Contains(contentType, "xml")
+}
+
+func (cw *compressWriter) start(compress bool) {
+	cw.wroteHeader = true
+	header := cw.Header()
+
+	// Клиент, который принимает сжатие, получает слабый ETag и в 304, и в несжатом ответе:
+	// так валидатор одного представления не меняется от размера тела.
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+
+	if compress {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+
+		switch cw.encoding {
+		case encodingBrotli:
+			encoder := brotliWriters.Get().(*brotli.Writer)
+			encoder.Reset(cw.ResponseWriter)
+			cw.encoder = encoder
+		case encodingGzip:
+			encoder := gzipWriters.Get().(*gzip.Writer)
+			encoder.Reset(cw.ResponseWriter)
+			cw.encoder = encoder
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func (cw *compressWriter) flushBuffer() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
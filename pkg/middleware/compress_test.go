@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/tools"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, expected := range map[string]string{
+		"":                          encodingIdentity,
+		"gzip, deflate, br":         encodingBrotli,
+		"gzip":                      encodingGzip,
+		"br;q=0.5, gzip;q=0.8":      encodingGzip,
+		"br;q=0, gzip;q=0":          encodingIdentity,
+		"*":                         encodingBrotli,
+		"*;q=0.1, br;q=0":           encodingGzip,
+		"deflate, identity":         encodingIdentity,
+		"GZIP;Q=1":                  encodingGzip,
+		"br;q=broken, gzip;q=0.001": encodingGzip,
+	} {
+		assert.Equal(t, expected, negotiateEncoding(header), header)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	listing := `[` + strings.Repeat(`{"title":"some title","category":"news"},`, 100) + `{}]`
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/posts/":
+			tools.WriteCacheableJSON(w, r, []byte(listing))
+		case "/api/user/alex12345/about":
+			w.Write([]byte(`{"username":"alex12345"}`))
+		}
+	}))
+
+	serve := func(path, acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("brotli", func(t *testing.T) {
+		w := serve("/api/posts/", "gzip, br", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+		assert.Less(t, w.Body.Len(), len(listing))
+
+		body, err := io.ReadAll(brotli.NewReader(w.Body))
+		assert.NoError(t, err)
+		assert.Equal(t, listing, string(body))
+	})
+
+	t.Run("gzip and conditional request", func(t *testing.T) {
+		w := serve("/api/posts/", "gzip", "")
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		etag := w.Header().Get("ETag")
+		assert.True(t, strings.HasPrefix(etag, `W/"`), etag)
+
+		reader, err := gzip.NewReader(w.Body)
+		assert.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, listing, string(body))
+
+		w = serve("/api/posts/", "gzip", etag)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.Zero(t, w.Body.Len())
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		w := serve("/api/posts/", "", "")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.False(t, strings.HasPrefix(w.Header().Get("ETag"), "W/"))
+		assert.Equal(t, listing, w.Body.String())
+	})
+
+	t.Run("small response", func(t *testing.T) {
+		w := serve("/api/user/alex12345/about", "br", "")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, `{"username":"alex12345"}`, w.Body.String())
+	})
+}
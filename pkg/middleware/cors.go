@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CORSOptions struct {
+	// AllowedOrigins - точные origin; "*" разрешает любой. Пустой список выключает CORS.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Заголовки ответа, которые скрипт на чужом origin может прочитать помимо стандартных.
+var corsExposedHeaders = strings.Join([]string{
+	RequestIDHeader,
+	"Retry-After",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"RateLimit-Policy",
+}, ", ")
+
+// CORS обслуживает запросы к /api со страниц других origin. Оборачивает роутер снаружи:
+// каждый маршрут mux зарегистрирован с одним методом, и preflight OPTIONS до него не дошел бы.
+func CORS(options CORSOptions, next http.Handler) http.Handler {
+	if len(options.AllowedOrigins) == 0 {
+		return next
+	}
+
+	allowedMethods := strings.Join(options.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(options.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(options.MaxAge.Seconds()))
+	anyOrigin := slices.Contains(options.AllowedOrigins, "*")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Ответ зависит от Origin, и кэши не должны отдавать его другому сайту. Vary нужен и
+		// ответу без Origin: иначе кэш отдал бы его странице с разрешенного origin без заголовков CORS.
+		// Разрешенный origin повторяется явно, поэтому "*" здесь ничего не меняет.
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !anyOrigin && !slices.Contains(options.AllowedOrigins, origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			// Без заголовков CORS браузер сам не отдаст ответ скрипту чужого сайта.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// С учетными данными браузер не принимает "*", поэтому origin всегда повторяется явно.
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if options.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !slices.Contains(options.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) ||
+			!headersAllowed(options.AllowedHeaders, r.Header.Get("Access-Control-Request-Headers")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+		w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+		w.Header().Set("Access-Control-Max-Age", maxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// headersAllowed сравнивает имена заголовков без учета регистра, как их и сравнивает HTTP.
+func headersAllowed(allowed []string, requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+
+		found := slices.ContainsFunc(allowed, func(candidate string) bool {
+			return strings.EqualFold(candidate, header)
+		})
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
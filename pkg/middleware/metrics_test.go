@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"redditclone/pkg/metrics"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Metrics)
+	router.HandleFunc("/api/post/{postID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+	router.HandleFunc("/api/posts/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}).Methods("GET")
+
+	notFound := metrics.HTTPRequestsTotal.WithLabelValues("/api/post/{postID}", "GET", "404")
+	ok := metrics.HTTPRequestsTotal.WithLabelValues("/api/posts/", "GET", "200")
+	notFoundBefore, okBefore := testutil.ToFloat64(notFound), testutil.ToFloat64(ok)
+
+	// Разные посты попадают в один ряд шаблона маршрута.
+	for _, path := range []string{"/api/post/1", "/api/post/2", "/api/posts/"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	assert.Equal(t, notFoundBefore+2, testutil.ToFloat64(notFound))
+	// Обработчик без явного WriteHeader отвечает 200.
+	assert.Equal(t, okBefore+1, testutil.ToFloat64(ok))
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"redditclone/pkg/models"
+	"redditclone/pkg/ratelimit/repository"
+	"redditclone/tools"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimit ограничивает частоту запросов к маршруту корзиной токенов. Маршруты с одинаковым name делят
+// одну корзину. Авторизованные пользователи считаются по ID, поэтому RateLimit должен стоять
+// внутри ValidateJWTToken; остальные запросы считаются по IP.
+func RateLimit(limiter repository.Limiter, name string, limit repository.Limit, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := name + ":ip:" + tools.ClientIP(r)
+		if userID, ok := r.Context().Value(UserIDContextKey).(int); ok {
+			key = name + ":user:" + strconv.Itoa(userID)
+		}
+
+		result, err := limiter.Take(key, limit)
+		if err != nil {
+			// Недоступное хранилище счетчиков не должно останавливать весь API.
+			tools.LoggerFrom(r.Context()).WithFields(logrus.Fields{
+				"method": "middleware.RateLimit",
+				"key":    key,
+			}).Error(err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Policy", strconv.Itoa(limit.Burst)+";w="+seconds(limit.Period))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(result.Reset))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", seconds(result.RetryAfter))
+			tools.JSONError(w, r, http.StatusTooManyRequests, models.ErrRateLimited.Error(), "middleware.RateLimit")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/pkg/ratelimit/repository"
+	"redditclone/pkg/ratelimit/repository/mock_repository"
+	"redditclone/tools"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLimiter := mock_repository.NewMockLimiter(ctrl)
+
+	tools.Init()
+
+	limit := repository.Limit{Rate: 10, Period: time.Minute, Burst: 5}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := RateLimit(mockLimiter, "vote", limit, next)
+
+	t.Run("anonymous request is keyed by IP", func(t *testing.T) {
+		mockLimiter.EXPECT().Take("vote:ip:192.0.2.1", limit).Return(&repository.Result{
+			Allowed:   true,
+			Limit:     5,
+			Remaining: 4,
+			Reset:     6 * time.Second,
+		}, nil)
+
+		req := httptest.NewRequest("GET", "/api/post/1/upvote", nil)
+		req.RemoteAddr = "192.0.2.1:54321"
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "5", resp.Header.Get("RateLimit-Limit"))
+		assert.Equal(t, "4", resp.Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "6", resp.Header.Get("RateLimit-Reset"))
+		assert.Equal(t, "5;w=60", resp.Header.Get("RateLimit-Policy"))
+	})
+
+	t.Run("authenticated request is keyed by user", func(t *testing.T) {
+		mockLimiter.EXPECT().Take("vote:user:1", limit).Return(&repository.Result{
+			Allowed:    false,
+			Limit:      5,
+			Reset:      30 * time.Second,
+			RetryAfter: 5500 * time.Millisecond,
+		}, nil)
+
+		req := httptest.NewRequest("GET", "/api/post/1/upvote", nil)
+		req = req.WithContext(context.WithValue(req.Context(), UserIDContextKey, 1))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "0", resp.Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "6", resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("limiter error lets request through", func(t *testing.T) {
+		mockLimiter.EXPECT().Take(gomock.Any(), limit).Return(nil, errors.New("mock error"))
+
+		req := httptest.NewRequest("GET", "/api/post/1/upvote", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("RateLimit-Limit"))
+	})
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"redditclone/pkg/metrics"
+	"redditclone/tools"
+	"runtime/debug"
+
+	"github.com/sirupsen/logrus"
+)
+
+// problem - тело ответа об ошибке по RFC 9457 (application/problem+json).
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// Recover оборачивает весь роутер: паника любого обработчика или промежуточного слоя
+// превращается в ответ 500, запись в лог со стеком и рост счетчика паник.
+// Request ID берется из заголовка ответа, который к этому моменту выставил AccessLog.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// Этой паникой обработчик нарочно обрывает ответ; net/http сам закроет соединение.
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			metrics.PanicsTotal.Inc()
+			requestID := w.Header().Get(RequestIDHeader)
+			tools.Logger.WithFields(logrus.Fields{
+				"request_id": requestID,
+				"method":     "middleware.Recover",
+				"path":       r.URL.Path,
+				"panic":      fmt.Sprint(p),
+				"stack":      string(debug.Stack()),
+			}).Error("panic recovered")
+
+			// Если обработчик уже начал ответ, статус не изменить; клиент получит оборванное тело.
+			response, err := json.Marshal(&problem{
+				Type:      "about:blank",
+				Title:     http.StatusText(http.StatusInternalServerError),
+				Status:    http.StatusInternalServerError,
+				Detail:    "the server failed to process the request",
+				RequestID: requestID,
+			})
+			if err != nil {
+				return
+			}
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(response)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/pkg/metrics"
+	"redditclone/tools"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover(t *testing.T) {
+	tools.Init()
+	hook := test.NewLocal(tools.Logger)
+
+	router := mux.NewRouter()
+	router.Use(AccessLog)
+	router.HandleFunc("/api/posts/", func(w http.ResponseWriter, r *http.Request) {
+		var posts map[string]int
+		posts["boom"]++
+	}).Methods("GET")
+	handler := Recover(router)
+
+	before := testutil.ToFloat64(metrics.PanicsTotal)
+	r := httptest.NewRequest("GET", "/api/posts/", nil)
+	r.Header.Set(RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	body := &problem{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), body))
+	assert.Equal(t, http.StatusInternalServerError, body.Status)
+	assert.Equal(t, "req-42", body.RequestID)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.PanicsTotal))
+
+	entries := hook.AllEntries()
+	if !assert.Len(t, entries, 2) {
+		return
+	}
+	// Строка доступа пишется при раскрутке стека, до перехвата паники.
+	assert.Equal(t, "request", entries[0].Message)
+	assert.Equal(t, http.StatusInternalServerError, entries[0].Data["status"])
+	assert.Equal(t, "panic recovered", entries[1].Message)
+	assert.Equal(t, "req-42", entries[1].Data["request_id"])
+	assert.Contains(t, entries[1].Data["panic"], "nil map")
+	assert.Contains(t, entries[1].Data["stack"], "recover_test.go")
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	tools.Init()
+
+	handler := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+}
@@ -0,0 +1,189 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrBadVersion       = errors.New("migration version must be positive")
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+	ErrUnknownVersion   = errors.New("applied migration is unknown to this binary")
+)
+
+// Migration - одна версия схемы. Down откатывает ровно то, что сделал Up.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context) error
+	Down    func(ctx context.Context) error
+}
+
+// Store хранит список примененных версий рядом с самой схемой.
+type Store interface {
+	Init(ctx context.Context) error
+	Applied(ctx context.Context) (map[int]time.Time, error)
+	Record(ctx context.Context, m *Migration) error
+	Remove(ctx context.Context, version int) error
+}
+
+// Locker не дает нескольким репликам мигрировать одну базу одновременно.
+// Хранилище реализует его, если база поддерживает блокировки.
+type Locker interface {
+	Lock(ctx context.Context) (unlock func() error, err error)
+}
+
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+	Applied   bool
+}
+
+type Migrator struct {
+	Store      Store
+	Migrations []*Migration
+}
+
+func NewMigrator(store Store, migrations []*Migration) (*Migrator, error) {
+	sorted := make([]*Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("%w: %d %s", ErrBadVersion, m.Version, m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, m.Version)
+		}
+	}
+
+	return &Migrator{
+		Store:      store,
+		Migrations: sorted,
+	}, nil
+}
+
+// Up применяет все непримененные миграции по возрастанию версий и возвращает их.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	done := []*Migration{}
+
+	err := m.locked(ctx, func(applied map[int]time.Time) error {
+		for _, migration := range m.Migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			if err := migration.Up(ctx); err != nil {
+				return fmt.Errorf("migration %d %s up: %w", migration.Version, migration.Name, err)
+			}
+			if err := m.Store.Record(ctx, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down откатывает steps последних примененных миграций.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	done := []*Migration{}
+
+	err := m.locked(ctx, func(applied map[int]time.Time) error {
+		for i := len(m.Migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.Migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if err := migration.Down(ctx); err != nil {
+				return fmt.Errorf("migration %d %s down: %w", migration.Version, migration.Name, err)
+			}
+			if err := m.Store.Remove(ctx, migration.Version); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	if err := m.Store.Init(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, &Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: appliedAt,
+			Applied:   ok,
+		})
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) locked(ctx context.Context, fn func(applied map[int]time.Time) error) error {
+	if err := m.Store.Init(ctx); err != nil {
+		return err
+	}
+
+	if locker, ok := m.Store.(Locker); ok {
+		unlock, err := locker.Lock(ctx)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	return fn(applied)
+}
+
+// applied отказывается работать, если в базе есть версии новее этого бинарника:
+// откатывать или накатывать поверх чужой схемы небезопасно.
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	applied, err := m.Store.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]bool, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		known[migration.Version] = true
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+		}
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,160 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryStore struct {
+	applied  map[int]time.Time
+	locked   int
+	unlocked int
+}
+
+func (s *memoryStore) Init(ctx context.Context) error {
+	if s.applied == nil {
+		s.applied = map[int]time.Time{}
+	}
+	return nil
+}
+
+func (s *memoryStore) Applied(ctx context.Context) (map[int]time.Time, error) {
+	applied := map[int]time.Time{}
+	for version, at := range s.applied {
+		applied[version] = at
+	}
+	return applied, nil
+}
+
+func (s *memoryStore) Record(ctx context.Context, m *Migration) error {
+	s.applied[m.Version] = time.Now()
+	return nil
+}
+
+func (s *memoryStore) Remove(ctx context.Context, version int) error {
+	delete(s.applied, version)
+	return nil
+}
+
+func (s *memoryStore) Lock(ctx context.Context) (func() error, error) {
+	s.locked++
+	return func() error {
+		s.unlocked++
+		return nil
+	}, nil
+}
+
+func newTestMigrations(log *[]string) []*Migration {
+	step := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			*log = append(*log, name)
+			return nil
+		}
+	}
+
+	return []*Migration{
+		{Version: 2, Name: "second", Up: step("up 2"), Down: step("down 2")},
+		{Version: 1, Name: "first", Up: step("up 1"), Down: step("down 1")},
+		{Version: 3, Name: "third", Up: step("up 3"), Down: step("down 3")},
+	}
+}
+
+func TestNewMigrator(t *testing.T) {
+	t.Run("sorts by version", func(t *testing.T) {
+		migrator, err := NewMigrator(&memoryStore{}, newTestMigrations(&[]string{}))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, migrator.Migrations[0].Version)
+		assert.Equal(t, 3, migrator.Migrations[2].Version)
+	})
+
+	t.Run("duplicate version", func(t *testing.T) {
+		_, err := NewMigrator(&memoryStore{}, []*Migration{{Version: 1}, {Version: 1}})
+		assert.True(t, errors.Is(err, ErrDuplicateVersion))
+	})
+
+	t.Run("bad version", func(t *testing.T) {
+		_, err := NewMigrator(&memoryStore{}, []*Migration{{Version: 0}})
+		assert.True(t, errors.Is(err, ErrBadVersion))
+	})
+}
+
+func TestUp(t *testing.T) {
+	log := []string{}
+	store := &memoryStore{}
+	migrator, _ := NewMigrator(store, newTestMigrations(&log))
+
+	applied, err := migrator.Up(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, applied, 3)
+	assert.Equal(t, []string{"up 1", "up 2", "up 3"}, log)
+	assert.Equal(t, 1, store.locked)
+	assert.Equal(t, 1, store.unlocked)
+
+	t.Run("already applied", func(t *testing.T) {
+		applied, err := migrator.Up(context.Background())
+		assert.NoError(t, err)
+		assert.Empty(t, applied)
+		assert.Len(t, log, 3)
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		upErr := errors.New("some up error")
+		store := &memoryStore{}
+		migrator, _ := NewMigrator(store, []*Migration{
+			{Version: 1, Up: func(ctx context.Context) error { return nil }},
+			{Version: 2, Up: func(ctx context.Context) error { return upErr }},
+			{Version: 3, Up: func(ctx context.Context) error { return nil }},
+		})
+
+		applied, err := migrator.Up(context.Background())
+		assert.True(t, errors.Is(err, upErr))
+		assert.Len(t, applied, 1)
+		assert.Len(t, store.applied, 1)
+		assert.Equal(t, 1, store.unlocked)
+	})
+
+	t.Run("unknown applied version", func(t *testing.T) {
+		store := &memoryStore{applied: map[int]time.Time{42: time.Now()}}
+		migrator, _ := NewMigrator(store, newTestMigrations(&[]string{}))
+
+		_, err := migrator.Up(context.Background())
+		assert.True(t, errors.Is(err, ErrUnknownVersion))
+	})
+}
+
+func TestDown(t *testing.T) {
+	log := []string{}
+	store := &memoryStore{}
+	migrator, _ := NewMigrator(store, newTestMigrations(&log))
+	migrator.Up(context.Background())
+	log = log[:0]
+
+	reverted, err := migrator.Down(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, 2)
+	assert.Equal(t, []string{"down 3", "down 2"}, log)
+	assert.Len(t, store.applied, 1)
+
+	reverted, err = migrator.Down(context.Background(), 5)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, 1)
+	assert.Empty(t, store.applied)
+}
+
+func TestStatus(t *testing.T) {
+	store := &memoryStore{}
+	migrator, _ := NewMigrator(store, newTestMigrations(&[]string{}))
+	store.Init(context.Background())
+	store.applied[1] = time.Now()
+
+	statuses, err := migrator.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 3)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+	assert.Equal(t, "third", statuses[2].Name)
+}
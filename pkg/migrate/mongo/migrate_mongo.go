@@ -0,0 +1,333 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"redditclone/pkg/migrate"
+	"redditclone/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	migrationsCollection = "schema_migrations"
+	lockCollection       = "schema_migrations_lock"
+	lockID               = "migrations"
+
+	// Аренда продлевается, пока миграции идут; упавшая реплика отпускает ее через leaseTTL.
+	defaultLeaseTTL    = 30 * time.Second
+	defaultLockTimeout = 2 * time.Minute
+	lockRetryDelay     = 200 * time.Millisecond
+)
+
+var ErrLockTimeout = errors.New("timed out waiting for migration lock")
+
+// caseInsensitive делает логин и почту уникальными без учета регистра, как в MySQL.
+// Репозиторий пользователей передает ту же collation в запросах.
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+type MigrationMongoDBStore struct {
+	DB     *mongo.Collection
+	LockDB *mongo.Collection
+	// LeaseTTL и LockTimeout вынесены в поля, чтобы тесты не ждали минутами.
+	LeaseTTL    time.Duration
+	LockTimeout time.Duration
+}
+
+type migrationRecord struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+func NewMigrationMongoDBStore(db *mongo.Database) *MigrationMongoDBStore {
+	return &MigrationMongoDBStore{
+		DB:          db.Collection(migrationsCollection),
+		LockDB:      db.Collection(lockCollection),
+		LeaseTTL:    defaultLeaseTTL,
+		LockTimeout: defaultLockTimeout,
+	}
+}
+
+// Init ничего не делает: коллекция создается при первой записи.
+func (store *MigrationMongoDBStore) Init(ctx context.Context) error {
+	return nil
+}
+
+// Lock берет аренду на документ в schema_migrations_lock. В Mongo нет именованных
+// блокировок, как GET_LOCK в MySQL, поэтому владелец записывает себя и срок аренды, а
+// остальные реплики ждут, пока документ не удалят или срок не истечет.
+func (store *MigrationMongoDBStore) Lock(ctx context.Context) (func() error, error) {
+	owner := primitive.NewObjectID()
+
+	waitCtx, cancel := context.WithTimeout(ctx, store.LockTimeout)
+	defer cancel()
+
+	for {
+		acquired, err := store.tryLock(waitCtx, owner)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, ErrLockTimeout
+		case <-time.After(lockRetryDelay):
+		}
+	}
+
+	stop := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(store.LeaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				store.LockDB.UpdateOne(context.Background(),
+					bson.M{"_id": lockID, "owner": owner},
+					bson.M{"$set": bson.M{"expiresAt": time.Now().Add(store.LeaseTTL)}},
+				)
+			}
+		}
+	}()
+
+	return func() error {
+		close(stop)
+		<-renewed
+		_, err := store.LockDB.DeleteOne(context.Background(), bson.M{"_id": lockID, "owner": owner})
+		return err
+	}, nil
+}
+
+// tryLock занимает документ, если его нет или аренда истекла. Если аренда чужая и живая,
+// фильтр не совпадает, upsert пытается вставить тот же _id и получает ошибку дубликата.
+func (store *MigrationMongoDBStore) tryLock(ctx context.Context, owner primitive.ObjectID) (bool, error) {
+	now := time.Now()
+	_, err := store.LockDB.UpdateOne(ctx,
+		bson.M{"_id": lockID, "expiresAt": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"owner": owner, "expiresAt": now.Add(store.LeaseTTL)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (store *MigrationMongoDBStore) Applied(ctx context.Context) (map[int]time.Time, error) {
+	cursor, err := store.DB.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*migrationRecord{}
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time, len(records))
+	for _, record := range records {
+		applied[record.Version] = record.AppliedAt
+	}
+
+	return applied, nil
+}
+
+func (store *MigrationMongoDBStore) Record(ctx context.Context, m *migrate.Migration) error {
+	_, err := store.DB.InsertOne(ctx, &migrationRecord{
+		Version:   m.Version,
+		Name:      m.Name,
+		AppliedAt: time.Now().UTC(),
+	})
+
+	return err
+}
+
+func (store *MigrationMongoDBStore) Remove(ctx context.Context, version int) error {
+	_, err := store.DB.DeleteOne(ctx, bson.M{"_id": version})
+
+	return err
+}
+
+// Migrations описывает индексы коллекций. Имена индексов совпадают с теми,
+// что Mongo дает по умолчанию, поэтому уже созданные вручную индексы не конфликтуют.
+func Migrations(db *mongo.Database) []*migrate.Migration {
+	posts := db.Collection("posts")
+	comments := db.Collection("comments")
+	users := db.Collection("users")
+	sessions := db.Collection("sessions")
+
+	return []*migrate.Migration{
+		{
+			Version: 1,
+			Name:    "posts_indexes",
+			Up: createIndexes(posts, []mongo.IndexModel{
+				indexModel("author.username_1", bson.D{{Key: "author.username", Value: 1}}),
+				indexModel("author.id_1", bson.D{{Key: "author.id", Value: 1}}),
+				indexModel("category_1", bson.D{{Key: "category", Value: 1}}),
+				indexModel("created_-1", bson.D{{Key: "created", Value: -1}}),
+			}),
+			Down: dropIndexes(posts, "author.username_1", "author.id_1", "category_1", "created_-1"),
+		},
+		{
+			Version: 2,
+			Name:    "comments_indexes",
+			Up: createIndexes(comments, []mongo.IndexModel{
+				indexModel("author.username_1_created_-1", bson.D{
+					{Key: "author.username", Value: 1},
+					{Key: "created", Value: -1},
+				}),
+				indexModel("author.username_1_score_-1_created_-1", bson.D{
+					{Key: "author.username", Value: 1},
+					{Key: "score", Value: -1},
+					{Key: "created", Value: -1},
+				}),
+				indexModel("author.id_1", bson.D{{Key: "author.id", Value: 1}}),
+			}),
+			Down: dropIndexes(comments, "author.username_1_created_-1", "author.username_1_score_-1_created_-1", "author.id_1"),
+		},
+		{
+			Version: 3,
+			Name:    "users_indexes",
+			Up: createIndexes(users, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "username", Value: 1}},
+					Options: options.Index().SetName("username_1").SetUnique(true).SetCollation(caseInsensitive),
+				},
+				// Почта необязательна: в уникальность попадают только документы, где она есть.
+				{
+					Keys: bson.D{{Key: "email", Value: 1}},
+					Options: options.Index().SetName("email_1").SetUnique(true).SetCollation(caseInsensitive).
+						SetPartialFilterExpression(bson.M{"email": bson.M{"$type": "string"}}),
+				},
+			}),
+			Down: dropIndexes(users, "username_1", "email_1"),
+		},
+		{
+			Version: 4,
+			Name:    "sessions_ttl",
+			Up: createIndexes(sessions, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+					Options: options.Index().SetName("expiresAt_1").SetExpireAfterSeconds(0),
+				},
+			}),
+			Down: dropIndexes(sessions, "expiresAt_1"),
+		},
+		{
+			Version: 5,
+			Name:    "posts_author_sort_indexes",
+			Up: createIndexes(posts, []mongo.IndexModel{
+				indexModel("author.username_1_created_-1", bson.D{
+					{Key: "author.username", Value: 1},
+					{Key: "created", Value: -1},
+				}),
+				indexModel("author.username_1_score_-1_created_-1", bson.D{
+					{Key: "author.username", Value: 1},
+					{Key: "score", Value: -1},
+					{Key: "created", Value: -1},
+				}),
+			}),
+			Down: dropIndexes(posts, "author.username_1_created_-1", "author.username_1_score_-1_created_-1"),
+		},
+		{
+			Version: 6,
+			Name:    "comments_post_ref",
+			Up:      backfillCommentPostRefs(posts, comments),
+			// Ссылку на пост новые комментарии получают при создании, отличить от них
+			// заполненные здесь нельзя, поэтому откат ничего не трогает.
+			Down: func(ctx context.Context) error {
+				return nil
+			},
+		},
+	}
+}
+
+type postComments struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Title    string             `bson:"title"`
+	Comments []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"comments"`
+}
+
+// backfillCommentPostRefs проставляет ссылку на пост комментариям, созданным до появления
+// поля post. Родителя знает только сам пост: его комментарии лежат у него в массиве comments.
+func backfillCommentPostRefs(posts *mongo.Collection, comments *mongo.Collection) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		cursor, err := posts.Find(ctx,
+			bson.M{"comments.0": bson.M{"$exists": true}},
+			options.Find().SetProjection(bson.M{"title": 1, "comments._id": 1}),
+		)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			post := &postComments{}
+			if err = cursor.Decode(post); err != nil {
+				return err
+			}
+
+			ids := make([]primitive.ObjectID, 0, len(post.Comments))
+			for _, comment := range post.Comments {
+				ids = append(ids, comment.ID)
+			}
+
+			_, err = comments.UpdateMany(ctx,
+				bson.M{"_id": bson.M{"$in": ids}, "post": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"post": models.PostRef{ID: post.ID, Title: post.Title}}},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return cursor.Err()
+	}
+}
+
+func indexModel(name string, keys bson.D) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetName(name),
+	}
+}
+
+func createIndexes(collection *mongo.Collection, indexes []mongo.IndexModel) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+}
+
+func dropIndexes(collection *mongo.Collection, names ...string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for _, name := range names {
+			if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,180 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"redditclone/pkg/migrate"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("acquired", func(mt *mtest.T) {
+		store := NewMigrationMongoDBStore(mt.DB)
+		assert.Implements(t, (*migrate.Locker)(nil), store)
+
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "upserted", Value: bson.A{
+				bson.D{{Key: "index", Value: 0}, {Key: "_id", Value: lockID}},
+			}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		unlock, err := store.Lock(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, unlock())
+
+		acquire := mt.GetStartedEvent()
+		if assert.NotNil(t, acquire) {
+			assert.Equal(t, "update", acquire.CommandName)
+			assert.True(t, acquire.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("upsert").Boolean())
+		}
+		release := mt.GetStartedEvent()
+		if assert.NotNil(t, release) {
+			assert.Equal(t, "delete", release.CommandName)
+		}
+	})
+
+	mt.Run("held by another runner", func(mt *mtest.T) {
+		store := NewMigrationMongoDBStore(mt.DB)
+		store.LockTimeout = 50 * time.Millisecond
+
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index:   0,
+			Code:    11000,
+			Message: "E11000 duplicate key error",
+		}))
+
+		_, err := store.Lock(ctx)
+		assert.Equal(t, ErrLockTimeout, err)
+	})
+
+	mt.Run("lock error", func(mt *mtest.T) {
+		store := NewMigrationMongoDBStore(mt.DB)
+
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		_, err := store.Lock(ctx)
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrLockTimeout, err)
+	})
+}
+
+// TestConcurrentUp запускает два мигратора на одной настоящей базе, например:
+// MONGODB_TEST_URI="mongodb://127.0.0.1:27017" go test ./pkg/migrate/mongo
+func TestConcurrentUp(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("cant connect to mongo: %s", err)
+	}
+	defer client.Disconnect(ctx)
+
+	db := client.Database("redditclone_migrate_test")
+	if err = db.Drop(ctx); err != nil {
+		t.Fatalf("cant drop test database: %s", err)
+	}
+	defer db.Drop(ctx)
+
+	var runs [3]atomic.Int32
+	migrations := make([]*migrate.Migration, 0, len(runs))
+	for i := range runs {
+		migrations = append(migrations, &migrate.Migration{
+			Version: i + 1,
+			Name:    "counted",
+			Up: func(ctx context.Context) error {
+				runs[i].Add(1)
+				// Пауза растягивает миграцию, чтобы второй мигратор успел в нее упереться.
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			},
+			Down: func(ctx context.Context) error {
+				return nil
+			},
+		})
+	}
+
+	wg := &sync.WaitGroup{}
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			migrator, err := migrate.NewMigrator(NewMigrationMongoDBStore(db), migrations)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			_, errs[i] = migrator.Up(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	for i := range runs {
+		assert.Equal(t, int32(1), runs[i].Load(), "migration %d", i+1)
+	}
+
+	count, err := db.Collection(lockCollection).CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestBackfillCommentPostRefs(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("sets post ref from embedded comments", func(mt *mtest.T) {
+		postID := primitive.NewObjectID()
+		commentID := primitive.NewObjectID()
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "redditclone.posts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: postID},
+				{Key: "title", Value: "some title"},
+				{Key: "comments", Value: bson.A{bson.D{{Key: "_id", Value: commentID}}}},
+			}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		err := backfillCommentPostRefs(mt.DB.Collection("posts"), mt.DB.Collection("comments"))(ctx)
+		assert.NoError(t, err)
+
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent()
+		if assert.NotNil(t, update) && assert.Equal(t, "update", update.CommandName) {
+			statement := update.Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.Equal(t, commentID, statement.Lookup("q", "_id", "$in").Array().Index(0).Value().ObjectID())
+			assert.Equal(t, postID, statement.Lookup("u", "$set", "post", "id").ObjectID())
+			assert.Equal(t, "some title", statement.Lookup("u", "$set", "post", "title").StringValue())
+		}
+	})
+
+	mt.Run("find error", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		err := backfillCommentPostRefs(mt.DB.Collection("posts"), mt.DB.Collection("comments"))(ctx)
+		assert.Error(t, err)
+	})
+}
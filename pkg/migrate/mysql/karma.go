@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"redditclone/pkg/migrate"
+	"redditclone/pkg/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// karmaBackfillVersion идет сразу за последним SQL-файлом: новый файл миграции
+// должен взять следующую версию.
+const karmaBackfillVersion = 9
+
+type authorKarma struct {
+	AuthorID int `bson:"_id"`
+	Karma    int `bson:"karma"`
+}
+
+// KarmaBackfill пересчитывает карму из оценок постов и комментариев в Mongo. 0003 заводит
+// колонки кармы с нулем, и без пересчета у пользователей, писавших до нее, карма занижена.
+// Значения записываются целиком, а не прибавляются, поэтому повторный запуск ничего не портит.
+func KarmaBackfill(db *sql.DB, mongoDB *mongo.Database) *migrate.Migration {
+	return &migrate.Migration{
+		Version: karmaBackfillVersion,
+		Name:    "backfill_user_karma",
+		Up: func(ctx context.Context) error {
+			postKarma, err := sumScoresByAuthor(ctx, mongoDB.Collection("posts"))
+			if err != nil {
+				return err
+			}
+
+			commentKarma, err := sumScoresByAuthor(ctx, mongoDB.Collection("comments"))
+			if err != nil {
+				return err
+			}
+
+			return setKarma(ctx, db, postKarma, commentKarma)
+		},
+		// Откатывать нечего: колонки остаются, а карма дальше меняется от голосов.
+		Down: func(ctx context.Context) error {
+			return nil
+		},
+	}
+}
+
+func sumScoresByAuthor(ctx context.Context, collection *mongo.Collection) (map[int]int, error) {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"author.id": bson.M{"$ne": models.DeletedUserID}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$author.id", "karma": bson.M{"$sum": "$score"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*authorKarma{}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	karma := make(map[int]int, len(rows))
+	for _, row := range rows {
+		karma[row.AuthorID] = row.Karma
+	}
+
+	return karma, nil
+}
+
+func setKarma(ctx context.Context, db *sql.DB, postKarma map[int]int, commentKarma map[int]int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// У пользователей без постов и комментариев карма нулевая.
+	if _, err = tx.ExecContext(ctx, "UPDATE user SET post_karma = 0, comment_karma = 0"); err != nil {
+		return err
+	}
+
+	authors := make(map[int]bool, len(postKarma)+len(commentKarma))
+	for id := range postKarma {
+		authors[id] = true
+	}
+	for id := range commentKarma {
+		authors[id] = true
+	}
+
+	for id := range authors {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE user SET post_karma = ?, comment_karma = ? WHERE id = ?",
+			postKarma[id],
+			commentKarma[id],
+			id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
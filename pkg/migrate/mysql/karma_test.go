@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestKarmaBackfill(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("sums scores per author", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("cant create mock: %s", err)
+		}
+		defer db.Close()
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "redditclone.posts", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: 1}, {Key: "karma", Value: 5}},
+			),
+			mtest.CreateCursorResponse(0, "redditclone.comments", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: 1}, {Key: "karma", Value: -2}},
+				bson.D{{Key: "_id", Value: 2}, {Key: "karma", Value: 3}},
+			),
+		)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE user SET post_karma = 0, comment_karma = 0").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.MatchExpectationsInOrder(false)
+		mock.ExpectExec("UPDATE user SET post_karma = \\?, comment_karma = \\? WHERE id = \\?").
+			WithArgs(5, -2, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE user SET post_karma = \\?, comment_karma = \\? WHERE id = \\?").
+			WithArgs(0, 3, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		migration := KarmaBackfill(db, mt.DB)
+		assert.Equal(t, karmaBackfillVersion, migration.Version)
+		assert.NoError(t, migration.Up(context.Background()))
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	mt.Run("aggregate error", func(mt *mtest.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("cant create mock: %s", err)
+		}
+		defer db.Close()
+
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		assert.Error(t, KarmaBackfill(db, mt.DB).Up(context.Background()))
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestKarmaBackfillAfterSQLMigrations(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	migrations, err := Migrations(db)
+	assert.NoError(t, err)
+	for _, m := range migrations {
+		assert.Less(t, m.Version, karmaBackfillVersion, m.Name)
+	}
+}
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"redditclone/pkg/migrate"
+	"time"
+)
+
+// 0001 повторяет таблицу user из исходного init_script.sql, поэтому на такой базе она ничего
+// не меняет. Колонки, появившиеся позже, добавляют отдельные миграции через ALTER TABLE:
+// тогда и старая, и пустая база после Up приходят к одной схеме.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const lockTimeout = 30 * time.Second
+
+var ErrLockTimeout = errors.New("timed out waiting for migration lock")
+
+type MigrationMysqlStore struct {
+	*migrate.SQLStore
+}
+
+func NewMigrationMysqlStore(db *sql.DB) *MigrationMysqlStore {
+	return &MigrationMysqlStore{
+		SQLStore: migrate.NewSQLStore(db, migrate.QuestionPlaceholder),
+	}
+}
+
+// Init переопределен из-за типа applied_at: TIMESTAMP в MySQL зависит от часового пояса сессии.
+func (store *MigrationMysqlStore) Init(ctx context.Context) error {
+	_, err := store.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+
+	return err
+}
+
+// Lock берет именованную блокировку MySQL. Она живет, пока открыто соединение,
+// поэтому соединение держим до вызова unlock.
+func (store *MigrationMysqlStore) Lock(ctx context.Context) (func() error, error) {
+	conn, err := store.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK('schema_migrations', ?)", int(lockTimeout.Seconds())).Scan(&acquired)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return nil, ErrLockTimeout
+	}
+
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK('schema_migrations')")
+		return err
+	}, nil
+}
+
+// Migrations собирает миграции из файлов вида 0001_name.up.sql и 0001_name.down.sql.
+func Migrations(db *sql.DB) ([]*migrate.Migration, error) {
+	return migrate.LoadSQL(db, migrationFiles, "migrations")
+}
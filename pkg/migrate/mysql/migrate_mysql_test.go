@@ -0,0 +1,187 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"redditclone/pkg/migrate"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestMigrations(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	migrations, err := Migrations(db)
+	assert.NoError(t, err)
+
+	_, err = migrate.NewMigrator(NewMigrationMysqlStore(db), migrations)
+	assert.NoError(t, err)
+}
+
+func TestLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	store := NewMigrationMysqlStore(db)
+
+	t.Run("acquired", func(t *testing.T) {
+		mock.ExpectQuery("SELECT GET_LOCK").WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+		mock.ExpectExec("SELECT RELEASE_LOCK").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		unlock, err := store.Lock(context.Background())
+		assert.NoError(t, err)
+		assert.NoError(t, unlock())
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		mock.ExpectQuery("SELECT GET_LOCK").WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(0))
+
+		_, err := store.Lock(context.Background())
+		assert.Equal(t, ErrLockTimeout, err)
+	})
+}
+
+var (
+	createTableRe = regexp.MustCompile(`(?s)^CREATE TABLE IF NOT EXISTS (\w+) \((.*)\)$`)
+	dropTableRe   = regexp.MustCompile(`^DROP TABLE IF EXISTS (\w+)$`)
+	alterTableRe  = regexp.MustCompile(`(?s)^ALTER TABLE (\w+)\s+(.*)$`)
+	alterClauseRe = regexp.MustCompile(`^(ADD COLUMN|DROP COLUMN|MODIFY) (\w+)`)
+)
+
+// schema - драйвер database/sql, который помнит только имена колонок таблиц и
+// выполняет DDL так же, как MySQL: CREATE TABLE IF NOT EXISTS не трогает существующую
+// таблицу, а ALTER TABLE падает целиком на лишней или отсутствующей колонке.
+type schema struct {
+	tables map[string][]string
+}
+
+func (s *schema) Connect(ctx context.Context) (driver.Conn, error) { return s, nil }
+func (s *schema) Driver() driver.Driver                            { return nil }
+func (s *schema) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("unexpected prepare: %s", query)
+}
+func (s *schema) Close() error              { return nil }
+func (s *schema) Begin() (driver.Tx, error) { return nil, fmt.Errorf("unexpected begin") }
+
+func (s *schema) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query = strings.TrimSpace(query)
+	if m := createTableRe.FindStringSubmatch(query); m != nil {
+		if _, ok := s.tables[m[1]]; ok {
+			return driver.ResultNoRows, nil
+		}
+		columns := []string{}
+		for _, line := range strings.Split(m[2], ",\n") {
+			name := strings.Fields(line)[0]
+			if name != "UNIQUE" && name != "FOREIGN" && name != "PRIMARY" {
+				columns = append(columns, name)
+			}
+		}
+		s.tables[m[1]] = columns
+		return driver.ResultNoRows, nil
+	}
+
+	if m := dropTableRe.FindStringSubmatch(query); m != nil {
+		delete(s.tables, m[1])
+		return driver.ResultNoRows, nil
+	}
+
+	m := alterTableRe.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported statement: %s", query)
+	}
+	columns, ok := s.tables[m[1]]
+	if !ok {
+		return nil, fmt.Errorf("no table %s", m[1])
+	}
+
+	// Изменения применяются к копии: при ошибке таблица остается как была.
+	columns = slices.Clone(columns)
+	for _, clause := range strings.Split(m[2], ",") {
+		c := alterClauseRe.FindStringSubmatch(strings.TrimSpace(clause))
+		if c == nil {
+			return nil, fmt.Errorf("unsupported clause: %s", clause)
+		}
+		exists := slices.Contains(columns, c[2])
+		switch {
+		case c[1] == "ADD COLUMN" && exists:
+			return nil, fmt.Errorf("duplicate column %s", c[2])
+		case c[1] != "ADD COLUMN" && !exists:
+			return nil, fmt.Errorf("unknown column %s", c[2])
+		case c[1] == "ADD COLUMN":
+			columns = append(columns, c[2])
+		case c[1] == "DROP COLUMN":
+			columns = slices.DeleteFunc(columns, func(column string) bool { return column == c[2] })
+		}
+	}
+	s.tables[m[1]] = columns
+
+	return driver.ResultNoRows, nil
+}
+
+func sortedMigrations(t *testing.T, s *schema) []*migrate.Migration {
+	db := sql.OpenDB(s)
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := Migrations(db)
+	assert.NoError(t, err)
+	slices.SortFunc(migrations, func(a, b *migrate.Migration) int { return a.Version - b.Version })
+
+	return migrations
+}
+
+func migrateUp(t *testing.T, s *schema) {
+	for _, m := range sortedMigrations(t, s) {
+		assert.NoError(t, m.Up(context.Background()), m.Name)
+	}
+}
+
+func TestUpFromBaseline(t *testing.T) {
+	// Таблица, которую создавал исходный init_script.sql.
+	legacy := &schema{tables: map[string][]string{"user": {"id", "login", "password"}}}
+	migrateUp(t, legacy)
+
+	fresh := &schema{tables: map[string][]string{}}
+	migrateUp(t, fresh)
+
+	assert.ElementsMatch(t, fresh.tables["user"], legacy.tables["user"])
+	assert.ElementsMatch(t, []string{
+		"id", "login", "password", "email", "email_verified", "created_at",
+		"post_karma", "comment_karma", "totp_secret", "totp_enabled", "is_admin", "totp_last_step",
+	}, legacy.tables["user"])
+	assert.Contains(t, legacy.tables, "recovery_code")
+
+	migrations := sortedMigrations(t, legacy)
+	for i := len(migrations) - 1; i >= 0; i-- {
+		assert.NoError(t, migrations[i].Down(context.Background()), migrations[i].Name)
+	}
+	assert.Empty(t, legacy.tables)
+}
+
+func TestOneStatementPerMigration(t *testing.T) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	assert.NoError(t, err)
+
+	for _, entry := range entries {
+		content, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(content), ";\n"), entry.Name())
+	}
+}
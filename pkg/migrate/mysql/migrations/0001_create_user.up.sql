@@ -1,7 +1,5 @@
-USE redditclone_mysql;
-
 CREATE TABLE IF NOT EXISTS user (
     id INT AUTO_INCREMENT PRIMARY KEY,
     login VARCHAR(255) NOT NULL UNIQUE,
     password VARCHAR(255) NOT NULL
-);
\ No newline at end of file
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS recovery_code (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    code_hash VARCHAR(255) NOT NULL,
+    UNIQUE KEY user_code (user_id, code_hash),
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
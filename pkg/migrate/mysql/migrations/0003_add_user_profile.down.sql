@@ -0,0 +1,4 @@
+ALTER TABLE user
+    DROP COLUMN comment_karma,
+    DROP COLUMN post_karma,
+    DROP COLUMN created_at;
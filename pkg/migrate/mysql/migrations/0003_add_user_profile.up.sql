@@ -0,0 +1,4 @@
+ALTER TABLE user
+    ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN post_karma INT NOT NULL DEFAULT 0,
+    ADD COLUMN comment_karma INT NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE user
+    DROP COLUMN email_verified,
+    DROP COLUMN email;
@@ -0,0 +1,3 @@
+ALTER TABLE user
+    ADD COLUMN email VARCHAR(255) NULL UNIQUE,
+    ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+ALTER TABLE user
+    DROP COLUMN totp_enabled,
+    DROP COLUMN totp_secret;
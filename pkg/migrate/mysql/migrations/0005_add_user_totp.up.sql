@@ -0,0 +1,3 @@
+ALTER TABLE user
+    ADD COLUMN totp_secret VARBINARY(255) NULL,
+    ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1 @@
+ALTER TABLE user ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1 @@
+ALTER TABLE user MODIFY login VARCHAR(255) NOT NULL;
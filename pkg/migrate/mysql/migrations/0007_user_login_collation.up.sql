@@ -0,0 +1,2 @@
+-- Логины сравниваются без учета регистра: alex и Alex - один пользователь.
+ALTER TABLE user MODIFY login VARCHAR(255) NOT NULL COLLATE utf8mb4_0900_ai_ci;
@@ -0,0 +1 @@
+ALTER TABLE user ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"redditclone/pkg/migrate"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const lockTimeout = 30 * time.Second
+
+var ErrLockTimeout = errors.New("timed out waiting for migration lock")
+
+type MigrationPostgresStore struct {
+	*migrate.SQLStore
+}
+
+func NewMigrationPostgresStore(db *sql.DB) *MigrationPostgresStore {
+	return &MigrationPostgresStore{
+		SQLStore: migrate.NewSQLStore(db, migrate.DollarPlaceholder),
+	}
+}
+
+// Lock берет advisory-блокировку сессии, поэтому соединение держим до вызова unlock.
+// Ожидание ограничено таймаутом контекста: драйвер отменяет запрос по его истечении.
+func (store *MigrationPostgresStore) Lock(ctx context.Context) (func() error, error) {
+	conn, err := store.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lockCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+
+	_, err = conn.ExecContext(lockCtx, "SELECT pg_advisory_lock(hashtext('schema_migrations'))")
+	if err != nil {
+		conn.Close()
+		if lockCtx.Err() == context.DeadlineExceeded {
+			return nil, ErrLockTimeout
+		}
+		return nil, err
+	}
+
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext('schema_migrations'))")
+		return err
+	}, nil
+}
+
+// Migrations собирает миграции из файлов вида 0001_name.up.sql и 0001_name.down.sql.
+func Migrations(db *sql.DB) ([]*migrate.Migration, error) {
+	return migrate.LoadSQL(db, migrationFiles, "migrations")
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"redditclone/pkg/migrate"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestMigrations(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	migrations, err := Migrations(db)
+	assert.NoError(t, err)
+
+	_, err = migrate.NewMigrator(NewMigrationPostgresStore(db), migrations)
+	assert.NoError(t, err)
+}
+
+func TestLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	store := NewMigrationPostgresStore(db)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(hashtext\('schema_migrations'\)\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(hashtext\('schema_migrations'\)\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	unlock, err := store.Lock(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, unlock())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
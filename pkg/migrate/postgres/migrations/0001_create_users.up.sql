@@ -0,0 +1,16 @@
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    login VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    post_karma INT NOT NULL DEFAULT 0,
+    comment_karma INT NOT NULL DEFAULT 0,
+    totp_secret BYTEA NULL,
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE
+);
+-- Логин и почта уникальны без учета регистра, как в MySQL с collation *_ci.
+CREATE UNIQUE INDEX users_login_key ON users (lower(login));
+CREATE UNIQUE INDEX users_email_key ON users (lower(email));
@@ -0,0 +1,212 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBadMigrationFile = errors.New("bad migration file name")
+	ErrNoDownMigration  = errors.New("migration has no down file")
+)
+
+// SQLStore хранит примененные версии в таблице schema_migrations. Запросы в нем
+// переносимы между MySQL, PostgreSQL и SQLite, различаются только плейсхолдеры.
+type SQLStore struct {
+	DB *sql.DB
+	// Placeholder возвращает n-й параметр запроса: "?" или "$n".
+	Placeholder func(n int) string
+}
+
+func QuestionPlaceholder(n int) string {
+	return "?"
+}
+
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func NewSQLStore(db *sql.DB, placeholder func(n int) string) *SQLStore {
+	return &SQLStore{
+		DB:          db,
+		Placeholder: placeholder,
+	}
+}
+
+func (store *SQLStore) Init(ctx context.Context) error {
+	_, err := store.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`)
+
+	return err
+}
+
+func (store *SQLStore) Applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := store.DB.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func (store *SQLStore) Record(ctx context.Context, m *Migration) error {
+	_, err := store.DB.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, %s)",
+			store.Placeholder(1), store.Placeholder(2), store.Placeholder(3),
+		),
+		m.Version,
+		m.Name,
+		time.Now().UTC(),
+	)
+
+	return err
+}
+
+func (store *SQLStore) Remove(ctx context.Context, version int) error {
+	_, err := store.DB.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = "+store.Placeholder(1), version)
+
+	return err
+}
+
+// LoadSQL собирает миграции из файлов вида 0001_name.up.sql и 0001_name.down.sql.
+func LoadSQL(db *sql.DB, files fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := map[int]*Migration{}
+	downs := map[int]bool{}
+	for _, entry := range entries {
+		version, name, direction, err := parseFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := migrations[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			migrations[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, version)
+		}
+
+		step := execStatements(db, splitStatements(string(content)))
+		if direction == "up" {
+			m.Up = step
+		} else {
+			m.Down = step
+			downs[version] = true
+		}
+	}
+
+	result := make([]*Migration, 0, len(migrations))
+	for version, m := range migrations {
+		if m.Up == nil {
+			return nil, fmt.Errorf("%w: %d %s has no up file", ErrBadMigrationFile, version, m.Name)
+		}
+		if !downs[version] {
+			return nil, fmt.Errorf("%w: %d %s", ErrNoDownMigration, version, m.Name)
+		}
+		result = append(result, m)
+	}
+
+	return result, nil
+}
+
+func parseFileName(fileName string) (version int, name string, direction string, err error) {
+	base, ok := strings.CutSuffix(fileName, ".sql")
+	if !ok {
+		return 0, "", "", fmt.Errorf("%w: %s", ErrBadMigrationFile, fileName)
+	}
+
+	dot := strings.LastIndex(base, ".")
+	if dot < 0 {
+		return 0, "", "", fmt.Errorf("%w: %s", ErrBadMigrationFile, fileName)
+	}
+	base, direction = base[:dot], base[dot+1:]
+	if direction != "up" && direction != "down" {
+		return 0, "", "", fmt.Errorf("%w: %s", ErrBadMigrationFile, fileName)
+	}
+
+	rawVersion, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("%w: %s", ErrBadMigrationFile, fileName)
+	}
+
+	version, err = strconv.Atoi(rawVersion)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("%w: %s", ErrBadMigrationFile, fileName)
+	}
+
+	return version, name, direction, nil
+}
+
+// splitStatements режет файл по ';' в конце строки: драйверы без multiStatements
+// выполняют только один запрос за вызов.
+func splitStatements(content string) []string {
+	statements := []string{}
+	current := strings.Builder{}
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+// DDL в MySQL коммитит неявно, поэтому транзакция здесь ничего не дала бы: если упадет
+// не первый запрос, предыдущие останутся примененными, а версия не запишется. Поэтому
+// в одной миграции держим один DDL-запрос, а колонки добавляем одним ALTER TABLE.
+func execStatements(db *sql.DB, statements []string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for _, statement := range statements {
+			if _, err := db.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
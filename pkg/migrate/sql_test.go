@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestLoadSQL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	t.Run("correct files", func(t *testing.T) {
+		files := fstest.MapFS{
+			"m/0001_create_test.up.sql":   {Data: []byte("CREATE TABLE a (id INT);\n-- comment\nCREATE TABLE b (id INT);\n")},
+			"m/0001_create_test.down.sql": {Data: []byte("DROP TABLE b;\nDROP TABLE a;\n")},
+		}
+
+		migrations, err := LoadSQL(db, files, "m")
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 1)
+		assert.Equal(t, 1, migrations[0].Version)
+		assert.Equal(t, "create_test", migrations[0].Name)
+
+		mock.ExpectExec("CREATE TABLE a").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE b").WillReturnResult(sqlmock.NewResult(0, 0))
+		assert.NoError(t, migrations[0].Up(context.Background()))
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("no down file", func(t *testing.T) {
+		files := fstest.MapFS{
+			"m/0001_create_test.up.sql": {Data: []byte("CREATE TABLE a (id INT);")},
+		}
+
+		_, err := LoadSQL(db, files, "m")
+		assert.True(t, errors.Is(err, ErrNoDownMigration))
+	})
+
+	t.Run("bad file name", func(t *testing.T) {
+		files := fstest.MapFS{
+			"m/create_test.up.sql": {Data: []byte("CREATE TABLE a (id INT);")},
+		}
+
+		_, err := LoadSQL(db, files, "m")
+		assert.True(t, errors.Is(err, ErrBadMigrationFile))
+	})
+}
+
+func TestParseFileName(t *testing.T) {
+	version, name, direction, err := parseFileName("0012_add_user_email.down.sql")
+	assert.NoError(t, err)
+	assert.Equal(t, 12, version)
+	assert.Equal(t, "add_user_email", name)
+	assert.Equal(t, "down", direction)
+
+	for _, fileName := range []string{"0001_test.sql", "0001_test.sideways.sql", "0001.up.sql", "abc_test.up.sql", "0001_test.up.txt"} {
+		_, _, _, err := parseFileName(fileName)
+		assert.True(t, errors.Is(err, ErrBadMigrationFile), fileName)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := splitStatements("-- header\nCREATE TABLE a (\n    id INT\n);\n\nDROP TABLE b;\nSELECT 1")
+	assert.Equal(t, []string{"CREATE TABLE a (\n    id INT\n)", "DROP TABLE b", "SELECT 1"}, statements)
+}
+
+func TestSQLStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	m := &Migration{Version: 3, Name: "test"}
+
+	t.Run("question placeholders", func(t *testing.T) {
+		store := NewSQLStore(db, QuestionPlaceholder)
+
+		mock.ExpectExec(`INSERT INTO schema_migrations \(version, name, applied_at\) VALUES \(\?, \?, \?\)`).
+			WithArgs(3, "test", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \?`).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, store.Record(context.Background(), m))
+		assert.NoError(t, store.Remove(context.Background(), 3))
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("dollar placeholders", func(t *testing.T) {
+		store := NewSQLStore(db, DollarPlaceholder)
+
+		mock.ExpectExec(`INSERT INTO schema_migrations \(version, name, applied_at\) VALUES \(\$1, \$2, \$3\)`).
+			WithArgs(3, "test", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \$1`).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, store.Record(context.Background(), m))
+		assert.NoError(t, store.Remove(context.Background(), 3))
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("applied", func(t *testing.T) {
+		store := NewSQLStore(db, QuestionPlaceholder)
+		appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+			WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt).AddRow(2, appliedAt))
+
+		applied, err := store.Applied(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]time.Time{1: appliedAt, 2: appliedAt}, applied)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
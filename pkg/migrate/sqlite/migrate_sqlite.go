@@ -0,0 +1,27 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"redditclone/pkg/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// MigrationSqliteStore не реализует Locker: файл базы открывает один процесс,
+// а одновременную запись SQLite и так сериализует.
+type MigrationSqliteStore struct {
+	*migrate.SQLStore
+}
+
+func NewMigrationSqliteStore(db *sql.DB) *MigrationSqliteStore {
+	return &MigrationSqliteStore{
+		SQLStore: migrate.NewSQLStore(db, migrate.QuestionPlaceholder),
+	}
+}
+
+// Migrations собирает миграции из файлов вида 0001_name.up.sql и 0001_name.down.sql.
+func Migrations(db *sql.DB) ([]*migrate.Migration, error) {
+	return migrate.LoadSQL(db, migrationFiles, "migrations")
+}
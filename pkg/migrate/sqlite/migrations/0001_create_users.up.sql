@@ -0,0 +1,14 @@
+-- AUTOINCREMENT не дает переиспользовать id удаленных пользователей, как AUTO_INCREMENT в MySQL.
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    login TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    password TEXT NOT NULL,
+    email TEXT NULL UNIQUE COLLATE NOCASE,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    post_karma INTEGER NOT NULL DEFAULT 0,
+    comment_karma INTEGER NOT NULL DEFAULT 0,
+    totp_secret BLOB NULL,
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE
+);
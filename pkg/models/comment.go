@@ -10,5 +10,13 @@ type Comment struct {
 	Created time.Time          `json:"created"`
 	Author  *User              `json:"author"`
 	Text    string             `json:"body"`
+	Score   int                `json:"score"`
+	Votes   []*Vote            `json:"votes"`
+	Post    *PostRef           `json:"post,omitempty" bson:"post,omitempty"`
 	ID      primitive.ObjectID `json:"id" bson:"_id"`
 }
+
+type PostRef struct {
+	ID    primitive.ObjectID `json:"id" bson:"id"`
+	Title string             `json:"title" bson:"title"`
+}
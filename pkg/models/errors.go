@@ -8,10 +8,23 @@ var (
 	ErrNoUser           = errors.New("no user found")
 	ErrWrongCredentials = errors.New("wrong login or password")
 	ErrAlreadyCreated   = errors.New("already created")
+	ErrUpdateUser       = errors.New("cant update user")
+	ErrDeleteUser       = errors.New("cant delete user")
+	ErrBadToken         = errors.New("bad or expired token")
+	ErrBadEmail         = errors.New("bad email")
+	ErrNoEmail          = errors.New("no email attached to account")
+	ErrEmailNotVerified = errors.New("verify your email first")
+	ErrNoTOTPSecret     = errors.New("two-factor enrollment not started")
+	ErrTOTPEnabled      = errors.New("two-factor authentication already enabled")
+	ErrTOTPDisabled     = errors.New("two-factor authentication is not enabled")
+	ErrBadOTP           = errors.New("invalid two-factor code")
+	ErrTooManyAttempts  = errors.New("too many attempts, try again later")
+	ErrNotAdmin         = errors.New("admin rights required")
 
 	ErrCorruptedCommentID = errors.New("bad comment id")
 	ErrNoComment          = errors.New("cant find such comment")
 	ErrDeleteComment      = errors.New("cant delete comment")
+	ErrUpdateComment      = errors.New("cant update comment")
 
 	ErrCorruptedPostID       = errors.New("bad post id")
 	ErrUnrecognizedRate      = errors.New("unrecognized rate")
@@ -19,4 +32,18 @@ var (
 	ErrUpdatePost            = errors.New("cant update post")
 	ErrDeletePost            = errors.New("cant delete post")
 	ErrIncorrectPostCategory = errors.New("incorrect post category")
+
+	ErrRateLimited = errors.New("rate limit exceeded")
+
+	ErrLoginLength      = errors.New("must be 3 to 20 characters long")
+	ErrLoginCharset     = errors.New("may contain only latin letters, digits, '_' and '-'")
+	ErrLoginReserved    = errors.New("is reserved")
+	ErrLoginTaken       = errors.New("already exists")
+	ErrEmailTaken       = errors.New("already in use")
+	ErrPasswordTooShort = errors.New("must be at least 8 characters long")
+	ErrPasswordTooLong  = errors.New("must be at most 72 bytes long")
+	ErrPasswordBreached = errors.New("is too common, choose another one")
+
+	ErrUnrecognizedSort = errors.New("unrecognized sort")
+	ErrBadPagination    = errors.New("bad pagination params")
 )
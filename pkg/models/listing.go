@@ -0,0 +1,12 @@
+package models
+
+const (
+	SortNew = "new"
+	SortTop = "top"
+)
+
+type ListOptions struct {
+	Sort   string
+	Limit  int
+	Offset int
+}
@@ -6,6 +6,15 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var PostCategories = map[string]bool{
+	"music":       true,
+	"funny":       true,
+	"videos":      true,
+	"programming": true,
+	"news":        true,
+	"fashion":     true,
+}
+
 type Post struct {
 	Score            int                `json:"score" bson:"score"`
 	Views            int                `json:"views" bson:"views"`
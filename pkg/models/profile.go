@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Profile struct {
+	ID           int       `json:"id,string"`
+	Login        string    `json:"username"`
+	Created      time.Time `json:"created"`
+	PostKarma    int       `json:"postKarma"`
+	CommentKarma int       `json:"commentKarma"`
+	Karma        int       `json:"karma"`
+	PostCount    int       `json:"postCount"`
+	CommentCount int       `json:"commentCount"`
+}
@@ -1,7 +1,18 @@
 package models
 
+// Посты и комментарии удаленного аккаунта остаются под этим автором.
+const (
+	DeletedUserID    = 0
+	DeletedUserLogin = "[deleted]"
+)
+
 type User struct {
 	ID       int    `json:"id,string" bson:"id"`
 	Login    string `json:"username" bson:"username"`
 	Password string `json:"-" bson:"-"`
+
+	Email         string `json:"-" bson:"-"`
+	EmailVerified bool   `json:"-" bson:"-"`
+	TOTPEnabled   bool   `json:"-" bson:"-"`
+	Admin         bool   `json:"-" bson:"-"`
 }
@@ -5,7 +5,9 @@ import (
 	"io"
 	"net/http"
 	commentRepository "redditclone/pkg/comment/repository"
+	"redditclone/pkg/metrics"
 	"redditclone/pkg/middleware"
+	"redditclone/pkg/models"
 	postRepository "redditclone/pkg/post/repository"
 	userRepository "redditclone/pkg/user/repository"
 	"redditclone/tools"
@@ -19,23 +21,26 @@ type PostHandler struct {
 	CommentRepo commentRepository.CommentRepo
 	PostRepo    postRepository.PostRepo
 	UserRepo    userRepository.UserRepo
+
+	RequireVerifiedEmail bool
 }
 
 func (h *PostHandler) Index(w http.ResponseWriter, r *http.Request) {
-	posts, err := h.PostRepo.GetAllPosts("", "")
+	posts, err := h.PostRepo.GetAllPosts(r.Context(), "", "")
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.GetAllPosts")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetAllPosts")
+		return
 	}
 
 	jsonPosts, err := json.Marshal(posts)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Index")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Index")
 		return
 	}
 
-	_, err = w.Write(jsonPosts)
+	err = tools.WriteCacheableJSON(w, r, jsonPosts)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Index")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Index")
 		return
 	}
 }
@@ -43,20 +48,21 @@ func (h *PostHandler) Index(w http.ResponseWriter, r *http.Request) {
 func (h *PostHandler) IndexByUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	username := vars["username"]
-	posts, err := h.PostRepo.GetAllPosts("", username)
+	posts, err := h.PostRepo.GetAllPosts(r.Context(), "", username)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.GetAllPosts")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetAllPosts")
+		return
 	}
 
 	jsonPosts, err := json.Marshal(posts)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.IndexByUser")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.IndexByUser")
 		return
 	}
 
-	_, err = w.Write(jsonPosts)
+	err = tools.WriteCacheableJSON(w, r, jsonPosts)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.IndexByUser")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.IndexByUser")
 		return
 	}
 }
@@ -64,19 +70,20 @@ func (h *PostHandler) IndexByUser(w http.ResponseWriter, r *http.Request) {
 func (h *PostHandler) IndexByCategory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	category := vars["category"]
-	posts, err := h.PostRepo.GetAllPosts(category, "")
+	posts, err := h.PostRepo.GetAllPosts(r.Context(), category, "")
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.GetAllPosts")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetAllPosts")
+		return
 	}
 	jsonPosts, err := json.Marshal(posts)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.IndexByCategory")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.IndexByCategory")
 		return
 	}
 
-	_, err = w.Write(jsonPosts)
+	err = tools.WriteCacheableJSON(w, r, jsonPosts)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.IndexByCategory")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.IndexByCategory")
 		return
 	}
 }
@@ -84,21 +91,21 @@ func (h *PostHandler) IndexByCategory(w http.ResponseWriter, r *http.Request) {
 func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID := vars["id"]
-	post, err := h.PostRepo.GetPostByID(postID)
+	post, err := h.PostRepo.GetPostByID(r.Context(), postID)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostByID")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostByID")
 		return
 	}
 
 	jsonPost, err := json.Marshal(post)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.GetPost")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.GetPost")
 		return
 	}
 
-	_, err = w.Write(jsonPost)
+	err = tools.WriteCacheableJSON(w, r, jsonPost)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.GetPost")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.GetPost")
 		return
 	}
 }
@@ -106,46 +113,46 @@ func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 func (h *PostHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID := vars["postID"]
-	post, err := h.PostRepo.GetPostByID(postID)
+	post, err := h.PostRepo.GetPostByIDForUpdate(r.Context(), postID)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Delete")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Delete")
 		return
 	}
 
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
 	if !ok {
-		tools.JSONError(w, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
 		return
 	}
 
 	if userID != post.Author.ID {
-		tools.JSONError(w, http.StatusForbidden, "you are not allowed to delete this post", "PostHandler.Delete")
+		tools.JSONError(w, r, http.StatusForbidden, "you are not allowed to delete this post", "PostHandler.Delete")
 		return
 	}
 
 	for _, comment := range post.Comments {
-		err := h.CommentRepo.DeleteComment(comment)
+		err := h.CommentRepo.DeleteComment(r.Context(), comment)
 		if err != nil {
-			tools.JSONError(w, http.StatusInternalServerError, "cant delete post comment", "CommentRepo.DeleteComment")
+			tools.JSONError(w, r, http.StatusInternalServerError, "cant delete post comment", "CommentRepo.DeleteComment")
 			return
 		}
 	}
 
-	err = h.PostRepo.DeletePost(post)
+	err = h.PostRepo.DeletePost(r.Context(), post)
 	if err != nil {
-		tools.JSONError(w, http.StatusForbidden, "cant delete such post", "PostRepo.DeletePost")
+		tools.JSONError(w, r, http.StatusForbidden, "cant delete such post", "PostRepo.DeletePost")
 		return
 	}
 
 	jsonOK, err := json.Marshal(map[string]string{"message": "success"})
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Delete")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Delete")
 		return
 	}
 
 	_, err = w.Write(jsonOK)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Delete")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Delete")
 		return
 	}
 }
@@ -161,51 +168,67 @@ type PostForm struct {
 func (h *PostHandler) Create(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
 	if !ok {
-		tools.JSONError(w, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
 		return
 	}
 
-	user, err := h.UserRepo.GetUserByID(userID)
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
 	if err != nil {
-		tools.JSONError(w, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	if h.RequireVerifiedEmail && !user.EmailVerified {
+		tools.JSONError(w, r, http.StatusForbidden, models.ErrEmailNotVerified.Error(), "PostHandler.Create")
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "PostHandler.Create")
+		return
+	}
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Create")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Create")
 		return
 	}
 
 	postForm := &PostForm{}
-	err = json.Unmarshal(body, postForm)
+	err = tools.UnmarshalStrict(body, postForm)
 	if err != nil {
-		tools.JSONError(w, http.StatusBadRequest, "cant unpack payload", "PostHandler.Create")
+		tools.JSONError(w, r, http.StatusBadRequest, "cant unpack payload", "PostHandler.Create")
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(postForm)
 	if err != nil {
-		tools.ValidationError(w, err)
+		tools.ValidationError(w, r, err)
 		return
 	}
 
-	newPost, err := h.PostRepo.CreateNewPost(postForm.Category, postForm.Title, postForm.Type, postForm.URL, postForm.Text, user)
+	newPost, err := h.PostRepo.CreateNewPost(r.Context(), postForm.Category, postForm.Title, postForm.Type, postForm.URL, postForm.Text, user)
 	if err != nil {
-		tools.JSONError(w, http.StatusConflict, err.Error(), "PostRepo.CreateNewPost")
+		tools.JSONError(w, r, http.StatusConflict, err.Error(), "PostRepo.CreateNewPost")
+		return
+	}
+	metrics.PostsTotal.Inc()
+
+	err = h.UserRepo.UpdateKarma(r.Context(), user.ID, newPost.Score, 0)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.UpdateKarma")
 		return
 	}
 
 	newPostJSON, err := json.Marshal(newPost)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Create")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Create")
 		return
 	}
 
 	_, err = w.Write(newPostJSON)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Create")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Create")
 		return
 	}
 }
@@ -213,39 +236,50 @@ func (h *PostHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *PostHandler) Vote(w http.ResponseWriter, r *http.Request, rate int) {
 	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
 	if !ok {
-		tools.JSONError(w, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "PostHandler.Create")
 		return
 	}
 
-	user, err := h.UserRepo.GetUserByID(userID)
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
 	if err != nil {
-		tools.JSONError(w, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
 		return
 	}
 
 	vars := mux.Vars(r)
 	postID := vars["postID"]
-	post, err := h.PostRepo.GetPostByID(postID)
+	post, err := h.PostRepo.GetPostByIDForUpdate(r.Context(), postID)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostByID")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostByIDForUpdate")
 		return
 	}
 
-	err = h.PostRepo.UpvotePost(user, post, rate)
+	scoreBefore := post.Score
+	err = h.PostRepo.UpvotePost(r.Context(), user, post, rate)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostRepo.UpvotePost")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.UpvotePost")
 		return
 	}
+	metrics.CountVote("post", rate)
+
+	// У удаленного автора кармы нет; голос к этому моменту уже сохранен.
+	if karmaDelta := post.Score - scoreBefore; karmaDelta != 0 && post.Author.ID != models.DeletedUserID {
+		err = h.UserRepo.UpdateKarma(r.Context(), post.Author.ID, karmaDelta, 0)
+		if err != nil && err != models.ErrNoUser {
+			tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.UpdateKarma")
+			return
+		}
+	}
 
 	postJSON, err := json.Marshal(post)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Upvote")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Upvote")
 		return
 	}
 
 	_, err = w.Write(postJSON)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "PostHandler.Upvote")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostHandler.Upvote")
 		return
 	}
 }
@@ -82,7 +82,7 @@ func TestIndex(t *testing.T) {
 	}
 
 	t.Run("correct Index", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetAllPosts("", "").Return([]*models.Post{&post}, nil)
+		mockPostRepo.EXPECT().GetAllPosts(gomock.Any(), "", "").Return([]*models.Post{&post}, nil)
 
 		req := httptest.NewRequest("GET", "/api/posts/", nil)
 		w := httptest.NewRecorder()
@@ -100,8 +100,42 @@ func TestIndex(t *testing.T) {
 		assert.True(t, ComparePosts(post, *posts[0]))
 	})
 
+	t.Run("conditional Index", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetAllPosts(gomock.Any(), "", "").Return([]*models.Post{&post}, nil).Times(3)
+
+		req := httptest.NewRequest("GET", "/api/posts/", nil)
+		w := httptest.NewRecorder()
+		postHandler.Index(w, req)
+
+		etag := w.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+		assert.Equal(t, tools.PublicCacheControl, w.Header().Get("Cache-Control"))
+
+		req = httptest.NewRequest("GET", "/api/posts/", nil)
+		req.Header.Set("If-None-Match", `"stale", W/`+etag)
+		w = httptest.NewRecorder()
+		postHandler.Index(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.Bytes())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+
+		// Список изменился - ETag тоже, клиент получает новое тело.
+		post.Score++
+		defer func() { post.Score-- }()
+		req = httptest.NewRequest("GET", "/api/posts/", nil)
+		req.Header.Set("If-None-Match", etag)
+		req.Header.Set("Authorization", "Bearer token")
+		w = httptest.NewRecorder()
+		postHandler.Index(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		assert.Equal(t, tools.PrivateCacheControl, w.Header().Get("Cache-Control"))
+	})
+
 	t.Run("PostRepo.GetAllPosts error", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetAllPosts("", "").Return(nil, errors.New("mock error"))
+		mockPostRepo.EXPECT().GetAllPosts(gomock.Any(), "", "").Return(nil, errors.New("mock error"))
 
 		req := httptest.NewRequest("GET", "/api/posts/", nil)
 		w := httptest.NewRecorder()
@@ -159,7 +193,7 @@ func TestIndexByUser(t *testing.T) {
 	}
 
 	t.Run("correct IndexByUser", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetAllPosts("", postAuthor.Login).Return([]*models.Post{&post}, nil)
+		mockPostRepo.EXPECT().GetAllPosts(gomock.Any(), "", postAuthor.Login).Return([]*models.Post{&post}, nil)
 
 		req := httptest.NewRequest("GET", "/api/user/"+postAuthor.Login, nil)
 		w := httptest.NewRecorder()
@@ -183,7 +217,7 @@ func TestIndexByUser(t *testing.T) {
 	})
 
 	t.Run("PostRepo.GetAllPosts error", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetAllPosts("", postAuthor.Login).Return(nil, errors.New("mock error"))
+		mockPostRepo.EXPECT().GetAllPosts(gomock.Any(), "", postAuthor.Login).Return(nil, errors.New("mock error"))
 
 		req := httptest.NewRequest("GET", "/api/user/"+postAuthor.Login, nil)
 		w := httptest.NewRecorder()
@@ -247,7 +281,7 @@ func TestIndexByCategory(t *testing.T) {
 	}
 
 	t.Run("correct IndexByCategory", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetAllPosts(category, "").Return([]*models.Post{&post}, nil)
+		mockPostRepo.EXPECT().GetAllPosts(gomock.Any(), category, "").Return([]*models.Post{&post}, nil)
 
 		req := httptest.NewRequest("GET", "/api/posts/"+category, nil)
 		w := httptest.NewRecorder()
@@ -271,7 +305,7 @@ func TestIndexByCategory(t *testing.T) {
 	})
 
 	t.Run("PostRepo.GetAllPosts error", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetAllPosts(category, "").Return(nil, errors.New("mock error"))
+		mockPostRepo.EXPECT().GetAllPosts(gomock.Any(), category, "").Return(nil, errors.New("mock error"))
 
 		req := httptest.NewRequest("GET", "/api/posts/"+category, nil)
 		w := httptest.NewRecorder()
@@ -335,7 +369,7 @@ func TestGetPost(t *testing.T) {
 	}
 
 	t.Run("correct GetPost", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID.Hex()).Return(&post, nil)
 
 		req := httptest.NewRequest("GET", "/api/post/"+post.ID.Hex(), nil)
 		w := httptest.NewRecorder()
@@ -358,8 +392,25 @@ func TestGetPost(t *testing.T) {
 		assert.True(t, ComparePosts(post, actualPost))
 	})
 
+	t.Run("conditional GetPost", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID.Hex()).Return(&post, nil).Times(2)
+
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/api/post/"+post.ID.Hex(), nil), map[string]string{"id": post.ID.Hex()})
+		w := httptest.NewRecorder()
+		postHandler.GetPost(w, req)
+		etag := w.Header().Get("ETag")
+
+		req = mux.SetURLVars(httptest.NewRequest("GET", "/api/post/"+post.ID.Hex(), nil), map[string]string{"id": post.ID.Hex()})
+		req.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		postHandler.GetPost(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.Bytes())
+	})
+
 	t.Run("PostRepo.GetPostByID error", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(nil, errors.New("mock error"))
+		mockPostRepo.EXPECT().GetPostByID(gomock.Any(), post.ID.Hex()).Return(nil, errors.New("mock error"))
 
 		req := httptest.NewRequest("GET", "/api/post/"+post.ID.Hex(), nil)
 		w := httptest.NewRecorder()
@@ -431,9 +482,9 @@ func TestDelete(t *testing.T) {
 	}
 
 	t.Run("correct Delete", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
-		mockCommentRepo.EXPECT().DeleteComment(post.Comments[0]).Return(nil)
-		mockPostRepo.EXPECT().DeletePost(&post).Return(nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
+		mockCommentRepo.EXPECT().DeleteComment(gomock.Any(), post.Comments[0]).Return(nil)
+		mockPostRepo.EXPECT().DeletePost(gomock.Any(), &post).Return(nil)
 
 		req := httptest.NewRequest("DELETE", "/api/post/"+post.ID.Hex(), nil)
 		w := httptest.NewRecorder()
@@ -459,8 +510,8 @@ func TestDelete(t *testing.T) {
 		assert.Equal(t, response["message"], "success")
 	})
 
-	t.Run("PostRepo.GetPostByID error", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(nil, errors.New("mock error"))
+	t.Run("PostRepo.GetPostByIDForUpdate error", func(t *testing.T) {
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(nil, errors.New("mock error"))
 
 		req := httptest.NewRequest("DELETE", "/api/post/"+post.ID.Hex(), nil)
 		w := httptest.NewRecorder()
@@ -482,7 +533,7 @@ func TestDelete(t *testing.T) {
 	})
 
 	t.Run("auth error, permission denied", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
 
 		req := httptest.NewRequest("DELETE", "/api/post/"+post.ID.Hex(), nil)
 		w := httptest.NewRecorder()
@@ -506,7 +557,7 @@ func TestDelete(t *testing.T) {
 	})
 
 	t.Run("permission denied - delete not owns post error", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
 
 		req := httptest.NewRequest("DELETE", "/api/post/"+post.ID.Hex(), nil)
 		w := httptest.NewRecorder()
@@ -534,8 +585,8 @@ func TestDelete(t *testing.T) {
 	})
 
 	t.Run("CommentRepo.DeleteComment error", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
-		mockCommentRepo.EXPECT().DeleteComment(post.Comments[0]).Return(errors.New("mock error"))
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
+		mockCommentRepo.EXPECT().DeleteComment(gomock.Any(), post.Comments[0]).Return(errors.New("mock error"))
 
 		req := httptest.NewRequest("DELETE", "/api/post/"+post.ID.Hex(), nil)
 		w := httptest.NewRecorder()
@@ -562,9 +613,9 @@ func TestDelete(t *testing.T) {
 	})
 
 	t.Run("correct Delete", func(t *testing.T) {
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
-		mockCommentRepo.EXPECT().DeleteComment(post.Comments[0]).Return(nil)
-		mockPostRepo.EXPECT().DeletePost(&post).Return(errors.New("mock error"))
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
+		mockCommentRepo.EXPECT().DeleteComment(gomock.Any(), post.Comments[0]).Return(nil)
+		mockPostRepo.EXPECT().DeletePost(gomock.Any(), &post).Return(errors.New("mock error"))
 
 		req := httptest.NewRequest("DELETE", "/api/post/"+post.ID.Hex(), nil)
 		w := httptest.NewRecorder()
@@ -650,8 +701,9 @@ func TestCreate(t *testing.T) {
 	}
 
 	t.Run("correct Create", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserByID(postAuthor.ID).Return(&postAuthor, nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
 		mockPostRepo.EXPECT().CreateNewPost(
+			gomock.Any(),
 			postForm.Category,
 			postForm.Title,
 			postForm.Type,
@@ -659,6 +711,7 @@ func TestCreate(t *testing.T) {
 			postForm.Text,
 			&postAuthor,
 		).Return(&post, nil)
+		mockUserRepo.EXPECT().UpdateKarma(gomock.Any(), postAuthor.ID, post.Score, 0).Return(nil)
 
 		reqBody, err := json.Marshal(postForm)
 		if err != nil {
@@ -684,6 +737,27 @@ func TestCreate(t *testing.T) {
 		assert.True(t, ComparePosts(post, actualPost))
 	})
 
+	t.Run("unverified email", func(t *testing.T) {
+		strictHandler := *postHandler
+		strictHandler.RequireVerifiedEmail = true
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
+
+		reqBody, _ := json.Marshal(postForm)
+		req := httptest.NewRequest("POST", "/api/posts", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		ctx := context.WithValue(req.Context(), middleware.UserIDContextKey, postAuthor.ID)
+		req = req.WithContext(ctx)
+
+		strictHandler.Create(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
 	t.Run("auth error, permission denied", func(t *testing.T) {
 		reqBody, err := json.Marshal(postForm)
 		if err != nil {
@@ -709,7 +783,7 @@ func TestCreate(t *testing.T) {
 	t.Run("UserRepo.GetUserByID", func(t *testing.T) {
 		unknownUserID := postAuthor.ID + 2
 
-		mockUserRepo.EXPECT().GetUserByID(unknownUserID).Return(nil, errors.New("mock error"))
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), unknownUserID).Return(nil, errors.New("mock error"))
 		reqBody, err := json.Marshal(postForm)
 		if err != nil {
 			t.Fatalf("failed to marshal request body: %v", err)
@@ -735,7 +809,7 @@ func TestCreate(t *testing.T) {
 	})
 
 	t.Run("govalidator.ValidateStruct error", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserByID(postAuthor.ID).Return(&postAuthor, nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
 
 		// Делаем форму некорректной для валидации.
 		incorrectPostForm := *postForm
@@ -761,8 +835,9 @@ func TestCreate(t *testing.T) {
 	})
 
 	t.Run("PostRepo.CreateNewPost error", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserByID(postAuthor.ID).Return(&postAuthor, nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
 		mockPostRepo.EXPECT().CreateNewPost(
+			gomock.Any(),
 			postForm.Category,
 			postForm.Title,
 			postForm.Type,
@@ -789,6 +864,38 @@ func TestCreate(t *testing.T) {
 
 		assert.Equal(t, http.StatusConflict, resp.StatusCode)
 	})
+
+	t.Run("UserRepo.UpdateKarma error", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
+		mockPostRepo.EXPECT().CreateNewPost(
+			gomock.Any(),
+			postForm.Category,
+			postForm.Title,
+			postForm.Type,
+			postForm.URL,
+			postForm.Text,
+			&postAuthor,
+		).Return(&post, nil)
+		mockUserRepo.EXPECT().UpdateKarma(gomock.Any(), postAuthor.ID, post.Score, 0).Return(errors.New("mock error"))
+
+		reqBody, err := json.Marshal(postForm)
+		if err != nil {
+			t.Fatalf("failed to marshal request body: %v", err)
+		}
+
+		req := httptest.NewRequest("POST", "/api/posts", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		ctx := context.WithValue(req.Context(), middleware.UserIDContextKey, postAuthor.ID)
+		req = req.WithContext(ctx)
+
+		postHandler.Create(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
 }
 
 func TestVote(t *testing.T) {
@@ -845,9 +952,9 @@ func TestVote(t *testing.T) {
 	var voteRate = 1
 
 	t.Run("correct Vote", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserByID(postAuthor.ID).Return(&postAuthor, nil)
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
-		mockPostRepo.EXPECT().UpvotePost(&postAuthor, &post, voteRate).Return(nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
+		mockPostRepo.EXPECT().UpvotePost(gomock.Any(), &postAuthor, &post, voteRate).Return(nil)
 
 		// Данный URL не реализован, т.к. хендлеру Vote делегируется изменение поста.
 		req := httptest.NewRequest("GET", "/post/upvote/"+post.ID.Hex(), nil)
@@ -900,7 +1007,7 @@ func TestVote(t *testing.T) {
 	t.Run("UserRepo.GetUserByID", func(t *testing.T) {
 		unknownUserID := postAuthor.ID + 2
 
-		mockUserRepo.EXPECT().GetUserByID(unknownUserID).Return(nil, errors.New("mock error"))
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), unknownUserID).Return(nil, errors.New("mock error"))
 
 		req := httptest.NewRequest("GET", "/post/upvote/"+post.ID.Hex(), nil)
 		w := httptest.NewRecorder()
@@ -926,9 +1033,9 @@ func TestVote(t *testing.T) {
 		assert.Equal(t, "you should authorize first", response["error"])
 	})
 
-	t.Run("PostRepo.GetPostByID error", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserByID(postAuthor.ID).Return(&postAuthor, nil)
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(nil, errors.New("mock error"))
+	t.Run("PostRepo.GetPostByIDForUpdate error", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(nil, errors.New("mock error"))
 
 		// Данный URL не реализован, т.к. хендлеру Vote делегируется изменение поста.
 		req := httptest.NewRequest("GET", "/post/upvote/"+post.ID.Hex(), nil)
@@ -951,9 +1058,9 @@ func TestVote(t *testing.T) {
 	})
 
 	t.Run("PostRepo.UpvotePost error", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserByID(postAuthor.ID).Return(&postAuthor, nil)
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
-		mockPostRepo.EXPECT().UpvotePost(&postAuthor, &post, voteRate).Return(errors.New("mock error"))
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
+		mockPostRepo.EXPECT().UpvotePost(gomock.Any(), &postAuthor, &post, voteRate).Return(errors.New("mock error"))
 
 		// Данный URL не реализован, т.к. хендлеру Vote делегируется изменение поста.
 		req := httptest.NewRequest("GET", "/post/upvote/"+post.ID.Hex(), nil)
@@ -974,6 +1081,72 @@ func TestVote(t *testing.T) {
 
 		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 	})
+
+	t.Run("score change updates author karma", func(t *testing.T) {
+		voter := models.User{
+			ID:    postAuthor.ID + 1,
+			Login: "max12345",
+		}
+		votedPost := post
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), voter.ID).Return(&voter, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&votedPost, nil)
+		mockPostRepo.EXPECT().UpvotePost(gomock.Any(), &voter, &votedPost, voteRate).
+			DoAndReturn(func(ctx context.Context, user *models.User, post *models.Post, rate int) error {
+				post.Score += rate
+				return nil
+			})
+		mockUserRepo.EXPECT().UpdateKarma(gomock.Any(), postAuthor.ID, voteRate, 0).Return(nil)
+
+		req := httptest.NewRequest("GET", "/post/upvote/"+post.ID.Hex(), nil)
+		w := httptest.NewRecorder()
+
+		vars := map[string]string{
+			"postID": post.ID.Hex(),
+		}
+		req = mux.SetURLVars(req, vars)
+
+		ctx := context.WithValue(req.Context(), middleware.UserIDContextKey, voter.ID)
+		req = req.WithContext(ctx)
+
+		postHandler.Vote(w, req, voteRate)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("UserRepo.UpdateKarma error", func(t *testing.T) {
+		votedPost := post
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&votedPost, nil)
+		mockPostRepo.EXPECT().UpvotePost(gomock.Any(), &postAuthor, &votedPost, -1).
+			DoAndReturn(func(ctx context.Context, user *models.User, post *models.Post, rate int) error {
+				post.Score -= 2
+				return nil
+			})
+		mockUserRepo.EXPECT().UpdateKarma(gomock.Any(), postAuthor.ID, -2, 0).Return(errors.New("mock error"))
+
+		req := httptest.NewRequest("GET", "/post/downvote/"+post.ID.Hex(), nil)
+		w := httptest.NewRecorder()
+
+		vars := map[string]string{
+			"postID": post.ID.Hex(),
+		}
+		req = mux.SetURLVars(req, vars)
+
+		ctx := context.WithValue(req.Context(), middleware.UserIDContextKey, postAuthor.ID)
+		req = req.WithContext(ctx)
+
+		postHandler.Vote(w, req, -1)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
 }
 
 func TestUpUnDownvote(t *testing.T) {
@@ -1030,9 +1203,9 @@ func TestUpUnDownvote(t *testing.T) {
 	t.Run("correct Upvote", func(t *testing.T) {
 		voteRate := 1
 
-		mockUserRepo.EXPECT().GetUserByID(postAuthor.ID).Return(&postAuthor, nil)
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
-		mockPostRepo.EXPECT().UpvotePost(&postAuthor, &post, voteRate).Return(nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
+		mockPostRepo.EXPECT().UpvotePost(gomock.Any(), &postAuthor, &post, voteRate).Return(nil)
 
 		req := httptest.NewRequest("GET", "/api/post/"+post.ID.Hex()+"/upvote", nil)
 		w := httptest.NewRecorder()
@@ -1061,9 +1234,9 @@ func TestUpUnDownvote(t *testing.T) {
 	t.Run("correct Unvote", func(t *testing.T) {
 		voteRate := 0
 
-		mockUserRepo.EXPECT().GetUserByID(postAuthor.ID).Return(&postAuthor, nil)
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
-		mockPostRepo.EXPECT().UpvotePost(&postAuthor, &post, voteRate).Return(nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
+		mockPostRepo.EXPECT().UpvotePost(gomock.Any(), &postAuthor, &post, voteRate).Return(nil)
 
 		req := httptest.NewRequest("GET", "/api/post/"+post.ID.Hex()+"/unvote", nil)
 		w := httptest.NewRecorder()
@@ -1092,9 +1265,9 @@ func TestUpUnDownvote(t *testing.T) {
 	t.Run("correct Downvote", func(t *testing.T) {
 		voteRate := -1
 
-		mockUserRepo.EXPECT().GetUserByID(postAuthor.ID).Return(&postAuthor, nil)
-		mockPostRepo.EXPECT().GetPostByID(post.ID.Hex()).Return(&post, nil)
-		mockPostRepo.EXPECT().UpvotePost(&postAuthor, &post, voteRate).Return(nil)
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), postAuthor.ID).Return(&postAuthor, nil)
+		mockPostRepo.EXPECT().GetPostByIDForUpdate(gomock.Any(), post.ID.Hex()).Return(&post, nil)
+		mockPostRepo.EXPECT().UpvotePost(gomock.Any(), &postAuthor, &post, voteRate).Return(nil)
 
 		req := httptest.NewRequest("GET", "/api/post/"+post.ID.Hex()+"/downvote", nil)
 		w := httptest.NewRecorder()
@@ -0,0 +1,259 @@
+package cached
+
+import (
+	"context"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	postRepository "redditclone/pkg/post/repository"
+	"redditclone/tools"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/sync/singleflight"
+)
+
+const keyPrefix = "postcache:"
+
+// postList - лента в кэше: BSON на верхнем уровне хранит только документ, не массив.
+type postList struct {
+	Posts []*models.Post `bson:"posts"`
+}
+
+// PostCachedRepository читает посты и ленты через Redis и сбрасывает затронутые ключи
+// после каждого изменения поста. Значения хранятся в BSON, как в Mongo: в JSON у модели
+// скрыты поля, без которых пост из кэша отличался бы от поста из базы.
+//
+// Чтение, начатое до записи, может положить в кэш старую версию уже после сброса,
+// и до истечения TTL ее будут видеть читатели. Поэтому запись строится только на посте
+// из GetPostByIDForUpdate: иначе старая версия ушла бы обратно в хранилище навсегда.
+type PostCachedRepository struct {
+	Repo postRepository.PostRepo
+
+	// Кэш читают все запросы сразу, поэтому соединения берутся из пула. Пул же выбрасывает
+	// соединение после сетевой ошибки и открывает новое, когда Redis снова доступен.
+	pool    *redis.Pool
+	loads   *singleflight.Group
+	postTTL time.Duration
+	listTTL time.Duration
+}
+
+func NewPostCachedRepository(repo postRepository.PostRepo, pool *redis.Pool, postTTL, listTTL time.Duration) *PostCachedRepository {
+	return &PostCachedRepository{
+		Repo:    repo,
+		pool:    pool,
+		loads:   &singleflight.Group{},
+		postTTL: postTTL,
+		listTTL: listTTL,
+	}
+}
+
+func postKey(id string) string {
+	return keyPrefix + "post:" + id
+}
+
+// listKey различает ленты так же, как GetAllPosts: общая, по категории и по автору.
+func listKey(category, username string) string {
+	return keyPrefix + "list:" + category + ":" + username
+}
+
+func (repo *PostCachedRepository) GetAllPosts(ctx context.Context, category string, username string) ([]*models.Post, error) {
+	list := &postList{}
+	err := repo.read(ctx, listKey(category, username), repo.listTTL, list, func(ctx context.Context) (interface{}, error) {
+		posts, err := repo.Repo.GetAllPosts(ctx, category, username)
+		if err != nil {
+			return nil, err
+		}
+		return &postList{Posts: posts}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Пустая лента должна уйти клиенту как [], а не null.
+	if list.Posts == nil {
+		list.Posts = []*models.Post{}
+	}
+
+	return list.Posts, nil
+}
+
+// GetPostsByAuthor и CountPostsByAuthor идут мимо кэша: страницы профиля со своими
+// сортировками и смещениями почти не повторяются, а сбросить их все при записи нечем.
+func (repo *PostCachedRepository) GetPostsByAuthor(ctx context.Context, username string, opts *models.ListOptions) ([]*models.Post, error) {
+	return repo.Repo.GetPostsByAuthor(ctx, username, opts)
+}
+
+func (repo *PostCachedRepository) CountPostsByAuthor(ctx context.Context, username string) (int, error) {
+	return repo.Repo.CountPostsByAuthor(ctx, username)
+}
+
+func (repo *PostCachedRepository) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
+	post := &models.Post{}
+	err := repo.read(ctx, postKey(id), repo.postTTL, post, func(ctx context.Context) (interface{}, error) {
+		return repo.Repo.GetPostByID(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// GetPostByIDForUpdate не смотрит в кэш: пост из него может оказаться устаревшим.
+func (repo *PostCachedRepository) GetPostByIDForUpdate(ctx context.Context, id string) (*models.Post, error) {
+	return repo.Repo.GetPostByIDForUpdate(ctx, id)
+}
+
+func (repo *PostCachedRepository) CreateNewPost(ctx context.Context, category string, title string, postType string, url string, text string, user *models.User) (*models.Post, error) {
+	post, err := repo.Repo.CreateNewPost(ctx, category, title, postType, url, text, user)
+	if err != nil {
+		return nil, err
+	}
+
+	repo.invalidate(ctx, post)
+	return post, nil
+}
+
+func (repo *PostCachedRepository) UpvotePost(ctx context.Context, user *models.User, post *models.Post, rate int) error {
+	err := repo.Repo.UpvotePost(ctx, user, post, rate)
+	repo.invalidate(ctx, post)
+	return err
+}
+
+func (repo *PostCachedRepository) DeletePostComment(ctx context.Context, post *models.Post, comment *models.Comment) error {
+	err := repo.Repo.DeletePostComment(ctx, post, comment)
+	repo.invalidate(ctx, post)
+	return err
+}
+
+func (repo *PostCachedRepository) AddPostComment(ctx context.Context, post *models.Post, comment *models.Comment) (*models.Post, error) {
+	updated, err := repo.Repo.AddPostComment(ctx, post, comment)
+	repo.invalidate(ctx, post)
+	return updated, err
+}
+
+func (repo *PostCachedRepository) UpdatePostComment(ctx context.Context, post *models.Post, comment *models.Comment) error {
+	err := repo.Repo.UpdatePostComment(ctx, post, comment)
+	repo.invalidate(ctx, post)
+	return err
+}
+
+func (repo *PostCachedRepository) DeletePost(ctx context.Context, post *models.Post) error {
+	err := repo.Repo.DeletePost(ctx, post)
+	repo.invalidate(ctx, post)
+	return err
+}
+
+// AnonymizeAuthor меняет автора постов и комментариев под чужими постами: какие ключи
+// затронуты, не узнать, поэтому кэш сбрасывается целиком. Удаление аккаунта - редкость.
+func (repo *PostCachedRepository) AnonymizeAuthor(ctx context.Context, userID int) error {
+	err := repo.Repo.AnonymizeAuthor(ctx, userID)
+	if flushErr := repo.flush(ctx); flushErr != nil {
+		warn(ctx, "AnonymizeAuthor", flushErr)
+	}
+	return err
+}
+
+// read отдает значение из кэша, а при промахе загружает его через load и кладет в кэш.
+// Одновременные промахи по одному ключу ждут одну загрузку; общими у них будут только
+// байты BSON, поэтому каждый вызов получает свою копию поста и может ее менять.
+func (repo *PostCachedRepository) read(ctx context.Context, key string, ttl time.Duration, dst interface{}, load func(ctx context.Context) (interface{}, error)) error {
+	data, err := redis.Bytes(repo.do(ctx, "GET", key))
+	switch {
+	case err == nil:
+		if err = bson.Unmarshal(data, dst); err == nil {
+			metrics.CacheRequestsTotal.WithLabelValues("post", "hit").Inc()
+			return nil
+		}
+		warn(ctx, "read", err)
+		metrics.CacheRequestsTotal.WithLabelValues("post", "miss").Inc()
+	case err == redis.ErrNil:
+		metrics.CacheRequestsTotal.WithLabelValues("post", "miss").Inc()
+	default:
+		warn(ctx, "read", err)
+		metrics.CacheRequestsTotal.WithLabelValues("post", "error").Inc()
+	}
+
+	loaded, err, _ := repo.loads.Do(key, func() (interface{}, error) {
+		// Загрузку ждут и другие запросы: отмена первого не должна оборвать ее для всех.
+		value, err := load(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := bson.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = repo.do(ctx, "SET", key, data, "PX", ttl.Milliseconds()); err != nil {
+			warn(ctx, "read", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(loaded.([]byte), dst)
+}
+
+// invalidate сбрасывает сам пост и все ленты, в которые он попадает. Запись зовет его
+// и при ошибке: хранилище могло успеть изменить пост.
+func (repo *PostCachedRepository) invalidate(ctx context.Context, post *models.Post) {
+	_, err := repo.do(ctx, "DEL",
+		postKey(post.ID.Hex()),
+		listKey("", ""),
+		listKey(post.Category, ""),
+		listKey("", post.Author.Login),
+	)
+	if err != nil {
+		warn(ctx, "invalidate", err)
+	}
+}
+
+func (repo *PostCachedRepository) flush(ctx context.Context) error {
+	cursor := 0
+	for {
+		values, err := redis.Values(repo.do(ctx, "SCAN", cursor, "MATCH", keyPrefix+"*", "COUNT", 100))
+		if err != nil {
+			return err
+		}
+
+		keys := []string{}
+		if _, err = redis.Scan(values, &cursor, &keys); err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			if _, err = repo.do(ctx, "DEL", redis.Args{}.AddFlat(keys)...); err != nil {
+				return err
+			}
+		}
+
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// do выполняет одну команду на соединении из пула. Соединение, оборванное ошибкой
+// или отменой ctx, пул при возврате закрывает, а не отдает следующему запросу.
+func (repo *PostCachedRepository) do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	conn, err := repo.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.DoContext(conn, ctx, commandName, args...)
+}
+
+// warn пишет сбой Redis в лог: кэш необязателен, и запрос обслуживается хранилищем.
+func warn(ctx context.Context, method string, err error) {
+	tools.LoggerFrom(ctx).WithFields(logrus.Fields{
+		"method": "PostCachedRepository." + method,
+	}).Warn(err)
+}
@@ -0,0 +1,350 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	"redditclone/pkg/post/repository"
+	"redditclone/pkg/post/repository/memory"
+	"redditclone/pkg/post/repository/mock_repository"
+	"redditclone/pkg/post/repository/repotest"
+	"redditclone/tools"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newPool(t *testing.T, server *miniredis.Miniredis) *redis.Pool {
+	// Адрес берется заранее: у остановленного miniredis его уже не спросить.
+	addr := server.Addr()
+	pool := &redis.Pool{
+		MaxIdle: 4,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	return pool
+}
+
+func TestConformance(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	repotest.TestPostRepo(t, func(t *testing.T) repository.PostRepo {
+		server.FlushAll()
+		return NewPostCachedRepository(memory.NewPostMemoryRepository(), newPool(t, server), time.Minute, time.Minute)
+	})
+}
+
+func TestReadThrough(t *testing.T) {
+	tools.Init()
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := miniredis.RunT(t)
+	mockRepo := mock_repository.NewMockPostRepo(ctrl)
+	repo := NewPostCachedRepository(mockRepo, newPool(t, server), 5*time.Minute, 30*time.Second)
+
+	author := models.User{ID: 1, Login: "alex12345"}
+	post := &models.Post{
+		ID:       primitive.NewObjectID(),
+		Title:    "some title",
+		Category: "music",
+		Author:   author,
+		Votes:    []*models.Vote{{Author: author, AuthorID: author.ID, Vote: 1}},
+		Comments: []*models.Comment{},
+		Created:  time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("post", func(t *testing.T) {
+		mockRepo.EXPECT().GetPostByID(gomock.Any(), post.ID.Hex()).Return(post, nil).Times(1)
+		hits := metrics.CacheRequestsTotal.WithLabelValues("post", "hit")
+		before := testutil.ToFloat64(hits)
+
+		first, err := repo.GetPostByID(ctx, post.ID.Hex())
+		assert.NoError(t, err)
+		second, err := repo.GetPostByID(ctx, post.ID.Hex())
+		assert.NoError(t, err)
+
+		assert.Equal(t, post, second)
+		// Поля, скрытые от JSON, в кэше сохраняются.
+		assert.Equal(t, author, second.Votes[0].Author)
+		assert.NotSame(t, first, second)
+		assert.Equal(t, before+1, testutil.ToFloat64(hits))
+		assert.Equal(t, 5*time.Minute, server.TTL(postKey(post.ID.Hex())))
+	})
+
+	t.Run("listing", func(t *testing.T) {
+		mockRepo.EXPECT().GetAllPosts(gomock.Any(), "music", "").Return([]*models.Post{post}, nil).Times(1)
+		mockRepo.EXPECT().GetAllPosts(gomock.Any(), "news", "").Return([]*models.Post{}, nil).Times(1)
+
+		for i := 0; i < 2; i++ {
+			posts, err := repo.GetAllPosts(ctx, "music", "")
+			assert.NoError(t, err)
+			assert.Equal(t, []*models.Post{post}, posts)
+
+			posts, err = repo.GetAllPosts(ctx, "news", "")
+			assert.NoError(t, err)
+			assert.NotNil(t, posts)
+			assert.Empty(t, posts)
+		}
+		assert.Equal(t, 30*time.Second, server.TTL(listKey("music", "")))
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		missing := primitive.NewObjectID().Hex()
+		mockRepo.EXPECT().GetPostByID(gomock.Any(), missing).Return(nil, models.ErrNoPost).Times(2)
+
+		for i := 0; i < 2; i++ {
+			_, err := repo.GetPostByID(ctx, missing)
+			assert.Equal(t, models.ErrNoPost, err)
+		}
+		assert.False(t, server.Exists(postKey(missing)))
+	})
+
+	t.Run("redis is down", func(t *testing.T) {
+		broken := miniredis.RunT(t)
+		brokenRepo := NewPostCachedRepository(mockRepo, newPool(t, broken), time.Minute, time.Minute)
+		broken.Close()
+		mockRepo.EXPECT().GetPostByID(gomock.Any(), post.ID.Hex()).Return(post, nil)
+
+		found, err := brokenRepo.GetPostByID(ctx, post.ID.Hex())
+		assert.NoError(t, err)
+		assert.Equal(t, post.Title, found.Title)
+	})
+
+	t.Run("redis restarts", func(t *testing.T) {
+		restarted := miniredis.RunT(t)
+		restartedRepo := NewPostCachedRepository(mockRepo, newPool(t, restarted), time.Minute, time.Minute)
+		hits := metrics.CacheRequestsTotal.WithLabelValues("post", "hit")
+		errs := metrics.CacheRequestsTotal.WithLabelValues("post", "error")
+
+		// Соединение в пуле уже открыто, когда Redis падает.
+		mockRepo.EXPECT().GetPostByID(gomock.Any(), post.ID.Hex()).Return(post, nil).Times(2)
+		_, err := restartedRepo.GetPostByID(ctx, post.ID.Hex())
+		assert.NoError(t, err)
+		restarted.Close()
+
+		errsBefore := testutil.ToFloat64(errs)
+		_, err = restartedRepo.GetPostByID(ctx, post.ID.Hex())
+		assert.NoError(t, err)
+		assert.Equal(t, errsBefore+1, testutil.ToFloat64(errs))
+
+		// После перезапуска кэш снова работает без перезапуска процесса.
+		assert.NoError(t, restarted.Restart())
+		restarted.FlushAll()
+		mockRepo.EXPECT().GetPostByID(gomock.Any(), post.ID.Hex()).Return(post, nil).Times(1)
+		hitsBefore := testutil.ToFloat64(hits)
+		for i := 0; i < 2; i++ {
+			_, err = restartedRepo.GetPostByID(ctx, post.ID.Hex())
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, hitsBefore+1, testutil.ToFloat64(hits))
+		assert.Equal(t, errsBefore+1, testutil.ToFloat64(errs))
+
+		restartedRepo.invalidate(ctx, post)
+		assert.False(t, restarted.Exists(postKey(post.ID.Hex())))
+	})
+}
+
+func TestInvalidation(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := miniredis.RunT(t)
+	mockRepo := mock_repository.NewMockPostRepo(ctrl)
+	repo := NewPostCachedRepository(mockRepo, newPool(t, server), time.Minute, time.Minute)
+
+	user := &models.User{ID: 2, Login: "bob"}
+	post := &models.Post{ID: primitive.NewObjectID(), Category: "music", Author: models.User{ID: 1, Login: "alex12345"}}
+	comment := &models.Comment{ID: primitive.NewObjectID()}
+
+	affected := []string{postKey(post.ID.Hex()), listKey("", ""), listKey("music", ""), listKey("", "alex12345")}
+	untouched := []string{postKey(primitive.NewObjectID().Hex()), listKey("news", ""), listKey("", "bob"), "sessions:1"}
+
+	for name, write := range map[string]func() error{
+		"CreateNewPost": func() error {
+			mockRepo.EXPECT().CreateNewPost(gomock.Any(), "music", "title", "text", "", "body", user).Return(post, nil)
+			_, err := repo.CreateNewPost(ctx, "music", "title", "text", "", "body", user)
+			return err
+		},
+		"UpvotePost": func() error {
+			mockRepo.EXPECT().UpvotePost(gomock.Any(), user, post, 1).Return(nil)
+			return repo.UpvotePost(ctx, user, post, 1)
+		},
+		"AddPostComment": func() error {
+			mockRepo.EXPECT().AddPostComment(gomock.Any(), post, comment).Return(post, nil)
+			_, err := repo.AddPostComment(ctx, post, comment)
+			return err
+		},
+		"UpdatePostComment": func() error {
+			mockRepo.EXPECT().UpdatePostComment(gomock.Any(), post, comment).Return(nil)
+			return repo.UpdatePostComment(ctx, post, comment)
+		},
+		"DeletePostComment": func() error {
+			mockRepo.EXPECT().DeletePostComment(gomock.Any(), post, comment).Return(nil)
+			return repo.DeletePostComment(ctx, post, comment)
+		},
+		"DeletePost": func() error {
+			mockRepo.EXPECT().DeletePost(gomock.Any(), post).Return(nil)
+			return repo.DeletePost(ctx, post)
+		},
+		"failed DeletePost": func() error {
+			mockRepo.EXPECT().DeletePost(gomock.Any(), post).Return(errors.New("mock error"))
+			assert.Error(t, repo.DeletePost(ctx, post))
+			return nil
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server.FlushAll()
+			for _, key := range append(affected, untouched...) {
+				server.Set(key, "cached")
+			}
+
+			assert.NoError(t, write())
+
+			for _, key := range affected {
+				assert.False(t, server.Exists(key), key)
+			}
+			for _, key := range untouched {
+				assert.True(t, server.Exists(key), key)
+			}
+		})
+	}
+
+	t.Run("AnonymizeAuthor", func(t *testing.T) {
+		server.FlushAll()
+		for i := 0; i < 250; i++ {
+			server.Set(postKey(primitive.NewObjectID().Hex()), "cached")
+		}
+		server.Set(listKey("", ""), "cached")
+		server.Set("sessions:1", "session")
+		mockRepo.EXPECT().AnonymizeAuthor(gomock.Any(), 1).Return(nil)
+
+		assert.NoError(t, repo.AnonymizeAuthor(ctx, 1))
+		assert.Equal(t, []string{"sessions:1"}, server.Keys())
+	})
+}
+
+func TestSingleFlight(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := miniredis.RunT(t)
+	mockRepo := mock_repository.NewMockPostRepo(ctrl)
+	repo := NewPostCachedRepository(mockRepo, newPool(t, server), time.Minute, time.Minute)
+
+	const readers = 10
+	misses := metrics.CacheRequestsTotal.WithLabelValues("post", "miss")
+	before := testutil.ToFloat64(misses)
+
+	post := &models.Post{ID: primitive.NewObjectID(), Title: "some title"}
+	mockRepo.EXPECT().GetPostByID(gomock.Any(), post.ID.Hex()).DoAndReturn(func(ctx context.Context, id string) (*models.Post, error) {
+		// Держим загрузку, пока промах не случится у всех читателей.
+		assert.Eventually(t, func() bool {
+			return testutil.ToFloat64(misses) >= before+readers
+		}, time.Second, time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		return post, nil
+	}).Times(1)
+
+	results := make([]*models.Post, readers)
+	wg := &sync.WaitGroup{}
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			found, err := repo.GetPostByID(ctx, post.ID.Hex())
+			assert.NoError(t, err)
+			results[i] = found
+		}(i)
+	}
+	wg.Wait()
+
+	// Каждый читатель получил свою копию.
+	results[0].Title = "changed"
+	for _, found := range results[1:] {
+		assert.Equal(t, "some title", found.Title)
+	}
+}
+
+// slowRepo задерживает первое чтение GetPostByID уже после того, как оно прочитало пост.
+type slowRepo struct {
+	repository.PostRepo
+	once    sync.Once
+	loaded  chan struct{}
+	release chan struct{}
+}
+
+func (repo *slowRepo) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
+	post, err := repo.PostRepo.GetPostByID(ctx, id)
+	repo.once.Do(func() {
+		close(repo.loaded)
+		// Без таймаута запись, ошибочно читающая через кэш, ждала бы эту загрузку вечно.
+		select {
+		case <-repo.release:
+		case <-time.After(time.Second):
+		}
+	})
+	return post, err
+}
+
+func TestStaleLoadIsNotWrittenBack(t *testing.T) {
+	tools.Init()
+	ctx := context.Background()
+
+	server := miniredis.RunT(t)
+	storage := memory.NewPostMemoryRepository()
+	slow := &slowRepo{PostRepo: storage, loaded: make(chan struct{}), release: make(chan struct{})}
+	repo := NewPostCachedRepository(slow, newPool(t, server), time.Minute, time.Minute)
+
+	post, err := storage.CreateNewPost(ctx, "music", "title", "text", "", "body", &models.User{ID: 1, Login: "alex12345"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Чтение достало пост с одним голосом и застряло до записи.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := repo.GetPostByID(ctx, post.ID.Hex())
+		assert.NoError(t, err)
+	}()
+	<-slow.loaded
+
+	vote := func(user *models.User) {
+		found, err := repo.GetPostByIDForUpdate(ctx, post.ID.Hex())
+		if assert.NoError(t, err) {
+			assert.NoError(t, repo.UpvotePost(ctx, user, found, 1))
+		}
+	}
+	vote(&models.User{ID: 2, Login: "bob"})
+
+	// Старая версия попадает в кэш уже после сброса.
+	close(slow.release)
+	<-done
+	cached, err := repo.GetPostByID(ctx, post.ID.Hex())
+	assert.NoError(t, err)
+	assert.Len(t, cached.Votes, 1)
+
+	// Следующая запись строится на посте из хранилища и не теряет голос bob.
+	vote(&models.User{ID: 3, Login: "carol"})
+	stored, err := storage.GetPostByID(ctx, post.ID.Hex())
+	assert.NoError(t, err)
+	assert.Len(t, stored.Votes, 3)
+}
@@ -0,0 +1,105 @@
+package instrumented
+
+import (
+	"context"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	postRepository "redditclone/pkg/post/repository"
+	"redditclone/pkg/tracing"
+)
+
+// PostInstrumentedRepository пишет в метрики длительность и ошибки каждого вызова PostRepo
+// и открывает на него спан трассировки.
+type PostInstrumentedRepository struct {
+	Repo postRepository.PostRepo
+}
+
+func NewPostInstrumentedRepository(repo postRepository.PostRepo) *PostInstrumentedRepository {
+	return &PostInstrumentedRepository{
+		Repo: repo,
+	}
+}
+
+func (repo *PostInstrumentedRepository) GetAllPosts(ctx context.Context, category string, username string) (posts []*models.Post, err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.GetAllPosts")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "GetAllPosts")(&err)
+	return repo.Repo.GetAllPosts(ctx, category, username)
+}
+
+func (repo *PostInstrumentedRepository) GetPostsByAuthor(ctx context.Context, username string, opts *models.ListOptions) (posts []*models.Post, err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.GetPostsByAuthor")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "GetPostsByAuthor")(&err)
+	return repo.Repo.GetPostsByAuthor(ctx, username, opts)
+}
+
+func (repo *PostInstrumentedRepository) CountPostsByAuthor(ctx context.Context, username string) (count int, err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.CountPostsByAuthor")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "CountPostsByAuthor")(&err)
+	return repo.Repo.CountPostsByAuthor(ctx, username)
+}
+
+func (repo *PostInstrumentedRepository) CreateNewPost(ctx context.Context, category string, title string, postType string, url string, text string, user *models.User) (post *models.Post, err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.CreateNewPost")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "CreateNewPost")(&err)
+	return repo.Repo.CreateNewPost(ctx, category, title, postType, url, text, user)
+}
+
+func (repo *PostInstrumentedRepository) GetPostByID(ctx context.Context, id string) (post *models.Post, err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.GetPostByID")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "GetPostByID")(&err)
+	return repo.Repo.GetPostByID(ctx, id)
+}
+
+func (repo *PostInstrumentedRepository) GetPostByIDForUpdate(ctx context.Context, id string) (post *models.Post, err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.GetPostByIDForUpdate")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "GetPostByIDForUpdate")(&err)
+	return repo.Repo.GetPostByIDForUpdate(ctx, id)
+}
+
+func (repo *PostInstrumentedRepository) UpvotePost(ctx context.Context, user *models.User, post *models.Post, rate int) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.UpvotePost")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "UpvotePost")(&err)
+	return repo.Repo.UpvotePost(ctx, user, post, rate)
+}
+
+func (repo *PostInstrumentedRepository) DeletePostComment(ctx context.Context, post *models.Post, comment *models.Comment) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.DeletePostComment")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "DeletePostComment")(&err)
+	return repo.Repo.DeletePostComment(ctx, post, comment)
+}
+
+func (repo *PostInstrumentedRepository) AddPostComment(ctx context.Context, post *models.Post, comment *models.Comment) (updated *models.Post, err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.AddPostComment")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "AddPostComment")(&err)
+	return repo.Repo.AddPostComment(ctx, post, comment)
+}
+
+func (repo *PostInstrumentedRepository) UpdatePostComment(ctx context.Context, post *models.Post, comment *models.Comment) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.UpdatePostComment")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "UpdatePostComment")(&err)
+	return repo.Repo.UpdatePostComment(ctx, post, comment)
+}
+
+func (repo *PostInstrumentedRepository) DeletePost(ctx context.Context, post *models.Post) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.DeletePost")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "DeletePost")(&err)
+	return repo.Repo.DeletePost(ctx, post)
+}
+
+func (repo *PostInstrumentedRepository) AnonymizeAuthor(ctx context.Context, userID int) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "PostRepo.AnonymizeAuthor")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("post", "AnonymizeAuthor")(&err)
+	return repo.Repo.AnonymizeAuthor(ctx, userID)
+}
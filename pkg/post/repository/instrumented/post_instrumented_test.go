@@ -0,0 +1,38 @@
+package instrumented
+
+import (
+	"context"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	"redditclone/pkg/post/repository"
+	"redditclone/pkg/post/repository/memory"
+	"redditclone/pkg/post/repository/repotest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.TestPostRepo(t, func(t *testing.T) repository.PostRepo {
+		return NewPostInstrumentedRepository(memory.NewPostMemoryRepository())
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewPostInstrumentedRepository(memory.NewPostMemoryRepository())
+	errors := metrics.RepositoryCallErrors.WithLabelValues("post", "GetPostByID")
+	before := testutil.ToFloat64(errors)
+
+	post, err := repo.CreateNewPost(ctx, "music", "title", "text", "", "body", &models.User{ID: 1, Login: "alex12345"})
+	assert.NoError(t, err)
+	_, err = repo.GetPostByID(ctx, post.ID.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, before, testutil.ToFloat64(errors))
+
+	_, err = repo.GetPostByID(ctx, "bad id")
+	assert.Equal(t, models.ErrCorruptedPostID, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(errors))
+}
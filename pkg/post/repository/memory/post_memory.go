@@ -0,0 +1,333 @@
+package memory
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PostMemoryRepository хранит посты в памяти процесса: для разработки без Mongo и для тестов.
+// Наружу отдаются только копии, чтобы вызывающий код не мог менять хранилище в обход методов.
+type PostMemoryRepository struct {
+	mu    *sync.RWMutex
+	posts map[primitive.ObjectID]*models.Post
+	order []primitive.ObjectID
+}
+
+func NewPostMemoryRepository() *PostMemoryRepository {
+	return &PostMemoryRepository{
+		mu:    &sync.RWMutex{},
+		posts: make(map[primitive.ObjectID]*models.Post),
+		order: []primitive.ObjectID{},
+	}
+}
+
+func (repo *PostMemoryRepository) GetAllPosts(ctx context.Context, category string, username string) ([]*models.Post, error) {
+	if category != "" {
+		if _, ok := models.PostCategories[category]; !ok {
+			return nil, models.ErrIncorrectPostCategory
+		}
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	posts := []*models.Post{}
+	for _, id := range repo.order {
+		post := repo.posts[id]
+		if category != "" && post.Category != category {
+			continue
+		} else if category == "" && username != "" && post.Author.Login != username {
+			continue
+		}
+		posts = append(posts, clonePost(post))
+	}
+
+	return posts, nil
+}
+
+// GetPostsByAuthor сортирует так же, как индексы в Mongo: по дате или по рейтингу, затем по дате.
+func (repo *PostMemoryRepository) GetPostsByAuthor(ctx context.Context, username string, listOpts *models.ListOptions) ([]*models.Post, error) {
+	repo.mu.RLock()
+	posts := []*models.Post{}
+	for _, id := range repo.order {
+		post := repo.posts[id]
+		if post.Author.Login == username {
+			posts = append(posts, clonePost(post))
+		}
+	}
+	repo.mu.RUnlock()
+
+	byTop := listOpts != nil && listOpts.Sort == models.SortTop
+	slices.SortStableFunc(posts, func(a, b *models.Post) int {
+		if byTop && a.Score != b.Score {
+			return b.Score - a.Score
+		}
+		return b.Created.Compare(a.Created)
+	})
+
+	if listOpts != nil {
+		if listOpts.Offset > 0 {
+			posts = posts[min(listOpts.Offset, len(posts)):]
+		}
+		if listOpts.Limit > 0 && listOpts.Limit < len(posts) {
+			posts = posts[:listOpts.Limit]
+		}
+	}
+
+	return posts, nil
+}
+
+func (repo *PostMemoryRepository) CountPostsByAuthor(ctx context.Context, username string) (int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	count := 0
+	for _, post := range repo.posts {
+		if post.Author.Login == username {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (repo *PostMemoryRepository) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
+	primitiveID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, models.ErrCorruptedPostID
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	post, ok := repo.posts[primitiveID]
+	if !ok {
+		return nil, models.ErrNoPost
+	}
+
+	return clonePost(post), nil
+}
+
+func (repo *PostMemoryRepository) GetPostByIDForUpdate(ctx context.Context, id string) (*models.Post, error) {
+	return repo.GetPostByID(ctx, id)
+}
+
+func (repo *PostMemoryRepository) CreateNewPost(ctx context.Context, category string, title string, postType string, url string, text string, user *models.User) (*models.Post, error) {
+	newPost := &models.Post{
+		ID:               primitive.NewObjectID(),
+		Score:            1,
+		Views:            1,
+		Type:             postType,
+		Title:            title,
+		Author:           *user,
+		Category:         category,
+		Text:             text,
+		URL:              url,
+		Created:          time.Now(),
+		UpvotePercentage: 100,
+		Votes: []*models.Vote{
+			{
+				Author:   *user,
+				AuthorID: user.ID,
+				Vote:     1,
+			},
+		},
+		Comments: []*models.Comment{},
+	}
+
+	repo.mu.Lock()
+	repo.posts[newPost.ID] = clonePost(newPost)
+	repo.order = append(repo.order, newPost.ID)
+	repo.mu.Unlock()
+
+	return newPost, nil
+}
+
+func (repo *PostMemoryRepository) UpvotePost(ctx context.Context, user *models.User, post *models.Post, rate int) error {
+	if rate > 1 || rate < -1 {
+		return models.ErrUnrecognizedRate
+	}
+
+	voteIndex := slices.IndexFunc(post.Votes, func(vote *models.Vote) bool {
+		return vote.Author.ID == user.ID
+	})
+
+	if voteIndex != -1 {
+		post.Score -= post.Votes[voteIndex].Vote
+		post.Votes = slices.Delete(post.Votes, voteIndex, voteIndex+1)
+	}
+
+	if rate == 1 || rate == -1 {
+		post.Votes = append(post.Votes, &models.Vote{
+			Author:   *user,
+			AuthorID: user.ID,
+			Vote:     rate,
+		})
+		post.Score += rate
+	}
+
+	if post.Score < 0 || len(post.Votes) == 0 {
+		post.UpvotePercentage = 0
+	} else {
+		post.UpvotePercentage = post.Score / len(post.Votes) * 100
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.posts[post.ID]
+	if !ok {
+		return models.ErrNoPost
+	}
+	stored.Votes = cloneVotes(post.Votes)
+	stored.Score = post.Score
+	stored.UpvotePercentage = post.UpvotePercentage
+
+	return nil
+}
+
+func (repo *PostMemoryRepository) DeletePostComment(ctx context.Context, post *models.Post, deleteComment *models.Comment) error {
+	commentIndex := slices.IndexFunc(post.Comments, func(comment *models.Comment) bool {
+		return comment.ID == deleteComment.ID
+	})
+
+	if commentIndex != -1 {
+		post.Comments = slices.Delete(post.Comments, commentIndex, commentIndex+1)
+	}
+
+	return repo.saveComments(post)
+}
+
+func (repo *PostMemoryRepository) AddPostComment(ctx context.Context, post *models.Post, comment *models.Comment) (*models.Post, error) {
+	post.Comments = append(post.Comments, comment)
+
+	if err := repo.saveComments(post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (repo *PostMemoryRepository) UpdatePostComment(ctx context.Context, post *models.Post, updatedComment *models.Comment) error {
+	commentIndex := slices.IndexFunc(post.Comments, func(comment *models.Comment) bool {
+		return comment.ID == updatedComment.ID
+	})
+	if commentIndex == -1 {
+		return models.ErrNoComment
+	}
+	post.Comments[commentIndex] = updatedComment
+
+	return repo.saveComments(post)
+}
+
+func (repo *PostMemoryRepository) saveComments(post *models.Post) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.posts[post.ID]
+	if !ok {
+		return models.ErrNoPost
+	}
+	stored.Comments = cloneComments(post.Comments)
+
+	return nil
+}
+
+func (repo *PostMemoryRepository) DeletePost(ctx context.Context, post *models.Post) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.posts[post.ID]; !ok {
+		return models.ErrNoPost
+	}
+
+	delete(repo.posts, post.ID)
+	repo.order = slices.DeleteFunc(repo.order, func(id primitive.ObjectID) bool {
+		return id == post.ID
+	})
+
+	return nil
+}
+
+func (repo *PostMemoryRepository) AnonymizeAuthor(ctx context.Context, userID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, post := range repo.posts {
+		if post.Author.ID == userID {
+			post.Author.ID = models.DeletedUserID
+			post.Author.Login = models.DeletedUserLogin
+		}
+		anonymizeVotes(post.Votes, userID)
+
+		for _, comment := range post.Comments {
+			if comment.Author != nil && comment.Author.ID == userID {
+				comment.Author.ID = models.DeletedUserID
+				comment.Author.Login = models.DeletedUserLogin
+			}
+			anonymizeVotes(comment.Votes, userID)
+		}
+	}
+
+	return nil
+}
+
+// anonymizeVotes оставляет голоса удаленного пользователя в рейтинге, но стирает из них автора.
+func anonymizeVotes(votes []*models.Vote, userID int) {
+	for _, vote := range votes {
+		if vote.AuthorID == userID {
+			vote.AuthorID = models.DeletedUserID
+			vote.Author = models.User{ID: models.DeletedUserID, Login: models.DeletedUserLogin}
+		}
+	}
+}
+
+func clonePost(post *models.Post) *models.Post {
+	clone := *post
+	clone.Votes = cloneVotes(post.Votes)
+	clone.Comments = cloneComments(post.Comments)
+
+	return &clone
+}
+
+func cloneVotes(votes []*models.Vote) []*models.Vote {
+	if votes == nil {
+		return nil
+	}
+
+	clone := make([]*models.Vote, 0, len(votes))
+	for _, vote := range votes {
+		voteClone := *vote
+		clone = append(clone, &voteClone)
+	}
+
+	return clone
+}
+
+func cloneComments(comments []*models.Comment) []*models.Comment {
+	if comments == nil {
+		return nil
+	}
+
+	clone := make([]*models.Comment, 0, len(comments))
+	for _, comment := range comments {
+		commentClone := *comment
+		if comment.Author != nil {
+			author := *comment.Author
+			commentClone.Author = &author
+		}
+		if comment.Post != nil {
+			postRef := *comment.Post
+			commentClone.Post = &postRef
+		}
+		commentClone.Votes = cloneVotes(comment.Votes)
+		clone = append(clone, &commentClone)
+	}
+
+	return clone
+}
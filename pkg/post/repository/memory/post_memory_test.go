@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"redditclone/pkg/post/repository"
+	"redditclone/pkg/post/repository/repotest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPosts(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewPostMemoryRepository()
+	author := &models.User{ID: 1, Login: "alex12345"}
+
+	post, err := repo.CreateNewPost(ctx, "music", "title", "text", "", "body", author)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, post.Score)
+	assert.Len(t, post.Votes, 1)
+	repo.CreateNewPost(ctx, "news", "other", "link", "http://example.com", "", &models.User{ID: 2, Login: "bob"})
+
+	t.Run("get by id", func(t *testing.T) {
+		found, err := repo.GetPostByID(ctx, post.ID.Hex())
+		assert.NoError(t, err)
+		assert.Equal(t, post.Title, found.Title)
+
+		_, err = repo.GetPostByID(ctx, "bad id")
+		assert.Equal(t, models.ErrCorruptedPostID, err)
+
+		_, err = repo.GetPostByID(ctx, primitive.NewObjectID().Hex())
+		assert.Equal(t, models.ErrNoPost, err)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		all, _ := repo.GetAllPosts(ctx, "", "")
+		assert.Len(t, all, 2)
+		assert.Equal(t, post.ID, all[0].ID)
+
+		byCategory, _ := repo.GetAllPosts(ctx, "news", "")
+		assert.Len(t, byCategory, 1)
+
+		byUser, _ := repo.GetAllPosts(ctx, "", "alex12345")
+		assert.Len(t, byUser, 1)
+
+		_, err := repo.GetAllPosts(ctx, "unknown", "")
+		assert.Equal(t, models.ErrIncorrectPostCategory, err)
+	})
+
+	t.Run("vote", func(t *testing.T) {
+		voter := &models.User{ID: 2, Login: "bob"}
+		found, _ := repo.GetPostByID(ctx, post.ID.Hex())
+		assert.NoError(t, repo.UpvotePost(ctx, voter, found, -1))
+		assert.Equal(t, models.ErrUnrecognizedRate, repo.UpvotePost(ctx, voter, found, 2))
+
+		stored, _ := repo.GetPostByID(ctx, post.ID.Hex())
+		assert.Equal(t, 0, stored.Score)
+		assert.Len(t, stored.Votes, 2)
+	})
+
+	t.Run("comments", func(t *testing.T) {
+		comment := &models.Comment{ID: primitive.NewObjectID(), Author: author, Text: "first"}
+		found, _ := repo.GetPostByID(ctx, post.ID.Hex())
+		_, err := repo.AddPostComment(ctx, found, comment)
+		assert.NoError(t, err)
+
+		comment.Text = "changed outside"
+		stored, _ := repo.GetPostByID(ctx, post.ID.Hex())
+		assert.Equal(t, "first", stored.Comments[0].Text)
+
+		assert.NoError(t, repo.UpdatePostComment(ctx, stored, &models.Comment{ID: comment.ID, Author: author, Text: "edited"}))
+		assert.Equal(t, models.ErrNoComment, repo.UpdatePostComment(ctx, stored, &models.Comment{ID: primitive.NewObjectID()}))
+
+		assert.NoError(t, repo.AnonymizeAuthor(ctx, author.ID))
+		stored, _ = repo.GetPostByID(ctx, post.ID.Hex())
+		assert.Equal(t, models.DeletedUserLogin, stored.Author.Login)
+		assert.Equal(t, models.DeletedUserLogin, stored.Comments[0].Author.Login)
+		assert.Equal(t, "alex12345", author.Login)
+
+		assert.NoError(t, repo.DeletePostComment(ctx, stored, stored.Comments[0]))
+		stored, _ = repo.GetPostByID(ctx, post.ID.Hex())
+		assert.Empty(t, stored.Comments)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, repo.DeletePost(ctx, post))
+		assert.Equal(t, models.ErrNoPost, repo.DeletePost(ctx, post))
+
+		_, err := repo.AddPostComment(ctx, post, &models.Comment{})
+		assert.Equal(t, models.ErrNoPost, err)
+	})
+}
+
+func TestConformance(t *testing.T) {
+	repotest.TestPostRepo(t, func(t *testing.T) repository.PostRepo {
+		return NewPostMemoryRepository()
+	})
+}
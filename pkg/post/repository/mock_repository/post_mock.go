@@ -5,6 +5,7 @@
 package mock_repository
 
 import (
+	context "context"
 	models "redditclone/pkg/models"
 	reflect "reflect"
 
@@ -35,103 +36,176 @@ func (m *MockPostRepo) EXPECT() *MockPostRepoMockRecorder {
 }
 
 // AddPostComment mocks base method.
-func (m *MockPostRepo) AddPostComment(post *models.Post, comment *models.Comment) (*models.Post, error) {
+func (m *MockPostRepo) AddPostComment(ctx context.Context, post *models.Post, comment *models.Comment) (*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddPostComment", post, comment)
+	ret := m.ctrl.Call(m, "AddPostComment", ctx, post, comment)
 	ret0, _ := ret[0].(*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddPostComment indicates an expected call of AddPostComment.
-func (mr *MockPostRepoMockRecorder) AddPostComment(post, comment interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) AddPostComment(ctx, post, comment interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPostComment", reflect.TypeOf((*MockPostRepo)(nil).AddPostComment), post, comment)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPostComment", reflect.TypeOf((*MockPostRepo)(nil).AddPostComment), ctx, post, comment)
+}
+
+// AnonymizeAuthor mocks base method.
+func (m *MockPostRepo) AnonymizeAuthor(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeAuthor", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AnonymizeAuthor indicates an expected call of AnonymizeAuthor.
+func (mr *MockPostRepoMockRecorder) AnonymizeAuthor(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeAuthor", reflect.TypeOf((*MockPostRepo)(nil).AnonymizeAuthor), ctx, userID)
+}
+
+// CountPostsByAuthor mocks base method.
+func (m *MockPostRepo) CountPostsByAuthor(ctx context.Context, username string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountPostsByAuthor", ctx, username)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountPostsByAuthor indicates an expected call of CountPostsByAuthor.
+func (mr *MockPostRepoMockRecorder) CountPostsByAuthor(ctx, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountPostsByAuthor", reflect.TypeOf((*MockPostRepo)(nil).CountPostsByAuthor), ctx, username)
 }
 
 // CreateNewPost mocks base method.
-func (m *MockPostRepo) CreateNewPost(category, title, postType, url, text string, user *models.User) (*models.Post, error) {
+func (m *MockPostRepo) CreateNewPost(ctx context.Context, category, title, postType, url, text string, user *models.User) (*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateNewPost", category, title, postType, url, text, user)
+	ret := m.ctrl.Call(m, "CreateNewPost", ctx, category, title, postType, url, text, user)
 	ret0, _ := ret[0].(*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateNewPost indicates an expected call of CreateNewPost.
-func (mr *MockPostRepoMockRecorder) CreateNewPost(category, title, postType, url, text, user interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) CreateNewPost(ctx, category, title, postType, url, text, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNewPost", reflect.TypeOf((*MockPostRepo)(nil).CreateNewPost), category, title, postType, url, text, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNewPost", reflect.TypeOf((*MockPostRepo)(nil).CreateNewPost), ctx, category, title, postType, url, text, user)
 }
 
 // DeletePost mocks base method.
-func (m *MockPostRepo) DeletePost(post *models.Post) error {
+func (m *MockPostRepo) DeletePost(ctx context.Context, post *models.Post) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeletePost", post)
+	ret := m.ctrl.Call(m, "DeletePost", ctx, post)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeletePost indicates an expected call of DeletePost.
-func (mr *MockPostRepoMockRecorder) DeletePost(post interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DeletePost(ctx, post interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostRepo)(nil).DeletePost), post)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostRepo)(nil).DeletePost), ctx, post)
 }
 
 // DeletePostComment mocks base method.
-func (m *MockPostRepo) DeletePostComment(post *models.Post, comment *models.Comment) error {
+func (m *MockPostRepo) DeletePostComment(ctx context.Context, post *models.Post, comment *models.Comment) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeletePostComment", post, comment)
+	ret := m.ctrl.Call(m, "DeletePostComment", ctx, post, comment)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeletePostComment indicates an expected call of DeletePostComment.
-func (mr *MockPostRepoMockRecorder) DeletePostComment(post, comment interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DeletePostComment(ctx, post, comment interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePostComment", reflect.TypeOf((*MockPostRepo)(nil).DeletePostComment), post, comment)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePostComment", reflect.TypeOf((*MockPostRepo)(nil).DeletePostComment), ctx, post, comment)
 }
 
 // GetAllPosts mocks base method.
-func (m *MockPostRepo) GetAllPosts(category, username string) ([]*models.Post, error) {
+func (m *MockPostRepo) GetAllPosts(ctx context.Context, category, username string) ([]*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllPosts", category, username)
+	ret := m.ctrl.Call(m, "GetAllPosts", ctx, category, username)
 	ret0, _ := ret[0].([]*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllPosts indicates an expected call of GetAllPosts.
-func (mr *MockPostRepoMockRecorder) GetAllPosts(category, username interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetAllPosts(ctx, category, username interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPosts", reflect.TypeOf((*MockPostRepo)(nil).GetAllPosts), category, username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPosts", reflect.TypeOf((*MockPostRepo)(nil).GetAllPosts), ctx, category, username)
 }
 
 // GetPostByID mocks base method.
-func (m *MockPostRepo) GetPostByID(id string) (*models.Post, error) {
+func (m *MockPostRepo) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostByID", id)
+	ret := m.ctrl.Call(m, "GetPostByID", ctx, id)
 	ret0, _ := ret[0].(*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostByID indicates an expected call of GetPostByID.
-func (mr *MockPostRepoMockRecorder) GetPostByID(id interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetPostByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByID", reflect.TypeOf((*MockPostRepo)(nil).GetPostByID), ctx, id)
+}
+
+// GetPostByIDForUpdate mocks base method.
+func (m *MockPostRepo) GetPostByIDForUpdate(ctx context.Context, id string) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostByIDForUpdate", ctx, id)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostByIDForUpdate indicates an expected call of GetPostByIDForUpdate.
+func (mr *MockPostRepoMockRecorder) GetPostByIDForUpdate(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByIDForUpdate", reflect.TypeOf((*MockPostRepo)(nil).GetPostByIDForUpdate), ctx, id)
+}
+
+// GetPostsByAuthor mocks base method.
+func (m *MockPostRepo) GetPostsByAuthor(ctx context.Context, username string, opts *models.ListOptions) ([]*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostsByAuthor", ctx, username, opts)
+	ret0, _ := ret[0].([]*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostsByAuthor indicates an expected call of GetPostsByAuthor.
+func (mr *MockPostRepoMockRecorder) GetPostsByAuthor(ctx, username, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByAuthor", reflect.TypeOf((*MockPostRepo)(nil).GetPostsByAuthor), ctx, username, opts)
+}
+
+// UpdatePostComment mocks base method.
+func (m *MockPostRepo) UpdatePostComment(ctx context.Context, post *models.Post, comment *models.Comment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePostComment", ctx, post, comment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePostComment indicates an expected call of UpdatePostComment.
+func (mr *MockPostRepoMockRecorder) UpdatePostComment(ctx, post, comment interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByID", reflect.TypeOf((*MockPostRepo)(nil).GetPostByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePostComment", reflect.TypeOf((*MockPostRepo)(nil).UpdatePostComment), ctx, post, comment)
 }
 
 // UpvotePost mocks base method.
-func (m *MockPostRepo) UpvotePost(user *models.User, post *models.Post, rate int) error {
+func (m *MockPostRepo) UpvotePost(ctx context.Context, user *models.User, post *models.Post, rate int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpvotePost", user, post, rate)
+	ret := m.ctrl.Call(m, "UpvotePost", ctx, user, post, rate)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpvotePost indicates an expected call of UpvotePost.
-func (mr *MockPostRepoMockRecorder) UpvotePost(user, post, rate interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) UpvotePost(ctx, user, post, rate interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpvotePost", reflect.TypeOf((*MockPostRepo)(nil).UpvotePost), user, post, rate)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpvotePost", reflect.TypeOf((*MockPostRepo)(nil).UpvotePost), ctx, user, post, rate)
 }
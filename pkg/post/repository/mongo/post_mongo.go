@@ -9,33 +9,28 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// deletedVoteAuthor заменяет автора в голосах удаленного пользователя.
+var deletedVoteAuthor = bson.M{"id": models.DeletedUserID, "username": models.DeletedUserLogin}
+
 type PostMongoDBRepository struct {
 	DB *mongo.Collection
 }
 
-var validPostCategories = map[string]bool{
-	"music":       true,
-	"funny":       true,
-	"videos":      true,
-	"programming": true,
-	"news":        true,
-	"fashion":     true,
-}
-
 func NewPostMongoDBMemoryRepo(postsCollection *mongo.Collection) *PostMongoDBRepository {
 	return &PostMongoDBRepository{
 		DB: postsCollection,
 	}
 }
 
-func (repo *PostMongoDBRepository) GetAllPosts(category string, username string) ([]*models.Post, error) {
+func (repo *PostMongoDBRepository) GetAllPosts(ctx context.Context, category string, username string) ([]*models.Post, error) {
 	posts := []*models.Post{}
 
 	filter := bson.M{}
 	if category != "" {
-		if _, ok := validPostCategories[category]; !ok {
+		if _, ok := models.PostCategories[category]; !ok {
 			return nil, models.ErrIncorrectPostCategory
 		}
 		filter["category"] = category
@@ -43,12 +38,42 @@ func (repo *PostMongoDBRepository) GetAllPosts(category string, username string)
 		filter["author.username"] = username
 	}
 
-	cursor, err := repo.DB.Find(context.Background(), filter)
+	cursor, err := repo.DB.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &posts)
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func (repo *PostMongoDBRepository) GetPostsByAuthor(ctx context.Context, username string, listOpts *models.ListOptions) ([]*models.Post, error) {
+	posts := []*models.Post{}
+
+	filter := bson.M{"author.username": username}
+	findOpts := options.Find().SetSort(bson.D{{Key: "created", Value: -1}})
+	if listOpts != nil {
+		if listOpts.Sort == models.SortTop {
+			findOpts.SetSort(bson.D{{Key: "score", Value: -1}, {Key: "created", Value: -1}})
+		}
+		if listOpts.Offset > 0 {
+			findOpts.SetSkip(int64(listOpts.Offset))
+		}
+		if listOpts.Limit > 0 {
+			findOpts.SetLimit(int64(listOpts.Limit))
+		}
+	}
+
+	cursor, err := repo.DB.Find(ctx, filter, findOpts)
 	if err != nil {
 		return nil, err
 	}
 
-	err = cursor.All(context.Background(), &posts)
+	err = cursor.All(ctx, &posts)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +81,16 @@ func (repo *PostMongoDBRepository) GetAllPosts(category string, username string)
 	return posts, nil
 }
 
-func (repo *PostMongoDBRepository) GetPostByID(id string) (*models.Post, error) {
+func (repo *PostMongoDBRepository) CountPostsByAuthor(ctx context.Context, username string) (int, error) {
+	count, err := repo.DB.CountDocuments(ctx, bson.M{"author.username": username})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+func (repo *PostMongoDBRepository) GetPostByID(ctx context.Context, id string) (*models.Post, error) {
 	primitiveID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, models.ErrCorruptedPostID
@@ -64,7 +98,7 @@ func (repo *PostMongoDBRepository) GetPostByID(id string) (*models.Post, error)
 	filter := bson.M{"_id": primitiveID}
 	post := models.Post{}
 
-	err = repo.DB.FindOne(context.Background(), filter).Decode(&post)
+	err = repo.DB.FindOne(ctx, filter).Decode(&post)
 	if err == mongo.ErrNoDocuments {
 		return nil, models.ErrNoPost
 	} else if err != nil {
@@ -74,7 +108,11 @@ func (repo *PostMongoDBRepository) GetPostByID(id string) (*models.Post, error)
 	return &post, nil
 }
 
-func (repo *PostMongoDBRepository) CreateNewPost(category string, title string, postType string, url string, text string, user *models.User) (*models.Post, error) {
+func (repo *PostMongoDBRepository) GetPostByIDForUpdate(ctx context.Context, id string) (*models.Post, error) {
+	return repo.GetPostByID(ctx, id)
+}
+
+func (repo *PostMongoDBRepository) CreateNewPost(ctx context.Context, category string, title string, postType string, url string, text string, user *models.User) (*models.Post, error) {
 	newPostBSON := bson.M{
 		"_id":              primitive.NewObjectID(),
 		"score":            1,
@@ -100,7 +138,7 @@ func (repo *PostMongoDBRepository) CreateNewPost(category string, title string,
 		return nil, err
 	}
 
-	_, err = repo.DB.InsertOne(context.Background(), newPostBSON)
+	_, err = repo.DB.InsertOne(ctx, newPostBSON)
 	if err != nil {
 		return nil, err
 	}
@@ -114,7 +152,7 @@ func (repo *PostMongoDBRepository) CreateNewPost(category string, title string,
 	return newPost, nil
 }
 
-func (repo *PostMongoDBRepository) UpvotePost(user *models.User, post *models.Post, rate int) error {
+func (repo *PostMongoDBRepository) UpvotePost(ctx context.Context, user *models.User, post *models.Post, rate int) error {
 	if rate > 1 || rate < -1 {
 		return models.ErrUnrecognizedRate
 	}
@@ -147,7 +185,7 @@ func (repo *PostMongoDBRepository) UpvotePost(user *models.User, post *models.Po
 
 	filter := bson.M{"_id": post.ID}
 	res, err := repo.DB.UpdateOne(
-		context.Background(),
+		ctx,
 		filter,
 		bson.M{"$set": bson.M{
 			"votes":            post.Votes,
@@ -157,14 +195,14 @@ func (repo *PostMongoDBRepository) UpvotePost(user *models.User, post *models.Po
 	)
 	if err != nil {
 		return models.ErrUpdatePost
-	} else if res.ModifiedCount == 0 {
+	} else if res.MatchedCount == 0 {
 		return models.ErrNoPost
 	}
 
 	return nil
 }
 
-func (repo *PostMongoDBRepository) DeletePostComment(post *models.Post, deleteComment *models.Comment) error {
+func (repo *PostMongoDBRepository) DeletePostComment(ctx context.Context, post *models.Post, deleteComment *models.Comment) error {
 	commentIndex := slices.IndexFunc(post.Comments, func(comment *models.Comment) bool {
 		return comment.ID == deleteComment.ID
 	})
@@ -175,7 +213,7 @@ func (repo *PostMongoDBRepository) DeletePostComment(post *models.Post, deleteCo
 
 	filter := bson.M{"_id": post.ID}
 	res, err := repo.DB.UpdateOne(
-		context.Background(),
+		ctx,
 		filter,
 		bson.M{"$set": bson.M{
 			"comments": post.Comments,
@@ -184,19 +222,19 @@ func (repo *PostMongoDBRepository) DeletePostComment(post *models.Post, deleteCo
 
 	if err != nil {
 		return models.ErrUpdatePost
-	} else if res.ModifiedCount == 0 {
+	} else if res.MatchedCount == 0 {
 		return models.ErrNoPost
 	}
 
 	return nil
 }
 
-func (repo *PostMongoDBRepository) AddPostComment(post *models.Post, comment *models.Comment) (*models.Post, error) {
+func (repo *PostMongoDBRepository) AddPostComment(ctx context.Context, post *models.Post, comment *models.Comment) (*models.Post, error) {
 	post.Comments = append(post.Comments, comment)
 
 	filter := bson.M{"_id": post.ID}
 	res, err := repo.DB.UpdateOne(
-		context.Background(),
+		ctx,
 		filter,
 		bson.M{"$set": bson.M{
 			"comments": post.Comments,
@@ -205,17 +243,44 @@ func (repo *PostMongoDBRepository) AddPostComment(post *models.Post, comment *mo
 
 	if err != nil {
 		return nil, models.ErrUpdatePost
-	} else if res.ModifiedCount == 0 {
+	} else if res.MatchedCount == 0 {
 		return nil, models.ErrNoPost
 	}
 
 	return post, nil
 }
 
-func (repo *PostMongoDBRepository) DeletePost(post *models.Post) error {
+func (repo *PostMongoDBRepository) UpdatePostComment(ctx context.Context, post *models.Post, updatedComment *models.Comment) error {
+	commentIndex := slices.IndexFunc(post.Comments, func(comment *models.Comment) bool {
+		return comment.ID == updatedComment.ID
+	})
+	if commentIndex == -1 {
+		return models.ErrNoComment
+	}
+	post.Comments[commentIndex] = updatedComment
+
 	filter := bson.M{"_id": post.ID}
+	res, err := repo.DB.UpdateOne(
+		ctx,
+		filter,
+		bson.M{"$set": bson.M{
+			"comments": post.Comments,
+		}},
+	)
 
-	res, err := repo.DB.DeleteOne(context.Background(), filter)
+	if err != nil {
+		return models.ErrUpdatePost
+	} else if res.MatchedCount == 0 {
+		return models.ErrNoPost
+	}
+
+	return nil
+}
+
+func (repo *PostMongoDBRepository) DeletePost(ctx context.Context, post *models.Post) error {
+	filter := bson.M{"_id": post.ID}
+
+	res, err := repo.DB.DeleteOne(ctx, filter)
 	if err != nil {
 		return models.ErrDeletePost
 	} else if res.DeletedCount == 0 {
@@ -224,3 +289,65 @@ func (repo *PostMongoDBRepository) DeletePost(post *models.Post) error {
 
 	return nil
 }
+
+func (repo *PostMongoDBRepository) AnonymizeAuthor(ctx context.Context, userID int) error {
+	_, err := repo.DB.UpdateMany(
+		ctx,
+		bson.M{"author.id": userID},
+		bson.M{"$set": bson.M{
+			"author.id":       models.DeletedUserID,
+			"author.username": models.DeletedUserLogin,
+		}},
+	)
+	if err != nil {
+		return models.ErrUpdatePost
+	}
+
+	_, err = repo.DB.UpdateMany(
+		ctx,
+		bson.M{"comments.author.id": userID},
+		bson.M{"$set": bson.M{
+			"comments.$[comment].author.id":       models.DeletedUserID,
+			"comments.$[comment].author.username": models.DeletedUserLogin,
+		}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"comment.author.id": userID}},
+		}),
+	)
+	if err != nil {
+		return models.ErrUpdatePost
+	}
+
+	// Голоса остаются в рейтинге, но в них не должно остаться ни id, ни логина автора.
+	_, err = repo.DB.UpdateMany(
+		ctx,
+		bson.M{"votes.user": userID},
+		bson.M{"$set": bson.M{
+			"votes.$[vote].user":   models.DeletedUserID,
+			"votes.$[vote].author": deletedVoteAuthor,
+		}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"vote.user": userID}},
+		}),
+	)
+	if err != nil {
+		return models.ErrUpdatePost
+	}
+
+	_, err = repo.DB.UpdateMany(
+		ctx,
+		bson.M{"comments.votes.user": userID},
+		bson.M{"$set": bson.M{
+			"comments.$[].votes.$[vote].user":   models.DeletedUserID,
+			"comments.$[].votes.$[vote].author": deletedVoteAuthor,
+		}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"vote.user": userID}},
+		}),
+	)
+	if err != nil {
+		return models.ErrUpdatePost
+	}
+
+	return nil
+}
@@ -1,14 +1,20 @@
 package mongo
 
 import (
+	"context"
+	"os"
 	"redditclone/pkg/models"
+	"redditclone/pkg/post/repository"
+	"redditclone/pkg/post/repository/repotest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func TestNewPostMongoDBMemoryRepo(t *testing.T) {
@@ -24,6 +30,8 @@ func TestNewPostMongoDBMemoryRepo(t *testing.T) {
 }
 
 func TestGetPostByID(t *testing.T) {
+	ctx := context.Background()
+
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 	// defer mt.Close()
 
@@ -76,7 +84,7 @@ func TestGetPostByID(t *testing.T) {
 
 		mt.AddMockResponses(response)
 
-		post, err := repo.GetPostByID(expectedPost.ID.Hex())
+		post, err := repo.GetPostByID(ctx, expectedPost.ID.Hex())
 		assert.Nil(t, err)
 		assert.Equal(t, expectedPost, post)
 	})
@@ -88,7 +96,7 @@ func TestGetPostByID(t *testing.T) {
 
 		incorrectPostID := "qwe"
 
-		_, err := repo.GetPostByID(incorrectPostID)
+		_, err := repo.GetPostByID(ctx, incorrectPostID)
 		assert.Equal(t, models.ErrCorruptedPostID, err)
 	})
 
@@ -101,7 +109,7 @@ func TestGetPostByID(t *testing.T) {
 
 		mt.AddMockResponses(response)
 
-		_, err := repo.GetPostByID(primitive.NewObjectID().Hex())
+		_, err := repo.GetPostByID(ctx, primitive.NewObjectID().Hex())
 
 		assert.Equal(t, models.ErrNoPost, err)
 	})
@@ -113,13 +121,15 @@ func TestGetPostByID(t *testing.T) {
 
 		mt.AddMockResponses(bson.D{bson.E{Key: "ok", Value: 0}})
 
-		_, err := repo.GetPostByID(primitive.NewObjectID().Hex())
+		_, err := repo.GetPostByID(ctx, primitive.NewObjectID().Hex())
 
 		assert.NotNil(t, err)
 	})
 }
 
 func TestGetAllPosts(t *testing.T) {
+	ctx := context.Background()
+
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	var postAuthor = models.User{
@@ -206,7 +216,7 @@ func TestGetAllPosts(t *testing.T) {
 		killCursor := mtest.CreateCursorResponse(0, "foo.bar", mtest.NextBatch)
 		mt.AddMockResponses(killCursor)
 
-		posts, err := repo.GetAllPosts("", "")
+		posts, err := repo.GetAllPosts(ctx, "", "")
 		assert.Nil(t, err)
 		assert.Equal(t, expectedPosts, posts)
 	})
@@ -234,7 +244,7 @@ func TestGetAllPosts(t *testing.T) {
 
 		mt.AddMockResponses(byCategoryResponse, killCursor)
 
-		postsByCategory, err := repo.GetAllPosts(firstPost.Category, "")
+		postsByCategory, err := repo.GetAllPosts(ctx, firstPost.Category, "")
 		assert.Nil(t, err)
 		assert.Equal(t, []*models.Post{&firstPost}, postsByCategory)
 	})
@@ -262,7 +272,7 @@ func TestGetAllPosts(t *testing.T) {
 
 		mt.AddMockResponses(byUsernameResponse, killCursor)
 
-		postsByUser, err := repo.GetAllPosts("", postAuthor.Login)
+		postsByUser, err := repo.GetAllPosts(ctx, "", postAuthor.Login)
 		assert.Nil(t, err)
 		assert.Equal(t, []*models.Post{&secondPost}, postsByUser)
 	})
@@ -273,7 +283,7 @@ func TestGetAllPosts(t *testing.T) {
 		}
 
 		incorrectCategoryName := "incorrect"
-		_, err := repo.GetAllPosts(incorrectCategoryName, "")
+		_, err := repo.GetAllPosts(ctx, incorrectCategoryName, "")
 		assert.Equal(t, models.ErrIncorrectPostCategory, err)
 	})
 
@@ -288,7 +298,7 @@ func TestGetAllPosts(t *testing.T) {
 		})
 		mt.AddMockResponses(errorResponse)
 
-		_, err := repo.GetAllPosts("", "")
+		_, err := repo.GetAllPosts(ctx, "", "")
 		assert.NotNil(t, err)
 	})
 
@@ -299,12 +309,86 @@ func TestGetAllPosts(t *testing.T) {
 
 		mt.AddMockResponses(bson.D{bson.E{Key: "ok", Value: 0}})
 
-		_, err := repo.GetAllPosts("", "")
+		_, err := repo.GetAllPosts(ctx, "", "")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetPostsByAuthor(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	postAuthor := models.User{
+		ID:    1,
+		Login: "alex12345",
+	}
+
+	mt.Run("correct query", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		postID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+			bson.E{Key: "_id", Value: postID},
+			bson.E{Key: "title", Value: "some title"},
+			bson.E{Key: "author", Value: postAuthor},
+		}))
+
+		posts, err := repo.GetPostsByAuthor(ctx, postAuthor.Login, &models.ListOptions{Sort: models.SortTop, Limit: 10, Offset: 10})
+		assert.Nil(t, err)
+		if assert.Len(t, posts, 1) {
+			assert.Equal(t, postID, posts[0].ID)
+		}
+	})
+
+	mt.Run("error due finding posts", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		mt.AddMockResponses(bson.D{bson.E{Key: "ok", Value: 0}})
+
+		_, err := repo.GetPostsByAuthor(ctx, postAuthor.Login, nil)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestCountPostsByAuthor(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("correct query", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+			bson.E{Key: "n", Value: 3},
+		}))
+
+		count, err := repo.CountPostsByAuthor(ctx, "alex12345")
+		assert.Nil(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	mt.Run("error due counting posts", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		mt.AddMockResponses(bson.D{bson.E{Key: "ok", Value: 0}})
+
+		_, err := repo.CountPostsByAuthor(ctx, "alex12345")
 		assert.NotNil(t, err)
 	})
 }
 
 func TestCreateNewPost(t *testing.T) {
+	ctx := context.Background()
+
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 	var postAuthor = models.User{
 		ID:    1,
@@ -340,7 +424,7 @@ func TestCreateNewPost(t *testing.T) {
 
 		mt.AddMockResponses(mtest.CreateSuccessResponse())
 
-		insertedPost, err := repo.CreateNewPost(createdPost.Category,
+		insertedPost, err := repo.CreateNewPost(ctx, createdPost.Category,
 			createdPost.Title,
 			createdPost.Type,
 			"",
@@ -362,7 +446,7 @@ func TestCreateNewPost(t *testing.T) {
 
 		mt.AddMockResponses(bson.D{bson.E{Key: "ok", Value: 0}})
 
-		_, err := repo.CreateNewPost(createdPost.Category,
+		_, err := repo.CreateNewPost(ctx, createdPost.Category,
 			createdPost.Title,
 			createdPost.Type,
 			"",
@@ -375,6 +459,8 @@ func TestCreateNewPost(t *testing.T) {
 }
 
 func TestUpvotePost(t *testing.T) {
+	ctx := context.Background()
+
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	var postAuthor = models.User{
@@ -415,7 +501,7 @@ func TestUpvotePost(t *testing.T) {
 			bson.E{Key: "nModified", Value: 1},
 		})
 
-		err := repo.UpvotePost(&postAuthor, &post, 1)
+		err := repo.UpvotePost(ctx, &postAuthor, &post, 1)
 		assert.Nil(t, err)
 	})
 
@@ -430,7 +516,7 @@ func TestUpvotePost(t *testing.T) {
 			bson.E{Key: "nModified", Value: 1},
 		})
 
-		err := repo.UpvotePost(&postAuthor, &post, -1)
+		err := repo.UpvotePost(ctx, &postAuthor, &post, -1)
 		assert.Nil(t, err)
 	})
 
@@ -441,7 +527,7 @@ func TestUpvotePost(t *testing.T) {
 
 		incorrectRate := -2
 
-		err := repo.UpvotePost(&postAuthor, &post, incorrectRate)
+		err := repo.UpvotePost(ctx, &postAuthor, &post, incorrectRate)
 		assert.Equal(t, models.ErrUnrecognizedRate, err)
 	})
 
@@ -454,7 +540,7 @@ func TestUpvotePost(t *testing.T) {
 			bson.E{Key: "ok", Value: 0},
 		})
 
-		err := repo.UpvotePost(&postAuthor, &post, 1)
+		err := repo.UpvotePost(ctx, &postAuthor, &post, 1)
 		assert.Equal(t, models.ErrUpdatePost, err)
 	})
 
@@ -467,12 +553,14 @@ func TestUpvotePost(t *testing.T) {
 			bson.E{Key: "ok", Value: 1},
 		})
 
-		err := repo.UpvotePost(&postAuthor, &post, 1)
+		err := repo.UpvotePost(ctx, &postAuthor, &post, 1)
 		assert.Equal(t, models.ErrNoPost, err)
 	})
 }
 
 func TestDeletePostComment(t *testing.T) {
+	ctx := context.Background()
+
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	var postAuthor = models.User{
@@ -523,7 +611,7 @@ func TestDeletePostComment(t *testing.T) {
 			bson.E{Key: "nModified", Value: 1},
 		})
 
-		err := repo.DeletePostComment(&post, &comment)
+		err := repo.DeletePostComment(ctx, &post, &comment)
 		assert.Nil(t, err)
 	})
 
@@ -536,7 +624,7 @@ func TestDeletePostComment(t *testing.T) {
 			bson.E{Key: "ok", Value: 0},
 		})
 
-		err := repo.DeletePostComment(&post, &comment)
+		err := repo.DeletePostComment(ctx, &post, &comment)
 		assert.Equal(t, models.ErrUpdatePost, err)
 	})
 
@@ -549,12 +637,14 @@ func TestDeletePostComment(t *testing.T) {
 			bson.E{Key: "ok", Value: 1},
 		})
 
-		err := repo.DeletePostComment(&post, &comment)
+		err := repo.DeletePostComment(ctx, &post, &comment)
 		assert.Equal(t, models.ErrNoPost, err)
 	})
 }
 
 func TestAddPostComment(t *testing.T) {
+	ctx := context.Background()
+
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	var postAuthor = models.User{
@@ -621,7 +711,7 @@ func TestAddPostComment(t *testing.T) {
 			}},
 		})
 
-		updatedPost, err := repo.AddPostComment(&post, &comment)
+		updatedPost, err := repo.AddPostComment(ctx, &post, &comment)
 
 		assert.Nil(t, err)
 		assert.Equal(t, updatedPostCommentCount, len(updatedPost.Comments))
@@ -636,7 +726,93 @@ func TestAddPostComment(t *testing.T) {
 			bson.E{Key: "ok", Value: 0},
 		})
 
-		_, err := repo.AddPostComment(&post, &comment)
+		_, err := repo.AddPostComment(ctx, &post, &comment)
+		assert.Equal(t, models.ErrUpdatePost, err)
+	})
+
+	mt.Run("ErrNoPost", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		mt.AddMockResponses(bson.D{
+			bson.E{Key: "ok", Value: 1},
+		})
+
+		_, err := repo.AddPostComment(ctx, &post, &comment)
+		assert.Equal(t, models.ErrNoPost, err)
+	})
+}
+
+func TestUpdatePostComment(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	var postAuthor = models.User{
+		ID:    1,
+		Login: "alex12345",
+	}
+
+	var comment = models.Comment{
+		Created: time.Now(),
+		Author:  &postAuthor,
+		Text:    "comment text",
+		ID:      primitive.NewObjectID(),
+	}
+
+	var post = models.Post{
+		ID:       primitive.NewObjectID(),
+		Title:    "some title",
+		Author:   postAuthor,
+		Category: "news",
+		Comments: []*models.Comment{
+			&comment,
+		},
+	}
+
+	mt.Run("correct query", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		mt.AddMockResponses(bson.D{
+			bson.E{Key: "ok", Value: 1},
+			bson.E{Key: "n", Value: 1},
+			bson.E{Key: "nModified", Value: 1},
+		})
+
+		updatedComment := comment
+		updatedComment.Score = 1
+
+		err := repo.UpdatePostComment(ctx, &post, &updatedComment)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, post.Comments[0].Score)
+	})
+
+	mt.Run("ErrNoComment", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		unknownComment := comment
+		unknownComment.ID = primitive.NewObjectID()
+
+		err := repo.UpdatePostComment(ctx, &post, &unknownComment)
+		assert.Equal(t, models.ErrNoComment, err)
+	})
+
+	mt.Run("ErrUpdatePost", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		mt.AddMockResponses(bson.D{
+			bson.E{Key: "ok", Value: 0},
+		})
+
+		err := repo.UpdatePostComment(ctx, &post, &comment)
 		assert.Equal(t, models.ErrUpdatePost, err)
 	})
 
@@ -649,12 +825,14 @@ func TestAddPostComment(t *testing.T) {
 			bson.E{Key: "ok", Value: 1},
 		})
 
-		_, err := repo.AddPostComment(&post, &comment)
+		err := repo.UpdatePostComment(ctx, &post, &comment)
 		assert.Equal(t, models.ErrNoPost, err)
 	})
 }
 
 func TestDeletePost(t *testing.T) {
+	ctx := context.Background()
+
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	var postAuthor = models.User{
@@ -695,7 +873,7 @@ func TestDeletePost(t *testing.T) {
 			bson.E{Key: "acknowledged", Value: true},
 		})
 
-		err := repo.DeletePost(&post)
+		err := repo.DeletePost(ctx, &post)
 
 		assert.Nil(t, err)
 	})
@@ -709,7 +887,7 @@ func TestDeletePost(t *testing.T) {
 			bson.E{Key: "ok", Value: 0},
 		})
 
-		err := repo.DeletePost(&post)
+		err := repo.DeletePost(ctx, &post)
 
 		assert.Equal(t, models.ErrDeletePost, err)
 	})
@@ -725,8 +903,88 @@ func TestDeletePost(t *testing.T) {
 			bson.E{Key: "acknowledged", Value: true},
 		})
 
-		err := repo.DeletePost(&post)
+		err := repo.DeletePost(ctx, &post)
 
 		assert.Equal(t, models.ErrNoPost, err)
 	})
 }
+
+func TestAnonymizeAuthor(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	var userID = 1
+
+	mt.Run("correct query", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		mt.AddMockResponses(
+			bson.D{
+				bson.E{Key: "ok", Value: 1},
+				bson.E{Key: "n", Value: 2},
+				bson.E{Key: "nModified", Value: 2},
+			},
+			bson.D{
+				bson.E{Key: "ok", Value: 1},
+				bson.E{Key: "n", Value: 1},
+				bson.E{Key: "nModified", Value: 1},
+			},
+			bson.D{
+				bson.E{Key: "ok", Value: 1},
+				bson.E{Key: "n", Value: 3},
+				bson.E{Key: "nModified", Value: 3},
+			},
+			bson.D{
+				bson.E{Key: "ok", Value: 1},
+				bson.E{Key: "n", Value: 1},
+				bson.E{Key: "nModified", Value: 1},
+			},
+		)
+
+		err := repo.AnonymizeAuthor(ctx, userID)
+
+		assert.Nil(t, err)
+	})
+
+	mt.Run("ErrUpdatePost", func(mt *mtest.T) {
+		repo := PostMongoDBRepository{
+			DB: mt.Coll,
+		}
+
+		mt.AddMockResponses(bson.D{
+			bson.E{Key: "ok", Value: 0},
+		})
+
+		err := repo.AnonymizeAuthor(ctx, userID)
+
+		assert.Equal(t, models.ErrUpdatePost, err)
+	})
+}
+
+// TestConformance гоняет общий набор проверок на настоящей базе, например:
+// MONGODB_TEST_URI="mongodb://127.0.0.1:27017" go test ./pkg/post/repository/mongo
+func TestConformance(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("cant connect to mongo: %s", err)
+	}
+	defer client.Disconnect(ctx)
+
+	collection := client.Database("redditclone_test").Collection("posts")
+	repotest.TestPostRepo(t, func(t *testing.T) repository.PostRepo {
+		if err := collection.Drop(ctx); err != nil {
+			t.Fatalf("cant drop posts: %s", err)
+		}
+
+		return NewPostMongoDBMemoryRepo(collection)
+	})
+}
@@ -1,14 +1,24 @@
 package repository
 
-import "redditclone/pkg/models"
+import (
+	"context"
+	"redditclone/pkg/models"
+)
 
 //go:generate mockgen -source=repository.go -destination=mock_repository/post_mock.go -package=mock_repository MockPostRepository
 type PostRepo interface {
-	GetAllPosts(category string, username string) ([]*models.Post, error)
-	CreateNewPost(category string, title string, postType string, url string, text string, user *models.User) (*models.Post, error)
-	GetPostByID(id string) (*models.Post, error)
-	UpvotePost(user *models.User, post *models.Post, rate int) error
-	DeletePostComment(post *models.Post, comment *models.Comment) error
-	AddPostComment(post *models.Post, comment *models.Comment) (*models.Post, error)
-	DeletePost(post *models.Post) error
+	GetAllPosts(ctx context.Context, category string, username string) ([]*models.Post, error)
+	CreateNewPost(ctx context.Context, category string, title string, postType string, url string, text string, user *models.User) (*models.Post, error)
+	GetPostsByAuthor(ctx context.Context, username string, opts *models.ListOptions) ([]*models.Post, error)
+	CountPostsByAuthor(ctx context.Context, username string) (int, error)
+	GetPostByID(ctx context.Context, id string) (*models.Post, error)
+	// GetPostByIDForUpdate читает пост мимо кэшей: запись отправляет в хранилище массивы
+	// голосов и комментариев целиком, и устаревшая копия затерла бы чужие изменения.
+	GetPostByIDForUpdate(ctx context.Context, id string) (*models.Post, error)
+	UpvotePost(ctx context.Context, user *models.User, post *models.Post, rate int) error
+	DeletePostComment(ctx context.Context, post *models.Post, comment *models.Comment) error
+	AddPostComment(ctx context.Context, post *models.Post, comment *models.Comment) (*models.Post, error)
+	UpdatePostComment(ctx context.Context, post *models.Post, comment *models.Comment) error
+	DeletePost(ctx context.Context, post *models.Post) error
+	AnonymizeAuthor(ctx context.Context, userID int) error
 }
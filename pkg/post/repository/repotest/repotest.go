@@ -0,0 +1,315 @@
+// Package repotest проверяет, что реализация PostRepo соблюдает контракт интерфейса.
+// Его запускает каждое хранилище: in-memory всегда, Mongo - при наличии тестовой базы.
+package repotest
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"redditclone/pkg/post/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	author = &models.User{ID: 1, Login: "alex12345"}
+	voter  = &models.User{ID: 2, Login: "bob"}
+)
+
+// TestPostRepo прогоняет набор проверок; newRepo должен каждый раз возвращать пустое хранилище.
+func TestPostRepo(t *testing.T, newRepo func(t *testing.T) repository.PostRepo) {
+	ctx := context.Background()
+
+	t.Run("not found", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetPostByID(ctx, "not an id")
+		assert.Equal(t, models.ErrCorruptedPostID, err)
+		_, err = repo.GetPostByID(ctx, primitive.NewObjectID().Hex())
+		assert.Equal(t, models.ErrNoPost, err)
+		_, err = repo.GetPostByIDForUpdate(ctx, "not an id")
+		assert.Equal(t, models.ErrCorruptedPostID, err)
+		_, err = repo.GetPostByIDForUpdate(ctx, primitive.NewObjectID().Hex())
+		assert.Equal(t, models.ErrNoPost, err)
+
+		missing := &models.Post{ID: primitive.NewObjectID()}
+		assert.Equal(t, models.ErrNoPost, repo.DeletePost(ctx, missing))
+		assert.Equal(t, models.ErrNoPost, repo.UpvotePost(ctx, voter, missing, 1))
+		_, err = repo.AddPostComment(ctx, missing, &models.Comment{ID: primitive.NewObjectID(), Author: author})
+		assert.Equal(t, models.ErrNoPost, err)
+
+		posts, err := repo.GetAllPosts(ctx, "", "")
+		assert.NoError(t, err)
+		assert.Empty(t, posts)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		repo := newRepo(t)
+
+		post, err := repo.CreateNewPost(ctx, "music", "title", "text", "", "body", author)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.False(t, post.ID.IsZero())
+		assert.Equal(t, 1, post.Score)
+		assert.Equal(t, 100, post.UpvotePercentage)
+		assert.Len(t, post.Votes, 1)
+		assert.Equal(t, author.ID, post.Votes[0].AuthorID)
+
+		found, err := repo.GetPostByID(ctx, post.ID.Hex())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "music", found.Category)
+		assert.Equal(t, "title", found.Title)
+		assert.Equal(t, "text", found.Type)
+		assert.Equal(t, "body", found.Text)
+		assert.Equal(t, author.Login, found.Author.Login)
+
+		forUpdate, err := repo.GetPostByIDForUpdate(ctx, post.ID.Hex())
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, found.ID, forUpdate.ID)
+		assert.Equal(t, found.Title, forUpdate.Title)
+		assert.Len(t, forUpdate.Votes, 1)
+		assert.Equal(t, author.ID, found.Author.ID)
+		assert.Equal(t, 1, found.Score)
+		assert.WithinDuration(t, post.Created, found.Created, time.Millisecond)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.CreateNewPost(ctx, "music", "first", "text", "", "body", author)
+		repo.CreateNewPost(ctx, "news", "second", "link", "http://example.com", "", author)
+		repo.CreateNewPost(ctx, "music", "third", "text", "", "body", voter)
+
+		all, err := repo.GetAllPosts(ctx, "", "")
+		assert.NoError(t, err)
+		assert.Len(t, all, 3)
+
+		music, err := repo.GetAllPosts(ctx, "music", "")
+		assert.NoError(t, err)
+		assert.Len(t, music, 2)
+
+		byAuthor, err := repo.GetAllPosts(ctx, "", author.Login)
+		assert.NoError(t, err)
+		assert.Len(t, byAuthor, 2)
+
+		// Категория важнее автора.
+		both, err := repo.GetAllPosts(ctx, "news", voter.Login)
+		assert.NoError(t, err)
+		assert.Len(t, both, 1)
+
+		_, err = repo.GetAllPosts(ctx, "unknown", "")
+		assert.Equal(t, models.ErrIncorrectPostCategory, err)
+	})
+
+	t.Run("author ordering and pagination", func(t *testing.T) {
+		repo := newRepo(t)
+
+		// Паузы нужны хранилищам, которые округляют время создания до миллисекунд.
+		oldest, _ := repo.CreateNewPost(ctx, "music", "oldest", "text", "", "body", author)
+		time.Sleep(5 * time.Millisecond)
+		repo.CreateNewPost(ctx, "music", "middle", "text", "", "body", author)
+		time.Sleep(5 * time.Millisecond)
+		newest, _ := repo.CreateNewPost(ctx, "news", "newest", "text", "", "body", author)
+		repo.CreateNewPost(ctx, "music", "foreign", "text", "", "body", voter)
+
+		found, _ := repo.GetPostByIDForUpdate(ctx, oldest.ID.Hex())
+		repo.UpvotePost(ctx, voter, found, 1)
+		found, _ = repo.GetPostByIDForUpdate(ctx, newest.ID.Hex())
+		repo.UpvotePost(ctx, voter, found, 1)
+
+		titles := func(posts []*models.Post) []string {
+			result := []string{}
+			for _, post := range posts {
+				result = append(result, post.Title)
+			}
+			return result
+		}
+
+		byNew, err := repo.GetPostsByAuthor(ctx, author.Login, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"newest", "middle", "oldest"}, titles(byNew))
+
+		// Среди постов с одинаковым рейтингом новые идут первыми.
+		byTop, err := repo.GetPostsByAuthor(ctx, author.Login, &models.ListOptions{Sort: models.SortTop})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"newest", "oldest", "middle"}, titles(byTop))
+
+		page, err := repo.GetPostsByAuthor(ctx, author.Login, &models.ListOptions{Sort: models.SortNew, Limit: 2, Offset: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"middle", "oldest"}, titles(page))
+
+		page, err = repo.GetPostsByAuthor(ctx, author.Login, &models.ListOptions{Offset: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, page)
+
+		count, err := repo.CountPostsByAuthor(ctx, author.Login)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		count, err = repo.CountPostsByAuthor(ctx, "nobody")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("votes", func(t *testing.T) {
+		repo := newRepo(t)
+		post, _ := repo.CreateNewPost(ctx, "music", "title", "text", "", "body", author)
+		reload := func() *models.Post {
+			found, err := repo.GetPostByID(ctx, post.ID.Hex())
+			assert.NoError(t, err)
+			return found
+		}
+
+		assert.Equal(t, models.ErrUnrecognizedRate, repo.UpvotePost(ctx, voter, reload(), 2))
+
+		assert.NoError(t, repo.UpvotePost(ctx, voter, reload(), -1))
+		found := reload()
+		assert.Equal(t, 0, found.Score)
+		assert.Len(t, found.Votes, 2)
+		assert.Equal(t, 0, found.UpvotePercentage)
+
+		// Повторный голос заменяет прежний, а не добавляется к нему.
+		assert.NoError(t, repo.UpvotePost(ctx, voter, reload(), 1))
+		assert.NoError(t, repo.UpvotePost(ctx, voter, reload(), 1))
+		found = reload()
+		assert.Equal(t, 2, found.Score)
+		assert.Len(t, found.Votes, 2)
+		assert.Equal(t, 100, found.UpvotePercentage)
+
+		assert.NoError(t, repo.UpvotePost(ctx, voter, reload(), 0))
+		found = reload()
+		assert.Equal(t, 1, found.Score)
+		assert.Len(t, found.Votes, 1)
+
+		assert.NoError(t, repo.UpvotePost(ctx, author, reload(), 0))
+		found = reload()
+		assert.Equal(t, 0, found.Score)
+		assert.Empty(t, found.Votes)
+		assert.Equal(t, 0, found.UpvotePercentage)
+	})
+
+	t.Run("comments", func(t *testing.T) {
+		repo := newRepo(t)
+		post, _ := repo.CreateNewPost(ctx, "music", "title", "text", "", "body", author)
+
+		first := &models.Comment{ID: primitive.NewObjectID(), Author: voter, Text: "first", Votes: []*models.Vote{}}
+		second := &models.Comment{ID: primitive.NewObjectID(), Author: author, Text: "second", Votes: []*models.Vote{}}
+
+		found, _ := repo.GetPostByID(ctx, post.ID.Hex())
+		updated, err := repo.AddPostComment(ctx, found, first)
+		assert.NoError(t, err)
+		assert.Len(t, updated.Comments, 1)
+
+		found, _ = repo.GetPostByID(ctx, post.ID.Hex())
+		_, err = repo.AddPostComment(ctx, found, second)
+		assert.NoError(t, err)
+
+		found, _ = repo.GetPostByID(ctx, post.ID.Hex())
+		if !assert.Len(t, found.Comments, 2) {
+			return
+		}
+		assert.Equal(t, "first", found.Comments[0].Text)
+		assert.Equal(t, "second", found.Comments[1].Text)
+
+		edited := &models.Comment{ID: first.ID, Author: voter, Text: "first", Score: 1, Votes: []*models.Vote{{Author: *author, AuthorID: author.ID, Vote: 1}}}
+		assert.NoError(t, repo.UpdatePostComment(ctx, found, edited))
+		assert.Equal(t, models.ErrNoComment, repo.UpdatePostComment(ctx, found, &models.Comment{ID: primitive.NewObjectID()}))
+
+		found, _ = repo.GetPostByID(ctx, post.ID.Hex())
+		assert.Equal(t, 1, found.Comments[0].Score)
+		assert.Len(t, found.Comments[0].Votes, 1)
+
+		assert.NoError(t, repo.DeletePostComment(ctx, found, first))
+		found, _ = repo.GetPostByID(ctx, post.ID.Hex())
+		if assert.Len(t, found.Comments, 1) {
+			assert.Equal(t, second.ID, found.Comments[0].ID)
+		}
+	})
+
+	t.Run("anonymize author", func(t *testing.T) {
+		repo := newRepo(t)
+		own, _ := repo.CreateNewPost(ctx, "music", "own", "text", "", "body", author)
+		foreign, _ := repo.CreateNewPost(ctx, "music", "foreign", "text", "", "body", voter)
+
+		found, _ := repo.GetPostByID(ctx, foreign.ID.Hex())
+		repo.AddPostComment(ctx, found, &models.Comment{ID: primitive.NewObjectID(), Author: author, Text: "mine", Votes: []*models.Vote{}})
+		found, _ = repo.GetPostByID(ctx, foreign.ID.Hex())
+		repo.AddPostComment(ctx, found, &models.Comment{ID: primitive.NewObjectID(), Author: voter, Text: "theirs", Votes: []*models.Vote{}})
+
+		assert.NoError(t, repo.AnonymizeAuthor(ctx, author.ID))
+
+		found, _ = repo.GetPostByID(ctx, own.ID.Hex())
+		assert.Equal(t, models.DeletedUserLogin, found.Author.Login)
+		assert.Equal(t, 0, found.Author.ID)
+
+		found, _ = repo.GetPostByID(ctx, foreign.ID.Hex())
+		assert.Equal(t, voter.Login, found.Author.Login)
+		if assert.Len(t, found.Comments, 2) {
+			assert.Equal(t, models.DeletedUserLogin, found.Comments[0].Author.Login)
+			assert.Equal(t, voter.Login, found.Comments[1].Author.Login)
+		}
+
+		byAuthor, _ := repo.GetAllPosts(ctx, "", author.Login)
+		assert.Empty(t, byAuthor)
+	})
+
+	t.Run("anonymize votes", func(t *testing.T) {
+		repo := newRepo(t)
+		foreign, _ := repo.CreateNewPost(ctx, "music", "foreign", "text", "", "body", voter)
+
+		found, _ := repo.GetPostByIDForUpdate(ctx, foreign.ID.Hex())
+		assert.NoError(t, repo.UpvotePost(ctx, author, found, 1))
+		found, _ = repo.GetPostByIDForUpdate(ctx, foreign.ID.Hex())
+		repo.AddPostComment(ctx, found, &models.Comment{
+			ID:     primitive.NewObjectID(),
+			Author: voter,
+			Text:   "theirs",
+			Score:  1,
+			Votes:  []*models.Vote{{Author: *author, AuthorID: author.ID, Vote: 1}},
+		})
+
+		assert.NoError(t, repo.AnonymizeAuthor(ctx, author.ID))
+
+		// Рейтинг не меняется, но ни id, ни логина удаленного пользователя в голосах нет.
+		found, _ = repo.GetPostByID(ctx, foreign.ID.Hex())
+		assert.Equal(t, 2, found.Score)
+		if assert.Len(t, found.Votes, 2) {
+			assert.Equal(t, voter.ID, found.Votes[0].AuthorID)
+			assert.Equal(t, voter.Login, found.Votes[0].Author.Login)
+			assert.Equal(t, models.DeletedUserID, found.Votes[1].AuthorID)
+			assert.Equal(t, models.DeletedUserID, found.Votes[1].Author.ID)
+			assert.Equal(t, models.DeletedUserLogin, found.Votes[1].Author.Login)
+			assert.Equal(t, 1, found.Votes[1].Vote)
+		}
+		if assert.Len(t, found.Comments, 1) && assert.Len(t, found.Comments[0].Votes, 1) {
+			vote := found.Comments[0].Votes[0]
+			assert.Equal(t, models.DeletedUserID, vote.AuthorID)
+			assert.Equal(t, models.DeletedUserLogin, vote.Author.Login)
+			assert.Equal(t, 1, found.Comments[0].Score)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		post, _ := repo.CreateNewPost(ctx, "music", "title", "text", "", "body", author)
+		other, _ := repo.CreateNewPost(ctx, "music", "other", "text", "", "body", author)
+
+		assert.NoError(t, repo.DeletePost(ctx, post))
+		assert.Equal(t, models.ErrNoPost, repo.DeletePost(ctx, post))
+
+		_, err := repo.GetPostByID(ctx, post.ID.Hex())
+		assert.Equal(t, models.ErrNoPost, err)
+		assert.Equal(t, models.ErrNoPost, repo.UpvotePost(ctx, voter, post, 1))
+
+		posts, _ := repo.GetAllPosts(ctx, "", "")
+		if assert.Len(t, posts, 1) {
+			assert.Equal(t, other.ID, posts[0].ID)
+		}
+	})
+}
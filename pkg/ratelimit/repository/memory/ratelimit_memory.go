@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"redditclone/pkg/ratelimit/repository"
+	"sync"
+	"time"
+)
+
+// Раз в sweepInterval из памяти удаляются корзины, которые уже успели наполниться.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time
+}
+
+// LimiterMemoryRepository хранит корзины в памяти процесса: подходит для одного узла и разработки.
+type LimiterMemoryRepository struct {
+	mu        *sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewLimiterMemoryRepository() *LimiterMemoryRepository {
+	return &LimiterMemoryRepository{
+		mu:        &sync.Mutex{},
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (repo *LimiterMemoryRepository) Take(key string, limit repository.Limit) (*repository.Result, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := repo.now()
+	repo.sweep(now)
+
+	b, ok := repo.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		repo.buckets[key] = b
+	}
+
+	b.tokens = limit.Refill(b.tokens, now.Sub(b.updatedAt))
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.fullAt = now.Add(limit.TimeToFull(b.tokens))
+
+	return repository.NewResult(limit, allowed, b.tokens), nil
+}
+
+func (repo *LimiterMemoryRepository) sweep(now time.Time) {
+	if now.Sub(repo.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, b := range repo.buckets {
+		if !now.Before(b.fullAt) {
+			delete(repo.buckets, key)
+		}
+	}
+	repo.lastSweep = now
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"redditclone/pkg/ratelimit/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTake(t *testing.T) {
+	repo := NewLimiterMemoryRepository()
+
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+	repo.lastSweep = now
+
+	limit := repository.Limit{Rate: 1, Period: time.Second, Burst: 3}
+
+	t.Run("burst is spent", func(t *testing.T) {
+		for i := 2; i >= 0; i-- {
+			result, err := repo.Take("key", limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 3, result.Limit)
+			assert.Equal(t, i, result.Remaining)
+		}
+
+		result, err := repo.Take("key", limit)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Second, result.RetryAfter)
+		assert.Equal(t, 3*time.Second, result.Reset)
+	})
+
+	t.Run("keys are independent", func(t *testing.T) {
+		result, err := repo.Take("another key", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("bucket refills", func(t *testing.T) {
+		now = now.Add(1500 * time.Millisecond)
+
+		result, err := repo.Take("key", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+	})
+
+	t.Run("full buckets are swept", func(t *testing.T) {
+		now = now.Add(time.Hour)
+
+		_, err := repo.Take("key", limit)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(repo.buckets))
+	})
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"redditclone/pkg/ratelimit/repository"
+	"strconv"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Скрипт выполняется атомарно, поэтому реплики API не могут одновременно потратить один и тот же токен.
+// Время берется у Redis, чтобы расхождение часов между репликами не влияло на пополнение.
+var takeScript = redis.NewScript(1, `
+local rate = tonumber(ARGV[1]) / tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated_at')
+local tokens = tonumber(state[1]) or burst
+local updatedAt = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - updatedAt) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated_at', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate / 1000) + 1000)
+
+return {allowed, tostring(tokens)}
+`)
+
+type LimiterRedisRepository struct {
+	redisConn redis.Conn
+	mu        *sync.Mutex
+}
+
+func NewLimiterRedisRepository(conn redis.Conn) *LimiterRedisRepository {
+	return &LimiterRedisRepository{
+		redisConn: conn,
+		mu:        &sync.Mutex{},
+	}
+}
+
+func (repo *LimiterRedisRepository) Take(key string, limit repository.Limit) (*repository.Result, error) {
+	// Скрипт считает в микросекундах, так как TIME в Redis отдает их.
+	repo.mu.Lock()
+	replies, err := redis.Values(takeScript.Do(
+		repo.redisConn,
+		"ratelimit:"+key,
+		limit.Rate,
+		limit.Period.Microseconds(),
+		limit.Burst,
+	))
+	repo.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := redis.Int(replies[0], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tokensString, err := redis.String(replies[1], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := strconv.ParseFloat(tokensString, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.NewResult(limit, allowed == 1, tokens), nil
+}
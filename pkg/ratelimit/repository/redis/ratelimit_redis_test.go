@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"redditclone/pkg/ratelimit/repository"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTake(t *testing.T) {
+	server := miniredis.RunT(t)
+	conn, err := redis.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatalf("cant connect to redis: %s", err)
+	}
+	defer conn.Close()
+
+	repo := NewLimiterRedisRepository(conn)
+
+	// Скрипт берет время из TIME, а его в miniredis задает SetTime.
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.SetTime(now)
+
+	limit := repository.Limit{Rate: 1, Period: time.Second, Burst: 3}
+
+	t.Run("burst is spent", func(t *testing.T) {
+		for i := 2; i >= 0; i-- {
+			result, err := repo.Take("key", limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 3, result.Limit)
+			assert.Equal(t, i, result.Remaining)
+		}
+
+		result, err := repo.Take("key", limit)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Second, result.RetryAfter)
+		assert.Equal(t, 3*time.Second, result.Reset)
+	})
+
+	t.Run("keys are independent", func(t *testing.T) {
+		result, err := repo.Take("another key", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2, result.Remaining)
+	})
+
+	t.Run("bucket refills", func(t *testing.T) {
+		now = now.Add(1500 * time.Millisecond)
+		server.SetTime(now)
+
+		result, err := repo.Take("key", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+
+		// Осталось полтокена: следующий появится через полсекунды.
+		result, err = repo.Take("key", limit)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+		assert.Equal(t, 2500*time.Millisecond, result.Reset)
+	})
+
+	t.Run("refill is capped by burst", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		server.SetTime(now)
+
+		result, err := repo.Take("key", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2, result.Remaining)
+	})
+
+	t.Run("key expires once the bucket is full", func(t *testing.T) {
+		// Корзина наполнится через секунду, ключ живет на секунду дольше.
+		assert.Equal(t, 2*time.Second, server.TTL("ratelimit:key"))
+
+		server.FastForward(2 * time.Second)
+		assert.False(t, server.Exists("ratelimit:key"))
+	})
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"math"
+	"time"
+)
+
+//go:generate mockgen -source=repository.go -destination=mock_repository/ratelimit_mock.go -package=mock_repository MockLimiter
+type Limiter interface {
+	// Take забирает из корзины key один токен, если он есть.
+	Take(key string, limit Limit) (*Result, error)
+}
+
+// Limit описывает корзину токенов: в ней помещается Burst токенов,
+// и за каждый Period она пополняется на Rate токенов.
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+func (l Limit) tokensPerNanosecond() float64 {
+	return float64(l.Rate) / float64(l.Period.Nanoseconds())
+}
+
+// Refill возвращает число токенов в корзине спустя elapsed после последнего обращения.
+func (l Limit) Refill(tokens float64, elapsed time.Duration) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return math.Min(float64(l.Burst), tokens+float64(elapsed.Nanoseconds())*l.tokensPerNanosecond())
+}
+
+// TimeToFull возвращает, через сколько корзина наполнится до Burst, а значит, ее можно забыть.
+func (l Limit) TimeToFull(tokens float64) time.Duration {
+	return l.timeFor(float64(l.Burst) - tokens)
+}
+
+func (l Limit) timeFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+
+	return time.Duration(math.Ceil(tokens / l.tokensPerNanosecond()))
+}
+
+type Result struct {
+	Allowed bool
+	// Limit, Remaining и Reset соответствуют заголовкам RateLimit-*.
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+	// RetryAfter - через сколько появится следующий токен, если запрос отклонен.
+	RetryAfter time.Duration
+}
+
+// NewResult собирает результат по числу токенов, оставшихся в корзине после попытки.
+func NewResult(limit Limit, allowed bool, tokens float64) *Result {
+	result := &Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     limit.TimeToFull(tokens),
+	}
+
+	if !allowed {
+		result.RetryAfter = limit.timeFor(1 - tokens)
+	}
+
+	return result
+}
@@ -0,0 +1,42 @@
+package instrumented
+
+import (
+	"context"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	sessionRepository "redditclone/pkg/session/repository"
+	"redditclone/pkg/tracing"
+)
+
+// SessionInstrumentedManager пишет в метрики длительность и ошибки каждого вызова SessionManager
+// и открывает на него спан трассировки.
+type SessionInstrumentedManager struct {
+	Manager sessionRepository.SessionManager
+}
+
+func NewSessionInstrumentedManager(manager sessionRepository.SessionManager) *SessionInstrumentedManager {
+	return &SessionInstrumentedManager{
+		Manager: manager,
+	}
+}
+
+func (sm *SessionInstrumentedManager) Create(ctx context.Context, JWTToken string, userID int) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "SessionManager.Create")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("session", "Create")(&err)
+	return sm.Manager.Create(ctx, JWTToken, userID)
+}
+
+func (sm *SessionInstrumentedManager) Check(ctx context.Context, userID int) (session *models.Session, err error) {
+	ctx, endSpan := tracing.Start(ctx, "SessionManager.Check")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("session", "Check")(&err)
+	return sm.Manager.Check(ctx, userID)
+}
+
+func (sm *SessionInstrumentedManager) Delete(ctx context.Context, userID int) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "SessionManager.Delete")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("session", "Delete")(&err)
+	return sm.Manager.Delete(ctx, userID)
+}
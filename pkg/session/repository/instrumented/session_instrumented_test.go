@@ -0,0 +1,37 @@
+package instrumented
+
+import (
+	"context"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	"redditclone/pkg/session/repository"
+	"redditclone/pkg/session/repository/memory"
+	"redditclone/pkg/session/repository/repotest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.TestSessionManager(t, func(t *testing.T) repository.SessionManager {
+		return NewSessionInstrumentedManager(memory.NewSessionMemoryManager())
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	sm := NewSessionInstrumentedManager(memory.NewSessionMemoryManager())
+	errors := metrics.RepositoryCallErrors.WithLabelValues("session", "Check")
+	before := testutil.ToFloat64(errors)
+
+	_, err := sm.Check(ctx, 1)
+	assert.Equal(t, models.ErrNoSession, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(errors))
+
+	assert.NoError(t, sm.Create(ctx, "token", 1))
+	_, err = sm.Check(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(errors))
+}
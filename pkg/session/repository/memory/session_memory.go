@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"sync"
+	"time"
+)
+
+// SessionMemoryManager хранит сессии в памяти процесса; как и в Redis, у пользователя одна сессия на 4 дня.
+type SessionMemoryManager struct {
+	mu       *sync.Mutex
+	sessions map[int]*models.Session
+	now      func() time.Time
+}
+
+func NewSessionMemoryManager() *SessionMemoryManager {
+	return &SessionMemoryManager{
+		mu:       &sync.Mutex{},
+		sessions: make(map[int]*models.Session),
+		now:      time.Now,
+	}
+}
+
+func (sm *SessionMemoryManager) Create(ctx context.Context, JWTToken string, userID int) error {
+	sm.mu.Lock()
+	sm.sessions[userID] = &models.Session{
+		ID:        1,
+		JWT:       JWTToken,
+		UserID:    userID,
+		ExpiresAt: sm.now().AddDate(0, 0, 4),
+	}
+	sm.mu.Unlock()
+
+	return nil
+}
+
+func (sm *SessionMemoryManager) Check(ctx context.Context, userID int) (*models.Session, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[userID]
+	if !ok {
+		return nil, models.ErrNoSession
+	}
+
+	if !sm.now().Before(session.ExpiresAt) {
+		delete(sm.sessions, userID)
+		return nil, models.ErrNoSession
+	}
+
+	sessionCopy := *session
+	return &sessionCopy, nil
+}
+
+func (sm *SessionMemoryManager) Delete(ctx context.Context, userID int) error {
+	sm.mu.Lock()
+	delete(sm.sessions, userID)
+	sm.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"redditclone/pkg/session/repository"
+	"redditclone/pkg/session/repository/repotest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+
+	sm := NewSessionMemoryManager()
+
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	sm.now = func() time.Time { return now }
+
+	_, err := sm.Check(ctx, 1)
+	assert.Equal(t, models.ErrNoSession, err)
+
+	assert.NoError(t, sm.Create(ctx, "token", 1))
+	session, err := sm.Check(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "token", session.JWT)
+
+	t.Run("new session replaces old one", func(t *testing.T) {
+		assert.NoError(t, sm.Create(ctx, "new token", 1))
+		session, _ := sm.Check(ctx, 1)
+		assert.Equal(t, "new token", session.JWT)
+	})
+
+	t.Run("session expires", func(t *testing.T) {
+		now = now.Add(4 * 24 * time.Hour)
+		_, err := sm.Check(ctx, 1)
+		assert.Equal(t, models.ErrNoSession, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, sm.Create(ctx, "token", 2))
+		assert.NoError(t, sm.Delete(ctx, 2))
+		_, err := sm.Check(ctx, 2)
+		assert.Equal(t, models.ErrNoSession, err)
+	})
+}
+
+func TestConformance(t *testing.T) {
+	repotest.TestSessionManager(t, func(t *testing.T) repository.SessionManager {
+		return NewSessionMemoryManager()
+	})
+}
@@ -5,6 +5,7 @@
 package mock_repository
 
 import (
+	context "context"
 	models "redditclone/pkg/models"
 	reflect "reflect"
 
@@ -35,44 +36,44 @@ func (m *MockSessionManager) EXPECT() *MockSessionManagerMockRecorder {
 }
 
 // Check mocks base method.
-func (m *MockSessionManager) Check(userID int) (*models.Session, error) {
+func (m *MockSessionManager) Check(ctx context.Context, userID int) (*models.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Check", userID)
+	ret := m.ctrl.Call(m, "Check", ctx, userID)
 	ret0, _ := ret[0].(*models.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Check indicates an expected call of Check.
-func (mr *MockSessionManagerMockRecorder) Check(userID interface{}) *gomock.Call {
+func (mr *MockSessionManagerMockRecorder) Check(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockSessionManager)(nil).Check), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockSessionManager)(nil).Check), ctx, userID)
 }
 
 // Create mocks base method.
-func (m *MockSessionManager) Create(JWTToken string, userID int) error {
+func (m *MockSessionManager) Create(ctx context.Context, JWTToken string, userID int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", JWTToken, userID)
+	ret := m.ctrl.Call(m, "Create", ctx, JWTToken, userID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockSessionManagerMockRecorder) Create(JWTToken, userID interface{}) *gomock.Call {
+func (mr *MockSessionManagerMockRecorder) Create(ctx, JWTToken, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionManager)(nil).Create), JWTToken, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionManager)(nil).Create), ctx, JWTToken, userID)
 }
 
 // Delete mocks base method.
-func (m *MockSessionManager) Delete(userID int) error {
+func (m *MockSessionManager) Delete(ctx context.Context, userID int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", userID)
+	ret := m.ctrl.Call(m, "Delete", ctx, userID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockSessionManagerMockRecorder) Delete(userID interface{}) *gomock.Call {
+func (mr *MockSessionManagerMockRecorder) Delete(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSessionManager)(nil).Delete), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSessionManager)(nil).Delete), ctx, userID)
 }
@@ -0,0 +1,76 @@
+package mongo
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const sessionTTL = 4 * 24 * time.Hour
+
+type sessionDocument struct {
+	UserID    int       `bson:"_id"`
+	JWT       string    `bson:"token"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// SessionMongoDBManager хранит одну сессию на пользователя. Просроченные документы удаляет
+// TTL-индекс по expiresAt, но его фоновая задача запускается раз в минуту,
+// поэтому Check дополнительно сверяет срок сам.
+type SessionMongoDBManager struct {
+	DB  *mongo.Collection
+	now func() time.Time
+}
+
+func NewSessionMongoDBManager(sessionsCollection *mongo.Collection) *SessionMongoDBManager {
+	return &SessionMongoDBManager{
+		DB:  sessionsCollection,
+		now: time.Now,
+	}
+}
+
+func (sm *SessionMongoDBManager) Create(ctx context.Context, JWTToken string, userID int) error {
+	_, err := sm.DB.ReplaceOne(
+		ctx,
+		bson.M{"_id": userID},
+		&sessionDocument{
+			UserID:    userID,
+			JWT:       JWTToken,
+			ExpiresAt: sm.now().Add(sessionTTL),
+		},
+		options.Replace().SetUpsert(true),
+	)
+
+	return err
+}
+
+func (sm *SessionMongoDBManager) Check(ctx context.Context, userID int) (*models.Session, error) {
+	doc := &sessionDocument{}
+
+	err := sm.DB.FindOne(
+		ctx,
+		bson.M{"_id": userID, "expiresAt": bson.M{"$gt": sm.now()}},
+	).Decode(doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrNoSession
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &models.Session{
+		ID:        1,
+		JWT:       doc.JWT,
+		UserID:    doc.UserID,
+		ExpiresAt: doc.ExpiresAt,
+	}, nil
+}
+
+func (sm *SessionMongoDBManager) Delete(ctx context.Context, userID int) error {
+	_, err := sm.DB.DeleteOne(ctx, bson.M{"_id": userID})
+
+	return err
+}
@@ -0,0 +1,72 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"redditclone/pkg/models"
+	"redditclone/pkg/session/repository"
+	"redditclone/pkg/session/repository/repotest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	expiresAt := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	mt.Run("correct query", func(mt *mtest.T) {
+		sm := NewSessionMongoDBManager(mt.Coll)
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(1, "foo.bar", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: 1},
+			{Key: "token", Value: "token"},
+			{Key: "expiresAt", Value: expiresAt},
+		}))
+
+		session, err := sm.Check(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Session{ID: 1, JWT: "token", UserID: 1, ExpiresAt: expiresAt}, session)
+	})
+
+	mt.Run("ErrNoSession", func(mt *mtest.T) {
+		sm := NewSessionMongoDBManager(mt.Coll)
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch))
+
+		_, err := sm.Check(ctx, 1)
+		assert.Equal(t, models.ErrNoSession, err)
+	})
+}
+
+// TestConformance гоняет общий набор проверок на настоящей базе, например:
+// MONGODB_TEST_URI="mongodb://127.0.0.1:27017" go test ./pkg/session/repository/mongo
+func TestConformance(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("cant connect to mongo: %s", err)
+	}
+	defer client.Disconnect(ctx)
+
+	collection := client.Database("redditclone_test").Collection("sessions")
+	repotest.TestSessionManager(t, func(t *testing.T) repository.SessionManager {
+		if err := collection.Drop(ctx); err != nil {
+			t.Fatalf("cant drop sessions: %s", err)
+		}
+
+		return NewSessionMongoDBManager(collection)
+	})
+}
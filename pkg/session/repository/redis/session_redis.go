@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"encoding/json"
 	"redditclone/pkg/models"
 	"strconv"
@@ -22,7 +23,7 @@ func NewSessionRedisManager(conn redis.Conn) *SessionRedisManager {
 	}
 }
 
-func (sm *SessionRedisManager) Create(JWTToken string, userID int) error {
+func (sm *SessionRedisManager) Create(ctx context.Context, JWTToken string, userID int) error {
 	mkey := "sessions:" + strconv.Itoa(userID)
 	newSession := models.Session{
 		ID:        1,
@@ -37,7 +38,7 @@ func (sm *SessionRedisManager) Create(JWTToken string, userID int) error {
 	}
 
 	sm.mu.Lock()
-	result, err := redis.String(sm.redisConn.Do("SET", mkey, dataSerialized, "EX", 4*24*60*60))
+	result, err := redis.String(sm.do(ctx, "SET", mkey, dataSerialized, "EX", 4*24*60*60))
 	sm.mu.Unlock()
 	if err != nil || result != "OK" {
 		return err
@@ -46,10 +47,10 @@ func (sm *SessionRedisManager) Create(JWTToken string, userID int) error {
 	return nil
 }
 
-func (sm *SessionRedisManager) Check(userID int) (*models.Session, error) {
+func (sm *SessionRedisManager) Check(ctx context.Context, userID int) (*models.Session, error) {
 	mkey := "sessions:" + strconv.Itoa(userID)
 	sm.mu.Lock()
-	data, err := redis.Bytes(sm.redisConn.Do("GET", mkey))
+	data, err := redis.Bytes(sm.do(ctx, "GET", mkey))
 	sm.mu.Unlock()
 	if err != nil {
 		if err == redis.ErrNil {
@@ -67,10 +68,10 @@ func (sm *SessionRedisManager) Check(userID int) (*models.Session, error) {
 	return session, nil
 }
 
-func (sm *SessionRedisManager) Delete(userID int) error {
+func (sm *SessionRedisManager) Delete(ctx context.Context, userID int) error {
 	mkey := "sessions:" + strconv.Itoa(userID)
 	sm.mu.Lock()
-	_, err := redis.Int(sm.redisConn.Do("DEL", mkey))
+	_, err := redis.Int(sm.do(ctx, "DEL", mkey))
 	sm.mu.Unlock()
 	if err != nil {
 		if err == redis.ErrNil {
@@ -82,3 +83,9 @@ func (sm *SessionRedisManager) Delete(userID int) error {
 
 	return nil
 }
+
+// do передает ctx дальше ради трассировки, но без отмены: redis.DoContext закрывает соединение,
+// если ctx отменили посреди команды, а соединение у менеджера одно на все запросы.
+func (sm *SessionRedisManager) do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	return redis.DoContext(sm.redisConn, context.WithoutCancel(ctx), commandName, args...)
+}
@@ -0,0 +1,26 @@
+package redis
+
+import (
+	"redditclone/pkg/session/repository"
+	"redditclone/pkg/session/repository/repotest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestConformance(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	repotest.TestSessionManager(t, func(t *testing.T) repository.SessionManager {
+		server.FlushAll()
+
+		conn, err := redis.Dial("tcp", server.Addr())
+		if err != nil {
+			t.Fatalf("cant connect to redis: %s", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		return NewSessionRedisManager(conn)
+	})
+}
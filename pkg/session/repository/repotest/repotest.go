@@ -0,0 +1,69 @@
+// Package repotest проверяет, что реализация SessionManager соблюдает контракт интерфейса.
+package repotest
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"redditclone/pkg/session/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSessionManager прогоняет набор проверок; newManager должен каждый раз возвращать пустое хранилище.
+func TestSessionManager(t *testing.T, newManager func(t *testing.T) repository.SessionManager) {
+	ctx := context.Background()
+
+	t.Run("not found", func(t *testing.T) {
+		sm := newManager(t)
+
+		_, err := sm.Check(ctx, 1)
+		assert.Equal(t, models.ErrNoSession, err)
+
+		// Удаление отсутствующей сессии - не ошибка: выход из аккаунта идемпотентен.
+		assert.NoError(t, sm.Delete(ctx, 1))
+	})
+
+	t.Run("create and check", func(t *testing.T) {
+		sm := newManager(t)
+
+		assert.NoError(t, sm.Create(ctx, "token", 1))
+		session, err := sm.Check(ctx, 1)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "token", session.JWT)
+		assert.Equal(t, 1, session.UserID)
+		assert.True(t, session.ExpiresAt.After(time.Now()))
+
+		_, err = sm.Check(ctx, 2)
+		assert.Equal(t, models.ErrNoSession, err)
+	})
+
+	t.Run("one session per user", func(t *testing.T) {
+		sm := newManager(t)
+
+		assert.NoError(t, sm.Create(ctx, "first", 1))
+		assert.NoError(t, sm.Create(ctx, "second", 1))
+		assert.NoError(t, sm.Create(ctx, "other", 2))
+
+		session, _ := sm.Check(ctx, 1)
+		assert.Equal(t, "second", session.JWT)
+		session, _ = sm.Check(ctx, 2)
+		assert.Equal(t, "other", session.JWT)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		sm := newManager(t)
+		sm.Create(ctx, "token", 1)
+		sm.Create(ctx, "other", 2)
+
+		assert.NoError(t, sm.Delete(ctx, 1))
+		_, err := sm.Check(ctx, 1)
+		assert.Equal(t, models.ErrNoSession, err)
+
+		_, err = sm.Check(ctx, 2)
+		assert.NoError(t, err)
+	})
+}
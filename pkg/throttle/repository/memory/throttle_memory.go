@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"redditclone/pkg/throttle/repository"
+	"sync"
+	"time"
+)
+
+// Раз в sweepInterval из памяти удаляются счетчики с истекшим окном.
+const sweepInterval = time.Minute
+
+type attempts struct {
+	count       int
+	windowEnd   time.Time
+	lockedUntil time.Time
+}
+
+// ThrottleMemoryManager считает попытки в памяти процесса: для одного узла и разработки без Redis.
+type ThrottleMemoryManager struct {
+	mu        *sync.Mutex
+	policy    repository.Policy
+	attempts  map[string]*attempts
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewThrottleMemoryManager(policy repository.Policy) *ThrottleMemoryManager {
+	return &ThrottleMemoryManager{
+		mu:        &sync.Mutex{},
+		policy:    policy,
+		attempts:  make(map[string]*attempts),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (tm *ThrottleMemoryManager) Check(key string) (time.Duration, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	a, ok := tm.attempts[key]
+	if !ok {
+		return 0, nil
+	}
+
+	return max(a.lockedUntil.Sub(tm.now()), 0), nil
+}
+
+func (tm *ThrottleMemoryManager) Hit(key string) (time.Duration, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	now := tm.now()
+	tm.sweep(now)
+
+	// Окно отсчитывается от первой попытки, как и в Redis-реализации.
+	a, ok := tm.attempts[key]
+	if !ok {
+		a = &attempts{}
+		tm.attempts[key] = a
+	}
+	if !now.Before(a.windowEnd) {
+		a.count = 0
+		a.windowEnd = now.Add(tm.policy.Window)
+	}
+	a.count++
+
+	delay := tm.policy.Delay(a.count)
+	if delay > 0 {
+		a.lockedUntil = now.Add(delay)
+	}
+
+	return delay, nil
+}
+
+func (tm *ThrottleMemoryManager) Reset(key string) error {
+	tm.mu.Lock()
+	delete(tm.attempts, key)
+	tm.mu.Unlock()
+
+	return nil
+}
+
+// sweep удаляет счетчики, у которых закончились и окно, и блокировка.
+func (tm *ThrottleMemoryManager) sweep(now time.Time) {
+	if now.Sub(tm.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, a := range tm.attempts {
+		if !now.Before(a.windowEnd) && !now.Before(a.lockedUntil) {
+			delete(tm.attempts, key)
+		}
+	}
+	tm.lastSweep = now
+}
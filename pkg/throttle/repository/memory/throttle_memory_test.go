@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"redditclone/pkg/throttle/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	tm := NewThrottleMemoryManager(repository.Policy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		Window:       time.Hour,
+	})
+
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	tm.now = func() time.Time { return now }
+	tm.lastSweep = now
+
+	for i := 0; i < 2; i++ {
+		delay, err := tm.Hit("key")
+		assert.NoError(t, err)
+		assert.Zero(t, delay)
+	}
+
+	delay, _ := tm.Hit("key")
+	assert.Equal(t, time.Second, delay)
+	delay, _ = tm.Hit("key")
+	assert.Equal(t, 2*time.Second, delay)
+
+	wait, err := tm.Check("key")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, wait)
+
+	t.Run("lock expires", func(t *testing.T) {
+		now = now.Add(3 * time.Second)
+		wait, _ := tm.Check("key")
+		assert.Zero(t, wait)
+	})
+
+	t.Run("window expires", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		delay, _ := tm.Hit("key")
+		assert.Zero(t, delay)
+		assert.Len(t, tm.attempts, 1)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		tm.Hit("key")
+		tm.Hit("key")
+		assert.NoError(t, tm.Reset("key"))
+		wait, _ := tm.Check("key")
+		assert.Zero(t, wait)
+	})
+}
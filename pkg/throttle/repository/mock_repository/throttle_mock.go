@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: repository.go
+
+// Package mock_repository is a generated GoMock package.
+package mock_repository
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockThrottler is a mock of Throttler interface.
+type MockThrottler struct {
+	ctrl     *gomock.Controller
+	recorder *MockThrottlerMockRecorder
+}
+
+// MockThrottlerMockRecorder is the mock recorder for MockThrottler.
+type MockThrottlerMockRecorder struct {
+	mock *MockThrottler
+}
+
+// NewMockThrottler creates a new mock instance.
+func NewMockThrottler(ctrl *gomock.Controller) *MockThrottler {
+	mock := &MockThrottler{ctrl: ctrl}
+	mock.recorder = &MockThrottlerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockThrottler) EXPECT() *MockThrottlerMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockThrottler) Check(key string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", key)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockThrottlerMockRecorder) Check(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockThrottler)(nil).Check), key)
+}
+
+// Hit mocks base method.
+func (m *MockThrottler) Hit(key string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hit", key)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Hit indicates an expected call of Hit.
+func (mr *MockThrottlerMockRecorder) Hit(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hit", reflect.TypeOf((*MockThrottler)(nil).Hit), key)
+}
+
+// Reset mocks base method.
+func (m *MockThrottler) Reset(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockThrottlerMockRecorder) Reset(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockThrottler)(nil).Reset), key)
+}
@@ -0,0 +1,89 @@
+package redis
+
+import (
+	"redditclone/pkg/throttle/repository"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type ThrottleRedisManager struct {
+	redisConn redis.Conn
+	mu        *sync.Mutex
+	prefix    string
+	policy    repository.Policy
+}
+
+// NewThrottleRedisManager создает счетчик попыток; prefix разделяет независимые счетчики (вход, регистрация).
+func NewThrottleRedisManager(conn redis.Conn, prefix string, policy repository.Policy) *ThrottleRedisManager {
+	return &ThrottleRedisManager{
+		redisConn: conn,
+		mu:        &sync.Mutex{},
+		prefix:    prefix,
+		policy:    policy,
+	}
+}
+
+func (tm *ThrottleRedisManager) attemptsKey(key string) string {
+	return "throttle:" + tm.prefix + ":attempts:" + key
+}
+
+func (tm *ThrottleRedisManager) lockKey(key string) string {
+	return "throttle:" + tm.prefix + ":lock:" + key
+}
+
+func (tm *ThrottleRedisManager) Check(key string) (time.Duration, error) {
+	tm.mu.Lock()
+	ttl, err := redis.Int64(tm.redisConn.Do("PTTL", tm.lockKey(key)))
+	tm.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	// PTTL возвращает -2 для отсутствующего ключа и -1 для ключа без срока жизни.
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+func (tm *ThrottleRedisManager) Hit(key string) (time.Duration, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	// Окно отсчитывается от первой попытки: NX не дает продлевать его каждой новой ошибкой.
+	tm.redisConn.Send("MULTI")
+	tm.redisConn.Send("INCR", tm.attemptsKey(key))
+	tm.redisConn.Send("PEXPIRE", tm.attemptsKey(key), tm.policy.Window.Milliseconds(), "NX")
+	replies, err := redis.Values(tm.redisConn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+
+	attempts, err := redis.Int(replies[0], nil)
+	if err != nil {
+		return 0, err
+	}
+
+	delay := tm.policy.Delay(attempts)
+	if delay == 0 {
+		return 0, nil
+	}
+
+	_, err = tm.redisConn.Do("SET", tm.lockKey(key), attempts, "PX", delay.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return delay, nil
+}
+
+func (tm *ThrottleRedisManager) Reset(key string) error {
+	tm.mu.Lock()
+	_, err := tm.redisConn.Do("DEL", tm.attemptsKey(key), tm.lockKey(key))
+	tm.mu.Unlock()
+
+	return err
+}
@@ -0,0 +1,37 @@
+package repository
+
+import "time"
+
+//go:generate mockgen -source=repository.go -destination=mock_repository/throttle_mock.go -package=mock_repository MockThrottler
+type Throttler interface {
+	// Check возвращает, сколько еще осталось ждать до следующей попытки.
+	Check(key string) (time.Duration, error)
+	// Hit учитывает попытку и возвращает назначенную блокировку, если попыток стало слишком много.
+	Hit(key string) (time.Duration, error)
+	Reset(key string) error
+}
+
+// Policy описывает экспоненциальную задержку: первые FreeAttempts попыток в окне Window бесплатны,
+// дальше каждая новая попытка удваивает блокировку, начиная с BaseDelay и не больше MaxDelay.
+type Policy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	Window       time.Duration
+}
+
+func (p Policy) Delay(attempts int) time.Duration {
+	if attempts <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+
+	return min(delay, p.MaxDelay)
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     10 * time.Second,
+		Window:       time.Hour,
+	}
+
+	cases := map[int]time.Duration{
+		0:   0,
+		3:   0,
+		4:   time.Second,
+		5:   2 * time.Second,
+		6:   4 * time.Second,
+		7:   8 * time.Second,
+		8:   10 * time.Second,
+		100: 10 * time.Second,
+	}
+
+	for attempts, expected := range cases {
+		assert.Equal(t, expected, policy.Delay(attempts), attempts)
+	}
+}
@@ -0,0 +1,77 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Тестовые векторы SHA1 из приложения B RFC 6238 (последние 6 цифр).
+func TestGenerateCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range cases {
+		code, err := GenerateCode(secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	code, err := GenerateCode(secret, now)
+	assert.NoError(t, err)
+
+	valid := func(t time.Time) bool {
+		_, ok := Validate(secret, code, t)
+		return ok
+	}
+
+	t.Run("current step", func(t *testing.T) {
+		step, ok := Validate(secret, code, now)
+		assert.True(t, ok)
+		assert.Equal(t, now.Unix()/30, step)
+	})
+
+	t.Run("clock skew", func(t *testing.T) {
+		// Шаг кода не зависит от того, по чьим часам его проверили.
+		step, ok := Validate(secret, code, now.Add(Period))
+		assert.True(t, ok)
+		assert.Equal(t, now.Unix()/30, step)
+		assert.True(t, valid(now.Add(-Period)))
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		assert.False(t, valid(now.Add(3*Period)))
+	})
+
+	t.Run("malformed input", func(t *testing.T) {
+		_, ok := Validate(secret, "12345", now)
+		assert.False(t, ok)
+		_, ok = Validate("not base32!", code, now)
+		assert.False(t, ok)
+	})
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("redditclone", "alex12345", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/redditclone:alex12345?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=redditclone")
+}
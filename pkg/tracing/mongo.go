@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MongoMonitor открывает спан на каждую команду Mongo. Команды вне трассы (пинги, миграции
+// при старте) не записываются, чтобы не плодить одиночные спаны.
+func MongoMonitor() *event.CommandMonitor {
+	// Драйвер сообщает о начале и конце команды отдельными событиями, связанными RequestID.
+	spans := &sync.Map{}
+
+	end := func(requestID int64, err error) {
+		if span, ok := spans.LoadAndDelete(requestID); ok {
+			finish(span.(trace.Span), err)
+		}
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+
+			collection, _ := evt.Command.Lookup(evt.CommandName).StringValueOK()
+			_, span := Tracer().Start(ctx, "mongo."+evt.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemMongoDB,
+					semconv.DBNamespace(evt.DatabaseName),
+					semconv.DBCollectionName(collection),
+					semconv.DBOperationName(evt.CommandName),
+				),
+			)
+			spans.Store(evt.RequestID, span)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			end(evt.RequestID, nil)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			end(evt.RequestID, errors.New(evt.Failure))
+		},
+	}
+}
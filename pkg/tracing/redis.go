@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redisConn открывает спан на каждую команду, отправленную через redis.DoContext
+// в рамках трассы. Do без контекста не трассируется: ему не к чему привязать спан.
+type redisConn struct {
+	redis.Conn
+}
+
+func WrapRedisConn(conn redis.Conn) redis.Conn {
+	return &redisConn{Conn: conn}
+}
+
+func (c *redisConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return redis.DoContext(c.Conn, ctx, commandName, args...)
+	}
+
+	ctx, end := Start(ctx, "redis."+commandName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperationName(commandName)),
+	)
+	defer end(&err)
+
+	return redis.DoContext(c.Conn, ctx, commandName, args...)
+}
+
+func (c *redisConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	return redis.ReceiveContext(c.Conn, ctx)
+}
@@ -0,0 +1,83 @@
+// Package tracing настраивает OpenTelemetry: провайдер спанов, их экспорт и распространение
+// контекста трассировки по заголовкам W3C traceparent и tracestate.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const serviceName = "redditclone"
+
+// Setup ставит глобальные провайдер и propagator. Возвращенную функцию вызывают при остановке:
+// она отправляет накопленные спаны. При exporter none спаны не создаются вовсе.
+func Setup(ctx context.Context, exporter, otlpEndpoint string) (func(ctx context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case ExporterNone:
+		return func(ctx context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New()
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(otlpEndpoint))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Tracer берет провайдер при каждом вызове, поэтому видит и тот, что поставлен после старта, например в тестах.
+func Tracer() trace.Tracer {
+	return otel.Tracer(serviceName)
+}
+
+// Start открывает дочерний спан; возвращенную функцию вызывают в defer с адресом именованной ошибки:
+// ctx, end := tracing.Start(ctx, "PostRepo.GetPostByID"); defer end(&err).
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, func(err *error)) {
+	ctx, span := Tracer().Start(ctx, name, opts...)
+
+	return ctx, func(err *error) {
+		finish(span, *err)
+	}
+}
+
+func finish(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	return exporter
+}
+
+func TestStart(t *testing.T) {
+	exporter := recordSpans(t)
+
+	func() (err error) {
+		_, end := Start(context.Background(), "PostRepo.GetPostByID")
+		defer end(&err)
+		return errors.New("no post")
+	}()
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 1) {
+		return
+	}
+	assert.Equal(t, "PostRepo.GetPostByID", spans[0].Name)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "no post", spans[0].Status.Description)
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), "jaeger", "")
+	assert.Error(t, err)
+
+	shutdown, err := Setup(context.Background(), ExporterNone, "")
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestRedisConn(t *testing.T) {
+	exporter := recordSpans(t)
+	server := miniredis.RunT(t)
+	raw, err := redis.Dial("tcp", server.Addr())
+	if !assert.NoError(t, err) {
+		return
+	}
+	conn := WrapRedisConn(raw)
+	defer conn.Close()
+
+	// Без родительского спана команда не трассируется.
+	_, err = redis.DoContext(conn, context.Background(), "SET", "key", "value")
+	assert.NoError(t, err)
+	assert.Empty(t, exporter.GetSpans())
+
+	ctx, end := Start(context.Background(), "SessionManager.Check")
+	value, err := redis.String(redis.DoContext(conn, ctx, "GET", "key"))
+	end(&err)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	assert.Equal(t, "redis.GET", spans[0].Name)
+	assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+}
@@ -0,0 +1,502 @@
+package delivery
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	commentRepository "redditclone/pkg/comment/repository"
+	"redditclone/pkg/mail"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/models"
+	postRepository "redditclone/pkg/post/repository"
+	sessionRepository "redditclone/pkg/session/repository"
+	"redditclone/pkg/user/policy"
+	userRepository "redditclone/pkg/user/repository"
+	"redditclone/tools"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	passwordResetPurpose = "password_reset"
+	passwordResetTTL     = time.Hour
+
+	// PasswordResetPagePath - адрес страницы из письма о сбросе пароля.
+	PasswordResetPagePath = "/password/reset"
+)
+
+//go:embed templates/password_reset.html
+var passwordResetPage string
+
+var passwordResetTemplate = template.Must(template.New("password_reset").Parse(passwordResetPage))
+
+type AccountHandler struct {
+	UserRepo          userRepository.UserRepo
+	SessionRepo       sessionRepository.SessionManager
+	PostRepo          postRepository.PostRepo
+	CommentRepo       commentRepository.CommentRepo
+	CredentialsPolicy *policy.Policy
+	Mailer            mail.Sender
+	PublicURL         string
+	TokenKey          []byte
+}
+
+type PasswordChangeForm struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type PasswordResetRequestForm struct {
+	Login string `json:"username"`
+}
+
+type PasswordResetForm struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type AccountDeleteForm struct {
+	Password string `json:"password"`
+}
+
+// Токен сброса подписывается отпечатком текущего хеша пароля,
+// поэтому после смены пароля он перестает быть валидным.
+func createPasswordResetToken(tokenKey []byte, user *models.User) (string, error) {
+	return createPurposeToken(tokenKey, passwordResetPurpose, passwordResetTTL, jwt.MapClaims{
+		"id":          strconv.Itoa(user.ID),
+		"fingerprint": tools.GetSHA1Hash(user.Password),
+	})
+}
+
+func parsePasswordResetToken(tokenKey []byte, tokenString string) (int, string, error) {
+	claims, err := parsePurposeToken(tokenKey, tokenString, passwordResetPurpose)
+	if err != nil {
+		return 0, "", err
+	}
+
+	userIDString, ok := claims["id"].(string)
+	if !ok {
+		return 0, "", models.ErrBadToken
+	}
+	userID, err := strconv.Atoi(userIDString)
+	if err != nil {
+		return 0, "", models.ErrBadToken
+	}
+
+	fingerprint, ok := claims["fingerprint"].(string)
+	if !ok {
+		return 0, "", models.ErrBadToken
+	}
+
+	return userID, fingerprint, nil
+}
+
+func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
+	if !ok {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "AccountHandler.ChangePassword")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "AccountHandler.ChangePassword")
+		return
+	}
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ChangePassword")
+		return
+	}
+
+	form := &PasswordChangeForm{}
+	err = tools.UnmarshalStrict(body, form)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, "cant unpack payload", "AccountHandler.ChangePassword")
+		return
+	}
+
+	if err = h.CredentialsPolicy.ValidatePassword(form.NewPassword); err != nil {
+		tools.JSONValidationErrors(w, r, []*tools.FieldError{passwordFieldError("newPassword", err)}, "AccountHandler.ChangePassword")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	_, err = h.UserRepo.GetUserFromRepo(r.Context(), user.Login, form.CurrentPassword)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "UserRepo.GetUserFromRepo")
+		return
+	}
+
+	err = h.UserRepo.UpdatePassword(r.Context(), user.ID, form.NewPassword)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.UpdatePassword")
+		return
+	}
+
+	err = h.SessionRepo.Delete(r.Context(), user.ID)
+	if err != nil && err != models.ErrNoSession {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "SessionRepo.Delete")
+		return
+	}
+
+	tokenString, err := createUserJWT(h.TokenKey, user)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "createUserJWT")
+		return
+	}
+
+	err = h.SessionRepo.Create(r.Context(), tokenString, user.ID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "SessionRepo.Create")
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"token": tokenString,
+	})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ChangePassword")
+		return
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ChangePassword")
+		return
+	}
+}
+
+func (h *AccountHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "AccountHandler.RequestPasswordReset")
+		return
+	}
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.RequestPasswordReset")
+		return
+	}
+
+	form := &PasswordResetRequestForm{}
+	err = tools.UnmarshalStrict(body, form)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, "cant unpack payload", "AccountHandler.RequestPasswordReset")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByLogin(r.Context(), form.Login)
+	if err != nil && err != models.ErrNoUser {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetUserByLogin")
+		return
+	}
+
+	// Ответ не зависит от существования пользователя, чтобы по нему нельзя было перебирать логины.
+	if user != nil && user.Email == "" {
+		tools.LoggerFrom(r.Context()).WithFields(logrus.Fields{
+			"method": "AccountHandler.RequestPasswordReset",
+			"user":   user.ID,
+		}).Warn(models.ErrNoEmail.Error())
+	} else if user != nil {
+		token, err := createPasswordResetToken(h.TokenKey, user)
+		if err != nil {
+			tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "createPasswordResetToken")
+			return
+		}
+
+		err = h.Mailer.Send(&mail.Message{
+			To:      user.Email,
+			Subject: "Password reset",
+			Body: fmt.Sprintf(
+				"To reset your password follow the link: %s%s?token=%s\nThe link expires in %s.",
+				h.PublicURL,
+				PasswordResetPagePath,
+				url.QueryEscape(token),
+				passwordResetTTL,
+			),
+		})
+		if err != nil {
+			tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "Mailer.Send")
+			return
+		}
+	}
+
+	response, err := json.Marshal(map[string]string{
+		"message": "if such user exists, reset instructions have been sent",
+	})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.RequestPasswordReset")
+		return
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.RequestPasswordReset")
+		return
+	}
+}
+
+// ResetPasswordPage отдает форму для ссылки из письма: в SPA такой страницы нет.
+// Форма отправляет токен и новый пароль в ResetPassword.
+func (h *AccountHandler) ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	page := &bytes.Buffer{}
+	err := passwordResetTemplate.Execute(page, map[string]string{"Token": r.URL.Query().Get("token")})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ResetPasswordPage")
+		return
+	}
+
+	// С токеном из адреса можно сменить пароль: страницу не кэшируем и адрес не передаем дальше.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	_, err = w.Write(page.Bytes())
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ResetPasswordPage")
+		return
+	}
+}
+
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "AccountHandler.ResetPassword")
+		return
+	}
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ResetPassword")
+		return
+	}
+
+	form := &PasswordResetForm{}
+	err = tools.UnmarshalStrict(body, form)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, "cant unpack payload", "AccountHandler.ResetPassword")
+		return
+	}
+
+	if err = h.CredentialsPolicy.ValidatePassword(form.Password); err != nil {
+		tools.JSONValidationErrors(w, r, []*tools.FieldError{passwordFieldError("password", err)}, "AccountHandler.ResetPassword")
+		return
+	}
+
+	userID, fingerprint, err := parsePasswordResetToken(h.TokenKey, form.Token)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "parsePasswordResetToken")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err == models.ErrNoUser {
+		tools.JSONError(w, r, http.StatusBadRequest, models.ErrBadToken.Error(), "UserRepo.GetUserByID")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetUserByID")
+		return
+	}
+
+	if tools.GetSHA1Hash(user.Password) != fingerprint {
+		tools.JSONError(w, r, http.StatusBadRequest, models.ErrBadToken.Error(), "AccountHandler.ResetPassword")
+		return
+	}
+
+	err = h.UserRepo.UpdatePassword(r.Context(), user.ID, form.Password)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.UpdatePassword")
+		return
+	}
+
+	err = h.SessionRepo.Delete(r.Context(), user.ID)
+	if err != nil && err != models.ErrNoSession {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "SessionRepo.Delete")
+		return
+	}
+
+	jsonOK, err := json.Marshal(map[string]string{"message": "success"})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ResetPassword")
+		return
+	}
+
+	_, err = w.Write(jsonOK)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ResetPassword")
+		return
+	}
+}
+
+func (h *AccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
+	if !ok {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "AccountHandler.DeleteAccount")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "AccountHandler.DeleteAccount")
+		return
+	}
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.DeleteAccount")
+		return
+	}
+
+	form := &AccountDeleteForm{}
+	err = tools.UnmarshalStrict(body, form)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, "cant unpack payload", "AccountHandler.DeleteAccount")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	_, err = h.UserRepo.GetUserFromRepo(r.Context(), user.Login, form.Password)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "UserRepo.GetUserFromRepo")
+		return
+	}
+
+	// Записи в разных базах не объединить в транзакцию, поэтому строка пользователя
+	// удаляется последней: при любом сбое раньше аккаунт остается, и пользователь может
+	// повторить удаление. Анонимизация при повторе ничего не портит.
+	err = h.PostRepo.AnonymizeAuthor(r.Context(), user.ID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.AnonymizeAuthor")
+		return
+	}
+
+	err = h.CommentRepo.AnonymizeAuthor(r.Context(), user.ID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentRepo.AnonymizeAuthor")
+		return
+	}
+
+	err = h.SessionRepo.Delete(r.Context(), user.ID)
+	if err != nil && err != models.ErrNoSession {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "SessionRepo.Delete")
+		return
+	}
+
+	err = h.UserRepo.DeleteUser(r.Context(), user.ID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.DeleteUser")
+		return
+	}
+
+	jsonOK, err := json.Marshal(map[string]string{"message": "success"})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.DeleteAccount")
+		return
+	}
+
+	_, err = w.Write(jsonOK)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.DeleteAccount")
+		return
+	}
+}
+
+func (h *AccountHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
+	if !ok {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "AccountHandler.ResendVerification")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	if user.Email == "" {
+		tools.JSONError(w, r, http.StatusBadRequest, models.ErrNoEmail.Error(), "AccountHandler.ResendVerification")
+		return
+	}
+
+	message := "email already verified"
+	if !user.EmailVerified {
+		err = sendVerificationEmail(h.Mailer, h.PublicURL, h.TokenKey, user)
+		if err != nil {
+			tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "sendVerificationEmail")
+			return
+		}
+		message = "verification email sent"
+	}
+
+	response, err := json.Marshal(map[string]string{"message": message})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ResendVerification")
+		return
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ResendVerification")
+		return
+	}
+}
+
+func (h *AccountHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	userID, email, err := parseEmailVerificationToken(h.TokenKey, r.URL.Query().Get("token"))
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "parseEmailVerificationToken")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err == models.ErrNoUser {
+		tools.JSONError(w, r, http.StatusBadRequest, models.ErrBadToken.Error(), "UserRepo.GetUserByID")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetUserByID")
+		return
+	}
+
+	if user.Email != email {
+		tools.JSONError(w, r, http.StatusBadRequest, models.ErrBadToken.Error(), "AccountHandler.ConfirmEmail")
+		return
+	}
+
+	if !user.EmailVerified {
+		err = h.UserRepo.VerifyEmail(r.Context(), user.ID, email)
+		if err != nil {
+			tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.VerifyEmail")
+			return
+		}
+	}
+
+	response, err := json.Marshal(map[string]string{"message": "email verified"})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ConfirmEmail")
+		return
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AccountHandler.ConfirmEmail")
+		return
+	}
+}
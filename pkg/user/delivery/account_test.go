@@ -0,0 +1,549 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	commentMemory "redditclone/pkg/comment/repository/memory"
+	commentMock "redditclone/pkg/comment/repository/mock_repository"
+	"redditclone/pkg/mail"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/models"
+	postRepository "redditclone/pkg/post/repository"
+	postMemory "redditclone/pkg/post/repository/memory"
+	postMock "redditclone/pkg/post/repository/mock_repository"
+	sessionMemory "redditclone/pkg/session/repository/memory"
+	sessionMock "redditclone/pkg/session/repository/mock_repository"
+	"redditclone/pkg/user/policy"
+	userMemory "redditclone/pkg/user/repository/memory"
+	userMock "redditclone/pkg/user/repository/mock_repository"
+	"redditclone/tools"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingAnonymizer отказывает в AnonymizeAuthor заданное число раз, как упавшая Mongo.
+type failingAnonymizer struct {
+	postRepository.PostRepo
+	failures int
+}
+
+func (repo *failingAnonymizer) AnonymizeAuthor(ctx context.Context, userID int) error {
+	if repo.failures > 0 {
+		repo.failures--
+		return errors.New("mongo is down")
+	}
+	return repo.PostRepo.AnonymizeAuthor(ctx, userID)
+}
+
+type fakeSender struct {
+	messages []*mail.Message
+}
+
+func (s *fakeSender) Send(msg *mail.Message) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func TestAccountHandlerChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockSessionRepo := sessionMock.NewMockSessionManager(ctrl)
+
+	accountHandler := &AccountHandler{
+		UserRepo:          mockUserRepo,
+		SessionRepo:       mockSessionRepo,
+		CredentialsPolicy: policy.NewPolicy(),
+		TokenKey:          []byte("test key"),
+	}
+
+	tools.Init()
+
+	var user = &models.User{
+		ID:       1,
+		Login:    "alex12345",
+		Password: "hashed password",
+	}
+
+	var form = &PasswordChangeForm{
+		CurrentPassword: "alex12345",
+		NewPassword:     "new password",
+	}
+
+	t.Run("correct password change", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), user.Login, form.CurrentPassword).Return(user, nil)
+		mockUserRepo.EXPECT().UpdatePassword(gomock.Any(), user.ID, form.NewPassword).Return(nil)
+		mockSessionRepo.EXPECT().Delete(gomock.Any(), user.ID).Return(nil)
+		mockSessionRepo.EXPECT().Create(gomock.Any(), gomock.Any(), user.ID).Return(nil)
+
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("POST", "/api/account/password", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		accountHandler.ChangePassword(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		var response map[string]interface{}
+		err := json.NewDecoder(resp.Body).Decode(&response)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotNil(t, response["token"])
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), user.Login, form.CurrentPassword).Return(nil, models.ErrWrongCredentials)
+
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("POST", "/api/account/password", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		accountHandler.ChangePassword(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("auth error", func(t *testing.T) {
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("POST", "/api/account/password", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		accountHandler.ChangePassword(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("UserRepo.UpdatePassword error", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), user.Login, form.CurrentPassword).Return(user, nil)
+		mockUserRepo.EXPECT().UpdatePassword(gomock.Any(), user.ID, form.NewPassword).Return(errors.New("mock error"))
+
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("POST", "/api/account/password", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		accountHandler.ChangePassword(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestAccountHandlerPasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockSessionRepo := sessionMock.NewMockSessionManager(ctrl)
+	sender := &fakeSender{}
+
+	accountHandler := &AccountHandler{
+		UserRepo:          mockUserRepo,
+		SessionRepo:       mockSessionRepo,
+		Mailer:            sender,
+		PublicURL:         "http://localhost:8080",
+		CredentialsPolicy: policy.NewPolicy(),
+		TokenKey:          []byte("test key"),
+	}
+
+	tools.Init()
+
+	var user = &models.User{
+		ID:       1,
+		Login:    "alex12345",
+		Password: "hashed password",
+		Email:    "alex@example.com",
+	}
+
+	t.Run("unknown user gets the same response", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByLogin(gomock.Any(), "unknown").Return(nil, models.ErrNoUser)
+
+		reqBody, _ := json.Marshal(&PasswordResetRequestForm{Login: "unknown"})
+		req := httptest.NewRequest("POST", "/api/password/reset", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		accountHandler.RequestPasswordReset(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, sender.messages)
+	})
+
+	t.Run("correct reset", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByLogin(gomock.Any(), user.Login).Return(user, nil)
+
+		reqBody, _ := json.Marshal(&PasswordResetRequestForm{Login: user.Login})
+		req := httptest.NewRequest("POST", "/api/password/reset", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		accountHandler.RequestPasswordReset(w, req)
+
+		resp := w.Result()
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 1, len(sender.messages))
+
+		token, err := createPasswordResetToken(accountHandler.TokenKey, user)
+		assert.NoError(t, err)
+		assert.Equal(t, user.Email, sender.messages[0].To)
+		assert.True(t, strings.Contains(sender.messages[0].Body, "http://localhost:8080/password/reset?token="))
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().UpdatePassword(gomock.Any(), user.ID, "new password").Return(nil)
+		mockSessionRepo.EXPECT().Delete(gomock.Any(), user.ID).Return(nil)
+
+		reqBody, _ = json.Marshal(&PasswordResetForm{Token: token, Password: "new password"})
+		req = httptest.NewRequest("POST", "/api/password/reset/confirm", bytes.NewReader(reqBody))
+		w = httptest.NewRecorder()
+
+		accountHandler.ResetPassword(w, req)
+
+		resp = w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("reset page", func(t *testing.T) {
+		req := httptest.NewRequest("GET", PasswordResetPagePath+"?token=abc%22%3E%3Cscript%3E", nil)
+		w := httptest.NewRecorder()
+
+		accountHandler.ResetPasswordPage(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+		page, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+		assert.Contains(t, string(page), `action="/api/password/reset/confirm"`)
+		// Токен из адреса экранируется.
+		assert.Contains(t, string(page), `value="abc&#34;&gt;&lt;script&gt;"`)
+	})
+
+	t.Run("token is single use", func(t *testing.T) {
+		token, err := createPasswordResetToken(accountHandler.TokenKey, user)
+		assert.NoError(t, err)
+
+		changedUser := *user
+		changedUser.Password = "another hashed password"
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(&changedUser, nil)
+
+		reqBody, _ := json.Marshal(&PasswordResetForm{Token: token, Password: "new password"})
+		req := httptest.NewRequest("POST", "/api/password/reset/confirm", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		accountHandler.ResetPassword(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("bad token", func(t *testing.T) {
+		sessionToken, err := createUserJWT(accountHandler.TokenKey, user)
+		assert.NoError(t, err)
+
+		for _, token := range []string{"qwe", sessionToken} {
+			reqBody, _ := json.Marshal(&PasswordResetForm{Token: token, Password: "new password"})
+			req := httptest.NewRequest("POST", "/api/password/reset/confirm", bytes.NewReader(reqBody))
+			w := httptest.NewRecorder()
+
+			accountHandler.ResetPassword(w, req)
+
+			resp := w.Result()
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+}
+
+func TestAccountHandlerDeleteAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockSessionRepo := sessionMock.NewMockSessionManager(ctrl)
+	mockPostRepo := postMock.NewMockPostRepo(ctrl)
+	mockCommentRepo := commentMock.NewMockCommentRepo(ctrl)
+
+	accountHandler := &AccountHandler{
+		UserRepo:          mockUserRepo,
+		SessionRepo:       mockSessionRepo,
+		PostRepo:          mockPostRepo,
+		CommentRepo:       mockCommentRepo,
+		CredentialsPolicy: policy.NewPolicy(),
+	}
+
+	tools.Init()
+
+	var user = &models.User{
+		ID:       1,
+		Login:    "alex12345",
+		Password: "hashed password",
+	}
+
+	var form = &AccountDeleteForm{
+		Password: "alex12345",
+	}
+
+	t.Run("correct delete", func(t *testing.T) {
+		gomock.InOrder(
+			mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil),
+			mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), user.Login, form.Password).Return(user, nil),
+			mockPostRepo.EXPECT().AnonymizeAuthor(gomock.Any(), user.ID).Return(nil),
+			mockCommentRepo.EXPECT().AnonymizeAuthor(gomock.Any(), user.ID).Return(nil),
+			mockSessionRepo.EXPECT().Delete(gomock.Any(), user.ID).Return(nil),
+			mockUserRepo.EXPECT().DeleteUser(gomock.Any(), user.ID).Return(nil),
+		)
+
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("DELETE", "/api/account", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		accountHandler.DeleteAccount(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("PostRepo.AnonymizeAuthor error keeps user", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), user.Login, form.Password).Return(user, nil)
+		mockPostRepo.EXPECT().AnonymizeAuthor(gomock.Any(), user.ID).Return(errors.New("mock error"))
+
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("DELETE", "/api/account", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		accountHandler.DeleteAccount(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("CommentRepo.AnonymizeAuthor error keeps user", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), user.Login, form.Password).Return(user, nil)
+		mockPostRepo.EXPECT().AnonymizeAuthor(gomock.Any(), user.ID).Return(nil)
+		mockCommentRepo.EXPECT().AnonymizeAuthor(gomock.Any(), user.ID).Return(errors.New("mock error"))
+
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("DELETE", "/api/account", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		accountHandler.DeleteAccount(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), user.Login, form.Password).Return(nil, models.ErrWrongCredentials)
+
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("DELETE", "/api/account", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		accountHandler.DeleteAccount(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestAccountHandlerDeleteAccountRetry(t *testing.T) {
+	tools.Init()
+	ctx := context.Background()
+
+	userRepo := userMemory.NewUserMemoryRepository()
+	postRepo := &failingAnonymizer{PostRepo: postMemory.NewPostMemoryRepository(), failures: 1}
+	accountHandler := &AccountHandler{
+		UserRepo:          userRepo,
+		SessionRepo:       sessionMemory.NewSessionMemoryManager(),
+		PostRepo:          postRepo,
+		CommentRepo:       commentMemory.NewCommentMemoryRepository(),
+		CredentialsPolicy: policy.NewPolicy(),
+	}
+
+	user, err := userRepo.CreateUser(ctx, "alex12345", "correct horse", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	post, _ := postRepo.CreateNewPost(ctx, "music", "title", "text", "", "body", user)
+
+	deleteAccount := func() int {
+		reqBody, _ := json.Marshal(&AccountDeleteForm{Password: "correct horse"})
+		req := httptest.NewRequest("DELETE", "/api/account", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		accountHandler.DeleteAccount(w, req)
+
+		resp := w.Result()
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Сбой анонимизации оставляет и аккаунт, и авторство: удаление можно повторить.
+	assert.Equal(t, http.StatusInternalServerError, deleteAccount())
+	_, err = userRepo.GetUserByID(ctx, user.ID)
+	assert.NoError(t, err)
+	found, _ := postRepo.GetPostByID(ctx, post.ID.Hex())
+	assert.Equal(t, user.Login, found.Author.Login)
+
+	assert.Equal(t, http.StatusOK, deleteAccount())
+	_, err = userRepo.GetUserByID(ctx, user.ID)
+	assert.Equal(t, models.ErrNoUser, err)
+	found, _ = postRepo.GetPostByID(ctx, post.ID.Hex())
+	assert.Equal(t, models.DeletedUserLogin, found.Author.Login)
+}
+
+func TestAccountHandlerEmailVerification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	sender := &fakeSender{}
+
+	accountHandler := &AccountHandler{
+		UserRepo:          mockUserRepo,
+		Mailer:            sender,
+		PublicURL:         "http://localhost:8080",
+		CredentialsPolicy: policy.NewPolicy(),
+		TokenKey:          []byte("test key"),
+	}
+
+	tools.Init()
+
+	var user = &models.User{
+		ID:    1,
+		Login: "alex12345",
+		Email: "alex@example.com",
+	}
+
+	t.Run("correct verification", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+
+		req := httptest.NewRequest("POST", "/api/account/email/verify", nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		accountHandler.ResendVerification(w, req)
+
+		resp := w.Result()
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 1, len(sender.messages))
+		assert.Equal(t, user.Email, sender.messages[0].To)
+
+		token, err := createEmailVerificationToken(accountHandler.TokenKey, user)
+		assert.NoError(t, err)
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().VerifyEmail(gomock.Any(), user.ID, user.Email).Return(nil)
+
+		req = httptest.NewRequest("GET", "/api/account/email/confirm?token="+token, nil)
+		w = httptest.NewRecorder()
+
+		accountHandler.ConfirmEmail(w, req)
+
+		resp = w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("token for old email", func(t *testing.T) {
+		oldUser := *user
+		oldUser.Email = "old@example.com"
+		token, err := createEmailVerificationToken(accountHandler.TokenKey, &oldUser)
+		assert.NoError(t, err)
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+
+		req := httptest.NewRequest("GET", "/api/account/email/confirm?token="+token, nil)
+		w := httptest.NewRecorder()
+
+		accountHandler.ConfirmEmail(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("password reset token is rejected", func(t *testing.T) {
+		token, err := createPasswordResetToken(accountHandler.TokenKey, user)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/api/account/email/confirm?token="+token, nil)
+		w := httptest.NewRecorder()
+
+		accountHandler.ConfirmEmail(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("ErrNoEmail", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), 2).Return(&models.User{ID: 2, Login: "noemail"}, nil)
+
+		req := httptest.NewRequest("POST", "/api/account/email/verify", nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, 2))
+		w := httptest.NewRecorder()
+
+		accountHandler.ResendVerification(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
@@ -0,0 +1,64 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/models"
+	throttleRepository "redditclone/pkg/throttle/repository"
+	userRepository "redditclone/pkg/user/repository"
+	"redditclone/tools"
+
+	"github.com/gorilla/mux"
+)
+
+type AdminHandler struct {
+	UserRepo       userRepository.UserRepo
+	LoginThrottler throttleRepository.Throttler
+}
+
+func (h *AdminHandler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
+	if !ok {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "AdminHandler.ClearLockout")
+		return
+	}
+
+	admin, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	if !admin.Admin {
+		tools.JSONError(w, r, http.StatusForbidden, models.ErrNotAdmin.Error(), "AdminHandler.ClearLockout")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByLogin(r.Context(), mux.Vars(r)["username"])
+	if err == models.ErrNoUser {
+		tools.JSONError(w, r, http.StatusNotFound, err.Error(), "UserRepo.GetUserByLogin")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetUserByLogin")
+		return
+	}
+
+	err = h.LoginThrottler.Reset(throttleUserKey(user.Login))
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "Throttler.Reset")
+		return
+	}
+
+	response, err := json.Marshal(map[string]string{"message": "lockout cleared"})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AdminHandler.ClearLockout")
+		return
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "AdminHandler.ClearLockout")
+		return
+	}
+}
@@ -0,0 +1,66 @@
+package delivery
+
+import (
+	"fmt"
+	"net/url"
+	"redditclone/pkg/mail"
+	"redditclone/pkg/models"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	emailVerificationPurpose = "email_verification"
+	emailVerificationTTL     = 7 * 24 * time.Hour
+)
+
+// В токен зашивается сам адрес, так что ссылка, отправленная на старый адрес, не подтвердит новый.
+func createEmailVerificationToken(tokenKey []byte, user *models.User) (string, error) {
+	return createPurposeToken(tokenKey, emailVerificationPurpose, emailVerificationTTL, jwt.MapClaims{
+		"id":    strconv.Itoa(user.ID),
+		"email": user.Email,
+	})
+}
+
+func parseEmailVerificationToken(tokenKey []byte, tokenString string) (int, string, error) {
+	claims, err := parsePurposeToken(tokenKey, tokenString, emailVerificationPurpose)
+	if err != nil {
+		return 0, "", err
+	}
+
+	userIDString, ok := claims["id"].(string)
+	if !ok {
+		return 0, "", models.ErrBadToken
+	}
+	userID, err := strconv.Atoi(userIDString)
+	if err != nil {
+		return 0, "", models.ErrBadToken
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return 0, "", models.ErrBadToken
+	}
+
+	return userID, email, nil
+}
+
+func sendVerificationEmail(mailer mail.Sender, publicURL string, tokenKey []byte, user *models.User) error {
+	token, err := createEmailVerificationToken(tokenKey, user)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(&mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf(
+			"Hi, %s! To confirm your email follow the link: %s/api/account/email/confirm?token=%s",
+			user.Login,
+			publicURL,
+			url.QueryEscape(token),
+		),
+	})
+}
@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
-	"os"
+	"redditclone/pkg/mail"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/middleware"
 	"redditclone/pkg/models"
 	sessionRepository "redditclone/pkg/session/repository"
+	throttleRepository "redditclone/pkg/throttle/repository"
+	"redditclone/pkg/user/policy"
 	userRepository "redditclone/pkg/user/repository"
 	"strconv"
 	"time"
@@ -14,48 +18,104 @@ import (
 	"redditclone/tools"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/sirupsen/logrus"
 )
 
 type AuthForm struct {
 	Login    string `json:"username"`
 	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
 }
 
 type UserHandler struct {
-	UserRepo    userRepository.UserRepo
-	SessionRepo sessionRepository.SessionManager
+	UserRepo          userRepository.UserRepo
+	SessionRepo       sessionRepository.SessionManager
+	LoginThrottler    throttleRepository.Throttler
+	SignupThrottler   throttleRepository.Throttler
+	CredentialsPolicy *policy.Policy
+	Mailer            mail.Sender
+	PublicURL         string
+	TokenKey          []byte
 }
 
 func (h *UserHandler) Signup(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "UserHandler.Signup")
+		return
+	}
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "UserHandler.Signup")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.Signup")
 		return
 	}
 
 	authForm := &AuthForm{}
-	err = json.Unmarshal(body, authForm)
+	err = tools.UnmarshalStrict(body, authForm)
 	if err != nil {
-		tools.JSONError(w, http.StatusUnauthorized, "bad login or pass", "UserHandler.Signup")
+		tools.JSONError(w, r, http.StatusUnauthorized, "bad login or pass", "UserHandler.Signup")
 		return
 	}
 
-	user, err := h.UserRepo.CreateUser(authForm.Login, authForm.Password)
-	if err != nil {
-		tools.JSONError(w, http.StatusUnauthorized, "couldnt create user:"+err.Error(), "UserRepo.CreateUser")
+	if fieldErrors := validateAuthForm(h.CredentialsPolicy, authForm); len(fieldErrors) > 0 {
+		tools.JSONValidationErrors(w, r, fieldErrors, "UserHandler.Signup")
+		return
+	}
+
+	ipKey := throttleIPKey(r)
+	if !checkThrottle(w, r, h.SignupThrottler, "UserHandler.Signup", ipKey) {
 		return
 	}
 
-	tokenString, err := createUserJWT(user)
+	// Учитываем каждую попытку регистрации, а не только неудачные: ограничиваем само создание аккаунтов.
+	if _, err = h.SignupThrottler.Hit(ipKey); err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "Throttler.Hit")
+		return
+	}
+
+	user, err := h.UserRepo.CreateUser(r.Context(), authForm.Login, authForm.Password, authForm.Email)
+	if err == models.ErrAlreadyCreated {
+		tools.JSONValidationErrors(w, r, []*tools.FieldError{{
+			Location: "body",
+			Param:    "username",
+			Value:    authForm.Login,
+			Msg:      models.ErrLoginTaken.Error(),
+		}}, "UserRepo.CreateUser")
+		return
+	} else if err == models.ErrEmailTaken {
+		tools.JSONValidationErrors(w, r, []*tools.FieldError{{
+			Location: "body",
+			Param:    "email",
+			Value:    authForm.Email,
+			Msg:      err.Error(),
+		}}, "UserRepo.CreateUser")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusUnauthorized, "couldnt create user:"+err.Error(), "UserRepo.CreateUser")
+		return
+	}
+	metrics.SignupsTotal.Inc()
+
+	// Пользователь уже создан, поэтому ошибка отправки письма не должна ломать регистрацию:
+	// письмо можно запросить повторно.
+	if user.Email != "" {
+		if err = sendVerificationEmail(h.Mailer, h.PublicURL, h.TokenKey, user); err != nil {
+			tools.LoggerFrom(r.Context()).WithFields(logrus.Fields{
+				"method": "sendVerificationEmail",
+				"user":   user.ID,
+			}).Error(err.Error())
+		}
+	}
+
+	tokenString, err := createUserJWT(h.TokenKey, user)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "createUserJWT")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "createUserJWT")
 		return
 	}
 
-	err = h.SessionRepo.Create(tokenString, user.ID)
+	err = h.SessionRepo.Create(r.Context(), tokenString, user.ID)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "SessionRepo.Create")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "SessionRepo.Create")
 		return
 	}
 
@@ -63,26 +123,28 @@ func (h *UserHandler) Signup(w http.ResponseWriter, r *http.Request) {
 		"token": tokenString,
 	})
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "UserHandler.Signup")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.Signup")
 		return
 	}
 
 	_, err = w.Write(response)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "UserHandler.Signup")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.Signup")
 		return
 	}
 }
 
-func createUserJWT(user *models.User) (string, error) {
-	tokenKey := []byte(os.Getenv("TOKEN_KEY"))
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user": map[string]string{
-			"username": user.Login,
-			"id":       strconv.Itoa(user.ID),
+func createUserJWT(tokenKey []byte, user *models.User) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &middleware.UserClaims{
+		User: middleware.TokenUser{
+			Username: user.Login,
+			ID:       strconv.Itoa(user.ID),
+		},
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.AddDate(0, 0, 4).Unix(),
 		},
-		"iat": time.Now().Unix(),
-		"exp": time.Now().AddDate(0, 0, 4).Unix(),
 	})
 	tokenString, err := token.SignedString(tokenKey)
 
@@ -92,52 +154,94 @@ func createUserJWT(user *models.User) (string, error) {
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "UserHandler.Login")
+		return
+	}
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "UserHandler.Login")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.Login")
 		return
 	}
 
 	authForm := &AuthForm{}
-	err = json.Unmarshal(body, authForm)
+	err = tools.UnmarshalStrict(body, authForm)
 	if err != nil {
-		tools.JSONError(w, http.StatusBadRequest, "cant unpack payload", "UserHandler.Login")
+		tools.JSONError(w, r, http.StatusBadRequest, "cant unpack payload", "UserHandler.Login")
 		return
 	}
 
-	user, err := h.UserRepo.GetUserFromRepo(authForm.Login, authForm.Password)
-	if err != nil {
-		tools.JSONError(w, http.StatusBadRequest, err.Error(), "UserRepo.GetUserFromRepo")
+	ipKey, userKey := throttleIPKey(r), throttleUserKey(authForm.Login)
+	if !checkThrottle(w, r, h.LoginThrottler, "UserHandler.Login", ipKey, userKey) {
 		return
 	}
 
-	tokenString, err := createUserJWT(user)
+	user, err := h.UserRepo.GetUserFromRepo(r.Context(), authForm.Login, authForm.Password)
+	if err == models.ErrWrongCredentials || err == models.ErrNoUser {
+		metrics.FailedLoginsTotal.Inc()
+		wait, hitErr := hitThrottle(h.LoginThrottler, ipKey, userKey)
+		if hitErr != nil {
+			tools.JSONError(w, r, http.StatusInternalServerError, hitErr.Error(), "Throttler.Hit")
+			return
+		}
+
+		if wait > 0 {
+			setRetryAfter(w, wait)
+			tools.JSONError(w, r, http.StatusTooManyRequests, models.ErrTooManyAttempts.Error(), "UserHandler.Login")
+			return
+		}
+
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "UserRepo.GetUserFromRepo")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "UserRepo.GetUserFromRepo")
+		return
+	}
+
+	// Счетчик аккаунта сбрасывается только после полного входа: пароль без второго фактора не в счет.
+	if user.TOTPEnabled {
+		h.requestSecondFactor(w, r, user)
+		return
+	}
+
+	// Счетчик по IP не сбрасываем, иначе вход в свой аккаунт обнулял бы подбор паролей к чужим.
+	resetThrottle(r.Context(), h.LoginThrottler, userKey)
+	metrics.LoginsTotal.Inc()
+
+	h.writeSessionToken(w, r, user, "UserHandler.Login")
+}
+
+// writeSessionToken отдает токен текущей сессии пользователя, создавая ее при необходимости.
+func (h *UserHandler) writeSessionToken(w http.ResponseWriter, r *http.Request, user *models.User, method string) {
+	tokenString, err := createUserJWT(h.TokenKey, user)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "createUserJWT")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "createUserJWT")
 		return
 	}
 
-	_, err = h.SessionRepo.Check(user.ID)
+	session, err := h.SessionRepo.Check(r.Context(), user.ID)
 	if err == models.ErrNoSession {
-		if createSessionErr := h.SessionRepo.Create(tokenString, user.ID); createSessionErr != nil {
-			tools.JSONError(w, http.StatusInternalServerError, err.Error(), "SessionRepo.Create")
+		if createSessionErr := h.SessionRepo.Create(r.Context(), tokenString, user.ID); createSessionErr != nil {
+			tools.JSONError(w, r, http.StatusInternalServerError, createSessionErr.Error(), "SessionRepo.Create")
 			return
 		}
 	} else if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "SessionRepo.Check")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "SessionRepo.Check")
 		return
+	} else {
+		tokenString = session.JWT
 	}
 
 	response, err := json.Marshal(map[string]interface{}{
 		"token": tokenString,
 	})
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "UserHandler.Login")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), method)
 		return
 	}
 
 	_, err = w.Write(response)
 	if err != nil {
-		tools.JSONError(w, http.StatusInternalServerError, err.Error(), "UserHandler.Login")
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), method)
 		return
 	}
 }
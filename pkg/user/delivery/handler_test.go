@@ -2,14 +2,23 @@ package delivery
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"redditclone/pkg/middleware"
 	"redditclone/pkg/models"
+	sessionMemory "redditclone/pkg/session/repository/memory"
 	sessionMock "redditclone/pkg/session/repository/mock_repository"
+	throttle "redditclone/pkg/throttle/repository"
+	throttleMemory "redditclone/pkg/throttle/repository/memory"
+	throttleMock "redditclone/pkg/throttle/repository/mock_repository"
+	"redditclone/pkg/user/policy"
+	userMemory "redditclone/pkg/user/repository/memory"
 	userMock "redditclone/pkg/user/repository/mock_repository"
 	"redditclone/tools"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,12 +46,20 @@ func TestUserHandlerSignup(t *testing.T) {
 
 	mockUserRepo := userMock.NewMockUserRepo(ctrl)
 	mockSessionRepo := sessionMock.NewMockSessionManager(ctrl)
+	mockThrottler := throttleMock.NewMockThrottler(ctrl)
 
 	userHandler := &UserHandler{
-		UserRepo:    mockUserRepo,
-		SessionRepo: mockSessionRepo,
+		UserRepo:          mockUserRepo,
+		SessionRepo:       mockSessionRepo,
+		LoginThrottler:    mockThrottler,
+		SignupThrottler:   mockThrottler,
+		CredentialsPolicy: policy.NewPolicy(),
 	}
 
+	mockThrottler.EXPECT().Check(gomock.Any()).Return(time.Duration(0), nil).AnyTimes()
+	mockThrottler.EXPECT().Hit(gomock.Any()).Return(time.Duration(0), nil).AnyTimes()
+	mockThrottler.EXPECT().Reset(gomock.Any()).Return(nil).AnyTimes()
+
 	tools.Init()
 
 	var authForm = &AuthForm{
@@ -57,8 +74,8 @@ func TestUserHandlerSignup(t *testing.T) {
 	}
 
 	t.Run("correct signup", func(t *testing.T) {
-		mockUserRepo.EXPECT().CreateUser(authForm.Login, authForm.Password).Return(user, nil)
-		mockSessionRepo.EXPECT().Create(gomock.Any(), user.ID).Return(nil)
+		mockUserRepo.EXPECT().CreateUser(gomock.Any(), authForm.Login, authForm.Password, "").Return(user, nil)
+		mockSessionRepo.EXPECT().Create(gomock.Any(), gomock.Any(), user.ID).Return(nil)
 
 		reqBody, err := json.Marshal(authForm)
 		if err != nil {
@@ -95,8 +112,8 @@ func TestUserHandlerSignup(t *testing.T) {
 	})
 
 	t.Run("error writing response", func(t *testing.T) {
-		mockUserRepo.EXPECT().CreateUser(authForm.Login, authForm.Password).Return(user, nil)
-		mockSessionRepo.EXPECT().Create(gomock.Any(), user.ID).Return(nil)
+		mockUserRepo.EXPECT().CreateUser(gomock.Any(), authForm.Login, authForm.Password, "").Return(user, nil)
+		mockSessionRepo.EXPECT().Create(gomock.Any(), gomock.Any(), user.ID).Return(nil)
 
 		reqBody, err := json.Marshal(authForm)
 		if err != nil {
@@ -122,8 +139,39 @@ func TestUserHandlerSignup(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 	})
 
+	t.Run("unknown field or trailing data", func(t *testing.T) {
+		for _, badReqBody := range []string{
+			`{"username":"alex12345","password":"love12345","admin":true}`,
+			`{"username":"alex12345","password":"love12345"} {"username":"other"}`,
+		} {
+			req := httptest.NewRequest("POST", "/api/register", strings.NewReader(badReqBody))
+			w := httptest.NewRecorder()
+
+			userHandler.Signup(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code, badReqBody)
+		}
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"username":"`+strings.Repeat("a", 64)+`"}`))
+		w := httptest.NewRecorder()
+
+		middleware.LimitBody(32, http.HandlerFunc(userHandler.Signup)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+		req = httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"username":"`+strings.Repeat("a", 64)+`"}`))
+		req.ContentLength = -1
+		w = httptest.NewRecorder()
+
+		middleware.LimitBody(32, http.HandlerFunc(userHandler.Signup)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
 	t.Run("UserRepo.CreateUser error", func(t *testing.T) {
-		mockUserRepo.EXPECT().CreateUser(authForm.Login, authForm.Password).Return(nil, errors.New("mock error"))
+		mockUserRepo.EXPECT().CreateUser(gomock.Any(), authForm.Login, authForm.Password, "").Return(nil, errors.New("mock error"))
 
 		reqBody, err := json.Marshal(authForm)
 		if err != nil {
@@ -147,9 +195,101 @@ func TestUserHandlerSignup(t *testing.T) {
 		assert.Contains(t, response["error"], errorWrapper)
 	})
 
+	t.Run("invalid form", func(t *testing.T) {
+		reqBody, _ := json.Marshal(&AuthForm{
+			Login:    "admin",
+			Password: "short",
+		})
+		req := httptest.NewRequest("POST", "/api/register", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		userHandler.Signup(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		var response struct {
+			Errors []*tools.FieldError `json:"errors"`
+		}
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, 2, len(response.Errors))
+		assert.Equal(t, "username", response.Errors[0].Param)
+		assert.Equal(t, "admin", response.Errors[0].Value)
+		assert.Equal(t, models.ErrLoginReserved.Error(), response.Errors[0].Msg)
+		assert.Equal(t, "password", response.Errors[1].Param)
+		assert.Nil(t, response.Errors[1].Value)
+	})
+
+	t.Run("ErrAlreadyCreated", func(t *testing.T) {
+		mockUserRepo.EXPECT().CreateUser(gomock.Any(), authForm.Login, authForm.Password, "").Return(nil, models.ErrAlreadyCreated)
+
+		reqBody, _ := json.Marshal(authForm)
+		req := httptest.NewRequest("POST", "/api/register", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		userHandler.Signup(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		var response struct {
+			Errors []*tools.FieldError `json:"errors"`
+		}
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, "username", response.Errors[0].Param)
+		assert.Equal(t, models.ErrLoginTaken.Error(), response.Errors[0].Msg)
+	})
+
+	t.Run("ErrEmailTaken", func(t *testing.T) {
+		form := &AuthForm{Login: authForm.Login, Password: authForm.Password, Email: "alex@example.com"}
+		mockUserRepo.EXPECT().CreateUser(gomock.Any(), form.Login, form.Password, form.Email).Return(nil, models.ErrEmailTaken)
+
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("POST", "/api/register", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		userHandler.Signup(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		var response struct {
+			Errors []*tools.FieldError `json:"errors"`
+		}
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, "email", response.Errors[0].Param)
+		assert.Equal(t, models.ErrEmailTaken.Error(), response.Errors[0].Msg)
+	})
+
+	t.Run("ErrBadEmail", func(t *testing.T) {
+		reqBody, _ := json.Marshal(&AuthForm{
+			Login:    authForm.Login,
+			Password: authForm.Password,
+			Email:    "not an email",
+		})
+		req := httptest.NewRequest("POST", "/api/register", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		userHandler.Signup(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
 	t.Run("SessionRepo.Create error", func(t *testing.T) {
-		mockUserRepo.EXPECT().CreateUser(authForm.Login, authForm.Password).Return(user, nil)
-		mockSessionRepo.EXPECT().Create(gomock.Any(), user.ID).Return(errors.New("mock error"))
+		mockUserRepo.EXPECT().CreateUser(gomock.Any(), authForm.Login, authForm.Password, "").Return(user, nil)
+		mockSessionRepo.EXPECT().Create(gomock.Any(), gomock.Any(), user.ID).Return(errors.New("mock error"))
 
 		reqBody, _ := json.Marshal(authForm)
 		req := httptest.NewRequest("POST", "/api/register", bytes.NewReader(reqBody))
@@ -170,12 +310,20 @@ func TestUserHandlerLogin(t *testing.T) {
 
 	mockUserRepo := userMock.NewMockUserRepo(ctrl)
 	mockSessionRepo := sessionMock.NewMockSessionManager(ctrl)
+	mockThrottler := throttleMock.NewMockThrottler(ctrl)
 
 	userHandler := &UserHandler{
-		UserRepo:    mockUserRepo,
-		SessionRepo: mockSessionRepo,
+		UserRepo:          mockUserRepo,
+		SessionRepo:       mockSessionRepo,
+		LoginThrottler:    mockThrottler,
+		SignupThrottler:   mockThrottler,
+		CredentialsPolicy: policy.NewPolicy(),
 	}
 
+	mockThrottler.EXPECT().Check(gomock.Any()).Return(time.Duration(0), nil).AnyTimes()
+	mockThrottler.EXPECT().Hit(gomock.Any()).Return(time.Duration(0), nil).AnyTimes()
+	mockThrottler.EXPECT().Reset(gomock.Any()).Return(nil).AnyTimes()
+
 	tools.Init()
 
 	var authForm = &AuthForm{
@@ -197,8 +345,8 @@ func TestUserHandlerLogin(t *testing.T) {
 	}
 
 	t.Run("correct login", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserFromRepo(authForm.Login, authForm.Password).Return(user, nil)
-		mockSessionRepo.EXPECT().Check(user.ID).Return(session, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(user, nil)
+		mockSessionRepo.EXPECT().Check(gomock.Any(), user.ID).Return(session, nil)
 
 		reqBody, err := json.Marshal(authForm)
 		if err != nil {
@@ -219,7 +367,7 @@ func TestUserHandlerLogin(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
-		assert.NotNil(t, response["token"])
+		assert.Equal(t, session.JWT, response["token"])
 	})
 
 	t.Run("error reading body", func(t *testing.T) {
@@ -235,8 +383,8 @@ func TestUserHandlerLogin(t *testing.T) {
 	})
 
 	t.Run("error writing response", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserFromRepo(authForm.Login, authForm.Password).Return(user, nil)
-		mockSessionRepo.EXPECT().Check(user.ID).Return(session, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(user, nil)
+		mockSessionRepo.EXPECT().Check(gomock.Any(), user.ID).Return(session, nil)
 
 		reqBody, err := json.Marshal(authForm)
 		if err != nil {
@@ -263,7 +411,7 @@ func TestUserHandlerLogin(t *testing.T) {
 	})
 
 	t.Run("UserRepo.GetUserFromRepo error", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserFromRepo(authForm.Login, authForm.Password).Return(nil, errors.New("mock error"))
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(nil, errors.New("mock error"))
 
 		reqBody, err := json.Marshal(authForm)
 		if err != nil {
@@ -282,8 +430,8 @@ func TestUserHandlerLogin(t *testing.T) {
 	})
 
 	t.Run("SessionRepo.Check unexpected error", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserFromRepo(authForm.Login, authForm.Password).Return(user, nil)
-		mockSessionRepo.EXPECT().Check(user.ID).Return(nil, errors.New("mock error"))
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(user, nil)
+		mockSessionRepo.EXPECT().Check(gomock.Any(), user.ID).Return(nil, errors.New("mock error"))
 
 		reqBody, err := json.Marshal(authForm)
 		if err != nil {
@@ -302,9 +450,9 @@ func TestUserHandlerLogin(t *testing.T) {
 	})
 
 	t.Run("SessionRepo.Check ErrNoSession - creating user session", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserFromRepo(authForm.Login, authForm.Password).Return(user, nil)
-		mockSessionRepo.EXPECT().Check(user.ID).Return(nil, models.ErrNoSession)
-		mockSessionRepo.EXPECT().Create(gomock.Any(), user.ID).Return(nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(user, nil)
+		mockSessionRepo.EXPECT().Check(gomock.Any(), user.ID).Return(nil, models.ErrNoSession)
+		mockSessionRepo.EXPECT().Create(gomock.Any(), gomock.Any(), user.ID).Return(nil)
 
 		reqBody, err := json.Marshal(authForm)
 		if err != nil {
@@ -328,9 +476,9 @@ func TestUserHandlerLogin(t *testing.T) {
 	})
 
 	t.Run("SessionRepo.Check ErrNoSession -> SessionRepo.Create error", func(t *testing.T) {
-		mockUserRepo.EXPECT().GetUserFromRepo(authForm.Login, authForm.Password).Return(user, nil)
-		mockSessionRepo.EXPECT().Check(user.ID).Return(nil, models.ErrNoSession)
-		mockSessionRepo.EXPECT().Create(gomock.Any(), user.ID).Return(errors.New("mock error"))
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(user, nil)
+		mockSessionRepo.EXPECT().Check(gomock.Any(), user.ID).Return(nil, models.ErrNoSession)
+		mockSessionRepo.EXPECT().Create(gomock.Any(), gomock.Any(), user.ID).Return(errors.New("mock error"))
 
 		reqBody, err := json.Marshal(authForm)
 		if err != nil {
@@ -348,3 +496,57 @@ func TestUserHandlerLogin(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 	})
 }
+
+// Сценарий целиком на in-memory хранилищах: проверяется поведение, а не порядок вызовов моков.
+func TestUserHandlerSignupLoginInMemory(t *testing.T) {
+	tools.Init()
+
+	sessionRepo := sessionMemory.NewSessionMemoryManager()
+	throttlePolicy := throttle.Policy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+	userHandler := &UserHandler{
+		UserRepo:          userMemory.NewUserMemoryRepository(),
+		SessionRepo:       sessionRepo,
+		LoginThrottler:    throttleMemory.NewThrottleMemoryManager(throttlePolicy),
+		SignupThrottler:   throttleMemory.NewThrottleMemoryManager(throttle.Policy{FreeAttempts: 10, Window: time.Hour}),
+		CredentialsPolicy: policy.NewPolicy(),
+		TokenKey:          []byte("test key"),
+	}
+
+	send := func(handler http.HandlerFunc, form *AuthForm) *http.Response {
+		reqBody, _ := json.Marshal(form)
+		req := httptest.NewRequest("POST", "/api/login", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w.Result()
+	}
+
+	form := &AuthForm{Login: "alex12345", Password: "correct horse"}
+
+	resp := send(userHandler.Signup, form)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = send(userHandler.Signup, &AuthForm{Login: "ALEX12345", Password: form.Password})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	resp = send(userHandler.Login, form)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var token struct {
+		Token string `json:"token"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+	session, err := sessionRepo.Check(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, token.Token, session.JWT)
+
+	for i := 0; i < 2; i++ {
+		resp = send(userHandler.Login, &AuthForm{Login: form.Login, Password: "wrong password"})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+
+	resp = send(userHandler.Login, &AuthForm{Login: form.Login, Password: "wrong password"})
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	resp = send(userHandler.Login, form)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
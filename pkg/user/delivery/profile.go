@@ -0,0 +1,264 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	commentRepository "redditclone/pkg/comment/repository"
+	"redditclone/pkg/models"
+	postRepository "redditclone/pkg/post/repository"
+	userRepository "redditclone/pkg/user/repository"
+	"redditclone/tools"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultListLimit = 25
+	maxListLimit     = 100
+	// maxListOffset ограничивает глубину листания: обзор читает Offset+Limit записей
+	// из каждого хранилища, а большое смещение еще и переполнило бы int.
+	maxListOffset = 5000
+)
+
+type ProfileHandler struct {
+	UserRepo    userRepository.UserRepo
+	PostRepo    postRepository.PostRepo
+	CommentRepo commentRepository.CommentRepo
+}
+
+type OverviewItem struct {
+	Kind    string          `json:"kind"`
+	Post    *models.Post    `json:"post,omitempty"`
+	Comment *models.Comment `json:"comment,omitempty"`
+}
+
+func (item *OverviewItem) created() time.Time {
+	if item.Post != nil {
+		return item.Post.Created
+	}
+
+	return item.Comment.Created
+}
+
+func (item *OverviewItem) score() int {
+	if item.Post != nil {
+		return item.Post.Score
+	}
+
+	return item.Comment.Score
+}
+
+func parseListOptions(r *http.Request) (*models.ListOptions, error) {
+	query := r.URL.Query()
+	opts := &models.ListOptions{
+		Sort:  models.SortNew,
+		Limit: defaultListLimit,
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if sort != models.SortNew && sort != models.SortTop {
+			return nil, models.ErrUnrecognizedSort
+		}
+		opts.Sort = sort
+	}
+
+	if limitParam := query.Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return nil, models.ErrBadPagination
+		}
+		opts.Limit = limit
+	}
+
+	if pageParam := query.Get("page"); pageParam != "" {
+		page, err := strconv.Atoi(pageParam)
+		if err != nil || page < 1 || page-1 > maxListOffset/opts.Limit {
+			return nil, models.ErrBadPagination
+		}
+		opts.Offset = (page - 1) * opts.Limit
+	}
+
+	return opts, nil
+}
+
+func (h *ProfileHandler) About(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	profile, err := h.UserRepo.GetProfile(r.Context(), username)
+	if err == models.ErrNoUser {
+		tools.JSONError(w, r, http.StatusNotFound, err.Error(), "UserRepo.GetProfile")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetProfile")
+		return
+	}
+
+	postCount, err := h.PostRepo.CountPostsByAuthor(r.Context(), username)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.CountPostsByAuthor")
+		return
+	}
+
+	commentCount, err := h.CommentRepo.CountCommentsByAuthor(r.Context(), username)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentRepo.CountCommentsByAuthor")
+		return
+	}
+
+	profile.PostCount = postCount
+	profile.CommentCount = commentCount
+
+	jsonProfile, err := json.Marshal(profile)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "ProfileHandler.About")
+		return
+	}
+
+	_, err = w.Write(jsonProfile)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "ProfileHandler.About")
+		return
+	}
+}
+
+func (h *ProfileHandler) Posts(w http.ResponseWriter, r *http.Request) {
+	listOpts, err := parseListOptions(r)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "ProfileHandler.Posts")
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	_, err = h.UserRepo.GetProfile(r.Context(), username)
+	if err == models.ErrNoUser {
+		tools.JSONError(w, r, http.StatusNotFound, err.Error(), "UserRepo.GetProfile")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetProfile")
+		return
+	}
+
+	posts, err := h.PostRepo.GetPostsByAuthor(r.Context(), username, listOpts)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostsByAuthor")
+		return
+	}
+
+	jsonPosts, err := json.Marshal(posts)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "ProfileHandler.Posts")
+		return
+	}
+
+	_, err = w.Write(jsonPosts)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "ProfileHandler.Posts")
+		return
+	}
+}
+
+func (h *ProfileHandler) Comments(w http.ResponseWriter, r *http.Request) {
+	listOpts, err := parseListOptions(r)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "ProfileHandler.Comments")
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	_, err = h.UserRepo.GetProfile(r.Context(), username)
+	if err == models.ErrNoUser {
+		tools.JSONError(w, r, http.StatusNotFound, err.Error(), "UserRepo.GetProfile")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetProfile")
+		return
+	}
+
+	comments, err := h.CommentRepo.GetCommentsByAuthor(r.Context(), username, listOpts)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentRepo.GetCommentsByAuthor")
+		return
+	}
+
+	jsonComments, err := json.Marshal(comments)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "ProfileHandler.Comments")
+		return
+	}
+
+	_, err = w.Write(jsonComments)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "ProfileHandler.Comments")
+		return
+	}
+}
+
+func (h *ProfileHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	listOpts, err := parseListOptions(r)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "ProfileHandler.Overview")
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	_, err = h.UserRepo.GetProfile(r.Context(), username)
+	if err == models.ErrNoUser {
+		tools.JSONError(w, r, http.StatusNotFound, err.Error(), "UserRepo.GetProfile")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetProfile")
+		return
+	}
+
+	// Посты и комментарии лежат в разных хранилищах, поэтому из каждого берутся первые
+	// Offset+Limit записей, а страница вырезается уже из слитой ленты.
+	headOpts := &models.ListOptions{
+		Sort:  listOpts.Sort,
+		Limit: listOpts.Offset + listOpts.Limit,
+	}
+
+	posts, err := h.PostRepo.GetPostsByAuthor(r.Context(), username, headOpts)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "PostRepo.GetPostsByAuthor")
+		return
+	}
+
+	comments, err := h.CommentRepo.GetCommentsByAuthor(r.Context(), username, headOpts)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "CommentRepo.GetCommentsByAuthor")
+		return
+	}
+
+	overview := make([]*OverviewItem, 0, len(posts)+len(comments))
+	for _, post := range posts {
+		overview = append(overview, &OverviewItem{Kind: "post", Post: post})
+	}
+	for _, comment := range comments {
+		overview = append(overview, &OverviewItem{Kind: "comment", Comment: comment})
+	}
+
+	byTop := listOpts.Sort == models.SortTop
+	slices.SortStableFunc(overview, func(a, b *OverviewItem) int {
+		if byTop && a.score() != b.score() {
+			return b.score() - a.score()
+		}
+		return b.created().Compare(a.created())
+	})
+
+	overview = overview[min(listOpts.Offset, len(overview)):]
+	overview = overview[:min(listOpts.Limit, len(overview))]
+
+	jsonOverview, err := json.Marshal(overview)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "ProfileHandler.Overview")
+		return
+	}
+
+	_, err = w.Write(jsonOverview)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "ProfileHandler.Overview")
+		return
+	}
+}
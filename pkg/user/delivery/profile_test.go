@@ -0,0 +1,426 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	commentMock "redditclone/pkg/comment/repository/mock_repository"
+	"redditclone/pkg/models"
+	postMock "redditclone/pkg/post/repository/mock_repository"
+	userMock "redditclone/pkg/user/repository/mock_repository"
+	"redditclone/tools"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestProfileHandlerAbout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockPostRepo := postMock.NewMockPostRepo(ctrl)
+	mockCommentRepo := commentMock.NewMockCommentRepo(ctrl)
+
+	profileHandler := &ProfileHandler{
+		UserRepo:    mockUserRepo,
+		PostRepo:    mockPostRepo,
+		CommentRepo: mockCommentRepo,
+	}
+
+	tools.Init()
+
+	var user = models.User{
+		ID:    1,
+		Login: "alex12345",
+	}
+
+	var createdTime = time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var profile = models.Profile{
+		ID:           user.ID,
+		Login:        user.Login,
+		Created:      createdTime,
+		PostKarma:    3,
+		CommentKarma: 2,
+		Karma:        5,
+	}
+
+	t.Run("correct About", func(t *testing.T) {
+		userProfile := profile
+
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(&userProfile, nil)
+		mockPostRepo.EXPECT().CountPostsByAuthor(gomock.Any(), user.Login).Return(1, nil)
+		mockCommentRepo.EXPECT().CountCommentsByAuthor(gomock.Any(), user.Login).Return(2, nil)
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/about", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.About(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		actualProfile := models.Profile{}
+		err := json.NewDecoder(resp.Body).Decode(&actualProfile)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, profile.Karma, actualProfile.Karma)
+		assert.Equal(t, 1, actualProfile.PostCount)
+		assert.Equal(t, 2, actualProfile.CommentCount)
+		assert.True(t, createdTime.Equal(actualProfile.Created))
+	})
+
+	t.Run("ErrNoUser", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), "unknown").Return(nil, models.ErrNoUser)
+
+		req := httptest.NewRequest("GET", "/api/user/unknown/about", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": "unknown"})
+		w := httptest.NewRecorder()
+
+		profileHandler.About(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("CommentRepo.CountCommentsByAuthor error", func(t *testing.T) {
+		userProfile := profile
+
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(&userProfile, nil)
+		mockPostRepo.EXPECT().CountPostsByAuthor(gomock.Any(), user.Login).Return(0, nil)
+		mockCommentRepo.EXPECT().CountCommentsByAuthor(gomock.Any(), user.Login).Return(0, errors.New("mock error"))
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/about", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.About(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestProfileHandlerPosts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockPostRepo := postMock.NewMockPostRepo(ctrl)
+	mockCommentRepo := commentMock.NewMockCommentRepo(ctrl)
+
+	profileHandler := &ProfileHandler{
+		UserRepo:    mockUserRepo,
+		PostRepo:    mockPostRepo,
+		CommentRepo: mockCommentRepo,
+	}
+
+	tools.Init()
+
+	var user = models.User{
+		ID:    1,
+		Login: "alex12345",
+	}
+
+	var post = &models.Post{
+		ID:      primitive.NewObjectID(),
+		Author:  user,
+		Created: time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("sorting and pagination", func(t *testing.T) {
+		topOpts := &models.ListOptions{Sort: models.SortTop, Limit: 10, Offset: 20}
+
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(&models.Profile{ID: user.ID, Login: user.Login}, nil)
+		mockPostRepo.EXPECT().GetPostsByAuthor(gomock.Any(), user.Login, topOpts).Return([]*models.Post{post}, nil)
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/posts?sort=top&limit=10&page=3", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.Posts(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		posts := []*models.Post{}
+		err := json.NewDecoder(resp.Body).Decode(&posts)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, post.ID, posts[0].ID)
+	})
+
+	t.Run("bad list options", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/posts?limit=1000", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.Posts(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestProfileHandlerComments(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockPostRepo := postMock.NewMockPostRepo(ctrl)
+	mockCommentRepo := commentMock.NewMockCommentRepo(ctrl)
+
+	profileHandler := &ProfileHandler{
+		UserRepo:    mockUserRepo,
+		PostRepo:    mockPostRepo,
+		CommentRepo: mockCommentRepo,
+	}
+
+	tools.Init()
+
+	var user = models.User{
+		ID:    1,
+		Login: "alex12345",
+	}
+
+	var comment = &models.Comment{
+		ID:      primitive.NewObjectID(),
+		Author:  &user,
+		Text:    "comment body",
+		Created: time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC),
+		Post: &models.PostRef{
+			ID:    primitive.NewObjectID(),
+			Title: "some title",
+		},
+	}
+
+	t.Run("correct Comments", func(t *testing.T) {
+		defaultOpts := &models.ListOptions{Sort: models.SortNew, Limit: defaultListLimit}
+
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(&models.Profile{ID: user.ID, Login: user.Login}, nil)
+		mockCommentRepo.EXPECT().GetCommentsByAuthor(gomock.Any(), user.Login, defaultOpts).Return([]*models.Comment{comment}, nil)
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/comments", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.Comments(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		comments := []*models.Comment{}
+		err := json.NewDecoder(resp.Body).Decode(&comments)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, comment.ID, comments[0].ID)
+		assert.Equal(t, comment.Post.Title, comments[0].Post.Title)
+	})
+
+	t.Run("sorting and pagination", func(t *testing.T) {
+		topOpts := &models.ListOptions{Sort: models.SortTop, Limit: 10, Offset: 20}
+
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(&models.Profile{ID: user.ID, Login: user.Login}, nil)
+		mockCommentRepo.EXPECT().GetCommentsByAuthor(gomock.Any(), user.Login, topOpts).Return([]*models.Comment{}, nil)
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/comments?sort=top&limit=10&page=3", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.Comments(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("bad list options", func(t *testing.T) {
+		for _, query := range []string{"sort=hot", "limit=0", "limit=1000", "page=0", "page=abc", "limit=100&page=52", "page=92233720368547758&limit=100"} {
+			req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/comments?"+query, nil)
+			req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+			w := httptest.NewRecorder()
+
+			profileHandler.Comments(w, req)
+
+			resp := w.Result()
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+
+	t.Run("UserRepo.GetProfile error", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(nil, errors.New("mock error"))
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/comments", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.Comments(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestProfileHandlerOverview(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockPostRepo := postMock.NewMockPostRepo(ctrl)
+	mockCommentRepo := commentMock.NewMockCommentRepo(ctrl)
+
+	profileHandler := &ProfileHandler{
+		UserRepo:    mockUserRepo,
+		PostRepo:    mockPostRepo,
+		CommentRepo: mockCommentRepo,
+	}
+
+	tools.Init()
+
+	var user = models.User{
+		ID:    1,
+		Login: "alex12345",
+	}
+
+	var olderPost = &models.Post{
+		ID:      primitive.NewObjectID(),
+		Author:  user,
+		Created: time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	var newerPost = &models.Post{
+		ID:      primitive.NewObjectID(),
+		Author:  user,
+		Created: time.Date(2022, 1, 3, 12, 0, 0, 0, time.UTC),
+	}
+
+	var comment = &models.Comment{
+		ID:      primitive.NewObjectID(),
+		Author:  &user,
+		Created: time.Date(2022, 1, 2, 12, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("correct Overview", func(t *testing.T) {
+		defaultOpts := &models.ListOptions{Sort: models.SortNew, Limit: defaultListLimit}
+
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(&models.Profile{ID: user.ID, Login: user.Login}, nil)
+		mockPostRepo.EXPECT().GetPostsByAuthor(gomock.Any(), user.Login, defaultOpts).Return([]*models.Post{newerPost, olderPost}, nil)
+		mockCommentRepo.EXPECT().GetCommentsByAuthor(gomock.Any(), user.Login, defaultOpts).Return([]*models.Comment{comment}, nil)
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/overview", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.Overview(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		overview := []*OverviewItem{}
+		err := json.NewDecoder(resp.Body).Decode(&overview)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 3, len(overview))
+		assert.Equal(t, newerPost.ID, overview[0].Post.ID)
+		assert.Equal(t, comment.ID, overview[1].Comment.ID)
+		assert.Equal(t, olderPost.ID, overview[2].Post.ID)
+	})
+
+	t.Run("pagination over merged feed", func(t *testing.T) {
+		// Вторая страница по одному элементу: из каждого хранилища нужны первые два.
+		headOpts := &models.ListOptions{Sort: models.SortNew, Limit: 2}
+
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(&models.Profile{ID: user.ID, Login: user.Login}, nil)
+		mockPostRepo.EXPECT().GetPostsByAuthor(gomock.Any(), user.Login, headOpts).Return([]*models.Post{newerPost, olderPost}, nil)
+		mockCommentRepo.EXPECT().GetCommentsByAuthor(gomock.Any(), user.Login, headOpts).Return([]*models.Comment{comment}, nil)
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/overview?limit=1&page=2", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.Overview(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		overview := []*OverviewItem{}
+		err := json.NewDecoder(resp.Body).Decode(&overview)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 1, len(overview))
+		assert.Equal(t, comment.ID, overview[0].Comment.ID)
+	})
+
+	t.Run("bad list options", func(t *testing.T) {
+		for _, query := range []string{"sort=hot", "page=92233720368547758&limit=100", "page=9223372036854775807"} {
+			req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/overview?"+query, nil)
+			req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+			w := httptest.NewRecorder()
+
+			profileHandler.Overview(w, req)
+
+			resp := w.Result()
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+
+	t.Run("deepest allowed page", func(t *testing.T) {
+		headOpts := &models.ListOptions{Sort: models.SortNew, Limit: maxListOffset + maxListLimit}
+
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(&models.Profile{ID: user.ID, Login: user.Login}, nil)
+		mockPostRepo.EXPECT().GetPostsByAuthor(gomock.Any(), user.Login, headOpts).Return([]*models.Post{}, nil)
+		mockCommentRepo.EXPECT().GetCommentsByAuthor(gomock.Any(), user.Login, headOpts).Return([]*models.Comment{}, nil)
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/overview?limit=100&page=51", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.Overview(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("PostRepo.GetPostsByAuthor error", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetProfile(gomock.Any(), user.Login).Return(&models.Profile{ID: user.ID, Login: user.Login}, nil)
+		mockPostRepo.EXPECT().GetPostsByAuthor(gomock.Any(), user.Login, gomock.Any()).Return(nil, errors.New("mock error"))
+
+		req := httptest.NewRequest("GET", "/api/user/"+user.Login+"/overview", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": user.Login})
+		w := httptest.NewRecorder()
+
+		profileHandler.Overview(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+}
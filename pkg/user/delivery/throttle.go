@@ -0,0 +1,71 @@
+package delivery
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"redditclone/pkg/models"
+	throttleRepository "redditclone/pkg/throttle/repository"
+	"redditclone/tools"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func throttleIPKey(r *http.Request) string {
+	return "ip:" + tools.ClientIP(r)
+}
+
+// Логины в MySQL сравниваются без учета регистра, поэтому и счетчик общий для всех вариантов написания.
+func throttleUserKey(login string) string {
+	return "user:" + strings.ToLower(login)
+}
+
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// checkThrottle пишет 429 с Retry-After и возвращает false, если хотя бы один из ключей заблокирован.
+func checkThrottle(w http.ResponseWriter, r *http.Request, throttler throttleRepository.Throttler, method string, keys ...string) bool {
+	for _, key := range keys {
+		wait, err := throttler.Check(key)
+		if err != nil {
+			tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "Throttler.Check")
+			return false
+		}
+
+		if wait > 0 {
+			setRetryAfter(w, wait)
+			tools.JSONError(w, r, http.StatusTooManyRequests, models.ErrTooManyAttempts.Error(), method)
+			return false
+		}
+	}
+
+	return true
+}
+
+// hitThrottle учитывает неудачную попытку и возвращает самую долгую из назначенных блокировок.
+func hitThrottle(throttler throttleRepository.Throttler, keys ...string) (time.Duration, error) {
+	var longestWait time.Duration
+	for _, key := range keys {
+		wait, err := throttler.Hit(key)
+		if err != nil {
+			return 0, err
+		}
+		longestWait = max(longestWait, wait)
+	}
+
+	return longestWait, nil
+}
+
+// Сбой при сбросе счетчика не должен мешать успешному входу, достаточно записать его в лог.
+func resetThrottle(ctx context.Context, throttler throttleRepository.Throttler, key string) {
+	if err := throttler.Reset(key); err != nil {
+		tools.LoggerFrom(ctx).WithFields(logrus.Fields{
+			"method": "Throttler.Reset",
+			"key":    key,
+		}).Error(err.Error())
+	}
+}
@@ -0,0 +1,218 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/models"
+	sessionMock "redditclone/pkg/session/repository/mock_repository"
+	throttleMock "redditclone/pkg/throttle/repository/mock_repository"
+	"redditclone/pkg/user/policy"
+	userMock "redditclone/pkg/user/repository/mock_repository"
+	"redditclone/tools"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserHandlerLoginThrottling(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockSessionRepo := sessionMock.NewMockSessionManager(ctrl)
+	mockThrottler := throttleMock.NewMockThrottler(ctrl)
+
+	userHandler := &UserHandler{
+		UserRepo:          mockUserRepo,
+		SessionRepo:       mockSessionRepo,
+		LoginThrottler:    mockThrottler,
+		CredentialsPolicy: policy.NewPolicy(),
+	}
+
+	tools.Init()
+
+	var authForm = &AuthForm{
+		Login:    "Alex12345",
+		Password: "wrong password",
+	}
+
+	const ipKey = "ip:192.0.2.1"
+	const userKey = "user:alex12345"
+
+	newRequest := func() *http.Request {
+		reqBody, _ := json.Marshal(authForm)
+		req := httptest.NewRequest("POST", "/api/login", bytes.NewReader(reqBody))
+		req.RemoteAddr = "192.0.2.1:54321"
+		return req
+	}
+
+	t.Run("locked account", func(t *testing.T) {
+		mockThrottler.EXPECT().Check(ipKey).Return(time.Duration(0), nil)
+		mockThrottler.EXPECT().Check(userKey).Return(90*time.Second+time.Millisecond, nil)
+
+		w := httptest.NewRecorder()
+		userHandler.Login(w, newRequest())
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "91", resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("wrong password is counted", func(t *testing.T) {
+		mockThrottler.EXPECT().Check(ipKey).Return(time.Duration(0), nil)
+		mockThrottler.EXPECT().Check(userKey).Return(time.Duration(0), nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(nil, models.ErrWrongCredentials)
+		mockThrottler.EXPECT().Hit(ipKey).Return(time.Duration(0), nil)
+		mockThrottler.EXPECT().Hit(userKey).Return(time.Duration(0), nil)
+
+		w := httptest.NewRecorder()
+		userHandler.Login(w, newRequest())
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("failure triggers lockout", func(t *testing.T) {
+		mockThrottler.EXPECT().Check(ipKey).Return(time.Duration(0), nil)
+		mockThrottler.EXPECT().Check(userKey).Return(time.Duration(0), nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(nil, models.ErrWrongCredentials)
+		mockThrottler.EXPECT().Hit(ipKey).Return(time.Duration(0), nil)
+		mockThrottler.EXPECT().Hit(userKey).Return(2*time.Second, nil)
+
+		w := httptest.NewRecorder()
+		userHandler.Login(w, newRequest())
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("successful login resets account counter", func(t *testing.T) {
+		user := &models.User{ID: 1, Login: authForm.Login}
+		session := &models.Session{ID: 1, JWT: "some jwt token", UserID: user.ID}
+
+		mockThrottler.EXPECT().Check(ipKey).Return(time.Duration(0), nil)
+		mockThrottler.EXPECT().Check(userKey).Return(time.Duration(0), nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(user, nil)
+		mockThrottler.EXPECT().Reset(userKey).Return(nil)
+		mockSessionRepo.EXPECT().Check(gomock.Any(), user.ID).Return(session, nil)
+
+		w := httptest.NewRecorder()
+		userHandler.Login(w, newRequest())
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestUserHandlerSignupThrottling(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockThrottler := throttleMock.NewMockThrottler(ctrl)
+
+	userHandler := &UserHandler{
+		UserRepo:          mockUserRepo,
+		SignupThrottler:   mockThrottler,
+		CredentialsPolicy: policy.NewPolicy(),
+	}
+
+	tools.Init()
+
+	t.Run("too many signups from one IP", func(t *testing.T) {
+		mockThrottler.EXPECT().Check("ip:192.0.2.1").Return(time.Hour, nil)
+
+		reqBody, _ := json.Marshal(&AuthForm{Login: "alex12345", Password: "alex12345"})
+		req := httptest.NewRequest("POST", "/api/register", bytes.NewReader(reqBody))
+		req.RemoteAddr = "192.0.2.1:54321"
+		w := httptest.NewRecorder()
+
+		userHandler.Signup(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "3600", resp.Header.Get("Retry-After"))
+	})
+}
+
+func TestAdminHandlerClearLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockThrottler := throttleMock.NewMockThrottler(ctrl)
+
+	adminHandler := &AdminHandler{
+		UserRepo:       mockUserRepo,
+		LoginThrottler: mockThrottler,
+	}
+
+	tools.Init()
+
+	var admin = &models.User{ID: 1, Login: "admin", Admin: true}
+	var user = &models.User{ID: 2, Login: "Alex12345"}
+
+	newRequest := func(userID int, username string) *http.Request {
+		req := httptest.NewRequest("DELETE", "/api/admin/lockouts/"+username, nil)
+		req = mux.SetURLVars(req, map[string]string{"username": username})
+		return req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, userID))
+	}
+
+	t.Run("correct ClearLockout", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), admin.ID).Return(admin, nil)
+		mockUserRepo.EXPECT().GetUserByLogin(gomock.Any(), user.Login).Return(user, nil)
+		mockThrottler.EXPECT().Reset("user:alex12345").Return(nil)
+
+		w := httptest.NewRecorder()
+		adminHandler.ClearLockout(w, newRequest(admin.ID, user.Login))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("not an admin", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+
+		w := httptest.NewRecorder()
+		adminHandler.ClearLockout(w, newRequest(user.ID, user.Login))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), admin.ID).Return(admin, nil)
+		mockUserRepo.EXPECT().GetUserByLogin(gomock.Any(), "unknown").Return(nil, models.ErrNoUser)
+
+		w := httptest.NewRecorder()
+		adminHandler.ClearLockout(w, newRequest(admin.ID, "unknown"))
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
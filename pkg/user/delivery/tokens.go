@@ -0,0 +1,36 @@
+package delivery
+
+import (
+	"redditclone/pkg/models"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func createPurposeToken(tokenKey []byte, purpose string, ttl time.Duration, claims jwt.MapClaims) (string, error) {
+	claims["purpose"] = purpose
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(ttl).Unix()
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(tokenKey)
+}
+
+func parsePurposeToken(tokenKey []byte, tokenString string, purpose string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		method, ok := token.Method.(*jwt.SigningMethodHMAC)
+		if !ok || method.Alg() != "HS256" {
+			return nil, models.ErrBadToken
+		}
+		return tokenKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, models.ErrBadToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return nil, models.ErrBadToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,398 @@
+package delivery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"io"
+	"net/http"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/models"
+	"redditclone/pkg/totp"
+	"redditclone/tools"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	totpIssuer = "redditclone"
+
+	twoFactorChallengePurpose = "2fa_challenge"
+	twoFactorChallengeTTL     = 5 * time.Minute
+
+	recoveryCodeCount = 10
+)
+
+type TwoFactorCodeForm struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorDisableForm struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type TwoFactorLoginForm struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+func createTwoFactorChallengeToken(tokenKey []byte, user *models.User) (string, error) {
+	return createPurposeToken(tokenKey, twoFactorChallengePurpose, twoFactorChallengeTTL, jwt.MapClaims{
+		"id": strconv.Itoa(user.ID),
+	})
+}
+
+func parseTwoFactorChallengeToken(tokenKey []byte, tokenString string) (int, error) {
+	claims, err := parsePurposeToken(tokenKey, tokenString, twoFactorChallengePurpose)
+	if err != nil {
+		return 0, err
+	}
+
+	userIDString, ok := claims["id"].(string)
+	if !ok {
+		return 0, models.ErrBadToken
+	}
+	userID, err := strconv.Atoi(userIDString)
+	if err != nil {
+		return 0, models.ErrBadToken
+	}
+
+	return userID, nil
+}
+
+// Коды вида "abcde-fghij": 50 бит энтропии достаточно для одноразового кода.
+func generateRecoveryCodes() ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(raw))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+
+	return codes, nil
+}
+
+// checkSecondFactor принимает либо текущий TOTP-код, либо неиспользованный код восстановления.
+// TOTP-код действует до трех шагов, поэтому его шаг запоминается и повторно не проходит.
+func (h *UserHandler) checkSecondFactor(ctx context.Context, user *models.User, code string) error {
+	secret, err := h.UserRepo.GetTOTPSecret(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if step, ok := totp.Validate(secret, strings.TrimSpace(code), time.Now()); ok {
+		return h.UserRepo.UseTOTPStep(ctx, user.ID, step)
+	}
+
+	return h.UserRepo.UseRecoveryCode(ctx, user.ID, code)
+}
+
+func (h *UserHandler) requestSecondFactor(w http.ResponseWriter, r *http.Request, user *models.User) {
+	challengeToken, err := createTwoFactorChallengeToken(h.TokenKey, user)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "createTwoFactorChallengeToken")
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"twoFactorRequired": true,
+		"challengeToken":    challengeToken,
+	})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.Login")
+		return
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.Login")
+		return
+	}
+}
+
+func (h *UserHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "UserHandler.LoginTwoFactor")
+		return
+	}
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.LoginTwoFactor")
+		return
+	}
+
+	form := &TwoFactorLoginForm{}
+	err = tools.UnmarshalStrict(body, form)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, "cant unpack payload", "UserHandler.LoginTwoFactor")
+		return
+	}
+
+	userID, err := parseTwoFactorChallengeToken(h.TokenKey, form.ChallengeToken)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusUnauthorized, err.Error(), "parseTwoFactorChallengeToken")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err == models.ErrNoUser {
+		tools.JSONError(w, r, http.StatusUnauthorized, models.ErrBadToken.Error(), "UserRepo.GetUserByID")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetUserByID")
+		return
+	}
+
+	if !user.TOTPEnabled {
+		tools.JSONError(w, r, http.StatusUnauthorized, models.ErrBadToken.Error(), "UserHandler.LoginTwoFactor")
+		return
+	}
+
+	userKey := throttleUserKey(user.Login)
+	if !checkThrottle(w, r, h.LoginThrottler, "UserHandler.LoginTwoFactor", userKey) {
+		return
+	}
+
+	err = h.checkSecondFactor(r.Context(), user, form.Code)
+	if err == models.ErrBadOTP {
+		metrics.FailedLoginsTotal.Inc()
+		wait, hitErr := h.LoginThrottler.Hit(userKey)
+		if hitErr != nil {
+			tools.JSONError(w, r, http.StatusInternalServerError, hitErr.Error(), "Throttler.Hit")
+			return
+		}
+
+		if wait > 0 {
+			setRetryAfter(w, wait)
+			tools.JSONError(w, r, http.StatusTooManyRequests, models.ErrTooManyAttempts.Error(), "UserHandler.LoginTwoFactor")
+			return
+		}
+
+		tools.JSONError(w, r, http.StatusUnauthorized, err.Error(), "UserHandler.checkSecondFactor")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.checkSecondFactor")
+		return
+	}
+
+	resetThrottle(r.Context(), h.LoginThrottler, userKey)
+	metrics.LoginsTotal.Inc()
+
+	h.writeSessionToken(w, r, user, "UserHandler.LoginTwoFactor")
+}
+
+func (h *UserHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
+	if !ok {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserHandler.EnrollTwoFactor")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	if user.TOTPEnabled {
+		tools.JSONError(w, r, http.StatusConflict, models.ErrTOTPEnabled.Error(), "UserHandler.EnrollTwoFactor")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "totp.GenerateSecret")
+		return
+	}
+
+	err = h.UserRepo.SetTOTPSecret(r.Context(), user.ID, secret)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.SetTOTPSecret")
+		return
+	}
+
+	response, err := json.Marshal(map[string]string{
+		"secret":          secret,
+		"provisioningUri": totp.ProvisioningURI(totpIssuer, user.Login, secret),
+	})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.EnrollTwoFactor")
+		return
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.EnrollTwoFactor")
+		return
+	}
+}
+
+func (h *UserHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
+	if !ok {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserHandler.VerifyTwoFactor")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "UserHandler.VerifyTwoFactor")
+		return
+	}
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.VerifyTwoFactor")
+		return
+	}
+
+	form := &TwoFactorCodeForm{}
+	err = tools.UnmarshalStrict(body, form)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, "cant unpack payload", "UserHandler.VerifyTwoFactor")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	if user.TOTPEnabled {
+		tools.JSONError(w, r, http.StatusConflict, models.ErrTOTPEnabled.Error(), "UserHandler.VerifyTwoFactor")
+		return
+	}
+
+	secret, err := h.UserRepo.GetTOTPSecret(r.Context(), user.ID)
+	if err == models.ErrNoTOTPSecret {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "UserRepo.GetTOTPSecret")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.GetTOTPSecret")
+		return
+	}
+
+	step, ok := totp.Validate(secret, strings.TrimSpace(form.Code), time.Now())
+	if !ok {
+		tools.JSONError(w, r, http.StatusBadRequest, models.ErrBadOTP.Error(), "totp.Validate")
+		return
+	}
+
+	// Код подтверждения тоже нельзя повторить, например сразу для входа.
+	err = h.UserRepo.UseTOTPStep(r.Context(), user.ID, step)
+	if err == models.ErrBadOTP {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "UserRepo.UseTOTPStep")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.UseTOTPStep")
+		return
+	}
+
+	recoveryCodes, err := generateRecoveryCodes()
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "generateRecoveryCodes")
+		return
+	}
+
+	err = h.UserRepo.EnableTOTP(r.Context(), user.ID, recoveryCodes)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.EnableTOTP")
+		return
+	}
+
+	response, err := json.Marshal(map[string]interface{}{
+		"recoveryCodes": recoveryCodes,
+	})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.VerifyTwoFactor")
+		return
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.VerifyTwoFactor")
+		return
+	}
+}
+
+func (h *UserHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDContextKey).(int)
+	if !ok {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserHandler.DisableTwoFactor")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if tools.BodyTooLarge(err) {
+		tools.JSONError(w, r, http.StatusRequestEntityTooLarge, err.Error(), "UserHandler.DisableTwoFactor")
+		return
+	}
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.DisableTwoFactor")
+		return
+	}
+
+	form := &TwoFactorDisableForm{}
+	err = tools.UnmarshalStrict(body, form)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, "cant unpack payload", "UserHandler.DisableTwoFactor")
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusUnauthorized, "you should authorize first", "UserRepo.GetUserByID")
+		return
+	}
+
+	if !user.TOTPEnabled {
+		tools.JSONError(w, r, http.StatusConflict, models.ErrTOTPDisabled.Error(), "UserHandler.DisableTwoFactor")
+		return
+	}
+
+	_, err = h.UserRepo.GetUserFromRepo(r.Context(), user.Login, form.Password)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "UserRepo.GetUserFromRepo")
+		return
+	}
+
+	err = h.checkSecondFactor(r.Context(), user, form.Code)
+	if err == models.ErrBadOTP {
+		tools.JSONError(w, r, http.StatusBadRequest, err.Error(), "UserHandler.checkSecondFactor")
+		return
+	} else if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.checkSecondFactor")
+		return
+	}
+
+	err = h.UserRepo.DisableTOTP(r.Context(), user.ID)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserRepo.DisableTOTP")
+		return
+	}
+
+	response, err := json.Marshal(map[string]string{"message": "two-factor authentication disabled"})
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.DisableTwoFactor")
+		return
+	}
+
+	_, err = w.Write(response)
+	if err != nil {
+		tools.JSONError(w, r, http.StatusInternalServerError, err.Error(), "UserHandler.DisableTwoFactor")
+		return
+	}
+}
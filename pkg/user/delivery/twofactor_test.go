@@ -0,0 +1,375 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/models"
+	sessionMock "redditclone/pkg/session/repository/mock_repository"
+	throttleMock "redditclone/pkg/throttle/repository/mock_repository"
+	"redditclone/pkg/totp"
+	userMock "redditclone/pkg/user/repository/mock_repository"
+	"redditclone/tools"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserHandlerTwoFactorEnrollment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+
+	userHandler := &UserHandler{
+		UserRepo: mockUserRepo,
+	}
+
+	tools.Init()
+
+	var user = &models.User{
+		ID:    1,
+		Login: "alex12345",
+	}
+
+	t.Run("correct enrollment", func(t *testing.T) {
+		var secret string
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().SetTOTPSecret(gomock.Any(), user.ID, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, userID int, s string) error {
+				secret = s
+				return nil
+			})
+
+		req := httptest.NewRequest("POST", "/api/account/2fa", nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		userHandler.EnrollTwoFactor(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		var response map[string]string
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, secret, response["secret"])
+		assert.True(t, strings.HasPrefix(response["provisioningUri"], "otpauth://totp/"))
+
+		code, err := totp.GenerateCode(secret, time.Now())
+		assert.NoError(t, err)
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetTOTPSecret(gomock.Any(), user.ID).Return(secret, nil)
+		mockUserRepo.EXPECT().UseTOTPStep(gomock.Any(), user.ID, gomock.Any()).Return(nil)
+		mockUserRepo.EXPECT().EnableTOTP(gomock.Any(), user.ID, gomock.Len(recoveryCodeCount)).Return(nil)
+
+		reqBody, _ := json.Marshal(&TwoFactorCodeForm{Code: code})
+		req = httptest.NewRequest("POST", "/api/account/2fa/verify", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w = httptest.NewRecorder()
+
+		userHandler.VerifyTwoFactor(w, req)
+
+		resp = w.Result()
+		defer resp.Body.Close()
+
+		var verifyResponse map[string][]string
+		err = json.NewDecoder(resp.Body).Decode(&verifyResponse)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, recoveryCodeCount, len(verifyResponse["recoveryCodes"]))
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		secret, _ := totp.GenerateSecret()
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetTOTPSecret(gomock.Any(), user.ID).Return(secret, nil)
+
+		reqBody, _ := json.Marshal(&TwoFactorCodeForm{Code: "000000x"})
+		req := httptest.NewRequest("POST", "/api/account/2fa/verify", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		userHandler.VerifyTwoFactor(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("already enabled", func(t *testing.T) {
+		enabledUser := *user
+		enabledUser.TOTPEnabled = true
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(&enabledUser, nil)
+
+		req := httptest.NewRequest("POST", "/api/account/2fa", nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		userHandler.EnrollTwoFactor(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+}
+
+func TestUserHandlerTwoFactorLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+	mockSessionRepo := sessionMock.NewMockSessionManager(ctrl)
+	mockThrottler := throttleMock.NewMockThrottler(ctrl)
+
+	userHandler := &UserHandler{
+		UserRepo:        mockUserRepo,
+		SessionRepo:     mockSessionRepo,
+		LoginThrottler:  mockThrottler,
+		SignupThrottler: mockThrottler,
+		TokenKey:        []byte("test key"),
+	}
+
+	mockThrottler.EXPECT().Check(gomock.Any()).Return(time.Duration(0), nil).AnyTimes()
+	mockThrottler.EXPECT().Hit(gomock.Any()).Return(time.Duration(0), nil).AnyTimes()
+	mockThrottler.EXPECT().Reset(gomock.Any()).Return(nil).AnyTimes()
+
+	tools.Init()
+
+	secret, _ := totp.GenerateSecret()
+
+	var authForm = &AuthForm{
+		Login:    "alex12345",
+		Password: "alex12345",
+	}
+
+	var user = &models.User{
+		ID:          1,
+		Login:       authForm.Login,
+		Password:    authForm.Password,
+		TOTPEnabled: true,
+	}
+
+	var session = &models.Session{
+		ID:        1,
+		JWT:       "some jwt token",
+		UserID:    user.ID,
+		ExpiresAt: time.Now().AddDate(0, 0, 4),
+	}
+
+	login := func(t *testing.T) string {
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), authForm.Login, authForm.Password).Return(user, nil)
+
+		reqBody, _ := json.Marshal(authForm)
+		req := httptest.NewRequest("POST", "/api/login", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		userHandler.Login(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		var response map[string]interface{}
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, true, response["twoFactorRequired"])
+		assert.Nil(t, response["token"])
+
+		challengeToken, _ := response["challengeToken"].(string)
+		return challengeToken
+	}
+
+	t.Run("correct TOTP code", func(t *testing.T) {
+		challengeToken := login(t)
+		code, _ := totp.GenerateCode(secret, time.Now())
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetTOTPSecret(gomock.Any(), user.ID).Return(secret, nil)
+		mockUserRepo.EXPECT().UseTOTPStep(gomock.Any(), user.ID, gomock.Any()).Return(nil)
+		mockSessionRepo.EXPECT().Check(gomock.Any(), user.ID).Return(session, nil)
+
+		reqBody, _ := json.Marshal(&TwoFactorLoginForm{ChallengeToken: challengeToken, Code: code})
+		req := httptest.NewRequest("POST", "/api/login/2fa", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		userHandler.LoginTwoFactor(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		var response map[string]interface{}
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, session.JWT, response["token"])
+	})
+
+	t.Run("recovery code", func(t *testing.T) {
+		challengeToken := login(t)
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetTOTPSecret(gomock.Any(), user.ID).Return(secret, nil)
+		mockUserRepo.EXPECT().UseRecoveryCode(gomock.Any(), user.ID, "abcde-fghij").Return(nil)
+		mockSessionRepo.EXPECT().Check(gomock.Any(), user.ID).Return(session, nil)
+
+		reqBody, _ := json.Marshal(&TwoFactorLoginForm{ChallengeToken: challengeToken, Code: "abcde-fghij"})
+		req := httptest.NewRequest("POST", "/api/login/2fa", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		userHandler.LoginTwoFactor(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		challengeToken := login(t)
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetTOTPSecret(gomock.Any(), user.ID).Return(secret, nil)
+		mockUserRepo.EXPECT().UseRecoveryCode(gomock.Any(), user.ID, "123").Return(models.ErrBadOTP)
+
+		reqBody, _ := json.Marshal(&TwoFactorLoginForm{ChallengeToken: challengeToken, Code: "123"})
+		req := httptest.NewRequest("POST", "/api/login/2fa", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		userHandler.LoginTwoFactor(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("replayed TOTP code", func(t *testing.T) {
+		code, _ := totp.GenerateCode(secret, time.Now())
+
+		// Хранилище принимает только шаги позже уже принятого.
+		var lastStep int64
+		mockUserRepo.EXPECT().UseTOTPStep(gomock.Any(), user.ID, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, userID int, step int64) error {
+				if step <= lastStep {
+					return models.ErrBadOTP
+				}
+				lastStep = step
+				return nil
+			},
+		).Times(2)
+
+		for _, expected := range []int{http.StatusOK, http.StatusUnauthorized} {
+			challengeToken := login(t)
+
+			mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+			mockUserRepo.EXPECT().GetTOTPSecret(gomock.Any(), user.ID).Return(secret, nil)
+			if expected == http.StatusOK {
+				mockSessionRepo.EXPECT().Check(gomock.Any(), user.ID).Return(session, nil)
+			}
+
+			reqBody, _ := json.Marshal(&TwoFactorLoginForm{ChallengeToken: challengeToken, Code: code})
+			req := httptest.NewRequest("POST", "/api/login/2fa", bytes.NewReader(reqBody))
+			w := httptest.NewRecorder()
+
+			userHandler.LoginTwoFactor(w, req)
+
+			resp := w.Result()
+			resp.Body.Close()
+
+			assert.Equal(t, expected, resp.StatusCode)
+		}
+	})
+
+	t.Run("session token is not a challenge", func(t *testing.T) {
+		tokenString, _ := createUserJWT(userHandler.TokenKey, user)
+
+		reqBody, _ := json.Marshal(&TwoFactorLoginForm{ChallengeToken: tokenString, Code: "123456"})
+		req := httptest.NewRequest("POST", "/api/login/2fa", bytes.NewReader(reqBody))
+		w := httptest.NewRecorder()
+
+		userHandler.LoginTwoFactor(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestUserHandlerDisableTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := userMock.NewMockUserRepo(ctrl)
+
+	userHandler := &UserHandler{
+		UserRepo: mockUserRepo,
+	}
+
+	tools.Init()
+
+	secret, _ := totp.GenerateSecret()
+
+	var user = &models.User{
+		ID:          1,
+		Login:       "alex12345",
+		TOTPEnabled: true,
+	}
+
+	t.Run("correct disable", func(t *testing.T) {
+		code, _ := totp.GenerateCode(secret, time.Now())
+
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), user.Login, "password").Return(user, nil)
+		mockUserRepo.EXPECT().GetTOTPSecret(gomock.Any(), user.ID).Return(secret, nil)
+		mockUserRepo.EXPECT().UseTOTPStep(gomock.Any(), user.ID, gomock.Any()).Return(nil)
+		mockUserRepo.EXPECT().DisableTOTP(gomock.Any(), user.ID).Return(nil)
+
+		reqBody, _ := json.Marshal(&TwoFactorDisableForm{Password: "password", Code: code})
+		req := httptest.NewRequest("DELETE", "/api/account/2fa", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		userHandler.DisableTwoFactor(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		mockUserRepo.EXPECT().GetUserByID(gomock.Any(), user.ID).Return(user, nil)
+		mockUserRepo.EXPECT().GetUserFromRepo(gomock.Any(), user.Login, "wrong").Return(nil, models.ErrWrongCredentials)
+
+		reqBody, _ := json.Marshal(&TwoFactorDisableForm{Password: "wrong", Code: "123456"})
+		req := httptest.NewRequest("DELETE", "/api/account/2fa", bytes.NewReader(reqBody))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDContextKey, user.ID))
+		w := httptest.NewRecorder()
+
+		userHandler.DisableTwoFactor(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
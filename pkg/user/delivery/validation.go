@@ -0,0 +1,47 @@
+package delivery
+
+import (
+	"redditclone/pkg/models"
+	"redditclone/pkg/user/policy"
+	"redditclone/tools"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// validateAuthForm собирает все ошибки формы регистрации сразу, чтобы пользователь исправил их за один раз.
+// Значение пароля в ответ не попадает.
+func validateAuthForm(credentialsPolicy *policy.Policy, form *AuthForm) []*tools.FieldError {
+	fieldErrors := []*tools.FieldError{}
+
+	if err := credentialsPolicy.ValidateLogin(form.Login); err != nil {
+		fieldErrors = append(fieldErrors, &tools.FieldError{
+			Location: "body",
+			Param:    "username",
+			Value:    form.Login,
+			Msg:      err.Error(),
+		})
+	}
+
+	if err := credentialsPolicy.ValidatePassword(form.Password); err != nil {
+		fieldErrors = append(fieldErrors, passwordFieldError("password", err))
+	}
+
+	if form.Email != "" && !govalidator.IsEmail(form.Email) {
+		fieldErrors = append(fieldErrors, &tools.FieldError{
+			Location: "body",
+			Param:    "email",
+			Value:    form.Email,
+			Msg:      models.ErrBadEmail.Error(),
+		})
+	}
+
+	return fieldErrors
+}
+
+func passwordFieldError(param string, err error) *tools.FieldError {
+	return &tools.FieldError{
+		Location: "body",
+		Param:    param,
+		Msg:      err.Error(),
+	}
+}
@@ -0,0 +1,111 @@
+package policy
+
+import (
+	"bufio"
+	"os"
+	"redditclone/pkg/models"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MinLoginLength = 3
+	MaxLoginLength = 20
+
+	MinPasswordLength = 8
+	// bcrypt учитывает только первые 72 байта, остаток пароля молча отбрасывался бы.
+	MaxPasswordBytes = 72
+)
+
+var loginCharset = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Имена, которые можно принять за служебные аккаунты или пути API.
+var reservedLogins = map[string]struct{}{
+	"admin":         {},
+	"administrator": {},
+	"anonymous":     {},
+	"api":           {},
+	"deleted":       {},
+	"mod":           {},
+	"moderator":     {},
+	"null":          {},
+	"root":          {},
+	"static":        {},
+	"support":       {},
+	"system":        {},
+	"undefined":     {},
+}
+
+type Policy struct {
+	breachedPasswords map[string]struct{}
+}
+
+func NewPolicy(breachedPasswords ...string) *Policy {
+	policy := &Policy{
+		breachedPasswords: make(map[string]struct{}, len(breachedPasswords)),
+	}
+	for _, password := range breachedPasswords {
+		policy.breachedPasswords[strings.ToLower(password)] = struct{}{}
+	}
+
+	return policy
+}
+
+// LoadPolicy читает список утекших паролей: по одному на строку, строки с # пропускаются.
+func LoadPolicy(blocklistPath string) (*Policy, error) {
+	file, err := os.Open(blocklistPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	breachedPasswords := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breachedPasswords = append(breachedPasswords, line)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewPolicy(breachedPasswords...), nil
+}
+
+func (p *Policy) ValidateLogin(login string) error {
+	if len(login) < MinLoginLength || len(login) > MaxLoginLength {
+		return models.ErrLoginLength
+	}
+
+	if !loginCharset.MatchString(login) {
+		return models.ErrLoginCharset
+	}
+
+	if _, ok := reservedLogins[strings.ToLower(login)]; ok {
+		return models.ErrLoginReserved
+	}
+
+	return nil
+}
+
+// Блоклист сравнивается без учета регистра: "Password1" ничем не надежнее "password1".
+func (p *Policy) ValidatePassword(password string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return models.ErrPasswordTooShort
+	}
+
+	if len(password) > MaxPasswordBytes {
+		return models.ErrPasswordTooLong
+	}
+
+	if _, ok := p.breachedPasswords[strings.ToLower(password)]; ok {
+		return models.ErrPasswordBreached
+	}
+
+	return nil
+}
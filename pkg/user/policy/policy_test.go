@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"redditclone/pkg/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLogin(t *testing.T) {
+	policy := NewPolicy()
+
+	cases := map[string]error{
+		"alex12345":             nil,
+		"Alex_Smith-2":          nil,
+		"ab":                    models.ErrLoginLength,
+		"":                      models.ErrLoginLength,
+		strings.Repeat("a", 21): models.ErrLoginLength,
+		"alex smith":            models.ErrLoginCharset,
+		" alex12345":            models.ErrLoginCharset,
+		"алекс123":              models.ErrLoginCharset,
+		"[deleted]":             models.ErrLoginCharset,
+		"Admin":                 models.ErrLoginReserved,
+		"ROOT":                  models.ErrLoginReserved,
+		strings.Repeat("a", 20): nil,
+		"alex12345\x00":         models.ErrLoginCharset,
+	}
+
+	for login, expected := range cases {
+		assert.Equal(t, expected, policy.ValidateLogin(login), login)
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	policy := NewPolicy("password123", "Qwertyuiop")
+
+	cases := map[string]error{
+		"correct horse battery": nil,
+		"short":                 models.ErrPasswordTooShort,
+		"пароль12":              nil,
+		strings.Repeat("a", 72): nil,
+		strings.Repeat("a", 73): models.ErrPasswordTooLong,
+		strings.Repeat("я", 37): models.ErrPasswordTooLong,
+		"password123":           models.ErrPasswordBreached,
+		"PASSWORD123":           models.ErrPasswordBreached,
+		"qwertyuiop":            models.ErrPasswordBreached,
+	}
+
+	for password, expected := range cases {
+		assert.Equal(t, expected, policy.ValidatePassword(password), password)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(path, []byte("# comment\n\n  iloveyou1  \nsunshine\n"), 0o644)
+	assert.NoError(t, err)
+
+	policy, err := LoadPolicy(path)
+	assert.NoError(t, err)
+
+	assert.Equal(t, models.ErrPasswordBreached, policy.ValidatePassword("iloveyou1"))
+	assert.Equal(t, models.ErrPasswordBreached, policy.ValidatePassword("sunshine"))
+	assert.NoError(t, policy.ValidatePassword("# comment"))
+
+	_, err = LoadPolicy(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package instrumented
+
+import (
+	"context"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	"redditclone/pkg/tracing"
+	userRepository "redditclone/pkg/user/repository"
+)
+
+// UserInstrumentedRepository пишет в метрики длительность и ошибки каждого вызова UserRepo
+// и открывает на него спан трассировки.
+type UserInstrumentedRepository struct {
+	Repo userRepository.UserRepo
+}
+
+func NewUserInstrumentedRepository(repo userRepository.UserRepo) *UserInstrumentedRepository {
+	return &UserInstrumentedRepository{
+		Repo: repo,
+	}
+}
+
+func (repo *UserInstrumentedRepository) GetUserFromRepo(ctx context.Context, login, pass string) (user *models.User, err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.GetUserFromRepo")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "GetUserFromRepo")(&err)
+	return repo.Repo.GetUserFromRepo(ctx, login, pass)
+}
+
+func (repo *UserInstrumentedRepository) CreateUser(ctx context.Context, login, pass, email string) (user *models.User, err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.CreateUser")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "CreateUser")(&err)
+	return repo.Repo.CreateUser(ctx, login, pass, email)
+}
+
+func (repo *UserInstrumentedRepository) GetUserByID(ctx context.Context, userID int) (user *models.User, err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.GetUserByID")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "GetUserByID")(&err)
+	return repo.Repo.GetUserByID(ctx, userID)
+}
+
+func (repo *UserInstrumentedRepository) GetUserByLogin(ctx context.Context, login string) (user *models.User, err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.GetUserByLogin")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "GetUserByLogin")(&err)
+	return repo.Repo.GetUserByLogin(ctx, login)
+}
+
+func (repo *UserInstrumentedRepository) UpdatePassword(ctx context.Context, userID int, pass string) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.UpdatePassword")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "UpdatePassword")(&err)
+	return repo.Repo.UpdatePassword(ctx, userID, pass)
+}
+
+func (repo *UserInstrumentedRepository) VerifyEmail(ctx context.Context, userID int, email string) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.VerifyEmail")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "VerifyEmail")(&err)
+	return repo.Repo.VerifyEmail(ctx, userID, email)
+}
+
+func (repo *UserInstrumentedRepository) DeleteUser(ctx context.Context, userID int) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.DeleteUser")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "DeleteUser")(&err)
+	return repo.Repo.DeleteUser(ctx, userID)
+}
+
+func (repo *UserInstrumentedRepository) GetProfile(ctx context.Context, login string) (profile *models.Profile, err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.GetProfile")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "GetProfile")(&err)
+	return repo.Repo.GetProfile(ctx, login)
+}
+
+func (repo *UserInstrumentedRepository) UpdateKarma(ctx context.Context, userID int, postKarmaDelta int, commentKarmaDelta int) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.UpdateKarma")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "UpdateKarma")(&err)
+	return repo.Repo.UpdateKarma(ctx, userID, postKarmaDelta, commentKarmaDelta)
+}
+
+func (repo *UserInstrumentedRepository) SetTOTPSecret(ctx context.Context, userID int, secret string) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.SetTOTPSecret")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "SetTOTPSecret")(&err)
+	return repo.Repo.SetTOTPSecret(ctx, userID, secret)
+}
+
+func (repo *UserInstrumentedRepository) GetTOTPSecret(ctx context.Context, userID int) (secret string, err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.GetTOTPSecret")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "GetTOTPSecret")(&err)
+	return repo.Repo.GetTOTPSecret(ctx, userID)
+}
+
+func (repo *UserInstrumentedRepository) EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.EnableTOTP")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "EnableTOTP")(&err)
+	return repo.Repo.EnableTOTP(ctx, userID, recoveryCodes)
+}
+
+func (repo *UserInstrumentedRepository) DisableTOTP(ctx context.Context, userID int) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.DisableTOTP")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "DisableTOTP")(&err)
+	return repo.Repo.DisableTOTP(ctx, userID)
+}
+
+func (repo *UserInstrumentedRepository) UseRecoveryCode(ctx context.Context, userID int, code string) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.UseRecoveryCode")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "UseRecoveryCode")(&err)
+	return repo.Repo.UseRecoveryCode(ctx, userID, code)
+}
+
+func (repo *UserInstrumentedRepository) UseTOTPStep(ctx context.Context, userID int, step int64) (err error) {
+	ctx, endSpan := tracing.Start(ctx, "UserRepo.UseTOTPStep")
+	defer endSpan(&err)
+	defer metrics.TrackRepositoryCall("user", "UseTOTPStep")(&err)
+	return repo.Repo.UseTOTPStep(ctx, userID, step)
+}
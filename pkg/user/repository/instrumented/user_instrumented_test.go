@@ -0,0 +1,32 @@
+package instrumented
+
+import (
+	"context"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/models"
+	"redditclone/pkg/user/repository"
+	"redditclone/pkg/user/repository/memory"
+	"redditclone/pkg/user/repository/repotest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.TestUserRepo(t, func(t *testing.T) repository.UserRepo {
+		return NewUserInstrumentedRepository(memory.NewUserMemoryRepository())
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewUserInstrumentedRepository(memory.NewUserMemoryRepository())
+	errors := metrics.RepositoryCallErrors.WithLabelValues("user", "GetUserByLogin")
+	before := testutil.ToFloat64(errors)
+
+	_, err := repo.GetUserByLogin(ctx, "nobody")
+	assert.Equal(t, models.ErrNoUser, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(errors))
+}
@@ -0,0 +1,289 @@
+package memory
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type userRecord struct {
+	user          models.User
+	created       time.Time
+	postKarma     int
+	commentKarma  int
+	totpSecret    string
+	totpLastStep  int64
+	recoveryCodes map[string]bool
+}
+
+// UserMemoryRepository хранит пользователей в памяти процесса: для разработки без MySQL и для тестов.
+// Логин и почта уникальны без учета регистра, как и в MySQL с collation utf8mb4_0900_ai_ci.
+type UserMemoryRepository struct {
+	mu      *sync.RWMutex
+	users   map[int]*userRecord
+	byLogin map[string]int
+	byEmail map[string]int
+	lastID  int
+}
+
+func NewUserMemoryRepository() *UserMemoryRepository {
+	return &UserMemoryRepository{
+		mu:      &sync.RWMutex{},
+		users:   make(map[int]*userRecord),
+		byLogin: make(map[string]int),
+		byEmail: make(map[string]int),
+	}
+}
+
+func (repo *UserMemoryRepository) findByLogin(login string) (*userRecord, bool) {
+	userID, ok := repo.byLogin[strings.ToLower(login)]
+	if !ok {
+		return nil, false
+	}
+
+	return repo.users[userID], true
+}
+
+func (repo *UserMemoryRepository) GetUserFromRepo(ctx context.Context, login, pass string) (*models.User, error) {
+	repo.mu.RLock()
+	record, ok := repo.findByLogin(login)
+	if !ok {
+		repo.mu.RUnlock()
+		return nil, models.ErrNoUser
+	}
+	user := record.user
+	repo.mu.RUnlock()
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(pass)); err != nil {
+		return nil, models.ErrWrongCredentials
+	}
+
+	return &user, nil
+}
+
+func (repo *UserMemoryRepository) CreateUser(ctx context.Context, login, pass, email string) (*models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.byLogin[strings.ToLower(login)]; ok {
+		return nil, models.ErrAlreadyCreated
+	}
+	if _, ok := repo.byEmail[strings.ToLower(email)]; ok && email != "" {
+		return nil, models.ErrEmailTaken
+	}
+
+	repo.lastID++
+	record := &userRecord{
+		user: models.User{
+			ID:       repo.lastID,
+			Login:    login,
+			Password: string(hashedPassword),
+			Email:    email,
+		},
+		created:       time.Now(),
+		recoveryCodes: map[string]bool{},
+	}
+
+	repo.users[record.user.ID] = record
+	repo.byLogin[strings.ToLower(login)] = record.user.ID
+	if email != "" {
+		repo.byEmail[strings.ToLower(email)] = record.user.ID
+	}
+
+	user := record.user
+	return &user, nil
+}
+
+func (repo *UserMemoryRepository) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	record, ok := repo.users[userID]
+	if !ok {
+		return nil, models.ErrNoUser
+	}
+
+	user := record.user
+	return &user, nil
+}
+
+func (repo *UserMemoryRepository) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	record, ok := repo.findByLogin(login)
+	if !ok {
+		return nil, models.ErrNoUser
+	}
+
+	user := record.user
+	return &user, nil
+}
+
+func (repo *UserMemoryRepository) UpdatePassword(ctx context.Context, userID int, pass string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return repo.update(userID, func(record *userRecord) error {
+		record.user.Password = string(hashedPassword)
+		return nil
+	})
+}
+
+func (repo *UserMemoryRepository) VerifyEmail(ctx context.Context, userID int, email string) error {
+	return repo.update(userID, func(record *userRecord) error {
+		if record.user.Email == "" || !strings.EqualFold(record.user.Email, email) {
+			return models.ErrNoUser
+		}
+
+		record.user.EmailVerified = true
+		return nil
+	})
+}
+
+func (repo *UserMemoryRepository) DeleteUser(ctx context.Context, userID int) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	record, ok := repo.users[userID]
+	if !ok {
+		return models.ErrNoUser
+	}
+
+	delete(repo.users, userID)
+	delete(repo.byLogin, strings.ToLower(record.user.Login))
+	if record.user.Email != "" {
+		delete(repo.byEmail, strings.ToLower(record.user.Email))
+	}
+
+	return nil
+}
+
+func (repo *UserMemoryRepository) GetProfile(ctx context.Context, login string) (*models.Profile, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	record, ok := repo.findByLogin(login)
+	if !ok {
+		return nil, models.ErrNoUser
+	}
+
+	return &models.Profile{
+		ID:           record.user.ID,
+		Login:        record.user.Login,
+		Created:      record.created,
+		PostKarma:    record.postKarma,
+		CommentKarma: record.commentKarma,
+		Karma:        record.postKarma + record.commentKarma,
+	}, nil
+}
+
+func (repo *UserMemoryRepository) UpdateKarma(ctx context.Context, userID int, postKarmaDelta int, commentKarmaDelta int) error {
+	return repo.update(userID, func(record *userRecord) error {
+		record.postKarma += postKarmaDelta
+		record.commentKarma += commentKarmaDelta
+		return nil
+	})
+}
+
+func (repo *UserMemoryRepository) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	return repo.update(userID, func(record *userRecord) error {
+		record.totpSecret = secret
+		record.user.TOTPEnabled = false
+		return nil
+	})
+}
+
+func (repo *UserMemoryRepository) GetTOTPSecret(ctx context.Context, userID int) (string, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	record, ok := repo.users[userID]
+	if !ok {
+		return "", models.ErrNoUser
+	}
+
+	if record.totpSecret == "" {
+		return "", models.ErrNoTOTPSecret
+	}
+
+	return record.totpSecret, nil
+}
+
+func (repo *UserMemoryRepository) EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error {
+	return repo.update(userID, func(record *userRecord) error {
+		if record.totpSecret == "" {
+			return models.ErrNoTOTPSecret
+		}
+
+		record.recoveryCodes = make(map[string]bool, len(recoveryCodes))
+		for _, code := range recoveryCodes {
+			record.recoveryCodes[normalizeRecoveryCode(code)] = true
+		}
+		record.user.TOTPEnabled = true
+		return nil
+	})
+}
+
+func (repo *UserMemoryRepository) DisableTOTP(ctx context.Context, userID int) error {
+	return repo.update(userID, func(record *userRecord) error {
+		record.totpSecret = ""
+		record.user.TOTPEnabled = false
+		record.recoveryCodes = map[string]bool{}
+		return nil
+	})
+}
+
+func (repo *UserMemoryRepository) UseRecoveryCode(ctx context.Context, userID int, code string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	record, ok := repo.users[userID]
+	if !ok || !record.recoveryCodes[normalizeRecoveryCode(code)] {
+		return models.ErrBadOTP
+	}
+
+	delete(record.recoveryCodes, normalizeRecoveryCode(code))
+	return nil
+}
+
+func (repo *UserMemoryRepository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	record, ok := repo.users[userID]
+	if !ok || step <= record.totpLastStep {
+		return models.ErrBadOTP
+	}
+
+	record.totpLastStep = step
+	return nil
+}
+
+func (repo *UserMemoryRepository) update(userID int, fn func(record *userRecord) error) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	record, ok := repo.users[userID]
+	if !ok {
+		return models.ErrNoUser
+	}
+
+	return fn(record)
+}
+
+// normalizeRecoveryCode совпадает с нормализацией в MySQL-репозитории: регистр и дефисы не важны.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"redditclone/pkg/user/repository"
+	"redditclone/pkg/user/repository/repotest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewUserMemoryRepository()
+
+	user, err := repo.CreateUser(ctx, "alex12345", "qwerty123", "alex@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, user.ID)
+	assert.NotEqual(t, "qwerty123", user.Password)
+
+	t.Run("login is case insensitive", func(t *testing.T) {
+		_, err := repo.CreateUser(ctx, "ALEX12345", "qwerty123", "")
+		assert.Equal(t, models.ErrAlreadyCreated, err)
+	})
+
+	t.Run("email is taken", func(t *testing.T) {
+		_, err := repo.CreateUser(ctx, "bob", "qwerty123", "Alex@Example.com")
+		assert.Equal(t, models.ErrEmailTaken, err)
+	})
+
+	t.Run("concurrent signup", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.CreateUser(ctx, "carol", "qwerty123", "")
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		created := 0
+		for err := range errs {
+			if err == nil {
+				created++
+			} else {
+				assert.Equal(t, models.ErrAlreadyCreated, err)
+			}
+		}
+		assert.Equal(t, 1, created)
+	})
+}
+
+func TestGetUserFromRepo(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewUserMemoryRepository()
+	created, _ := repo.CreateUser(ctx, "alex12345", "qwerty123", "")
+
+	user, err := repo.GetUserFromRepo(ctx, "alex12345", "qwerty123")
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, user.ID)
+
+	_, err = repo.GetUserFromRepo(ctx, "alex12345", "wrong")
+	assert.Equal(t, models.ErrWrongCredentials, err)
+
+	_, err = repo.GetUserFromRepo(ctx, "nobody", "qwerty123")
+	assert.Equal(t, models.ErrNoUser, err)
+
+	t.Run("returned user is a copy", func(t *testing.T) {
+		user.Login = "changed"
+		stored, _ := repo.GetUserByID(ctx, created.ID)
+		assert.Equal(t, "alex12345", stored.Login)
+	})
+}
+
+func TestProfileAndKarma(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewUserMemoryRepository()
+	user, _ := repo.CreateUser(ctx, "alex12345", "qwerty123", "")
+
+	assert.NoError(t, repo.UpdateKarma(ctx, user.ID, 3, -1))
+	profile, err := repo.GetProfile(ctx, "alex12345")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, profile.PostKarma)
+	assert.Equal(t, -1, profile.CommentKarma)
+	assert.Equal(t, 2, profile.Karma)
+
+	assert.Equal(t, models.ErrNoUser, repo.UpdateKarma(ctx, 42, 1, 1))
+}
+
+func TestVerifyEmailAndDelete(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewUserMemoryRepository()
+	user, _ := repo.CreateUser(ctx, "alex12345", "qwerty123", "alex@example.com")
+
+	assert.Equal(t, models.ErrNoUser, repo.VerifyEmail(ctx, user.ID, "other@example.com"))
+	assert.NoError(t, repo.VerifyEmail(ctx, user.ID, "alex@example.com"))
+	stored, _ := repo.GetUserByLogin(ctx, "alex12345")
+	assert.True(t, stored.EmailVerified)
+
+	assert.NoError(t, repo.DeleteUser(ctx, user.ID))
+	assert.Equal(t, models.ErrNoUser, repo.DeleteUser(ctx, user.ID))
+
+	_, err := repo.CreateUser(ctx, "alex12345", "qwerty123", "alex@example.com")
+	assert.NoError(t, err)
+}
+
+func TestTOTP(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewUserMemoryRepository()
+	user, _ := repo.CreateUser(ctx, "alex12345", "qwerty123", "")
+
+	_, err := repo.GetTOTPSecret(ctx, user.ID)
+	assert.Equal(t, models.ErrNoTOTPSecret, err)
+	assert.Equal(t, models.ErrNoTOTPSecret, repo.EnableTOTP(ctx, user.ID, nil))
+
+	assert.NoError(t, repo.SetTOTPSecret(ctx, user.ID, "SECRET"))
+	secret, err := repo.GetTOTPSecret(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET", secret)
+
+	assert.NoError(t, repo.EnableTOTP(ctx, user.ID, []string{"abcde-12345"}))
+	stored, _ := repo.GetUserByID(ctx, user.ID)
+	assert.True(t, stored.TOTPEnabled)
+
+	assert.NoError(t, repo.UseRecoveryCode(ctx, user.ID, "ABCDE12345"))
+	assert.Equal(t, models.ErrBadOTP, repo.UseRecoveryCode(ctx, user.ID, "abcde-12345"))
+
+	assert.NoError(t, repo.DisableTOTP(ctx, user.ID))
+	_, err = repo.GetTOTPSecret(ctx, user.ID)
+	assert.Equal(t, models.ErrNoTOTPSecret, err)
+}
+
+func TestConformance(t *testing.T) {
+	repotest.TestUserRepo(t, func(t *testing.T) repository.UserRepo {
+		return NewUserMemoryRepository()
+	})
+}
@@ -0,0 +1,326 @@
+package mongo
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"redditclone/tools"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// caseInsensitive совпадает с collation уникальных индексов из миграции users_indexes:
+// без нее запросы сравнивают строки с учетом регистра и не используют индекс.
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+type userDocument struct {
+	ID            int       `bson:"_id"`
+	Login         string    `bson:"username"`
+	Password      string    `bson:"password"`
+	Email         string    `bson:"email,omitempty"`
+	EmailVerified bool      `bson:"emailVerified"`
+	Created       time.Time `bson:"created"`
+	PostKarma     int       `bson:"postKarma"`
+	CommentKarma  int       `bson:"commentKarma"`
+	TOTPSecret    []byte    `bson:"totpSecret,omitempty"`
+	TOTPEnabled   bool      `bson:"totpEnabled"`
+	TOTPLastStep  int64     `bson:"totpLastStep"`
+	RecoveryCodes []string  `bson:"recoveryCodes"`
+	Admin         bool      `bson:"isAdmin"`
+}
+
+func (doc *userDocument) user() *models.User {
+	return &models.User{
+		ID:            doc.ID,
+		Login:         doc.Login,
+		Password:      doc.Password,
+		Email:         doc.Email,
+		EmailVerified: doc.EmailVerified,
+		TOTPEnabled:   doc.TOTPEnabled,
+		Admin:         doc.Admin,
+	}
+}
+
+// UserMongoDBRepository хранит пользователей в Mongo. Числовые id, которые ждут JWT и посты,
+// выдает счетчик в отдельной коллекции.
+type UserMongoDBRepository struct {
+	DB       *mongo.Collection
+	Counters *mongo.Collection
+	// SecretKey шифрует TOTP-секреты, чтобы дамп базы не раскрывал вторые факторы.
+	SecretKey []byte
+}
+
+func NewUserMongoDBRepository(usersCollection, countersCollection *mongo.Collection, secretKey string) *UserMongoDBRepository {
+	return &UserMongoDBRepository{
+		DB:        usersCollection,
+		Counters:  countersCollection,
+		SecretKey: tools.DeriveKey(secretKey),
+	}
+}
+
+func (repo *UserMongoDBRepository) findOne(ctx context.Context, filter bson.M) (*userDocument, error) {
+	doc := &userDocument{}
+
+	err := repo.DB.FindOne(ctx, filter, options.FindOne().SetCollation(caseInsensitive)).Decode(doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, models.ErrNoUser
+	} else if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// updateOne проверяет MatchedCount, а не ModifiedCount: повторная запись тех же значений - не ошибка.
+func (repo *UserMongoDBRepository) updateOne(ctx context.Context, filter bson.M, update bson.M, notFound error) error {
+	res, err := repo.DB.UpdateOne(ctx, filter, update, options.Update().SetCollation(caseInsensitive))
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if res.MatchedCount == 0 {
+		return notFound
+	}
+
+	return nil
+}
+
+func (repo *UserMongoDBRepository) nextID(ctx context.Context) (int, error) {
+	counter := struct {
+		Seq int `bson:"seq"`
+	}{}
+
+	err := repo.Counters.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": "users"},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+
+	return counter.Seq, err
+}
+
+func (repo *UserMongoDBRepository) GetUserFromRepo(ctx context.Context, login, pass string) (*models.User, error) {
+	doc, err := repo.findOne(ctx, bson.M{"username": login})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(doc.Password), []byte(pass)); err != nil {
+		return nil, models.ErrWrongCredentials
+	}
+
+	return doc.user(), nil
+}
+
+// CreateUser полагается на уникальные индексы: при одновременной регистрации
+// одного логина вставка второго запроса упадет с ошибкой дубликата.
+func (repo *UserMongoDBRepository) CreateUser(ctx context.Context, login, pass, email string) (*models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := repo.nextID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &userDocument{
+		ID:            userID,
+		Login:         login,
+		Password:      string(hashedPassword),
+		Email:         email,
+		Created:       time.Now(),
+		RecoveryCodes: []string{},
+	}
+
+	if _, err = repo.DB.InsertOne(ctx, doc); err != nil {
+		return nil, duplicateKeyError(err)
+	}
+
+	return doc.user(), nil
+}
+
+// duplicateKeyError превращает нарушение уникального индекса в доменную ошибку
+// по имени индекса из сообщения Mongo: "E11000 duplicate key error ... index: email_1".
+func duplicateKeyError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	if strings.Contains(err.Error(), "index: email_1") {
+		return models.ErrEmailTaken
+	}
+
+	return models.ErrAlreadyCreated
+}
+
+func (repo *UserMongoDBRepository) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	doc, err := repo.findOne(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.user(), nil
+}
+
+func (repo *UserMongoDBRepository) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	doc, err := repo.findOne(ctx, bson.M{"username": login})
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.user(), nil
+}
+
+func (repo *UserMongoDBRepository) VerifyEmail(ctx context.Context, userID int, email string) error {
+	return repo.updateOne(
+		ctx,
+		bson.M{"_id": userID, "email": email},
+		bson.M{"$set": bson.M{"emailVerified": true}},
+		models.ErrNoUser,
+	)
+}
+
+func (repo *UserMongoDBRepository) UpdatePassword(ctx context.Context, userID int, pass string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return repo.updateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"password": string(hashedPassword)}},
+		models.ErrNoUser,
+	)
+}
+
+func (repo *UserMongoDBRepository) DeleteUser(ctx context.Context, userID int) error {
+	res, err := repo.DB.DeleteOne(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return models.ErrDeleteUser
+	} else if res.DeletedCount == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserMongoDBRepository) GetProfile(ctx context.Context, login string) (*models.Profile, error) {
+	doc, err := repo.findOne(ctx, bson.M{"username": login})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Profile{
+		ID:           doc.ID,
+		Login:        doc.Login,
+		Created:      doc.Created,
+		PostKarma:    doc.PostKarma,
+		CommentKarma: doc.CommentKarma,
+		Karma:        doc.PostKarma + doc.CommentKarma,
+	}, nil
+}
+
+func (repo *UserMongoDBRepository) UpdateKarma(ctx context.Context, userID int, postKarmaDelta int, commentKarmaDelta int) error {
+	return repo.updateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$inc": bson.M{"postKarma": postKarmaDelta, "commentKarma": commentKarmaDelta}},
+		models.ErrNoUser,
+	)
+}
+
+// SetTOTPSecret начинает подключение 2FA: секрет сохраняется, но не действует до EnableTOTP.
+func (repo *UserMongoDBRepository) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	encryptedSecret, err := tools.Encrypt(repo.SecretKey, []byte(secret))
+	if err != nil {
+		return err
+	}
+
+	return repo.updateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"totpSecret": encryptedSecret, "totpEnabled": false}},
+		models.ErrNoUser,
+	)
+}
+
+func (repo *UserMongoDBRepository) GetTOTPSecret(ctx context.Context, userID int) (string, error) {
+	doc, err := repo.findOne(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return "", err
+	}
+
+	if doc.TOTPSecret == nil {
+		return "", models.ErrNoTOTPSecret
+	}
+
+	secret, err := tools.Decrypt(repo.SecretKey, doc.TOTPSecret)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+// EnableTOTP заменяет коды восстановления и включает 2FA одним обновлением документа.
+func (repo *UserMongoDBRepository) EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error {
+	hashes := make([]string, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return repo.updateOne(
+		ctx,
+		bson.M{"_id": userID, "totpSecret": bson.M{"$exists": true}},
+		bson.M{"$set": bson.M{"recoveryCodes": hashes, "totpEnabled": true}},
+		models.ErrNoTOTPSecret,
+	)
+}
+
+func (repo *UserMongoDBRepository) DisableTOTP(ctx context.Context, userID int) error {
+	return repo.updateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set":   bson.M{"recoveryCodes": []string{}, "totpEnabled": false},
+			"$unset": bson.M{"totpSecret": ""},
+		},
+		models.ErrNoUser,
+	)
+}
+
+// UseRecoveryCode погашает код восстановления: $pull с условием на наличие кода атомарен,
+// поэтому один код нельзя использовать дважды даже параллельными запросами.
+func (repo *UserMongoDBRepository) UseRecoveryCode(ctx context.Context, userID int, code string) error {
+	hash := hashRecoveryCode(code)
+
+	return repo.updateOne(
+		ctx,
+		bson.M{"_id": userID, "recoveryCodes": hash},
+		bson.M{"$pull": bson.M{"recoveryCodes": hash}},
+		models.ErrBadOTP,
+	)
+}
+
+// UseTOTPStep сравнивает и записывает шаг одним обновлением: $not охватывает и документы,
+// созданные до появления поля.
+func (repo *UserMongoDBRepository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	return repo.updateOne(
+		ctx,
+		bson.M{"_id": userID, "totpLastStep": bson.M{"$not": bson.M{"$gte": step}}},
+		bson.M{"$set": bson.M{"totpLastStep": step}},
+		models.ErrBadOTP,
+	)
+}
+
+// Коды восстановления хранятся только в виде хешей, пользователь может вводить их с дефисом и в любом регистре.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return tools.GetSHA1Hash(normalized)
+}
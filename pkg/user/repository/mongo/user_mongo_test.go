@@ -0,0 +1,148 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"redditclone/pkg/migrate"
+	migrateMongo "redditclone/pkg/migrate/mongo"
+	"redditclone/pkg/models"
+	"redditclone/pkg/user/repository"
+	"redditclone/pkg/user/repository/repotest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	counterResponse := mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+		{Key: "_id", Value: "users"},
+		{Key: "seq", Value: 7},
+	}})
+
+	mt.Run("correct query", func(mt *mtest.T) {
+		repo := NewUserMongoDBRepository(mt.Coll, mt.Coll, "secret key")
+
+		mt.AddMockResponses(counterResponse, mtest.CreateSuccessResponse())
+
+		user, err := repo.CreateUser(ctx, "alex12345", "correct horse", "alex@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 7, user.ID)
+		assert.Equal(t, "alex12345", user.Login)
+		assert.Equal(t, "alex@example.com", user.Email)
+		assert.NotEqual(t, "correct horse", user.Password)
+	})
+
+	mt.Run("duplicates", func(mt *mtest.T) {
+		repo := NewUserMongoDBRepository(mt.Coll, mt.Coll, "secret key")
+		cases := map[string]error{
+			"username_1": models.ErrAlreadyCreated,
+			"email_1":    models.ErrEmailTaken,
+		}
+
+		for index, expected := range cases {
+			mt.AddMockResponses(counterResponse, mtest.CreateWriteErrorsResponse(mtest.WriteError{
+				Code:    11000,
+				Message: "E11000 duplicate key error collection: redditclone.users index: " + index + " dup key",
+			}))
+
+			_, err := repo.CreateUser(ctx, "alex12345", "correct horse", "alex@example.com")
+			assert.Equal(t, expected, err, index)
+		}
+	})
+
+	mt.Run("counter error", func(mt *mtest.T) {
+		repo := NewUserMongoDBRepository(mt.Coll, mt.Coll, "secret key")
+
+		mt.AddMockResponses(bson.D{bson.E{Key: "ok", Value: 0}})
+
+		_, err := repo.CreateUser(ctx, "alex12345", "correct horse", "")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("used code", func(mt *mtest.T) {
+		repo := NewUserMongoDBRepository(mt.Coll, mt.Coll, "secret key")
+
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		assert.Equal(t, models.ErrBadOTP, repo.UseRecoveryCode(ctx, 1, "aaaaa-bbbbb"))
+	})
+
+	mt.Run("update error", func(mt *mtest.T) {
+		repo := NewUserMongoDBRepository(mt.Coll, mt.Coll, "secret key")
+
+		mt.AddMockResponses(bson.D{bson.E{Key: "ok", Value: 0}})
+
+		assert.Equal(t, models.ErrUpdateUser, repo.UseRecoveryCode(ctx, 1, "aaaaa-bbbbb"))
+	})
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	ctx := context.Background()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("new step", func(mt *mtest.T) {
+		repo := NewUserMongoDBRepository(mt.Coll, mt.Coll, "secret key")
+
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		assert.NoError(t, repo.UseTOTPStep(ctx, 1, 56666667))
+	})
+
+	mt.Run("replayed step", func(mt *mtest.T) {
+		repo := NewUserMongoDBRepository(mt.Coll, mt.Coll, "secret key")
+
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		assert.Equal(t, models.ErrBadOTP, repo.UseTOTPStep(ctx, 1, 56666667))
+	})
+}
+
+// TestConformance гоняет общий набор проверок на настоящей базе, например:
+// MONGODB_TEST_URI="mongodb://127.0.0.1:27017" go test ./pkg/user/repository/mongo
+func TestConformance(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("cant connect to mongo: %s", err)
+	}
+	defer client.Disconnect(ctx)
+
+	db := client.Database("redditclone_test")
+	migrator, err := migrate.NewMigrator(migrateMongo.NewMigrationMongoDBStore(db), migrateMongo.Migrations(db))
+	if err != nil {
+		t.Fatalf("cant create migrator: %s", err)
+	}
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatalf("cant migrate: %s", err)
+	}
+
+	// Коллекцию чистим, а не удаляем: уникальные индексы создает миграция.
+	users := db.Collection("users")
+	repotest.TestUserRepo(t, func(t *testing.T) repository.UserRepo {
+		if _, err := users.DeleteMany(ctx, bson.M{}); err != nil {
+			t.Fatalf("cant clean users: %s", err)
+		}
+
+		return NewUserMongoDBRepository(users, db.Collection("counters"), "secret key")
+	})
+}
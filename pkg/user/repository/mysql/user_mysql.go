@@ -1,33 +1,54 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"redditclone/pkg/models"
+	"redditclone/tools"
+	"strings"
 
+	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserMysqlRepository struct {
 	DB *sql.DB
+	// SecretKey шифрует TOTP-секреты, чтобы дамп базы не раскрывал вторые факторы.
+	SecretKey []byte
 }
 
-func NewUserMySqlRepo(db *sql.DB) *UserMysqlRepository {
+func NewUserMySqlRepo(db *sql.DB, secretKey string) *UserMysqlRepository {
 	return &UserMysqlRepository{
-		DB: db,
+		DB:        db,
+		SecretKey: tools.DeriveKey(secretKey),
 	}
 }
 
-func (repo *UserMysqlRepository) GetUserFromRepo(login, pass string) (*models.User, error) {
+const mysqlDuplicateEntry = 1062
+
+const userColumns = "id, login, password, email, email_verified, totp_enabled, is_admin"
+
+func scanUser(row *sql.Row) (*models.User, error) {
 	user := &models.User{}
+	email := sql.NullString{}
 
-	err := repo.DB.
-		QueryRow("SELECT id, login, password FROM user WHERE login = ?", login).
-		Scan(&user.ID, &user.Login, &user.Password)
+	err := row.Scan(&user.ID, &user.Login, &user.Password, &email, &user.EmailVerified, &user.TOTPEnabled, &user.Admin)
 	if err == sql.ErrNoRows {
 		return nil, models.ErrNoUser
 	} else if err != nil {
 		return nil, err
 	}
+	user.Email = email.String
+
+	return user, nil
+}
+
+func (repo *UserMysqlRepository) GetUserFromRepo(ctx context.Context, login, pass string) (*models.User, error) {
+	user, err := scanUser(repo.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM user WHERE login = ?", login))
+	if err != nil {
+		return nil, err
+	}
 
 	if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(pass)); err != nil {
 		return nil, models.ErrWrongCredentials
@@ -36,49 +57,345 @@ func (repo *UserMysqlRepository) GetUserFromRepo(login, pass string) (*models.Us
 	return user, nil
 }
 
-func (repo *UserMysqlRepository) CreateUser(login, pass string) (*models.User, error) {
-	err := repo.DB.
-		QueryRow("SELECT 1 FROM user WHERE login = ?", login).Scan(new(int))
-	if err == sql.ErrNoRows {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
-		if err != nil {
-			return nil, err
-		}
-		repo.DB.Exec(
-			"INSERT INTO user (`login`, `password`) VALUES (?, ?)",
-			login,
-			string(hashedPassword),
-		)
+// CreateUser полагается на уникальные индексы: при одновременной регистрации
+// одного логина вставка второго запроса упадет с ошибкой дубликата.
+func (repo *UserMysqlRepository) CreateUser(ctx context.Context, login, pass, email string) (*models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
 
-		user := &models.User{}
-		err = repo.DB.
-			QueryRow("SELECT id, login, password FROM user WHERE login = ?", login).
-			Scan(&user.ID, &user.Login, &user.Password)
-		if err != nil {
-			return nil, err
-		}
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO user (`login`, `password`, `email`) VALUES (?, ?, ?)",
+		login,
+		string(hashedPassword),
+		sql.NullString{String: email, Valid: email != ""},
+	)
+	if err != nil {
+		return nil, duplicateEntryError(err)
+	}
 
-		return user, nil
+	userID, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
 	}
 
+	user, err := scanUser(tx.QueryRowContext(ctx, "SELECT "+userColumns+" FROM user WHERE id = ?", userID))
 	if err != nil {
 		return nil, err
 	}
 
-	return nil, models.ErrAlreadyCreated
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
-func (repo *UserMysqlRepository) GetUserByID(userID int) (*models.User, error) {
-	user := &models.User{}
+// duplicateEntryError превращает нарушение уникального индекса в доменную ошибку
+// по имени индекса из сообщения MySQL: "Duplicate entry '...' for key 'user.login'".
+func duplicateEntryError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlDuplicateEntry {
+		return err
+	}
+
+	if strings.Contains(mysqlErr.Message, "email'") {
+		return models.ErrEmailTaken
+	}
+
+	return models.ErrAlreadyCreated
+}
+
+func (repo *UserMysqlRepository) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	return scanUser(repo.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM user WHERE id = ?", userID))
+}
+
+func (repo *UserMysqlRepository) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	return scanUser(repo.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM user WHERE login = ?", login))
+}
+
+func (repo *UserMysqlRepository) VerifyEmail(ctx context.Context, userID int, email string) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE user SET email_verified = TRUE WHERE id = ? AND email = ?",
+		userID,
+		email,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserMysqlRepository) UpdatePassword(ctx context.Context, userID int, pass string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE user SET password = ? WHERE id = ?",
+		string(hashedPassword),
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserMysqlRepository) DeleteUser(ctx context.Context, userID int) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM user WHERE id = ?", userID)
+	if err != nil {
+		return models.ErrDeleteUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrDeleteUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserMysqlRepository) GetProfile(ctx context.Context, login string) (*models.Profile, error) {
+	profile := &models.Profile{}
 
 	err := repo.DB.
-		QueryRow("SELECT id, login, password FROM user WHERE id = ?", userID).
-		Scan(&user.ID, &user.Login, &user.Password)
+		QueryRowContext(ctx, "SELECT id, login, created_at, post_karma, comment_karma FROM user WHERE login = ?", login).
+		Scan(&profile.ID, &profile.Login, &profile.Created, &profile.PostKarma, &profile.CommentKarma)
 	if err == sql.ErrNoRows {
 		return nil, models.ErrNoUser
 	} else if err != nil {
 		return nil, err
 	}
+	profile.Karma = profile.PostKarma + profile.CommentKarma
 
-	return user, nil
+	return profile, nil
+}
+
+func (repo *UserMysqlRepository) UpdateKarma(ctx context.Context, userID int, postKarmaDelta int, commentKarmaDelta int) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE user SET post_karma = post_karma + ?, comment_karma = comment_karma + ? WHERE id = ?",
+		postKarmaDelta,
+		commentKarmaDelta,
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+// SetTOTPSecret начинает подключение 2FA: секрет сохраняется, но не действует до EnableTOTP.
+func (repo *UserMysqlRepository) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	encryptedSecret, err := tools.Encrypt(repo.SecretKey, []byte(secret))
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE user SET totp_secret = ?, totp_enabled = FALSE WHERE id = ?",
+		encryptedSecret,
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserMysqlRepository) GetTOTPSecret(ctx context.Context, userID int) (string, error) {
+	var encryptedSecret []byte
+
+	err := repo.DB.
+		QueryRowContext(ctx, "SELECT totp_secret FROM user WHERE id = ?", userID).
+		Scan(&encryptedSecret)
+	if err == sql.ErrNoRows {
+		return "", models.ErrNoUser
+	} else if err != nil {
+		return "", err
+	}
+
+	if encryptedSecret == nil {
+		return "", models.ErrNoTOTPSecret
+	}
+
+	secret, err := tools.Decrypt(repo.SecretKey, encryptedSecret)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+func (repo *UserMysqlRepository) EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_code WHERE user_id = ?", userID)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	for _, code := range recoveryCodes {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO recovery_code (`user_id`, `code_hash`) VALUES (?, ?)",
+			userID,
+			hashRecoveryCode(code),
+		)
+		if err != nil {
+			return models.ErrUpdateUser
+		}
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		"UPDATE user SET totp_enabled = TRUE WHERE id = ? AND totp_secret IS NOT NULL",
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoTOTPSecret
+	}
+
+	return tx.Commit()
+}
+
+func (repo *UserMysqlRepository) DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_code WHERE user_id = ?", userID)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		"UPDATE user SET totp_secret = NULL, totp_enabled = FALSE WHERE id = ?",
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode погашает код восстановления: удаление строки гарантирует, что код одноразовый.
+func (repo *UserMysqlRepository) UseRecoveryCode(ctx context.Context, userID int, code string) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"DELETE FROM recovery_code WHERE user_id = ? AND code_hash = ?",
+		userID,
+		hashRecoveryCode(code),
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrBadOTP
+	}
+
+	return nil
+}
+
+// UseTOTPStep сравнивает и записывает шаг одним запросом, поэтому один код не пройдет
+// дважды и при параллельных входах.
+func (repo *UserMysqlRepository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE user SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+		step,
+		userID,
+		step,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrBadOTP
+	}
+
+	return nil
+}
+
+// Коды восстановления хранятся только в виде хешей, пользователь может вводить их с дефисом и в любом регистре.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return tools.GetSHA1Hash(normalized)
 }
@@ -1,12 +1,24 @@
 package mysql
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"os"
+	"redditclone/pkg/migrate"
+	migrateMysql "redditclone/pkg/migrate/mysql"
 	"redditclone/pkg/models"
+	"redditclone/pkg/user/repository"
+	"redditclone/pkg/user/repository/repotest"
+	"redditclone/tools"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
@@ -18,14 +30,20 @@ func TestNewUserMySqlRepo(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := NewUserMySqlRepo(db)
+	repo := NewUserMySqlRepo(db, "secret key")
 
 	if repo.DB != db {
 		t.Errorf("expected db connection: %v, got: %v", db, repo.DB)
 	}
+
+	if len(repo.SecretKey) != 32 {
+		t.Errorf("expected 32-byte secret key, got %d bytes", len(repo.SecretKey))
+	}
 }
 
 func TestGetUserFromRepo(t *testing.T) {
+	ctx := context.Background()
+
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("cant create mock: %s", err)
@@ -46,7 +64,7 @@ func TestGetUserFromRepo(t *testing.T) {
 			t.Fatalf("cant hash password: %s", err)
 		}
 
-		rows := sqlmock.NewRows([]string{"id", "login", "password"})
+		rows := sqlmock.NewRows([]string{"id", "login", "password", "email", "email_verified", "totp_enabled", "is_admin"})
 		expect := []*models.User{
 			{
 				ID:       1,
@@ -55,15 +73,15 @@ func TestGetUserFromRepo(t *testing.T) {
 			},
 		}
 		for _, user := range expect {
-			rows = rows.AddRow(user.ID, user.Login, user.Password)
+			rows = rows.AddRow(user.ID, user.Login, user.Password, nil, false, false, false)
 		}
 
 		mock.
-			ExpectQuery("SELECT id, login, password FROM user WHERE").
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
 			WithArgs(login).
 			WillReturnRows(rows)
 
-		user, err := repo.GetUserFromRepo(login, correctPassword)
+		user, err := repo.GetUserFromRepo(ctx, login, correctPassword)
 		if err != nil {
 			t.Errorf("unexpected err: %s", err)
 			return
@@ -85,11 +103,11 @@ func TestGetUserFromRepo(t *testing.T) {
 		somePassword := "12345"
 
 		mock.
-			ExpectQuery("SELECT id, login, password FROM user WHERE").
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
 			WithArgs(unknownLogin).
 			WillReturnError(sql.ErrNoRows)
 
-		_, err := repo.GetUserFromRepo(unknownLogin, somePassword)
+		_, err := repo.GetUserFromRepo(ctx, unknownLogin, somePassword)
 		if err == nil {
 			t.Error("expected error, got nil")
 			return
@@ -105,11 +123,11 @@ func TestGetUserFromRepo(t *testing.T) {
 		unexpectedErr := errors.New("some error")
 
 		mock.
-			ExpectQuery("SELECT id, login, password FROM user WHERE").
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
 			WithArgs(login).
 			WillReturnError(unexpectedErr)
 
-		_, err := repo.GetUserFromRepo(login, correctPassword)
+		_, err := repo.GetUserFromRepo(ctx, login, correctPassword)
 		if err == nil {
 			t.Error("expected error, got nil")
 			return
@@ -124,15 +142,15 @@ func TestGetUserFromRepo(t *testing.T) {
 	t.Run("ErrWrongCredentials", func(t *testing.T) {
 		incorrectPassword := "xlxl123"
 
-		rows := sqlmock.NewRows([]string{"id", "login", "password"})
-		rows.AddRow(userID, login, correctPassword)
+		rows := sqlmock.NewRows([]string{"id", "login", "password", "email", "email_verified", "totp_enabled", "is_admin"})
+		rows.AddRow(userID, login, correctPassword, nil, false, false, false)
 
 		mock.
-			ExpectQuery("SELECT id, login, password FROM user WHERE").
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
 			WithArgs(login).
 			WillReturnRows(rows)
 
-		_, err := repo.GetUserFromRepo(login, incorrectPassword)
+		_, err := repo.GetUserFromRepo(ctx, login, incorrectPassword)
 		if err == nil {
 			t.Error("expected error, got nil")
 			return
@@ -146,6 +164,8 @@ func TestGetUserFromRepo(t *testing.T) {
 }
 
 func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("cant create mock: %s", err)
@@ -161,38 +181,26 @@ func TestCreateUser(t *testing.T) {
 	var hashedPassword, _ = bcrypt.GenerateFromPassword([]byte(correctPassword), bcrypt.DefaultCost)
 
 	t.Run("correct query", func(t *testing.T) {
-
-		user := &models.User{
-			ID:       1,
-			Login:    login,
-			Password: correctPassword,
-		}
-
+		mock.ExpectBegin()
 		mock.
-			ExpectQuery("SELECT 1 FROM user WHERE").
-			WithArgs(login).
-			WillReturnError(sql.ErrNoRows)
-
+			ExpectExec("INSERT INTO user").
+			WithArgs(login, sqlmock.AnyArg(), sql.NullString{}).
+			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.
-			ExpectQuery("SELECT id, login, password FROM user WHERE").
-			WithArgs(login).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "login", "password"}).
-				AddRow(1, login, string(hashedPassword)))
-
-		// Почему не отлавливается через sqlmock?????
-		// mock.
-		// 	ExpectExec(`INSERT INTO user`).
-		// 	WithArgs(login, string(hashedPassword)).
-		// 	WillReturnResult(sqlmock.NewResult(1, 1))
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "login", "password", "email", "email_verified", "totp_enabled", "is_admin"}).
+				AddRow(1, login, string(hashedPassword), nil, false, false, false))
+		mock.ExpectCommit()
 
-		user, err := repo.CreateUser(login, correctPassword)
+		user, err := repo.CreateUser(ctx, login, correctPassword, "")
 		if err != nil {
 			t.Errorf("unexpected err: %s", err)
 			return
 		}
 
 		if user.ID != 1 {
-			t.Errorf("bad id: want %v, have %v", user.ID, 1)
+			t.Errorf("bad id: want %v, have %v", 1, user.ID)
 			return
 		}
 
@@ -207,89 +215,223 @@ func TestCreateUser(t *testing.T) {
 		f;dslr;ewl;l;rlewdcx,cnmdsnfmjslkjfewiur2r32rjoi2fjei293uj2di32d32
 		32ed32d2fewkflklskl;fkp[r23krpo2k]`
 
-		mock.
-			ExpectQuery("SELECT 1 FROM user WHERE").
-			WithArgs(login).
-			WillReturnError(sql.ErrNoRows)
-
-		_, err := repo.CreateUser(login, tooLongPassword)
-		if err == nil {
-			t.Error("expected error, got nil")
+		_, err := repo.CreateUser(ctx, login, tooLongPassword, "")
+		if err != bcrypt.ErrPasswordTooLong {
+			t.Errorf("unexpected err: want %s, got %v", bcrypt.ErrPasswordTooLong, err)
 			return
 		}
 
-		if err != bcrypt.ErrPasswordTooLong {
-			t.Errorf("unexpected err: want %s, got %s", bcrypt.ErrPasswordTooLong, err)
-			return
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
 		}
 	})
 
 	t.Run("ErrAlreadyCreated", func(t *testing.T) {
+		mock.ExpectBegin()
 		mock.
-			ExpectQuery("SELECT 1 FROM user WHERE").
-			WithArgs(login).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "login", "password"}).
-				AddRow(1, login, string(hashedPassword)))
+			ExpectExec("INSERT INTO user").
+			WithArgs(login, sqlmock.AnyArg(), sql.NullString{}).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alex12345' for key 'user.login'"})
+		mock.ExpectRollback()
 
-		_, err := repo.CreateUser(login, correctPassword)
-		if err == nil {
-			t.Error("expected error, got nil")
+		_, err := repo.CreateUser(ctx, login, correctPassword, "")
+		if err != models.ErrAlreadyCreated {
+			t.Errorf("unexpected err: want %s, got %v", models.ErrAlreadyCreated, err)
 			return
 		}
 
-		if err != models.ErrAlreadyCreated {
-			t.Errorf("unexpected err: want %s, got %s", models.ErrAlreadyCreated, err)
-			return
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
 		}
 	})
 
-	t.Run("unexpected check query error", func(t *testing.T) {
-		unexpectedErr := errors.New("some error")
+	t.Run("ErrEmailTaken", func(t *testing.T) {
+		email := "alex@example.com"
 
+		mock.ExpectBegin()
 		mock.
-			ExpectQuery("SELECT 1 FROM user WHERE").
-			WithArgs(login).
-			WillReturnError(unexpectedErr)
-
-		_, err := repo.CreateUser(login, correctPassword)
-		if err == nil {
-			t.Error("expected error, got nil")
+			ExpectExec("INSERT INTO user").
+			WithArgs(login, sqlmock.AnyArg(), sql.NullString{String: email, Valid: true}).
+			WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alex@example.com' for key 'user.email'"})
+		mock.ExpectRollback()
+
+		_, err := repo.CreateUser(ctx, login, correctPassword, email)
+		if err != models.ErrEmailTaken {
+			t.Errorf("unexpected err: want %s, got %v", models.ErrEmailTaken, err)
 			return
 		}
 
-		if err != unexpectedErr {
-			t.Errorf("unexpected err: want %s, got %s", unexpectedErr, err)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("begin error", func(t *testing.T) {
+		beginErr := errors.New("some begin error")
+
+		mock.ExpectBegin().WillReturnError(beginErr)
+
+		_, err := repo.CreateUser(ctx, login, correctPassword, "")
+		if err != beginErr {
+			t.Errorf("unexpected err: want %s, got %v", beginErr, err)
 			return
 		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
 	})
 
 	t.Run("insertion error", func(t *testing.T) {
 		insertionErr := errors.New("some insertion error")
 
+		mock.ExpectBegin()
 		mock.
-			ExpectQuery("SELECT 1 FROM user WHERE").
-			WithArgs(login).
-			WillReturnError(sql.ErrNoRows)
+			ExpectExec("INSERT INTO user").
+			WithArgs(login, sqlmock.AnyArg(), sql.NullString{}).
+			WillReturnError(insertionErr)
+		mock.ExpectRollback()
+
+		_, err := repo.CreateUser(ctx, login, correctPassword, "")
+		if err != insertionErr {
+			t.Errorf("unexpected err: want %s, got %v", insertionErr, err)
+			return
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
 
+	t.Run("select error", func(t *testing.T) {
+		mock.ExpectBegin()
 		mock.
-			ExpectQuery("SELECT id, login, password FROM user WHERE").
-			WithArgs(login).
-			WillReturnError(insertionErr)
+			ExpectExec("INSERT INTO user").
+			WithArgs(login, sqlmock.AnyArg(), sql.NullString{}).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
+			WithArgs(1).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
 
-		_, err := repo.CreateUser(login, correctPassword)
-		if err == nil {
-			t.Error("expected error, got nil")
+		_, err := repo.CreateUser(ctx, login, correctPassword, "")
+		if err != models.ErrNoUser {
+			t.Errorf("unexpected err: want %s, got %v", models.ErrNoUser, err)
 			return
 		}
 
-		if err != insertionErr {
-			t.Errorf("unexpected err: want %s, got %s", insertionErr, err)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("commit error", func(t *testing.T) {
+		commitErr := errors.New("some commit error")
+
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("INSERT INTO user").
+			WithArgs(login, sqlmock.AnyArg(), sql.NullString{}).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "login", "password", "email", "email_verified", "totp_enabled", "is_admin"}).
+				AddRow(1, login, string(hashedPassword), nil, false, false, false))
+		mock.ExpectCommit().WillReturnError(commitErr)
+
+		_, err := repo.CreateUser(ctx, login, correctPassword, "")
+		if err != commitErr {
+			t.Errorf("unexpected err: want %s, got %v", commitErr, err)
 			return
 		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
 	})
 }
 
+func TestCreateUserConcurrent(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	// Запросы двух горутин перемешиваются, поэтому порядок не проверяем.
+	mock.MatchExpectationsInOrder(false)
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	login := "alex12345"
+
+	// Уникальный индекс пропускает только одну вставку, вторая получает ошибку дубликата.
+	mock.ExpectBegin()
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("INSERT INTO user").
+		WithArgs(login, sqlmock.AnyArg(), sql.NullString{}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec("INSERT INTO user").
+		WithArgs(login, sqlmock.AnyArg(), sql.NullString{}).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alex12345' for key 'user.login'"})
+	mock.
+		ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "login", "password", "email", "email_verified", "totp_enabled", "is_admin"}).
+			AddRow(1, login, "hash", nil, false, false, false))
+	mock.ExpectCommit()
+	mock.ExpectRollback()
+
+	type result struct {
+		user *models.User
+		err  error
+	}
+
+	results := make(chan result, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := repo.CreateUser(ctx, login, "qwerty", "")
+			results <- result{user, err}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	created, duplicates := 0, 0
+	for res := range results {
+		switch {
+		case res.err == nil && res.user != nil && res.user.ID == 1:
+			created++
+		case res.err == models.ErrAlreadyCreated:
+			duplicates++
+		default:
+			t.Errorf("unexpected result: user %v, err %v", res.user, res.err)
+		}
+	}
+
+	if created != 1 || duplicates != 1 {
+		t.Errorf("bad results: want 1 created and 1 duplicate, have %d and %d", created, duplicates)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestGetUserByID(t *testing.T) {
+	ctx := context.Background()
+
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("cant create mock: %s", err)
@@ -307,15 +449,15 @@ func TestGetUserByID(t *testing.T) {
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 
 	t.Run("correct query", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "login", "password"}).
-			AddRow(userID, login, hashedPassword)
+		rows := sqlmock.NewRows([]string{"id", "login", "password", "email", "email_verified", "totp_enabled", "is_admin"}).
+			AddRow(userID, login, hashedPassword, nil, false, false, false)
 
 		mock.
-			ExpectQuery("SELECT id, login, password FROM user WHERE").
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
 			WithArgs(userID).
 			WillReturnRows(rows)
 
-		user, err := repo.GetUserByID(userID)
+		user, err := repo.GetUserByID(ctx, userID)
 		if err != nil {
 			t.Errorf("unexpected err: %s", err)
 			return
@@ -335,11 +477,11 @@ func TestGetUserByID(t *testing.T) {
 		unknownUserID := 2
 
 		mock.
-			ExpectQuery("SELECT id, login, password FROM user WHERE").
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
 			WithArgs(unknownUserID).
 			WillReturnError(sql.ErrNoRows)
 
-		_, err := repo.GetUserByID(unknownUserID)
+		_, err := repo.GetUserByID(ctx, unknownUserID)
 		if err == nil {
 			t.Error("expected error, got nil")
 			return
@@ -356,11 +498,11 @@ func TestGetUserByID(t *testing.T) {
 		unexpectedErr := errors.New("some error")
 
 		mock.
-			ExpectQuery("SELECT id, login, password FROM user WHERE").
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
 			WithArgs(unknownUserID).
 			WillReturnError(unexpectedErr)
 
-		_, err := repo.GetUserByID(unknownUserID)
+		_, err := repo.GetUserByID(ctx, unknownUserID)
 		if err == nil {
 			t.Error("expected error, got nil")
 			return
@@ -372,3 +514,663 @@ func TestGetUserByID(t *testing.T) {
 		}
 	})
 }
+
+func TestGetProfile(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	var login = "alex12345"
+	var createdTime = time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("correct query", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "login", "created_at", "post_karma", "comment_karma"}).
+			AddRow(1, login, createdTime, 10, 5)
+
+		mock.
+			ExpectQuery("SELECT id, login, created_at, post_karma, comment_karma FROM user WHERE").
+			WithArgs(login).
+			WillReturnRows(rows)
+
+		profile, err := repo.GetProfile(ctx, login)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+
+		expect := &models.Profile{
+			ID:           1,
+			Login:        login,
+			Created:      createdTime,
+			PostKarma:    10,
+			CommentKarma: 5,
+			Karma:        15,
+		}
+		if !reflect.DeepEqual(profile, expect) {
+			t.Errorf("results not match, want %v, have %v", expect, profile)
+			return
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("ErrNoUser", func(t *testing.T) {
+		mock.
+			ExpectQuery("SELECT id, login, created_at, post_karma, comment_karma FROM user WHERE").
+			WithArgs(login).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetProfile(ctx, login)
+		if err != models.ErrNoUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrNoUser, err)
+			return
+		}
+	})
+
+	t.Run("unexpected error", func(t *testing.T) {
+		unexpectedErr := errors.New("some error")
+
+		mock.
+			ExpectQuery("SELECT id, login, created_at, post_karma, comment_karma FROM user WHERE").
+			WithArgs(login).
+			WillReturnError(unexpectedErr)
+
+		_, err := repo.GetProfile(ctx, login)
+		if err != unexpectedErr {
+			t.Errorf("unexpected err: want %s, got %s", unexpectedErr, err)
+			return
+		}
+	})
+}
+
+func TestUpdateKarma(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	var userID = 1
+
+	t.Run("correct query", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET post_karma").
+			WithArgs(1, 0, userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.UpdateKarma(ctx, userID, 1, 0)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("ErrNoUser", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET post_karma").
+			WithArgs(0, -1, userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.UpdateKarma(ctx, userID, 0, -1)
+		if err != models.ErrNoUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrNoUser, err)
+			return
+		}
+	})
+
+	t.Run("ErrUpdateUser", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET post_karma").
+			WithArgs(1, 0, userID).
+			WillReturnError(errors.New("some error"))
+
+		err := repo.UpdateKarma(ctx, userID, 1, 0)
+		if err != models.ErrUpdateUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrUpdateUser, err)
+			return
+		}
+	})
+}
+
+func TestGetUserByLogin(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	var login = "alex12345"
+
+	t.Run("correct query", func(t *testing.T) {
+		mock.
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
+			WithArgs(login).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "login", "password", "email", "email_verified", "totp_enabled", "is_admin"}).
+				AddRow(1, login, "hash", nil, false, false, false))
+
+		user, err := repo.GetUserByLogin(ctx, login)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+
+		if user.ID != 1 || user.Password != "hash" {
+			t.Errorf("bad user: %v", user)
+			return
+		}
+	})
+
+	t.Run("ErrNoUser", func(t *testing.T) {
+		mock.
+			ExpectQuery("SELECT id, login, password, email, email_verified, totp_enabled, is_admin FROM user WHERE").
+			WithArgs(login).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetUserByLogin(ctx, login)
+		if err != models.ErrNoUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrNoUser, err)
+			return
+		}
+	})
+}
+
+func TestUpdatePassword(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	var userID = 1
+
+	t.Run("correct query", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET password").
+			WithArgs(sqlmock.AnyArg(), userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.UpdatePassword(ctx, userID, "new password")
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("ErrNoUser", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET password").
+			WithArgs(sqlmock.AnyArg(), userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.UpdatePassword(ctx, userID, "new password")
+		if err != models.ErrNoUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrNoUser, err)
+			return
+		}
+	})
+
+	t.Run("ErrUpdateUser", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET password").
+			WithArgs(sqlmock.AnyArg(), userID).
+			WillReturnError(errors.New("some error"))
+
+		err := repo.UpdatePassword(ctx, userID, "new password")
+		if err != models.ErrUpdateUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrUpdateUser, err)
+			return
+		}
+	})
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	var userID = 1
+
+	t.Run("correct query", func(t *testing.T) {
+		mock.
+			ExpectExec("DELETE FROM user WHERE").
+			WithArgs(userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.DeleteUser(ctx, userID)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+	})
+
+	t.Run("ErrNoUser", func(t *testing.T) {
+		mock.
+			ExpectExec("DELETE FROM user WHERE").
+			WithArgs(userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.DeleteUser(ctx, userID)
+		if err != models.ErrNoUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrNoUser, err)
+			return
+		}
+	})
+
+	t.Run("ErrDeleteUser", func(t *testing.T) {
+		mock.
+			ExpectExec("DELETE FROM user WHERE").
+			WithArgs(userID).
+			WillReturnError(errors.New("some error"))
+
+		err := repo.DeleteUser(ctx, userID)
+		if err != models.ErrDeleteUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrDeleteUser, err)
+			return
+		}
+	})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	var userID = 1
+	var email = "alex@example.com"
+
+	t.Run("correct query", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET email_verified").
+			WithArgs(userID, email).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.VerifyEmail(ctx, userID, email)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("email changed", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET email_verified").
+			WithArgs(userID, email).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.VerifyEmail(ctx, userID, email)
+		if err != models.ErrNoUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrNoUser, err)
+			return
+		}
+	})
+
+	t.Run("ErrUpdateUser", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET email_verified").
+			WithArgs(userID, email).
+			WillReturnError(errors.New("some error"))
+
+		err := repo.VerifyEmail(ctx, userID, email)
+		if err != models.ErrUpdateUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrUpdateUser, err)
+			return
+		}
+	})
+}
+
+// bytesArg запоминает значение аргумента, чтобы проверить, что именно ушло в базу.
+type bytesArg struct {
+	value *[]byte
+}
+
+func (arg bytesArg) Match(v driver.Value) bool {
+	b, ok := v.([]byte)
+	if ok {
+		*arg.value = b
+	}
+	return ok
+}
+
+func TestTOTPSecret(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUserMySqlRepo(db, "secret key")
+
+	var userID = 1
+	var secret = "JBSWY3DPEHPK3PXP"
+
+	t.Run("secret is stored encrypted", func(t *testing.T) {
+		var stored []byte
+
+		mock.
+			ExpectExec("UPDATE user SET totp_secret").
+			WithArgs(bytesArg{&stored}, userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.SetTOTPSecret(ctx, userID, secret)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+
+		if len(stored) == 0 || bytes.Contains(stored, []byte(secret)) {
+			t.Errorf("secret leaked into ciphertext")
+		}
+
+		mock.
+			ExpectQuery("SELECT totp_secret FROM user WHERE").
+			WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"totp_secret"}).AddRow(stored))
+
+		actualSecret, err := repo.GetTOTPSecret(ctx, userID)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+
+		if actualSecret != secret {
+			t.Errorf("bad secret: want %s, have %s", secret, actualSecret)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("ErrNoTOTPSecret", func(t *testing.T) {
+		mock.
+			ExpectQuery("SELECT totp_secret FROM user WHERE").
+			WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"totp_secret"}).AddRow(nil))
+
+		_, err := repo.GetTOTPSecret(ctx, userID)
+		if err != models.ErrNoTOTPSecret {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrNoTOTPSecret, err)
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		stored, _ := tools.Encrypt(tools.DeriveKey("another key"), []byte(secret))
+
+		mock.
+			ExpectQuery("SELECT totp_secret FROM user WHERE").
+			WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"totp_secret"}).AddRow(stored))
+
+		_, err := repo.GetTOTPSecret(ctx, userID)
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestEnableTOTP(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	var userID = 1
+	var recoveryCodes = []string{"abcde-fghij", "klmno-pqrst"}
+
+	t.Run("correct query", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("DELETE FROM recovery_code WHERE").
+			WithArgs(userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		for _, code := range recoveryCodes {
+			mock.
+				ExpectExec("INSERT INTO recovery_code").
+				WithArgs(userID, hashRecoveryCode(code)).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+		mock.
+			ExpectExec("UPDATE user SET totp_enabled = TRUE").
+			WithArgs(userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := repo.EnableTOTP(ctx, userID, recoveryCodes)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("enrollment not started", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.
+			ExpectExec("DELETE FROM recovery_code WHERE").
+			WithArgs(userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.
+			ExpectExec("UPDATE user SET totp_enabled = TRUE").
+			WithArgs(userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := repo.EnableTOTP(ctx, userID, nil)
+		if err != models.ErrNoTOTPSecret {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrNoTOTPSecret, err)
+			return
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	var userID = 1
+
+	t.Run("code is normalized", func(t *testing.T) {
+		mock.
+			ExpectExec("DELETE FROM recovery_code WHERE").
+			WithArgs(userID, hashRecoveryCode("abcde-fghij")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.UseRecoveryCode(ctx, userID, " ABCDEFGHIJ ")
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+	})
+
+	t.Run("ErrBadOTP", func(t *testing.T) {
+		mock.
+			ExpectExec("DELETE FROM recovery_code WHERE").
+			WithArgs(userID, hashRecoveryCode("abcde-fghij")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.UseRecoveryCode(ctx, userID, "abcde-fghij")
+		if err != models.ErrBadOTP {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrBadOTP, err)
+			return
+		}
+	})
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := &UserMysqlRepository{
+		DB: db,
+	}
+
+	var userID = 1
+	var step int64 = 56666667
+
+	t.Run("new step", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET totp_last_step = \\? WHERE id = \\? AND totp_last_step < \\?").
+			WithArgs(step, userID, step).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			t.Errorf("unexpected err: %s", err)
+			return
+		}
+	})
+
+	t.Run("replayed step", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET totp_last_step").
+			WithArgs(step, userID, step).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.UseTOTPStep(ctx, userID, step)
+		if err != models.ErrBadOTP {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrBadOTP, err)
+			return
+		}
+	})
+
+	t.Run("update error", func(t *testing.T) {
+		mock.
+			ExpectExec("UPDATE user SET totp_last_step").
+			WithArgs(step, userID, step).
+			WillReturnError(errors.New("mock error"))
+
+		err := repo.UseTOTPStep(ctx, userID, step)
+		if err != models.ErrUpdateUser {
+			t.Errorf("unexpected err: want %s, got %s", models.ErrUpdateUser, err)
+			return
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestConformance гоняет общий набор проверок на настоящей базе, например:
+// MYSQL_TEST_DSN="root:pass@tcp(127.0.0.1:3306)/redditclone_test" go test ./pkg/user/repository/mysql
+// Таблицы создаются миграциями и очищаются перед каждой проверкой.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN is not set")
+	}
+
+	db, err := sql.Open("mysql", dsn+"?parseTime=true&clientFoundRows=true")
+	if err != nil {
+		t.Fatalf("cant connect to mysql: %s", err)
+	}
+	defer db.Close()
+
+	migrations, err := migrateMysql.Migrations(db)
+	if err != nil {
+		t.Fatalf("cant load migrations: %s", err)
+	}
+	migrator, err := migrate.NewMigrator(migrateMysql.NewMigrationMysqlStore(db), migrations)
+	if err != nil {
+		t.Fatalf("cant create migrator: %s", err)
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		t.Fatalf("cant migrate: %s", err)
+	}
+
+	repotest.TestUserRepo(t, func(t *testing.T) repository.UserRepo {
+		for _, table := range []string{"recovery_code", "user"} {
+			if _, err := db.Exec("DELETE FROM " + table); err != nil {
+				t.Fatalf("cant clean %s: %s", table, err)
+			}
+		}
+
+		return NewUserMySqlRepo(db, "secret key")
+	})
+}
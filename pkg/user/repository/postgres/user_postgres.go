@@ -0,0 +1,381 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"redditclone/pkg/models"
+	"redditclone/tools"
+	"strings"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserPostgresRepository struct {
+	DB *sql.DB
+	// SecretKey шифрует TOTP-секреты, чтобы дамп базы не раскрывал вторые факторы.
+	SecretKey []byte
+}
+
+func NewUserPostgresRepo(db *sql.DB, secretKey string) *UserPostgresRepository {
+	return &UserPostgresRepository{
+		DB:        db,
+		SecretKey: tools.DeriveKey(secretKey),
+	}
+}
+
+const postgresUniqueViolation = "23505"
+
+const userColumns = "id, login, password, email, email_verified, totp_enabled, is_admin"
+
+func scanUser(row *sql.Row) (*models.User, error) {
+	user := &models.User{}
+	email := sql.NullString{}
+
+	err := row.Scan(&user.ID, &user.Login, &user.Password, &email, &user.EmailVerified, &user.TOTPEnabled, &user.Admin)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoUser
+	} else if err != nil {
+		return nil, err
+	}
+	user.Email = email.String
+
+	return user, nil
+}
+
+func (repo *UserPostgresRepository) GetUserFromRepo(ctx context.Context, login, pass string) (*models.User, error) {
+	user, err := scanUser(repo.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE lower(login) = lower($1)", login))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(pass)); err != nil {
+		return nil, models.ErrWrongCredentials
+	}
+
+	return user, nil
+}
+
+// CreateUser полагается на уникальные индексы: при одновременной регистрации
+// одного логина вставка второго запроса упадет с ошибкой дубликата.
+func (repo *UserPostgresRepository) CreateUser(ctx context.Context, login, pass, email string) (*models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := scanUser(repo.DB.QueryRowContext(
+		ctx,
+		"INSERT INTO users (login, password, email) VALUES ($1, $2, $3) RETURNING "+userColumns,
+		login,
+		string(hashedPassword),
+		sql.NullString{String: email, Valid: email != ""},
+	))
+	if err != nil {
+		return nil, uniqueViolationError(err)
+	}
+
+	return user, nil
+}
+
+// uniqueViolationError превращает нарушение уникального индекса в доменную ошибку
+// по имени индекса из миграции.
+func uniqueViolationError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != postgresUniqueViolation {
+		return err
+	}
+
+	if pqErr.Constraint == "users_email_key" {
+		return models.ErrEmailTaken
+	}
+
+	return models.ErrAlreadyCreated
+}
+
+func (repo *UserPostgresRepository) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	return scanUser(repo.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", userID))
+}
+
+func (repo *UserPostgresRepository) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	return scanUser(repo.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE lower(login) = lower($1)", login))
+}
+
+func (repo *UserPostgresRepository) VerifyEmail(ctx context.Context, userID int, email string) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET email_verified = TRUE WHERE id = $1 AND lower(email) = lower($2)",
+		userID,
+		email,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserPostgresRepository) UpdatePassword(ctx context.Context, userID int, pass string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET password = $1 WHERE id = $2",
+		string(hashedPassword),
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserPostgresRepository) DeleteUser(ctx context.Context, userID int) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM users WHERE id = $1", userID)
+	if err != nil {
+		return models.ErrDeleteUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrDeleteUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserPostgresRepository) GetProfile(ctx context.Context, login string) (*models.Profile, error) {
+	profile := &models.Profile{}
+
+	err := repo.DB.
+		QueryRowContext(ctx, "SELECT id, login, created_at, post_karma, comment_karma FROM users WHERE lower(login) = lower($1)", login).
+		Scan(&profile.ID, &profile.Login, &profile.Created, &profile.PostKarma, &profile.CommentKarma)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoUser
+	} else if err != nil {
+		return nil, err
+	}
+	profile.Karma = profile.PostKarma + profile.CommentKarma
+
+	return profile, nil
+}
+
+func (repo *UserPostgresRepository) UpdateKarma(ctx context.Context, userID int, postKarmaDelta int, commentKarmaDelta int) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET post_karma = post_karma + $1, comment_karma = comment_karma + $2 WHERE id = $3",
+		postKarmaDelta,
+		commentKarmaDelta,
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+// SetTOTPSecret начинает подключение 2FA: секрет сохраняется, но не действует до EnableTOTP.
+func (repo *UserPostgresRepository) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	encryptedSecret, err := tools.Encrypt(repo.SecretKey, []byte(secret))
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET totp_secret = $1, totp_enabled = FALSE WHERE id = $2",
+		encryptedSecret,
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserPostgresRepository) GetTOTPSecret(ctx context.Context, userID int) (string, error) {
+	var encryptedSecret []byte
+
+	err := repo.DB.
+		QueryRowContext(ctx, "SELECT totp_secret FROM users WHERE id = $1", userID).
+		Scan(&encryptedSecret)
+	if err == sql.ErrNoRows {
+		return "", models.ErrNoUser
+	} else if err != nil {
+		return "", err
+	}
+
+	if encryptedSecret == nil {
+		return "", models.ErrNoTOTPSecret
+	}
+
+	secret, err := tools.Decrypt(repo.SecretKey, encryptedSecret)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+func (repo *UserPostgresRepository) EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	for _, code := range recoveryCodes {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+			userID,
+			hashRecoveryCode(code),
+		)
+		if err != nil {
+			return models.ErrUpdateUser
+		}
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		"UPDATE users SET totp_enabled = TRUE WHERE id = $1 AND totp_secret IS NOT NULL",
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoTOTPSecret
+	}
+
+	return tx.Commit()
+}
+
+func (repo *UserPostgresRepository) DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		"UPDATE users SET totp_secret = NULL, totp_enabled = FALSE WHERE id = $1",
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode погашает код восстановления: удаление строки гарантирует, что код одноразовый.
+func (repo *UserPostgresRepository) UseRecoveryCode(ctx context.Context, userID int, code string) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"DELETE FROM recovery_codes WHERE user_id = $1 AND code_hash = $2",
+		userID,
+		hashRecoveryCode(code),
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrBadOTP
+	}
+
+	return nil
+}
+
+// UseTOTPStep сравнивает и записывает шаг одним запросом, поэтому один код не пройдет
+// дважды и при параллельных входах.
+func (repo *UserPostgresRepository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $3",
+		step,
+		userID,
+		step,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrBadOTP
+	}
+
+	return nil
+}
+
+// Коды восстановления хранятся только в виде хешей, пользователь может вводить их с дефисом и в любом регистре.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return tools.GetSHA1Hash(normalized)
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"redditclone/pkg/migrate"
+	migratePostgres "redditclone/pkg/migrate/postgres"
+	"redditclone/pkg/models"
+	"redditclone/pkg/user/repository"
+	"redditclone/pkg/user/repository/repotest"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("cant create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUserPostgresRepo(db, "secret key")
+	columns := []string{"id", "login", "password", "email", "email_verified", "totp_enabled", "is_admin"}
+
+	t.Run("correct query", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO users \(login, password, email\) VALUES \(\$1, \$2, \$3\) RETURNING`).
+			WithArgs("alex12345", sqlmock.AnyArg(), nil).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "alex12345", "hash", nil, false, false, false))
+
+		user, err := repo.CreateUser(ctx, "alex12345", "correct horse", "")
+		assert.NoError(t, err)
+		assert.Equal(t, &models.User{ID: 1, Login: "alex12345", Password: "hash"}, user)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		cases := map[string]error{
+			"users_login_key": models.ErrAlreadyCreated,
+			"users_email_key": models.ErrEmailTaken,
+		}
+		for constraint, expected := range cases {
+			mock.ExpectQuery("INSERT INTO users").
+				WillReturnError(&pq.Error{Code: postgresUniqueViolation, Constraint: constraint})
+
+			_, err := repo.CreateUser(ctx, "alex12345", "correct horse", "alex@example.com")
+			assert.Equal(t, expected, err, constraint)
+		}
+	})
+
+	t.Run("insertion error", func(t *testing.T) {
+		insertErr := errors.New("connection reset")
+		mock.ExpectQuery("INSERT INTO users").WillReturnError(insertErr)
+
+		_, err := repo.CreateUser(ctx, "alex12345", "correct horse", "")
+		assert.Equal(t, insertErr, err)
+	})
+}
+
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("cant connect to postgres: %s", err)
+	}
+	defer db.Close()
+
+	migrations, err := migratePostgres.Migrations(db)
+	if err != nil {
+		t.Fatalf("cant load migrations: %s", err)
+	}
+	migrator, err := migrate.NewMigrator(migratePostgres.NewMigrationPostgresStore(db), migrations)
+	if err != nil {
+		t.Fatalf("cant create migrator: %s", err)
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		t.Fatalf("cant migrate: %s", err)
+	}
+
+	repotest.TestUserRepo(t, func(t *testing.T) repository.UserRepo {
+		if _, err := db.Exec("TRUNCATE users, recovery_codes"); err != nil {
+			t.Fatalf("cant clean tables: %s", err)
+		}
+
+		return NewUserPostgresRepo(db, "secret key")
+	})
+}
@@ -1,10 +1,26 @@
 package repository
 
-import "redditclone/pkg/models"
+import (
+	"context"
+	"redditclone/pkg/models"
+)
 
 //go:generate mockgen -source=repository.go -destination=mock_repository/user_mock.go -package=mock_repository MockUserRepository
 type UserRepo interface {
-	GetUserFromRepo(login, pass string) (*models.User, error)
-	CreateUser(login, pass string) (*models.User, error)
-	GetUserByID(userID int) (*models.User, error)
+	GetUserFromRepo(ctx context.Context, login, pass string) (*models.User, error)
+	CreateUser(ctx context.Context, login, pass, email string) (*models.User, error)
+	GetUserByID(ctx context.Context, userID int) (*models.User, error)
+	GetUserByLogin(ctx context.Context, login string) (*models.User, error)
+	UpdatePassword(ctx context.Context, userID int, pass string) error
+	VerifyEmail(ctx context.Context, userID int, email string) error
+	DeleteUser(ctx context.Context, userID int) error
+	GetProfile(ctx context.Context, login string) (*models.Profile, error)
+	UpdateKarma(ctx context.Context, userID int, postKarmaDelta int, commentKarmaDelta int) error
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	GetTOTPSecret(ctx context.Context, userID int) (string, error)
+	EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error
+	DisableTOTP(ctx context.Context, userID int) error
+	UseRecoveryCode(ctx context.Context, userID int, code string) error
+	// UseTOTPStep запоминает шаг принятого TOTP-кода; шаг не позже уже принятого дает ErrBadOTP.
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
 }
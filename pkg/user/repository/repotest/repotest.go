@@ -0,0 +1,246 @@
+// Package repotest проверяет, что реализация UserRepo соблюдает контракт интерфейса.
+// Его запускает каждое хранилище: in-memory и SQLite всегда, MySQL, PostgreSQL и Mongo - при наличии тестовой базы.
+package repotest
+
+import (
+	"context"
+	"redditclone/pkg/models"
+	"redditclone/pkg/user/repository"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const password = "correct horse"
+
+// TestUserRepo прогоняет набор проверок; newRepo должен каждый раз возвращать пустое хранилище.
+func TestUserRepo(t *testing.T, newRepo func(t *testing.T) repository.UserRepo) {
+	ctx := context.Background()
+
+	t.Run("not found", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetUserByID(ctx, 42)
+		assert.Equal(t, models.ErrNoUser, err)
+		_, err = repo.GetUserByLogin(ctx, "nobody")
+		assert.Equal(t, models.ErrNoUser, err)
+		_, err = repo.GetProfile(ctx, "nobody")
+		assert.Equal(t, models.ErrNoUser, err)
+		_, err = repo.GetUserFromRepo(ctx, "nobody", password)
+		assert.Equal(t, models.ErrNoUser, err)
+		_, err = repo.GetTOTPSecret(ctx, 42)
+		assert.Equal(t, models.ErrNoUser, err)
+
+		assert.Equal(t, models.ErrNoUser, repo.UpdatePassword(ctx, 42, password))
+		assert.Equal(t, models.ErrNoUser, repo.VerifyEmail(ctx, 42, "alex@example.com"))
+		assert.Equal(t, models.ErrNoUser, repo.UpdateKarma(ctx, 42, 1, 1))
+		assert.Equal(t, models.ErrNoUser, repo.SetTOTPSecret(ctx, 42, "SECRET"))
+		assert.Equal(t, models.ErrNoUser, repo.DeleteUser(ctx, 42))
+	})
+
+	t.Run("create and authenticate", func(t *testing.T) {
+		repo := newRepo(t)
+
+		user, err := repo.CreateUser(ctx, "alex12345", password, "alex@example.com")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Positive(t, user.ID)
+		assert.Equal(t, "alex12345", user.Login)
+		assert.Equal(t, "alex@example.com", user.Email)
+		assert.NotEqual(t, password, user.Password)
+		assert.False(t, user.EmailVerified)
+		assert.False(t, user.TOTPEnabled)
+
+		found, err := repo.GetUserFromRepo(ctx, "alex12345", password)
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, found.ID)
+
+		_, err = repo.GetUserFromRepo(ctx, "alex12345", "wrong password")
+		assert.Equal(t, models.ErrWrongCredentials, err)
+
+		byID, err := repo.GetUserByID(ctx, user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, user.Login, byID.Login)
+
+		other, err := repo.CreateUser(ctx, "bob", password, "")
+		assert.NoError(t, err)
+		assert.NotEqual(t, user.ID, other.ID)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.CreateUser(ctx, "alex12345", password, "alex@example.com")
+		assert.NoError(t, err)
+
+		_, err = repo.CreateUser(ctx, "alex12345", password, "")
+		assert.Equal(t, models.ErrAlreadyCreated, err)
+		_, err = repo.CreateUser(ctx, "ALEX12345", password, "")
+		assert.Equal(t, models.ErrAlreadyCreated, err)
+		_, err = repo.CreateUser(ctx, "bob", password, "alex@example.com")
+		assert.Equal(t, models.ErrEmailTaken, err)
+
+		// Пустая почта не участвует в уникальности.
+		_, err = repo.CreateUser(ctx, "carol", password, "")
+		assert.NoError(t, err)
+		_, err = repo.CreateUser(ctx, "dave", password, "")
+		assert.NoError(t, err)
+	})
+
+	t.Run("concurrent signup", func(t *testing.T) {
+		repo := newRepo(t)
+
+		// Все регистрации стартуют вместе: проверка "логин свободен" в отдельном запросе
+		// пропустила бы нескольких, поэтому уникальность должно держать само хранилище.
+		const signups = 8
+		start := make(chan struct{})
+		errs := make([]error, signups)
+		wg := &sync.WaitGroup{}
+		for i := 0; i < signups; i++ {
+			login := "racer"
+			if i%2 == 1 {
+				login = "Racer"
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				_, errs[i] = repo.CreateUser(ctx, login, password, "")
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		created := 0
+		for _, err := range errs {
+			if err == nil {
+				created++
+				continue
+			}
+			assert.Equal(t, models.ErrAlreadyCreated, err)
+		}
+		assert.Equal(t, 1, created)
+
+		_, err := repo.GetUserByLogin(ctx, "racer")
+		assert.NoError(t, err)
+	})
+
+	t.Run("update password", func(t *testing.T) {
+		repo := newRepo(t)
+		user, _ := repo.CreateUser(ctx, "alex12345", password, "")
+
+		assert.NoError(t, repo.UpdatePassword(ctx, user.ID, "new password"))
+		_, err := repo.GetUserFromRepo(ctx, "alex12345", password)
+		assert.Equal(t, models.ErrWrongCredentials, err)
+		_, err = repo.GetUserFromRepo(ctx, "alex12345", "new password")
+		assert.NoError(t, err)
+	})
+
+	t.Run("verify email", func(t *testing.T) {
+		repo := newRepo(t)
+		user, _ := repo.CreateUser(ctx, "alex12345", password, "alex@example.com")
+		noEmail, _ := repo.CreateUser(ctx, "bob", password, "")
+
+		assert.Equal(t, models.ErrNoUser, repo.VerifyEmail(ctx, user.ID, "other@example.com"))
+		assert.Equal(t, models.ErrNoUser, repo.VerifyEmail(ctx, noEmail.ID, ""))
+
+		assert.NoError(t, repo.VerifyEmail(ctx, user.ID, "alex@example.com"))
+		assert.NoError(t, repo.VerifyEmail(ctx, user.ID, "alex@example.com"))
+
+		found, _ := repo.GetUserByID(ctx, user.ID)
+		assert.True(t, found.EmailVerified)
+	})
+
+	t.Run("karma", func(t *testing.T) {
+		repo := newRepo(t)
+		user, _ := repo.CreateUser(ctx, "alex12345", password, "")
+
+		assert.NoError(t, repo.UpdateKarma(ctx, user.ID, 3, 0))
+		assert.NoError(t, repo.UpdateKarma(ctx, user.ID, -1, 2))
+
+		profile, err := repo.GetProfile(ctx, "alex12345")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, user.ID, profile.ID)
+		assert.Equal(t, 2, profile.PostKarma)
+		assert.Equal(t, 2, profile.CommentKarma)
+		assert.Equal(t, 4, profile.Karma)
+		assert.False(t, profile.Created.IsZero())
+	})
+
+	t.Run("two-factor", func(t *testing.T) {
+		repo := newRepo(t)
+		user, _ := repo.CreateUser(ctx, "alex12345", password, "")
+
+		_, err := repo.GetTOTPSecret(ctx, user.ID)
+		assert.Equal(t, models.ErrNoTOTPSecret, err)
+		assert.Equal(t, models.ErrNoTOTPSecret, repo.EnableTOTP(ctx, user.ID, []string{"aaaaa-bbbbb"}))
+
+		assert.NoError(t, repo.SetTOTPSecret(ctx, user.ID, "SECRET"))
+		secret, err := repo.GetTOTPSecret(ctx, user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "SECRET", secret)
+
+		assert.NoError(t, repo.EnableTOTP(ctx, user.ID, []string{"aaaaa-bbbbb", "ccccc-ddddd"}))
+		found, _ := repo.GetUserByID(ctx, user.ID)
+		assert.True(t, found.TOTPEnabled)
+
+		// Коды одноразовые и не зависят от регистра и дефиса.
+		assert.NoError(t, repo.UseRecoveryCode(ctx, user.ID, "AAAAABBBBB"))
+		assert.Equal(t, models.ErrBadOTP, repo.UseRecoveryCode(ctx, user.ID, "aaaaa-bbbbb"))
+		assert.Equal(t, models.ErrBadOTP, repo.UseRecoveryCode(ctx, user.ID, "zzzzz-zzzzz"))
+
+		// Шаг TOTP принимается один раз, более ранние шаги - никогда.
+		assert.NoError(t, repo.UseTOTPStep(ctx, user.ID, 56666667))
+		assert.Equal(t, models.ErrBadOTP, repo.UseTOTPStep(ctx, user.ID, 56666667))
+		assert.Equal(t, models.ErrBadOTP, repo.UseTOTPStep(ctx, user.ID, 56666666))
+		assert.NoError(t, repo.UseTOTPStep(ctx, user.ID, 56666668))
+		assert.Equal(t, models.ErrBadOTP, repo.UseTOTPStep(ctx, user.ID+1000, 56666669))
+
+		// Новое подключение сбрасывает включенную 2FA до подтверждения.
+		assert.NoError(t, repo.SetTOTPSecret(ctx, user.ID, "OTHER"))
+		found, _ = repo.GetUserByID(ctx, user.ID)
+		assert.False(t, found.TOTPEnabled)
+
+		assert.NoError(t, repo.EnableTOTP(ctx, user.ID, []string{"eeeee-fffff"}))
+		assert.Equal(t, models.ErrBadOTP, repo.UseRecoveryCode(ctx, user.ID, "ccccc-ddddd"))
+
+		assert.NoError(t, repo.DisableTOTP(ctx, user.ID))
+		found, _ = repo.GetUserByID(ctx, user.ID)
+		assert.False(t, found.TOTPEnabled)
+		_, err = repo.GetTOTPSecret(ctx, user.ID)
+		assert.Equal(t, models.ErrNoTOTPSecret, err)
+		assert.Equal(t, models.ErrBadOTP, repo.UseRecoveryCode(ctx, user.ID, "eeeee-fffff"))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		user, _ := repo.CreateUser(ctx, "alex12345", password, "alex@example.com")
+		other, _ := repo.CreateUser(ctx, "bob", password, "")
+		repo.SetTOTPSecret(ctx, user.ID, "SECRET")
+		repo.EnableTOTP(ctx, user.ID, []string{"aaaaa-bbbbb"})
+
+		assert.NoError(t, repo.DeleteUser(ctx, user.ID))
+		assert.Equal(t, models.ErrNoUser, repo.DeleteUser(ctx, user.ID))
+
+		_, err := repo.GetUserByID(ctx, user.ID)
+		assert.Equal(t, models.ErrNoUser, err)
+		_, err = repo.GetUserByLogin(ctx, "alex12345")
+		assert.Equal(t, models.ErrNoUser, err)
+		assert.Equal(t, models.ErrBadOTP, repo.UseRecoveryCode(ctx, user.ID, "aaaaa-bbbbb"))
+
+		_, err = repo.GetUserByID(ctx, other.ID)
+		assert.NoError(t, err)
+
+		// Логин и почта освобождаются вместе с аккаунтом.
+		recreated, err := repo.CreateUser(ctx, "alex12345", password, "alex@example.com")
+		assert.NoError(t, err)
+		if recreated != nil {
+			assert.NotEqual(t, user.ID, recreated.ID)
+		}
+	})
+}
@@ -0,0 +1,379 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"redditclone/pkg/models"
+	"redditclone/tools"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserSqliteRepository struct {
+	DB *sql.DB
+	// SecretKey шифрует TOTP-секреты, чтобы дамп базы не раскрывал вторые факторы.
+	SecretKey []byte
+}
+
+func NewUserSqliteRepo(db *sql.DB, secretKey string) *UserSqliteRepository {
+	return &UserSqliteRepository{
+		DB:        db,
+		SecretKey: tools.DeriveKey(secretKey),
+	}
+}
+
+const userColumns = "id, login, password, email, email_verified, totp_enabled, is_admin"
+
+func scanUser(row *sql.Row) (*models.User, error) {
+	user := &models.User{}
+	email := sql.NullString{}
+
+	err := row.Scan(&user.ID, &user.Login, &user.Password, &email, &user.EmailVerified, &user.TOTPEnabled, &user.Admin)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoUser
+	} else if err != nil {
+		return nil, err
+	}
+	user.Email = email.String
+
+	return user, nil
+}
+
+func (repo *UserSqliteRepository) GetUserFromRepo(ctx context.Context, login, pass string) (*models.User, error) {
+	user, err := scanUser(repo.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE login = ?", login))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(pass)); err != nil {
+		return nil, models.ErrWrongCredentials
+	}
+
+	return user, nil
+}
+
+// CreateUser полагается на уникальные индексы: при одновременной регистрации
+// одного логина вставка второго запроса упадет с ошибкой дубликата.
+func (repo *UserSqliteRepository) CreateUser(ctx context.Context, login, pass, email string) (*models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := scanUser(repo.DB.QueryRowContext(
+		ctx,
+		"INSERT INTO users (login, password, email) VALUES (?, ?, ?) RETURNING "+userColumns,
+		login,
+		string(hashedPassword),
+		sql.NullString{String: email, Valid: email != ""},
+	))
+	if err != nil {
+		return nil, uniqueConstraintError(err)
+	}
+
+	return user, nil
+}
+
+// uniqueConstraintError превращает нарушение уникального индекса в доменную ошибку
+// по колонке из сообщения SQLite: "UNIQUE constraint failed: users.login".
+func uniqueConstraintError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+		return err
+	}
+
+	if strings.HasSuffix(sqliteErr.Error(), "users.email") {
+		return models.ErrEmailTaken
+	}
+
+	return models.ErrAlreadyCreated
+}
+
+func (repo *UserSqliteRepository) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	return scanUser(repo.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", userID))
+}
+
+func (repo *UserSqliteRepository) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	return scanUser(repo.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE login = ?", login))
+}
+
+func (repo *UserSqliteRepository) VerifyEmail(ctx context.Context, userID int, email string) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET email_verified = TRUE WHERE id = ? AND email = ?",
+		userID,
+		email,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserSqliteRepository) UpdatePassword(ctx context.Context, userID int, pass string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET password = ? WHERE id = ?",
+		string(hashedPassword),
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserSqliteRepository) DeleteUser(ctx context.Context, userID int) error {
+	res, err := repo.DB.ExecContext(ctx, "DELETE FROM users WHERE id = ?", userID)
+	if err != nil {
+		return models.ErrDeleteUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrDeleteUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserSqliteRepository) GetProfile(ctx context.Context, login string) (*models.Profile, error) {
+	profile := &models.Profile{}
+
+	err := repo.DB.
+		QueryRowContext(ctx, "SELECT id, login, created_at, post_karma, comment_karma FROM users WHERE login = ?", login).
+		Scan(&profile.ID, &profile.Login, &profile.Created, &profile.PostKarma, &profile.CommentKarma)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoUser
+	} else if err != nil {
+		return nil, err
+	}
+	profile.Karma = profile.PostKarma + profile.CommentKarma
+
+	return profile, nil
+}
+
+func (repo *UserSqliteRepository) UpdateKarma(ctx context.Context, userID int, postKarmaDelta int, commentKarmaDelta int) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET post_karma = post_karma + ?, comment_karma = comment_karma + ? WHERE id = ?",
+		postKarmaDelta,
+		commentKarmaDelta,
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+// SetTOTPSecret начинает подключение 2FA: секрет сохраняется, но не действует до EnableTOTP.
+func (repo *UserSqliteRepository) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	encryptedSecret, err := tools.Encrypt(repo.SecretKey, []byte(secret))
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET totp_secret = ?, totp_enabled = FALSE WHERE id = ?",
+		encryptedSecret,
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return nil
+}
+
+func (repo *UserSqliteRepository) GetTOTPSecret(ctx context.Context, userID int) (string, error) {
+	var encryptedSecret []byte
+
+	err := repo.DB.
+		QueryRowContext(ctx, "SELECT totp_secret FROM users WHERE id = ?", userID).
+		Scan(&encryptedSecret)
+	if err == sql.ErrNoRows {
+		return "", models.ErrNoUser
+	} else if err != nil {
+		return "", err
+	}
+
+	if encryptedSecret == nil {
+		return "", models.ErrNoTOTPSecret
+	}
+
+	secret, err := tools.Decrypt(repo.SecretKey, encryptedSecret)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+func (repo *UserSqliteRepository) EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	for _, code := range recoveryCodes {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userID,
+			hashRecoveryCode(code),
+		)
+		if err != nil {
+			return models.ErrUpdateUser
+		}
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		"UPDATE users SET totp_enabled = TRUE WHERE id = ? AND totp_secret IS NOT NULL",
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoTOTPSecret
+	}
+
+	return tx.Commit()
+}
+
+func (repo *UserSqliteRepository) DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		"UPDATE users SET totp_secret = NULL, totp_enabled = FALSE WHERE id = ?",
+		userID,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrNoUser
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode погашает код восстановления: удаление строки гарантирует, что код одноразовый.
+func (repo *UserSqliteRepository) UseRecoveryCode(ctx context.Context, userID int, code string) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?",
+		userID,
+		hashRecoveryCode(code),
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrBadOTP
+	}
+
+	return nil
+}
+
+// UseTOTPStep сравнивает и записывает шаг одним запросом, поэтому один код не пройдет
+// дважды и при параллельных входах.
+func (repo *UserSqliteRepository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	res, err := repo.DB.ExecContext(
+		ctx,
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+		step,
+		userID,
+		step,
+	)
+	if err != nil {
+		return models.ErrUpdateUser
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.ErrUpdateUser
+	} else if affected == 0 {
+		return models.ErrBadOTP
+	}
+
+	return nil
+}
+
+// Коды восстановления хранятся только в виде хешей, пользователь может вводить их с дефисом и в любом регистре.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return tools.GetSHA1Hash(normalized)
+}
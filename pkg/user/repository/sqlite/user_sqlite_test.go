@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"redditclone/pkg/migrate"
+	migrateSqlite "redditclone/pkg/migrate/sqlite"
+	"redditclone/pkg/models"
+	"redditclone/pkg/user/repository"
+	"redditclone/pkg/user/repository/repotest"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDB создает пустую базу во временном файле: у ":memory:" каждое соединение пула
+// получило бы свою базу.
+func newTestDB(t *testing.T) *sql.DB {
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("cant open sqlite: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := migrateSqlite.Migrations(db)
+	if err != nil {
+		t.Fatalf("cant load migrations: %s", err)
+	}
+	migrator, err := migrate.NewMigrator(migrateSqlite.NewMigrationSqliteStore(db), migrations)
+	if err != nil {
+		t.Fatalf("cant create migrator: %s", err)
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		t.Fatalf("cant migrate: %s", err)
+	}
+
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	repotest.TestUserRepo(t, func(t *testing.T) repository.UserRepo {
+		return NewUserSqliteRepo(newTestDB(t), "secret key")
+	})
+}
+
+func TestMigrateDown(t *testing.T) {
+	db := newTestDB(t)
+
+	migrations, _ := migrateSqlite.Migrations(db)
+	migrator, _ := migrate.NewMigrator(migrateSqlite.NewMigrationSqliteStore(db), migrations)
+
+	reverted, err := migrator.Down(context.Background(), len(migrations))
+	assert.NoError(t, err)
+	assert.Len(t, reverted, len(migrations))
+
+	var tables int
+	err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('users', 'recovery_codes')").Scan(&tables)
+	assert.NoError(t, err)
+	assert.Zero(t, tables)
+}
+
+func TestCreateUserConcurrent(t *testing.T) {
+	ctx := context.Background()
+
+	repo := NewUserSqliteRepo(newTestDB(t), "secret key")
+
+	const attempts = 5
+	errs := make(chan error, attempts)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.CreateUser(ctx, "alex12345", "correct horse", "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.Equal(t, models.ErrAlreadyCreated, err)
+	}
+	assert.Equal(t, 1, created)
+}
@@ -2,25 +2,24 @@ package tools
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/sirupsen/logrus"
 )
 
-func JSONError(w http.ResponseWriter, status int, msg string, method string) {
+func JSONError(w http.ResponseWriter, r *http.Request, status int, msg string, method string) {
 	defer func() {
-		if r := recover(); r != nil {
-			Logger.WithFields(logrus.Fields{
+		if p := recover(); p != nil {
+			LoggerFrom(r.Context()).WithFields(logrus.Fields{
 				"method": method,
 				"status": status,
-				"panic":  r,
+				"panic":  p,
 			}).Error("panic occurred")
 		}
 	}()
 
-	Logger.WithFields(logrus.Fields{
+	LoggerFrom(r.Context()).WithFields(logrus.Fields{
 		"method": method,
 		"status": status,
 	}).Error(msg)
@@ -41,11 +40,54 @@ func JSONError(w http.ResponseWriter, status int, msg string, method string) {
 	}
 }
 
-func ValidationError(w http.ResponseWriter, validationError error) {
-	if allErrs, ok := validationError.(govalidator.Errors); ok {
-		for _, wrongField := range allErrs.Errors() {
-			message := fmt.Sprintf("field: %#v\n\n", wrongField)
-			JSONError(w, http.StatusUnprocessableEntity, message, "errorresponses.ValidationError")
+// FieldError описывает одну ошибку валидации в формате, который ожидает фронтенд.
+type FieldError struct {
+	Location string      `json:"location"`
+	Param    string      `json:"param"`
+	Value    interface{} `json:"value,omitempty"`
+	Msg      string      `json:"msg"`
+}
+
+func JSONValidationErrors(w http.ResponseWriter, r *http.Request, fieldErrors []*FieldError, method string) {
+	for _, fieldError := range fieldErrors {
+		LoggerFrom(r.Context()).WithFields(logrus.Fields{
+			"method": method,
+			"status": http.StatusUnprocessableEntity,
+			"param":  fieldError.Param,
+		}).Error(fieldError.Msg)
+	}
+
+	w.WriteHeader(http.StatusUnprocessableEntity)
+
+	resp, err := json.Marshal(map[string]interface{}{
+		"errors": fieldErrors,
+	})
+	if err != nil {
+		return
+	}
+
+	_, err = w.Write(resp)
+	if err != nil {
+		return
+	}
+}
+
+func ValidationError(w http.ResponseWriter, r *http.Request, validationError error) {
+	fieldErrors := []*FieldError{}
+
+	allErrs, ok := validationError.(govalidator.Errors)
+	if !ok {
+		allErrs = govalidator.Errors{validationError}
+	}
+
+	for _, wrongField := range allErrs.Errors() {
+		fieldError := &FieldError{Location: "body", Msg: wrongField.Error()}
+		if fieldErr, ok := wrongField.(govalidator.Error); ok {
+			fieldError.Param = fieldErr.Name
+			fieldError.Msg = fieldErr.Err.Error()
 		}
+		fieldErrors = append(fieldErrors, fieldError)
 	}
+
+	JSONValidationErrors(w, r, fieldErrors, "errorresponses.ValidationError")
 }
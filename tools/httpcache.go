@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Анонимное чтение одинаково для всех, поэтому его может хранить CDN. Браузер
+	// каждый раз переспрашивает сервер и по ETag получает дешевый 304.
+	PublicCacheControl = "public, max-age=0, s-maxage=10, stale-while-revalidate=30"
+	// С Authorization общий кэш ответ хранить не должен.
+	PrivateCacheControl = "private, no-cache"
+)
+
+// WriteCacheableJSON отдает тело ответа на GET с сильным ETag и Cache-Control.
+// Если клиент прислал If-None-Match с тем же ETag, тело не отправляется: ответ 304.
+func WriteCacheableJSON(w http.ResponseWriter, r *http.Request, body []byte) error {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("Authorization") == "" {
+		w.Header().Set("Cache-Control", PublicCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", PrivateCacheControl)
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	_, err := w.Write(body)
+	return err
+}
+
+// etagMatches сравнивает по правилам If-None-Match: слабая форма W/ совпадает с сильной.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
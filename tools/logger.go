@@ -1,6 +1,11 @@
 package tools
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
 
 var Logger *logrus.Logger
 
@@ -8,3 +13,40 @@ func Init() {
 	Logger = logrus.New()
 	Logger.SetLevel(logrus.DebugLevel)
 }
+
+// ConfigureLogger задает уровень (debug, info, warn, error) и формат (text или json) общего логгера.
+func ConfigureLogger(level, format string) error {
+	parsedLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	Logger.SetLevel(parsedLevel)
+
+	switch format {
+	case "text":
+		Logger.SetFormatter(&logrus.TextFormatter{})
+	case "json":
+		Logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	return nil
+}
+
+type loggerContextKey struct{}
+
+// WithLogger кладет в контекст логгер запроса: его поля (request_id, user_id) попадут
+// во все строки, записанные через LoggerFrom.
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFrom возвращает логгер запроса, а вне запроса - общий логгер без полей.
+func LoggerFrom(ctx context.Context) *logrus.Entry {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*logrus.Entry); ok {
+		return logger
+	}
+
+	return logrus.NewEntry(Logger)
+}
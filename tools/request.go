@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ClientIP возвращает адрес клиента без порта. Заголовкам прокси не доверяем:
+// иначе ограничения по IP обходятся подделкой X-Forwarded-For.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// ErrTrailingData - после JSON-объекта в теле есть что-то еще.
+var ErrTrailingData = errors.New("unexpected data after JSON value")
+
+// UnmarshalStrict разбирает тело запроса строже json.Unmarshal: неизвестное поле
+// или данные после объекта - ошибка, а не молча отброшенная часть запроса.
+func UnmarshalStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+// BodyTooLarge сообщает, что чтение тела оборвал лимит middleware.LimitBody.
+func BodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"context"
+	"time"
+)
+
+// Retry повторяет attempt, пока он не вернет nil или не истечет ctx. Пауза между попытками
+// растет вдвое от baseDelay до maxDelay; при отмене ctx возвращается последняя ошибка attempt.
+func Retry(ctx context.Context, name string, baseDelay, maxDelay time.Duration, attempt func(ctx context.Context) error) error {
+	delay := baseDelay
+	for {
+		err := attempt(ctx)
+		if err == nil {
+			return nil
+		}
+
+		Logger.Warnf("%s is not available, retrying in %s: %v", name, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}